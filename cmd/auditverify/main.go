@@ -0,0 +1,42 @@
+// Command auditverify walks the audit log hash chain and exits with status 1 if it is broken.
+package main
+
+import (
+	"automation-hub-idp/internal/app/audit"
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/logging"
+	"automation-hub-idp/internal/app/repositories"
+	"automation-hub-idp/internal/infra"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+)
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Connect without migrating, the verifier only reads
+	postgres := cfg.Postgres
+	database, err := infra.NewPostgresDatabase(postgres.User, postgres.Password, postgres.DbName, postgres.DbHost,
+		postgres.DbPort)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Log to stderr only, the verifier does not depend on Kafka
+	logger := logging.NewPrintfLogger(logging.New(logging.NewRedactor(""), slog.NewTextHandler(os.Stderr, nil)))
+	auditService := audit.NewService(repositories.NewGormAuditRecordRepository(database, logger), logger)
+	result, err := auditService.Verify(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !result.Valid {
+		fmt.Printf("audit log chain broken at record %d after %d valid records: %s\n", *result.FirstInvalid, result.Checked, result.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("audit log chain intact, %d records verified\n", result.Checked)
+}
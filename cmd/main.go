@@ -1,19 +1,38 @@
 package main
 
 import (
+	"automation-hub-idp/internal/app/application"
 	"automation-hub-idp/internal/app/config"
-	"automation-hub-idp/internal/app/router"
+	"context"
+	"errors"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	err := config.Setup()
+	cfg, err := config.Load()
 	if err != nil {
 		panic(err)
 	}
 
-	err = router.Initialize()
+	infrastructure, err := application.NewInfrastructure(cfg)
 	if err != nil {
 		panic(err)
 	}
+	app, err := application.New(cfg, infrastructure)
+	if err != nil {
+		panic(errors.Join(err, infrastructure.Close(context.Background())))
+	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills the process right away
+		<-ctx.Done()
+		stop()
+	}()
+	err = app.Run(ctx)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,188 @@
+// Package application is the composition root of the service. It builds every service once, on the
+// infrastructure it is given, and owns the lifecycle of the HTTP server and the background workers.
+package application
+
+import (
+	"automation-hub-idp/internal/app/audit"
+	"automation-hub-idp/internal/app/authentication"
+	"automation-hub-idp/internal/app/commands"
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/health"
+	"automation-hub-idp/internal/app/invitations"
+	"automation-hub-idp/internal/app/iprules"
+	"automation-hub-idp/internal/app/logging"
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/outbox"
+	"automation-hub-idp/internal/app/risk"
+	"automation-hub-idp/internal/app/router"
+	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/webhooks"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type App struct {
+	cfg            *config.Config
+	infrastructure *Infrastructure
+	handler        http.Handler
+	health         health.Service
+	// workers run in the background until shutdown
+	workers []func(ctx context.Context)
+}
+
+// New builds the services on the infrastructure and the HTTP API serving them.
+func New(cfg *config.Config, infrastructure *Infrastructure) (*App, error) {
+	logger := logging.NewPrintfLogger(infrastructure.Logger)
+	store := infrastructure.Store
+	auth := cfg.Authentication
+
+	auditService := audit.NewService(store.AuditRecords, logger)
+	userService := users.NewUserService(store.Users, store.UnitOfWork, auditService, logger, auth.PasswordHasher,
+		infrastructure.Clock, infrastructure.IDs, cfg.Kafka.Origin(), auth.AccountCreatedTopic, auth.AccountBlockedTopic)
+	loginHistoryService := loginhistory.NewService(store.LoginAttempts, infrastructure.GeoLocator, logger)
+	riskAssessor, err := risk.NewConfiguredAssessor(cfg, loginHistoryService, infrastructure.GeoLocator, logger)
+	if err != nil {
+		return nil, err
+	}
+	ipRuleService := iprules.NewService(store.IPRules, auditService, logger, cfg.Server.IPRuleCacheTTL)
+	webhookService := webhooks.NewService(store.Webhooks, userService, auditService, logger, cfg.Webhook.AllowPrivateNetworks)
+	// Events published by the services also go to the webhooks of their organization
+	sender := webhooks.NewMessageSender(infrastructure.Events, webhookService, cfg.Kafka.Origin())
+	authService := authentication.NewService(userService, store.PasswordResetTokens, store.LoginChallenges, store.ImpersonationSessions,
+		loginHistoryService, riskAssessor, ipRuleService, auditService, auth.PasswordHasher, auth.TokenHasher, sender,
+		infrastructure.BlockList, logger, auth, infrastructure.Clock, infrastructure.IDs, &infrastructure.Background)
+	invitationService := invitations.NewService(store.Invitations, userService, authService, auth.TokenHasher,
+		infrastructure.Events, auditService, logger, infrastructure.Clock, auth)
+	healthService := health.NewService(cfg.Health.CheckTimeout, logger, infrastructure.HealthChecks...)
+
+	// publish the domain events stored in the outbox
+	relay := outbox.NewRelay(store.Outbox, sender, infrastructure.Events, logger, cfg.Outbox.PollInterval,
+		cfg.Outbox.BatchSize, cfg.Outbox.RetryBackoff, cfg.Outbox.MaxBackoff, cfg.Outbox.MaxAttempts,
+		cfg.Outbox.DeadLetterTopic)
+	// send the queued webhook deliveries
+	dispatcher := webhooks.NewDispatcher(store.Webhooks, webhooks.NewHTTPClient(cfg.Webhook.Timeout, cfg.Webhook.AllowPrivateNetworks),
+		logger, cfg.Webhook.PollInterval, cfg.Webhook.BatchSize, cfg.Webhook.MaxAttempts, cfg.Webhook.RetryBackoff,
+		cfg.Webhook.MaxBackoff)
+	// execute the administrative commands of other services
+	consumer := commands.NewConsumer(infrastructure.Commands, store.ProcessedCommands, userService, authService,
+		infrastructure.Events, infrastructure.Events, auditService, logger, cfg.Command.ProducerKeys,
+		cfg.Command.ReplyTopic, cfg.Command.DeadLetterTopic, cfg.Command.MaxAttempts, cfg.Command.RetryBackoff)
+
+	handler, err := router.New(cfg, infrastructure.Logger, router.Services{
+		Auth:         authService,
+		Users:        userService,
+		LoginHistory: loginHistoryService,
+		Invitations:  invitationService,
+		IPRules:      ipRuleService,
+		Webhooks:     webhookService,
+		Audit:        auditService,
+		Health:       healthService,
+		RateLimiter:  infrastructure.RateLimiter,
+		Logger:       logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &App{
+		cfg:            cfg,
+		infrastructure: infrastructure,
+		handler:        handler,
+		health:         healthService,
+		workers:        []func(ctx context.Context){relay.Run, dispatcher.Run, consumer.Run},
+	}, nil
+}
+
+// Handler returns the HTTP API.
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// Run serves the API and runs the background workers until ctx is done, then shuts down gracefully and
+// releases the infrastructure.
+func (a *App) Run(ctx context.Context) error {
+	logger := a.infrastructure.Logger
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	workers := &sync.WaitGroup{}
+	for _, run := range a.workers {
+		workers.Add(1)
+		go func(run func(ctx context.Context)) {
+			defer workers.Done()
+			run(workersCtx)
+		}(run)
+	}
+
+	server := &http.Server{
+		Addr:    ":" + a.cfg.Server.Port,
+		Handler: a.handler,
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+
+	var serveErr error
+	select {
+	case serveErr = <-served:
+		logger.Error("server stopped", "error", serveErr)
+	case <-ctx.Done():
+		logger.Info("shutdown requested")
+	}
+	return errors.Join(serveErr, a.shutdown(server, stopWorkers, workers))
+}
+
+// shutdown stops the service in the order that loses no work. Readiness fails first, so no new requests are
+// routed here; then the in-flight requests, the background workers and the work the requests left running
+// finish, and only then is the infrastructure released, the Kafka producer flushing what it queued. The
+// release has its own timeout, a drain running out of time must not cut the flush short. The logger goes
+// last, so every step is logged.
+func (a *App) shutdown(server *http.Server, stopWorkers context.CancelFunc, workers *sync.WaitGroup) error {
+	logger := a.infrastructure.Logger
+	cfg := a.cfg.Server
+	a.health.Drain()
+	logger.Info("readiness failing, waiting for the load balancer", "delay_ms", cfg.ReadinessDelay.Milliseconds())
+	time.Sleep(cfg.ReadinessDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+	var err error
+	if drainErr := server.Shutdown(ctx); drainErr != nil {
+		// Cut the requests still running, their clients retry against another instance
+		err = errors.Join(err, fmt.Errorf("http server: %w", errors.Join(drainErr, server.Close())))
+	}
+	stopWorkers()
+	if waitErr := waitGroup(ctx, workers); waitErr != nil {
+		err = errors.Join(err, fmt.Errorf("background workers: %w", waitErr))
+	}
+	// The requests are done, what they left running in the background still needs the infrastructure
+	if waitErr := waitGroup(ctx, &a.infrastructure.Background); waitErr != nil {
+		err = errors.Join(err, fmt.Errorf("background work: %w", waitErr))
+	}
+	if err != nil {
+		logger.Error("shutdown did not drain", "error", err)
+	}
+	logger.Info("releasing the infrastructure")
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), cfg.CloseTimeout)
+	defer cancelClose()
+	return errors.Join(err, a.infrastructure.Close(closeCtx))
+}
+
+// waitGroup waits for the group, or until ctx is done.
+func waitGroup(ctx context.Context, group *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/health"
+	"automation-hub-idp/internal/app/logging"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	env := map[string]string{
+		"LOGGER_TOPIC":                       "logger",
+		"MAIL_TOPIC":                         "mail",
+		"BROKERS_ADDR":                       "localhost:9092",
+		"DB_HOST":                            "localhost",
+		"DB_NAME":                            "idp",
+		"DB_PORT":                            "5432",
+		"WEB_SERVER_PORT":                    "8080",
+		"BASE_URL":                           "/api",
+		"JWT_SECRET":                         "secret",
+		"PASSWORD_RESET_TOPIC":               "password-reset",
+		"ACCOUNT_BLOCKED_TOPIC":              "account-blocked",
+		"ACCOUNT_CREATED_TOPIC":              "account-created",
+		"BLOCKING_TIME_EXPONENTIATION_BASIS": "2",
+		"MAX_LOGIN_ATTEMPTS_BEFORE_BLOCK":    "5",
+		"MAX_RESET_TOKEN_ATTEMPTS":           "3",
+		"LOG_SINKS":                          "stdout",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Server.Port = "0"
+	cfg.Server.ReadinessDelay = 0
+	cfg.Server.DrainTimeout = 5 * time.Second
+	cfg.RateLimit.Enabled = false
+	return cfg
+}
+
+// testInfrastructure stands in for Postgres, Redis and Kafka. Only the repositories the background workers
+// and the IP rule middleware use expect calls.
+func testInfrastructure(t *testing.T) *Infrastructure {
+	ipRules := new(repository_mock.MockIPRuleRepository)
+	ipRules.On("FindAll").Return([]*models.IPRule{}, nil).Maybe()
+	outbox := new(repository_mock.MockOutboxRepository)
+	outbox.On("RunExclusive", mock.Anything).Return(true, nil).Maybe()
+	webhooks := new(repository_mock.MockWebhookRepository)
+	webhooks.On("ClaimDue", mock.Anything, mock.Anything, mock.Anything).Return([]*models.WebhookDelivery{}, nil).Maybe()
+	geoLocator, err := services.NewGeoLocator("")
+	assert.NoError(t, err)
+
+	return &Infrastructure{
+		Logger: logging.New(logging.NewRedactor(""), logging.JSONSink(io.Discard, slog.LevelInfo)),
+		Store: irepository.Store{
+			IPRules:           ipRules,
+			Outbox:            outbox,
+			Webhooks:          webhooks,
+			ProcessedCommands: new(repository_mock.MockProcessedCommandRepository),
+		},
+		Events:     service_mock.NewFakeMessageSender(),
+		Commands:   service_mock.NewFakeMessageConsumer(),
+		BlockList:  new(service_mock.MockBlockListService),
+		GeoLocator: geoLocator,
+		Clock:      utils.SystemClock(),
+		IDs:        utils.RandomIDGenerator(),
+		HealthChecks: []health.Check{
+			{Name: "postgres", Critical: true, Probe: func(context.Context) error { return nil }},
+		},
+	}
+}
+
+func serve(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func TestNew_ServesHTTPAPI(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig(t)
+
+	// Act
+	app, err := New(cfg, testInfrastructure(t))
+
+	// Assert
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, serve(app.Handler(), http.MethodGet, "/healthz", "").Code)
+	ready := serve(app.Handler(), http.MethodGet, "/readyz", "")
+	assert.Equal(t, http.StatusOK, ready.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(ready.Body.Bytes(), &response))
+	assert.Contains(t, response["components"], "postgres")
+	login := serve(app.Handler(), http.MethodPost, cfg.Server.BaseURL+"/v1/auth/login", "{not json")
+	assert.Equal(t, http.StatusBadRequest, login.Code)
+}
+
+func TestRun_ShutsDownAndReleasesInfrastructureInOrder(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	infrastructure := testInfrastructure(t)
+	var mu sync.Mutex
+	var released []string
+	for _, name := range []string{"logging", "postgres", "kafka producer"} {
+		name := name
+		infrastructure.OnClose(name, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline, "%s was released without a deadline", name)
+			released = append(released, name)
+			return nil
+		})
+	}
+	app, err := New(testConfig(t), infrastructure)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	// Act
+	go func() {
+		done <- app.Run(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// Assert
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was done")
+	}
+	assert.Equal(t, []string{"kafka producer", "postgres", "logging"}, released)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(app.Handler(), http.MethodGet, "/readyz", "").Code)
+}
+
+func TestRun_WaitsForBackgroundWorkBeforeReleasingInfrastructure(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	infrastructure := testInfrastructure(t)
+	var finished atomic.Bool
+	var finishedBeforeRelease bool
+	infrastructure.OnClose("kafka producer", func(ctx context.Context) error {
+		finishedBeforeRelease = finished.Load()
+		return nil
+	})
+	// A request left a notification to send
+	infrastructure.Background.Add(1)
+	go func() {
+		defer infrastructure.Background.Done()
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	}()
+	app, err := New(testConfig(t), infrastructure)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	err = app.Run(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, finishedBeforeRelease)
+}
+
+func TestRun_ReleasesInfrastructureWithTimeLeftAfterDrainRunsOut(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig(t)
+	cfg.Server.DrainTimeout = 50 * time.Millisecond
+	infrastructure := testInfrastructure(t)
+	var closeCtxErr error
+	var closeTimeLeft time.Duration
+	infrastructure.OnClose("kafka producer", func(ctx context.Context) error {
+		closeCtxErr = ctx.Err()
+		if deadline, ok := ctx.Deadline(); ok {
+			closeTimeLeft = time.Until(deadline)
+		}
+		return nil
+	})
+	// A request left work running past the drain timeout
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	infrastructure.Background.Add(1)
+	go func() {
+		defer infrastructure.Background.Done()
+		<-release
+	}()
+	app, err := New(cfg, infrastructure)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	err = app.Run(ctx)
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, closeCtxErr)
+	assert.Greater(t, closeTimeLeft, cfg.Server.CloseTimeout/2)
+}
@@ -0,0 +1,188 @@
+package application
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/health"
+	"automation-hub-idp/internal/app/logging"
+	"automation-hub-idp/internal/app/repositories"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
+	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/infra"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// EventSender publishes the events of the service and forwards raw messages, like dead letters.
+type EventSender interface {
+	iservice.MessageSender
+	iservice.RawMessageSender
+}
+
+// Infrastructure is how the application reaches what runs outside the process. NewInfrastructure connects
+// to Postgres, Redis and Kafka; tests fill it with in-memory fakes.
+type Infrastructure struct {
+	Logger      *slog.Logger
+	Store       irepository.Store
+	Events      EventSender
+	Commands    iservice.MessageConsumer
+	BlockList   iservice.TokenBlockListService
+	RateLimiter iservice.RateLimiter
+	GeoLocator  iservice.GeoLocator
+	// Clock tells the services the time
+	Clock utils.Clock
+	// IDs makes the IDs of the records and tokens the services create
+	IDs utils.IDGenerator
+	// HealthChecks are the dependency checks of the readiness probe
+	HealthChecks []health.Check
+	// Background counts the work the services still run after responding, shutdown waits for it
+	Background sync.WaitGroup
+
+	closers []closer
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// OnClose registers the release of a resource. Close releases them in the reverse order of registration, so
+// what was opened first, like the logger, is closed last.
+func (i *Infrastructure) OnClose(name string, close func(ctx context.Context) error) {
+	i.closers = append(i.closers, closer{name: name, close: close})
+}
+
+// Close releases the resources registered with OnClose. A failing release does not stop the others.
+func (i *Infrastructure) Close(ctx context.Context) error {
+	var err error
+	for index := len(i.closers) - 1; index >= 0; index-- {
+		closer := i.closers[index]
+		if closeErr := closer.close(ctx); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("%s: %w", closer.name, closeErr))
+		}
+	}
+	return err
+}
+
+// NewInfrastructure opens every connection of the service once: the logger, the tracer, the database, which
+// it migrates and seeds, the Redis client and the Kafka producer and consumer. The Kafka producer is flushed
+// on Close; the command consumer is closed by the worker reading it. Adapters configured with the memory
+// backend keep their state in the process instead and connect to nothing.
+func NewInfrastructure(cfg *config.Config) (_ *Infrastructure, err error) {
+	infrastructure := &Infrastructure{Clock: utils.SystemClock(), IDs: utils.RandomIDGenerator()}
+	// Release what was opened when a later connection fails
+	defer func() {
+		if err == nil {
+			return
+		}
+		if infrastructure.Commands != nil {
+			err = errors.Join(err, infrastructure.Commands.Close())
+		}
+		err = errors.Join(err, infrastructure.Close(context.Background()))
+	}()
+
+	root, err := logging.NewRoot(cfg)
+	if err != nil {
+		return nil, err
+	}
+	infrastructure.Logger = root.Logger
+	infrastructure.OnClose("logging", func(context.Context) error { return root.Close() })
+	logger := root.Printf()
+
+	shutdownTracing, err := tracing.Setup(cfg)
+	if err != nil {
+		return nil, err
+	}
+	infrastructure.OnClose("tracing", shutdownTracing)
+
+	if err := infrastructure.openStorage(cfg, logger); err != nil {
+		return nil, err
+	}
+	infrastructure.openCache(cfg)
+	if err := infrastructure.openMessaging(cfg, logger); err != nil {
+		return nil, err
+	}
+
+	infrastructure.GeoLocator, err = services.NewGeoLocator(cfg.Authentication.GeoIPDatabasePath)
+	if err != nil {
+		return nil, err
+	}
+	if geoDatabase, ok := infrastructure.GeoLocator.(io.Closer); ok {
+		infrastructure.OnClose("geoip", func(context.Context) error { return geoDatabase.Close() })
+	}
+
+	// The logger topic only carries logs, so losing it degrades the service without taking it out of rotation
+	for _, sink := range cfg.Logging.Sinks {
+		if sink == config.LogSinkKafka {
+			infrastructure.HealthChecks = append(infrastructure.HealthChecks,
+				health.Check{Name: "kafka_logs", Critical: false, Probe: root.PingKafkaSink})
+		}
+	}
+	return infrastructure, nil
+}
+
+func (i *Infrastructure) openStorage(cfg *config.Config, logger iservice.Logger) error {
+	if cfg.Backend.Storage == config.BackendMemory {
+		i.Store = repositories.NewMemoryStore(logger)
+		return infra.SeedUsers(context.Background(), i.Store.Users)
+	}
+	database, err := infra.OpenDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	i.OnClose("postgres", func(context.Context) error {
+		sqlDB, err := database.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	})
+	i.Store = repositories.NewGormStore(database, logger)
+	i.HealthChecks = append(i.HealthChecks,
+		health.Check{Name: "postgres", Critical: true, Probe: health.PostgresProbe(database, infra.SchemaVersion)})
+	return nil
+}
+
+func (i *Infrastructure) openCache(cfg *config.Config) {
+	if cfg.Backend.Cache == config.BackendMemory {
+		i.BlockList = services.NewMemoryTokenBlockListService()
+		i.RateLimiter = services.NewMemoryRateLimiter()
+		return
+	}
+	redisClient := infra.NewRedisClient(cfg.Redis.RedisAddr)
+	i.OnClose("redis", func(context.Context) error { return redisClient.Close() })
+	i.BlockList = services.NewTokenBlockListService(redisClient)
+	i.RateLimiter = services.NewRedisRateLimiter(redisClient)
+	i.HealthChecks = append(i.HealthChecks,
+		health.Check{Name: "redis", Critical: true, Probe: health.RedisProbe(redisClient)})
+}
+
+func (i *Infrastructure) openMessaging(cfg *config.Config, logger iservice.Logger) error {
+	if cfg.Backend.Messaging == config.BackendMemory {
+		i.Events = services.NewMemoryMessageSender(logger, cfg.Kafka.Origin())
+		i.Commands = services.NewMemoryMessageConsumer(cfg.Command.Topic)
+		return nil
+	}
+	producer, err := infra.NewKafkaProducer(cfg.Kafka.BrokersAddr, cfg.Kafka.ClientID)
+	if err != nil {
+		return err
+	}
+	i.OnClose("kafka producer", func(ctx context.Context) error { return infra.CloseKafkaProducer(ctx, producer) })
+	i.Events = services.NewKafkaMessageSender(producer, cfg.Kafka.Origin())
+	i.HealthChecks = append(i.HealthChecks,
+		health.Check{Name: "kafka_events", Critical: true, Probe: health.KafkaProducerProbe(producer)})
+
+	consumer, err := services.NewKafkaMessageConsumer(cfg.Kafka.BrokersAddr, cfg.Kafka.ClientID,
+		cfg.Command.ConsumerGroup, cfg.Command.Topic)
+	if err != nil {
+		return err
+	}
+	i.Commands = consumer
+	return nil
+}
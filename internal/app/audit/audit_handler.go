@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Handler struct {
+	auditService Service
+}
+
+func NewHandler(auditService Service) *Handler {
+	return &Handler{
+		auditService: auditService,
+	}
+}
+
+// QueryEvents
+// @Summary QueryEvents
+// @Description Lists audit log records matching the filters, newest first
+// @Tags Admin
+// @Produce json
+// @Param eventType query string false "Event type"
+// @Param outcome query string false "Outcome"
+// @Param actorId query string false "Actor user ID"
+// @Param targetId query string false "Target ID"
+// @Param ip query string false "Client IP"
+// @Param from query string false "Start of the time range, RFC 3339"
+// @Param to query string false "End of the time range, RFC 3339, exclusive"
+// @Param limit query int false "Page size, at most 100"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} dto.AuditRecordResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /admin/audit-events [get]
+func (h *Handler) QueryEvents(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	filter, err := parseFilter(c)
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	records, err := h.auditService.Query(c.Request.Context(), filter, utils.NewPagination(limit, offset))
+	if err != nil {
+		errorResponse.Message = "Error fetching audit events"
+		errorResponse.ErrorCode = http.StatusInternalServerError
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	response := make([]dto.AuditRecordResponse, 0, len(records))
+	for _, record := range records {
+		response = append(response, toResponse(record))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportEvents
+// @Summary ExportEvents
+// @Description Exports the audit log records matching the filters as JSON lines in chain order, including their hashes
+// @Tags Admin
+// @Produce application/x-ndjson
+// @Param eventType query string false "Event type"
+// @Param outcome query string false "Outcome"
+// @Param actorId query string false "Actor user ID"
+// @Param targetId query string false "Target ID"
+// @Param ip query string false "Client IP"
+// @Param from query string false "Start of the time range, RFC 3339"
+// @Param to query string false "End of the time range, RFC 3339, exclusive"
+// @Success 200 {string} string
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /admin/audit-events/export [get]
+func (h *Handler) ExportEvents(c *gin.Context) {
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message:   err.Error(),
+			ErrorCode: http.StatusBadRequest,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit-events.ndjson"`)
+	c.Status(http.StatusOK)
+	if err := h.auditService.Export(c.Request.Context(), filter, c.Writer); err != nil {
+		// The status is already sent, an incomplete export is only recognizable by the aborted stream
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+// VerifyChain
+// @Summary VerifyChain
+// @Description Checks the hash chain of the whole audit log
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} dto.AuditVerification
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /admin/audit-events/verify [get]
+func (h *Handler) VerifyChain(c *gin.Context) {
+	result, err := h.auditService.Verify(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Message:   err.Error(),
+			ErrorCode: http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func parseFilter(c *gin.Context) (irepository.AuditFilter, error) {
+	filter := irepository.AuditFilter{
+		EventType: c.Query("eventType"),
+		Outcome:   c.Query("outcome"),
+		IP:        c.Query("ip"),
+	}
+	var err error
+	if filter.ActorID, err = optionalUUID(c.Query("actorId")); err != nil {
+		return filter, errors.New("invalid actorId")
+	}
+	if filter.TargetID, err = optionalUUID(c.Query("targetId")); err != nil {
+		return filter, errors.New("invalid targetId")
+	}
+	if filter.From, err = optionalTime(c.Query("from")); err != nil {
+		return filter, errors.New("invalid from, expected RFC 3339")
+	}
+	if filter.To, err = optionalTime(c.Query("to")); err != nil {
+		return filter, errors.New("invalid to, expected RFC 3339")
+	}
+	return filter, nil
+}
+
+func optionalUUID(value string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func optionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func toResponse(record *models.AuditRecord) dto.AuditRecordResponse {
+	var details map[string]string
+	if record.Details != "" {
+		_ = json.Unmarshal([]byte(record.Details), &details)
+	}
+	return dto.AuditRecordResponse{
+		ID:         record.ID,
+		Sequence:   record.Sequence,
+		EventType:  record.EventType,
+		Outcome:    record.Outcome,
+		ActorID:    record.ActorID,
+		TargetType: record.TargetType,
+		TargetID:   record.TargetID,
+		IP:         record.IP,
+		Details:    details,
+		CreatedAt:  record.CreatedAt,
+		PrevHash:   record.PrevHash,
+		Hash:       record.Hash,
+	}
+}
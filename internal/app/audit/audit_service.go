@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+// batchSize is the number of records read at once by Export and Verify.
+const batchSize = 500
+
+type service struct {
+	repo   irepository.AuditRecordRepository
+	logger iservice.Logger
+	now    func() time.Time
+}
+
+func NewService(repo irepository.AuditRecordRepository, logger iservice.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (s *service) Record(ctx context.Context, event dto.AuditEvent) {
+	record := &models.AuditRecord{
+		ID:         uuid.New(),
+		EventType:  event.Type,
+		Outcome:    event.Outcome,
+		ActorID:    event.ActorID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		// Postgres keeps microseconds, the hash must be computed over what is stored
+		CreatedAt: s.now().UTC().Truncate(time.Microsecond),
+	}
+	if len(event.Details) > 0 {
+		details, err := json.Marshal(event.Details)
+		if err != nil {
+			s.logger.Error("Failed to encode details of audit event %s: %v", event.Type, err)
+		} else {
+			record.Details = string(details)
+		}
+	}
+
+	if _, err := s.repo.Append(ctx, record); err != nil {
+		s.logger.Error("Failed to record audit event %s: %v", event.Type, err)
+	}
+}
+
+func (s *service) Query(ctx context.Context, filter irepository.AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error) {
+	return s.repo.Find(ctx, filter, p)
+}
+
+func (s *service) Export(ctx context.Context, filter irepository.AuditFilter, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	var after int64
+	for {
+		records, err := s.repo.FindAfter(ctx, filter, after, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := encoder.Encode(toResponse(record)); err != nil {
+				return err
+			}
+			after = record.Sequence
+		}
+		if len(records) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (s *service) Verify(ctx context.Context) (*dto.AuditVerification, error) {
+	result := &dto.AuditVerification{Valid: true}
+	var previous *models.AuditRecord
+	for {
+		var after int64
+		if previous != nil {
+			after = previous.Sequence
+		}
+		records, err := s.repo.FindAfter(ctx, irepository.AuditFilter{}, after, batchSize)
+		if err != nil {
+			return nil, errors.New("failed to read the audit log")
+		}
+		for _, record := range records {
+			if reason := checkLink(previous, record); reason != "" {
+				s.logger.Warn("Audit chain broken at record %d: %s", record.Sequence, reason)
+				sequence := record.Sequence
+				result.Valid = false
+				result.FirstInvalid = &sequence
+				result.Reason = reason
+				return result, nil
+			}
+			result.Checked++
+			previous = record
+		}
+		if len(records) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+// checkLink returns why the record does not follow previous in the chain, or an empty string if it does.
+func checkLink(previous, record *models.AuditRecord) string {
+	expectedSequence, expectedPrevHash := int64(1), ""
+	if previous != nil {
+		expectedSequence, expectedPrevHash = previous.Sequence+1, previous.Hash
+	}
+	switch {
+	case record.Sequence != expectedSequence:
+		return fmt.Sprintf("expected record %d, found %d", expectedSequence, record.Sequence)
+	case record.PrevHash != expectedPrevHash:
+		return "previous hash does not match the preceding record"
+	case record.Hash != record.ComputeHash():
+		return "hash does not match the record content"
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"io"
+)
+
+type Service interface {
+	iservice.AuditRecorder
+	Query(ctx context.Context, filter irepository.AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error)
+	// Export writes the matching records to w as JSON lines, in chain order.
+	Export(ctx context.Context, filter irepository.AuditFilter, w io.Writer) error
+	// Verify walks the whole chain and reports the first record that does not match its hash or predecessor.
+	Verify(ctx context.Context) (*dto.AuditVerification, error)
+}
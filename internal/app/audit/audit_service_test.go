@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestService(repo *repository_mock.MockAuditRecordRepository) *service {
+	return NewService(repo, service_mock.NewPermissiveMockLogger()).(*service)
+}
+
+// chain builds n correctly linked records.
+func chain(n int) []*models.AuditRecord {
+	var records []*models.AuditRecord
+	prevHash := ""
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		actorID := uuid.New()
+		record := &models.AuditRecord{
+			ID:        uuid.New(),
+			Sequence:  int64(i),
+			EventType: models.AuditEventLoginSucceeded,
+			Outcome:   models.AuditOutcomeSuccess,
+			ActorID:   &actorID,
+			IP:        "192.0.2.1",
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Minute),
+			PrevHash:  prevHash,
+		}
+		record.Hash = record.ComputeHash()
+		prevHash = record.Hash
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestRecord_StoresStructuredEvent(t *testing.T) {
+	// Arrange
+	repo := new(repository_mock.MockAuditRecordRepository)
+	var stored *models.AuditRecord
+	repo.On("Append", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*models.AuditRecord)
+	}).Return(&models.AuditRecord{}, nil)
+	svc := newTestService(repo)
+	svc.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC) }
+	actorID, targetID := uuid.New(), uuid.New()
+
+	// Act
+	svc.Record(context.Background(), dto.AuditEvent{
+		Type:       models.AuditEventRoleChanged,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &actorID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &targetID,
+		IP:         "192.0.2.1",
+		Details:    map[string]string{"role": "admin", "previous_role": "user"},
+	})
+
+	// Assert
+	assert.NotNil(t, stored)
+	assert.Equal(t, models.AuditEventRoleChanged, stored.EventType)
+	assert.Equal(t, &actorID, stored.ActorID)
+	assert.Equal(t, &targetID, stored.TargetID)
+	assert.Equal(t, "192.0.2.1", stored.IP)
+	assert.JSONEq(t, `{"role":"admin","previous_role":"user"}`, stored.Details)
+	// Truncated to what Postgres stores, so the hash can be recomputed from the stored row
+	assert.Equal(t, 123456000, stored.CreatedAt.Nanosecond())
+}
+
+func TestRecord_FailureDoesNotPanic(t *testing.T) {
+	// Arrange
+	repo := new(repository_mock.MockAuditRecordRepository)
+	repo.On("Append", mock.Anything).Return(nil, errors.New("database unavailable"))
+	svc := newTestService(repo)
+
+	// Act & Assert
+	assert.NotPanics(t, func() {
+		svc.Record(context.Background(), dto.AuditEvent{Type: models.AuditEventLoggedOut, Outcome: models.AuditOutcomeSuccess})
+	})
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(records []*models.AuditRecord) []*models.AuditRecord
+		valid        bool
+		firstInvalid int64
+	}{
+		{
+			name:   "intact chain",
+			tamper: func(records []*models.AuditRecord) []*models.AuditRecord { return records },
+			valid:  true,
+		},
+		{
+			name: "modified record",
+			tamper: func(records []*models.AuditRecord) []*models.AuditRecord {
+				records[1].Outcome = models.AuditOutcomeFailure
+				return records
+			},
+			firstInvalid: 2,
+		},
+		{
+			name: "modified record with recomputed hash",
+			tamper: func(records []*models.AuditRecord) []*models.AuditRecord {
+				records[1].IP = "198.51.100.1"
+				records[1].Hash = records[1].ComputeHash()
+				return records
+			},
+			firstInvalid: 3,
+		},
+		{
+			name: "deleted record",
+			tamper: func(records []*models.AuditRecord) []*models.AuditRecord {
+				return append(records[:1], records[2:]...)
+			},
+			firstInvalid: 3,
+		},
+		{
+			name: "deleted first record",
+			tamper: func(records []*models.AuditRecord) []*models.AuditRecord {
+				return records[1:]
+			},
+			firstInvalid: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := new(repository_mock.MockAuditRecordRepository)
+			repo.On("FindAfter", irepository.AuditFilter{}, int64(0), batchSize).Return(tt.tamper(chain(4)), nil)
+			svc := newTestService(repo)
+
+			// Act
+			result, err := svc.Verify(context.Background())
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, result.Valid)
+			if tt.valid {
+				assert.Equal(t, int64(4), result.Checked)
+				assert.Nil(t, result.FirstInvalid)
+				return
+			}
+			if assert.NotNil(t, result.FirstInvalid) {
+				assert.Equal(t, tt.firstInvalid, *result.FirstInvalid)
+			}
+			assert.NotEmpty(t, result.Reason)
+		})
+	}
+}
+
+func TestVerify_ReadsInBatches(t *testing.T) {
+	// Arrange
+	records := chain(batchSize + 2)
+	repo := new(repository_mock.MockAuditRecordRepository)
+	repo.On("FindAfter", irepository.AuditFilter{}, int64(0), batchSize).Return(records[:batchSize], nil)
+	repo.On("FindAfter", irepository.AuditFilter{}, int64(batchSize), batchSize).Return(records[batchSize:], nil)
+	svc := newTestService(repo)
+
+	// Act
+	result, err := svc.Verify(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(batchSize+2), result.Checked)
+}
+
+func TestExport_WritesJSONLines(t *testing.T) {
+	// Arrange
+	records := chain(3)
+	records[0].Details = `{"email":"someone@example.com"}`
+	filter := irepository.AuditFilter{EventType: models.AuditEventLoginSucceeded}
+	repo := new(repository_mock.MockAuditRecordRepository)
+	repo.On("FindAfter", filter, int64(0), batchSize).Return(records, nil)
+	svc := newTestService(repo)
+	var buffer bytes.Buffer
+
+	// Act
+	err := svc.Export(context.Background(), filter, &buffer)
+
+	// Assert
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 3)
+	var first dto.AuditRecordResponse
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, records[0].Hash, first.Hash)
+	assert.Equal(t, "someone@example.com", first.Details["email"])
+}
@@ -2,6 +2,8 @@ package authentication
 
 import (
 	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
@@ -9,23 +11,27 @@ import (
 
 type Handler struct {
 	authService IService
+	// uniformAuthResponses answers registrations the same whether or not the email is already registered
+	uniformAuthResponses bool
 }
 
-func NewHandler(authService IService) *Handler {
+func NewHandler(authService IService, uniformAuthResponses bool) *Handler {
 	return &Handler{
-		authService: authService,
+		authService:          authService,
+		uniformAuthResponses: uniformAuthResponses,
 	}
 }
 
 // Register
 // @Summary Register a new user
-// @Description Register a new user
+// @Description Register a new user. In uniform response mode the answer is the same whether or not the email is already registered.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param body body dto.UserDTO true "User registration details"
 // @Success 200 {object} dto.UserDTO
 // @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
 // @Router /auth/register [post]
 func (h *Handler) Register(c *gin.Context) {
@@ -38,7 +44,26 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(userDTO)
+	response, err := h.authService.Register(c.Request.Context(), userDTO, utils.ClientInfo(c))
+	if errors.Is(err, ErrRegistrationClosed) || errors.Is(err, ErrEmailDomainNotAllowed) {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusForbidden
+		c.JSON(http.StatusForbidden, errorResponse)
+		return
+	}
+	if h.uniformAuthResponses {
+		if err != nil && !errors.Is(err, ErrAccountExists) {
+			errorResponse.Message = "Failed to register user"
+			errorResponse.ErrorCode = http.StatusInternalServerError
+			c.JSON(http.StatusInternalServerError, errorResponse)
+			return
+		}
+		c.JSON(http.StatusOK, dto.SuccessResponse{
+			Message:    "Registration received, please check your email",
+			StatusCode: http.StatusOK,
+		})
+		return
+	}
 	if err != nil {
 		errorResponse.Message = err.Error()
 		errorResponse.ErrorCode = http.StatusInternalServerError
@@ -51,12 +76,14 @@ func (h *Handler) Register(c *gin.Context) {
 
 // Login
 // @Summary Login
-// @Description Login
+// @Description Login. A login the risk assessment holds back answers 401 with a login challenge, the code sent to the user's mailbox completes it at /auth/login/verify.
 // @Tags Authentication
 // @Accept application/json
+// @Produce json
 // @Param body body dto.UserLoginDTO true "User object"
 // @Success 200 "Successfully logged in"
 // @Failure 400 "Unauthorized"
+// @Failure 401 {object} dto.LoginChallengeResponse "Unauthorized, with a body when a login challenge has to be completed"
 // @Failure 500 "Internal Server Error"
 // @Router /auth/login [post]
 func (h *Handler) Login(c *gin.Context) {
@@ -66,12 +93,54 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	tokenDetails, err := h.authService.Login(userLoginDTO.Email, userLoginDTO.Password)
+	tokenDetails, err := h.authService.Login(c.Request.Context(), userLoginDTO.Email, userLoginDTO.Password, utils.ClientInfo(c))
+	var stepUp *StepUpRequiredError
+	if errors.As(err, &stepUp) {
+		c.JSON(http.StatusUnauthorized, dto.LoginChallengeResponse{
+			StepUpRequired: true,
+			ChallengeID:    stepUp.ChallengeID,
+			ExpiresAt:      stepUp.ExpiresAt,
+		})
+		return
+	}
 	if err != nil {
 		c.Status(http.StatusUnauthorized)
 		return
 	}
 
+	setSessionCookies(c, tokenDetails)
+	c.Status(http.StatusOK)
+}
+
+// VerifyLoginChallenge
+// @Summary VerifyLoginChallenge
+// @Description Completes a login that answered with a login challenge, using the code sent to the user's mailbox
+// @Tags Authentication
+// @Accept application/json
+// @Param body body dto.LoginChallengeDTO true "Login challenge and code"
+// @Success 200 "Successfully logged in"
+// @Failure 400 "Bad Request"
+// @Failure 401 "Unauthorized"
+// @Router /auth/login/verify [post]
+func (h *Handler) VerifyLoginChallenge(c *gin.Context) {
+	var challengeDTO dto.LoginChallengeDTO
+	if err := c.ShouldBindJSON(&challengeDTO); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokenDetails, err := h.authService.CompleteLoginChallenge(c.Request.Context(), challengeDTO.ChallengeID, challengeDTO.Code, utils.ClientInfo(c))
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	setSessionCookies(c, tokenDetails)
+	c.Status(http.StatusOK)
+}
+
+// setSessionCookies hands the tokens of a completed login to the browser.
+func setSessionCookies(c *gin.Context, tokenDetails *dto.TokenDetails) {
 	atExpiresTime := time.Unix(tokenDetails.AtExpires, 0)
 	rtExpiresTime := time.Unix(tokenDetails.RtExpires, 0)
 
@@ -96,8 +165,6 @@ func (h *Handler) Login(c *gin.Context) {
 		SameSite: http.SameSiteStrictMode,
 		Path:     "/",
 	})
-
-	c.Status(http.StatusOK)
 }
 
 // Logout
@@ -115,7 +182,7 @@ func (h *Handler) Logout(c *gin.Context) {
 		return
 	}
 
-	err = h.authService.Logout(accessToken)
+	err = h.authService.Logout(c.Request.Context(), accessToken, utils.ClientInfo(c))
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		return
@@ -133,44 +200,39 @@ func (h *Handler) Logout(c *gin.Context) {
 // @Failure 500 "Internal Server Error"
 // @Router /auth/is-user-authenticated [get]
 func (h *Handler) IsUserAuthenticated(c *gin.Context) {
-	accessToken, err := c.Cookie("access_token")
+	// The browser drops the access token cookie when it expires
+	accessToken, _ := c.Cookie("access_token")
+	if isAuthenticated, _ := h.authService.IsUserAuthenticated(c.Request.Context(), accessToken); isAuthenticated {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// If the access token is missing or not valid, try to refresh it
+	refreshToken, err := c.Cookie("refresh_token")
 	if err != nil {
 		c.Status(http.StatusUnauthorized)
 		return
 	}
 
-	isAuthenticated, err := h.authService.IsUserAuthenticated(accessToken)
-	if err != nil || !isAuthenticated {
-		// If the access token is not valid, try to refresh it
-		refreshToken, err := c.Cookie("refresh_token")
-		if err != nil {
-			c.Status(http.StatusUnauthorized)
-			return
-		}
-
-		newAccessToken, err := h.authService.RefreshToken(refreshToken)
-		if err != nil {
-			c.Status(http.StatusUnauthorized)
-			return
-		}
-
-		atExpiresTime := time.Unix(newAccessToken.AtExpires, 0)
-
-		// Set the new access token as a cookie
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "access_token",
-			Value:    newAccessToken.AccessToken,
-			Expires:  atExpiresTime,
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteStrictMode,
-			Path:     "/",
-		})
-
-		c.Status(http.StatusOK)
+	newAccessToken, err := h.authService.RefreshToken(c.Request.Context(), refreshToken)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
 		return
 	}
 
+	atExpiresTime := time.Unix(newAccessToken.AtExpires, 0)
+
+	// Set the new access token as a cookie
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "access_token",
+		Value:    newAccessToken.AccessToken,
+		Expires:  atExpiresTime,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+
 	c.Status(http.StatusOK)
 }
 
@@ -189,7 +251,7 @@ func (h *Handler) RequestPasswordReset(c *gin.Context) {
 	var errorResponse dto.ErrorResponse
 	email := c.PostForm("email")
 
-	_, _, err := h.authService.RequestPasswordReset(email)
+	err := h.authService.RequestPasswordReset(c.Request.Context(), email)
 	if err != nil {
 		errorResponse.Message = err.Error()
 		errorResponse.ErrorCode = http.StatusInternalServerError
@@ -197,7 +259,7 @@ func (h *Handler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 	response := dto.SuccessResponse{
-		Message:    "Password reset token sent successfully",
+		Message:    "If the email is registered, a password reset token has been sent",
 		StatusCode: http.StatusOK,
 	}
 	c.JSON(http.StatusOK, response)
@@ -214,13 +276,13 @@ func (h *Handler) RequestPasswordReset(c *gin.Context) {
 // @Success 200 {object} string
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 500 {object} dto.ErrorResponse
-// @Router /auth/confirm-password-reset [post]
+// @Router /auth/confirm-password-reset/{reset-token} [post]
 func (h *Handler) ConfirmPasswordReset(c *gin.Context) {
 	var errorResponse dto.ErrorResponse
-	token := c.Query("reset-token")
+	token := c.Param("reset-token")
 	newPassword := c.PostForm("newPassword")
 
-	err := h.authService.ConfirmPasswordReset(token, newPassword)
+	err := h.authService.ConfirmPasswordReset(c.Request.Context(), token, newPassword, utils.ClientInfo(c))
 	if err != nil {
 		errorResponse.Message = err.Error()
 		errorResponse.ErrorCode = http.StatusBadRequest
@@ -233,3 +295,57 @@ func (h *Handler) ConfirmPasswordReset(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// ConfirmEmailChange
+// @Summary ConfirmEmailChange
+// @Description Confirms a pending email change with the token sent to the new address
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 200 {object} dto.SuccessResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/confirm-email-change [post]
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	token := c.Query("token")
+
+	err := h.authService.ConfirmEmailChange(c.Request.Context(), token)
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	response := dto.SuccessResponse{
+		Message:    "Email changed successfully",
+		StatusCode: http.StatusOK,
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevertEmailChange
+// @Summary RevertEmailChange
+// @Description Reverts an email change with the token sent to the previous address, locking the account and revoking its sessions
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Email revert token"
+// @Success 200 {object} dto.SuccessResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/revert-email-change [post]
+func (h *Handler) RevertEmailChange(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	token := c.Query("token")
+
+	err := h.authService.RevertEmailChange(c.Request.Context(), token, utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	response := dto.SuccessResponse{
+		Message:    "Email change reverted, please reset your password to unlock the account",
+		StatusCode: http.StatusOK,
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,275 @@
+package authentication
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/risk"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/app/utils/utils_mock"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type handlerTestDeps struct {
+	userService       *service_mock.MockUserService
+	resetTokenRepo    *repository_mock.MockPasswordResetTokenRepository
+	challengeRepo     *repository_mock.MockLoginChallengeRepository
+	impersonationRepo *repository_mock.MockImpersonationSessionRepository
+	loginHistory      *service_mock.MockLoginHistoryService
+	sender            *service_mock.MockMessageSender
+	service           *service
+	router            *gin.Engine
+	cfg               *config.AuthenticationConfig
+}
+
+func newHandlerTestDeps(t *testing.T, hasher utils.PasswordHasher) *handlerTestDeps {
+	cfg := newTestConfig(t)
+	gin.SetMode(gin.TestMode)
+	deps := &handlerTestDeps{
+		userService:       new(service_mock.MockUserService),
+		resetTokenRepo:    new(repository_mock.MockPasswordResetTokenRepository),
+		challengeRepo:     new(repository_mock.MockLoginChallengeRepository),
+		impersonationRepo: new(repository_mock.MockImpersonationSessionRepository),
+		loginHistory:      service_mock.NewPermissiveMockLoginHistoryService(),
+		sender:            new(service_mock.MockMessageSender),
+		cfg:               cfg,
+	}
+	authService := NewService(deps.userService, deps.resetTokenRepo, deps.challengeRepo, deps.impersonationRepo, deps.loginHistory,
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), service_mock.NewPermissiveMockAuditRecorder(),
+		hasher, utils.NewHmacTokenHasher("test-key"), deps.sender, new(service_mock.MockBlockListService),
+		service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+	// Run background work inline so the mocks have been called when the request returns
+	authService.(*service).dispatch = func(work func()) { work() }
+	deps.service = authService.(*service)
+
+	handler := NewHandler(authService, cfg.UniformAuthResponses)
+	deps.router = gin.New()
+	deps.router.POST("/register", handler.Register)
+	deps.router.POST("/login", handler.Login)
+	deps.router.POST("/login/verify", handler.VerifyLoginChallenge)
+	deps.router.POST("/request-password-reset", handler.RequestPasswordReset)
+	deps.router.POST("/confirm-password-reset/:reset-token", handler.ConfirmPasswordReset)
+	return deps
+}
+
+func (d *handlerTestDeps) do(method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	d.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRegister_UniformResponseForExistingAccount(t *testing.T) {
+	// Arrange
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Hash", mock.Anything).Return("hashed", nil)
+	deps := newHandlerTestDeps(t, hasher)
+
+	deps.userService.On("GetUserByEmail", "taken@example.com").Return(&models.User{ID: uuid.New(), Email: "taken@example.com"}, nil)
+	deps.userService.On("GetUserByEmail", "new@example.com").Return((*models.User)(nil), errors.New("user not found"))
+	deps.userService.On("CreateUser", mock.AnythingOfType("models.User")).Return(&models.User{ID: uuid.New(), Email: "new@example.com"}, nil)
+	deps.sender.On("Send", mock.Anything, mock.Anything).Return(nil)
+
+	// Act
+	taken := deps.do(http.MethodPost, "/register", "application/json", `{"email":"taken@example.com","password":"secret"}`)
+	created := deps.do(http.MethodPost, "/register", "application/json", `{"email":"new@example.com","password":"secret"}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, taken.Code)
+	assert.Equal(t, created.Code, taken.Code)
+	assert.Equal(t, created.Body.String(), taken.Body.String())
+	deps.sender.AssertCalled(t, "Send", deps.cfg.AccountExistsTopic, mock.Anything)
+	deps.userService.AssertCalled(t, "CreateUser", mock.AnythingOfType("models.User"))
+}
+
+func TestRequestPasswordReset_UniformResponseForUnknownEmail(t *testing.T) {
+	// Arrange
+	deps := newHandlerTestDeps(t, new(utils_mock.MockHasher))
+
+	deps.userService.On("GetUserByEmail", "known@example.com").Return(&models.User{ID: uuid.New(), Email: "known@example.com"}, nil)
+	deps.userService.On("GetUserByEmail", "unknown@example.com").Return((*models.User)(nil), errors.New("user not found"))
+	deps.resetTokenRepo.On("Create", mock.Anything).Return(&models.PasswordResetToken{}, nil)
+	// A failing send must not be observable either
+	deps.sender.On("Send", mock.Anything, mock.Anything).Return(errors.New("kafka unavailable"))
+
+	// Act
+	known := deps.do(http.MethodPost, "/request-password-reset", "application/x-www-form-urlencoded",
+		url.Values{"email": {"known@example.com"}}.Encode())
+	unknown := deps.do(http.MethodPost, "/request-password-reset", "application/x-www-form-urlencoded",
+		url.Values{"email": {"unknown@example.com"}}.Encode())
+
+	// Assert
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+	deps.resetTokenRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestConfirmPasswordReset_ReadsTokenFromPath(t *testing.T) {
+	// Arrange
+	deps := newHandlerTestDeps(t, new(utils_mock.MockHasher))
+	user := &models.User{ID: uuid.New(), Email: "known@example.com"}
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: utils.NewHmacTokenHasher("test-key").Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+	deps.resetTokenRepo.On("MarkUsed", resetToken.ID, mock.AnythingOfType("time.Time")).Return(true, nil)
+	deps.resetTokenRepo.On("InvalidateAllForUser", user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	deps.userService.On("UpdatePassword", user.ID, "new-password").Return(nil)
+
+	// Act
+	w := deps.do(http.MethodPost, "/confirm-password-reset/"+resetToken.ID.String()+".verifier",
+		"application/x-www-form-urlencoded", url.Values{"newPassword": {"new-password"}}.Encode())
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	deps.userService.AssertCalled(t, "UpdatePassword", user.ID, "new-password")
+}
+
+func TestLogin_UniformResponseAndTimingForUnknownUser(t *testing.T) {
+	// Arrange
+	hasher := utils.DefaultBcryptHasher()
+	deps := newHandlerTestDeps(t, hasher)
+
+	hashedPassword, err := hasher.Hash("correct-password")
+	assert.NoError(t, err)
+	known := &models.User{ID: uuid.New(), Email: "known@example.com", Password: hashedPassword}
+	deps.userService.On("GetUserByEmail", "known@example.com").Return(known, nil)
+	deps.userService.On("GetUserByEmail", "unknown@example.com").Return((*models.User)(nil), errors.New("user not found"))
+	// Keep the account below the lockout threshold between iterations
+	deps.userService.On("IncrementFailedAttempts", known.ID, mock.AnythingOfType("time.Time")).Return(1, nil)
+
+	login := func(email string) (*httptest.ResponseRecorder, time.Duration) {
+		start := time.Now()
+		w := deps.do(http.MethodPost, "/login", "application/json", `{"email":"`+email+`","password":"wrong-password"}`)
+		return w, time.Since(start)
+	}
+	// Warm up the dummy hash so it is not part of the measurement
+	login("unknown@example.com")
+
+	// Act
+	var knownDurations, unknownDurations []time.Duration
+	var knownResponse, unknownResponse *httptest.ResponseRecorder
+	for i := 0; i < 5; i++ {
+		var d time.Duration
+		knownResponse, d = login("known@example.com")
+		knownDurations = append(knownDurations, d)
+		unknownResponse, d = login("unknown@example.com")
+		unknownDurations = append(unknownDurations, d)
+	}
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, knownResponse.Code)
+	assert.Equal(t, knownResponse.Code, unknownResponse.Code)
+	assert.Equal(t, knownResponse.Body.String(), unknownResponse.Body.String())
+
+	knownMedian, unknownMedian := median(knownDurations), median(unknownDurations)
+	ratio := float64(unknownMedian) / float64(knownMedian)
+	assert.Greater(t, ratio, 0.5, "unknown user login is much faster (%s vs %s)", unknownMedian, knownMedian)
+	assert.Less(t, ratio, 2.0, "unknown user login is much slower (%s vs %s)", unknownMedian, knownMedian)
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+func TestRegister_RegistrationModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		domains string
+		email   string
+		status  int
+	}{
+		{"open", config.RegistrationModeOpen, "", "someone@example.com", http.StatusOK},
+		{"allowed domain", config.RegistrationModeDomainAllowlist, "corp.example, example.com", "someone@Example.com", http.StatusOK},
+		{"other domain", config.RegistrationModeDomainAllowlist, "corp.example", "someone@example.com", http.StatusForbidden},
+		{"invite only", config.RegistrationModeInviteOnly, "", "someone@example.com", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			t.Setenv("REGISTRATION_MODE", tt.mode)
+			t.Setenv("REGISTRATION_ALLOWED_DOMAINS", tt.domains)
+			hasher := new(utils_mock.MockHasher)
+			hasher.On("Hash", mock.Anything).Return("hashed", nil)
+			deps := newHandlerTestDeps(t, hasher)
+			deps.userService.On("GetUserByEmail", tt.email).Return((*models.User)(nil), errors.New("user not found"))
+			deps.userService.On("CreateUser", mock.AnythingOfType("models.User")).Return(&models.User{ID: uuid.New(), Email: tt.email}, nil)
+			deps.sender.On("Send", mock.Anything, mock.Anything).Return(nil)
+
+			// Act
+			w := deps.do(http.MethodPost, "/register", "application/json", `{"email":"`+tt.email+`","password":"secret"}`)
+
+			// Assert
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				deps.userService.AssertNotCalled(t, "CreateUser", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestLogin_StepUpAnswersWithLoginChallenge(t *testing.T) {
+	// Arrange
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Compare", "hashed", "correct-password").Return(nil)
+	deps := newHandlerTestDeps(t, hasher)
+	deps.service.riskAssessor = risk.NewAssessor(50, 90, service_mock.NewPermissiveMockLogger(), riskSignal{score: 60})
+	user := &models.User{ID: uuid.New(), Email: "known@example.com", Password: "hashed"}
+	deps.userService.On("GetUserByEmail", user.Email).Return(user, nil)
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	deps.userService.On("IncrementFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(1, nil)
+	deps.userService.On("ResetFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	challenges := repositories.NewMemoryLoginChallengeRepository(service_mock.NewPermissiveMockLogger())
+	deps.service.challengeRepo = challenges
+	var code string
+	deps.sender.On("Send", deps.cfg.LoginChallengeTopic, mock.AnythingOfType("events.LoginChallengeRequested")).Run(func(args mock.Arguments) {
+		code = args.Get(1).(events.LoginChallengeRequested).Code
+	}).Return(nil)
+
+	// Act
+	login := deps.do(http.MethodPost, "/login", "application/json", `{"email":"known@example.com","password":"correct-password"}`)
+	var response dto.LoginChallengeResponse
+	_ = json.Unmarshal(login.Body.Bytes(), &response)
+	verify := deps.do(http.MethodPost, "/login/verify", "application/json",
+		`{"challengeId":"`+response.ChallengeID.String()+`","code":"`+code+`"}`)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, login.Code)
+	assert.True(t, response.StepUpRequired)
+	assert.Empty(t, login.Result().Cookies())
+	assert.Equal(t, http.StatusOK, verify.Code)
+	assert.Len(t, verify.Result().Cookies(), 2)
+	challenge, _ := challenges.FindByID(context.Background(), response.ChallengeID)
+	if assert.NotNil(t, challenge) {
+		assert.NotNil(t, challenge.CompletedAt)
+	}
+}
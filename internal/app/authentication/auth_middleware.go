@@ -1,6 +1,7 @@
 package authentication
 
 import (
+	"automation-hub-idp/internal/app/logging"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
@@ -8,48 +9,83 @@ import (
 
 func AuthMiddleware(h *Handler) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		accessToken, err := c.Cookie("access_token")
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-			return
-		}
 		refreshToken, err := c.Cookie("refresh_token")
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
 			return
 		}
 
-		userID, err := h.authService.GetIdFromToken(accessToken)
+		// The browser drops the access token cookie when it expires. A missing, expired or revoked access token
+		// is renewed with the refresh token before the user is identified.
+		accessToken, _ := c.Cookie("access_token")
+		if isValid, _ := h.authService.IsUserAuthenticated(c.Request.Context(), accessToken); !isValid {
+			tokenDetails, err := h.authService.RefreshToken(c.Request.Context(), refreshToken)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+				return
+			}
+			accessToken = tokenDetails.AccessToken
+
+			// Set the new access token as a cookie
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     "access_token",
+				Value:    accessToken,
+				Expires:  time.Unix(tokenDetails.AtExpires, 0),
+				HttpOnly: true,
+				Secure:   true,
+				SameSite: http.SameSiteStrictMode,
+				Path:     "/",
+			})
+			// The handlers read the access token from the request, they must see the new one
+			replaceRequestCookie(c.Request, "access_token", accessToken)
+		}
+
+		userID, err := h.authService.GetIdFromToken(c.Request.Context(), accessToken)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid token"})
 			return
 		}
 		c.Set("userID", userID)
-
-		if isValid, _ := h.authService.IsUserAuthenticated(accessToken); isValid {
-			c.Next()
-			return
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), userID))
+		if impersonatorID, err := h.authService.GetImpersonatorFromToken(c.Request.Context(), accessToken); err == nil && impersonatorID != nil {
+			c.Set("impersonatorID", *impersonatorID)
 		}
 
-		newAccessToken, err := h.authService.RefreshToken(refreshToken)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+		c.Next()
+	}
+}
+
+// DenyImpersonation blocks credential changes from impersonated sessions. Behind AuthMiddleware it uses the
+// impersonator it found, on the routes that authenticate with an emailed token it checks the access token
+// the request carries, if any.
+func DenyImpersonation(h *Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, impersonated := c.Get("impersonatorID")
+		if accessToken, err := c.Cookie("access_token"); !impersonated && err == nil {
+			impersonatorID, _ := h.authService.GetImpersonatorFromToken(c.Request.Context(), accessToken)
+			impersonated = impersonatorID != nil
+		}
+		if impersonated {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrImpersonatedSession.Error()})
 			return
 		}
-
-		atExpiresTime := time.Unix(newAccessToken.AtExpires, 0)
-
-		// Set the new access token as a cookie
-		http.SetCookie(c.Writer, &http.Cookie{
-			Name:     "access_token",
-			Value:    newAccessToken.AccessToken,
-			Expires:  atExpiresTime,
-			HttpOnly: true,
-			Secure:   true,
-			SameSite: http.SameSiteStrictMode,
-			Path:     "/",
-		})
-
 		c.Next()
 	}
 }
+
+// replaceRequestCookie sets the value of the cookie sent with the request, adding the cookie if it is missing.
+func replaceRequestCookie(r *http.Request, name, value string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	replaced := false
+	for _, cookie := range cookies {
+		if cookie.Name == name {
+			cookie.Value = value
+			replaced = true
+		}
+		r.AddCookie(cookie)
+	}
+	if !replaced {
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+}
@@ -0,0 +1,202 @@
+package authentication
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type middlewareTestDeps struct {
+	service *service
+	router  *gin.Engine
+	userID  uuid.UUID
+	cfg     *config.AuthenticationConfig
+	// seen is the access token the handler behind the middleware received
+	seen string
+}
+
+func newMiddlewareTestDeps(t *testing.T) *middlewareTestDeps {
+	cfg := newTestConfig(t)
+	gin.SetMode(gin.TestMode)
+	blockList := new(service_mock.MockBlockListService)
+	blockList.On("IsInBlockList", mock.Anything).Return(false, nil)
+	blockList.On("GetSessionsRevokedAt", mock.Anything).Return(nil, nil)
+	deps := &middlewareTestDeps{service: newTokenService(cfg, blockList, utils.SystemClock()), userID: uuid.New(), cfg: cfg}
+
+	handler := NewHandler(deps.service, cfg.UniformAuthResponses)
+	deps.router = gin.New()
+	deps.router.GET("/is-user-authenticated", handler.IsUserAuthenticated)
+	deps.router.GET("/protected", AuthMiddleware(handler), func(c *gin.Context) {
+		deps.seen, _ = c.Cookie("access_token")
+		c.String(http.StatusOK, c.MustGet("userID").(uuid.UUID).String())
+	})
+	return deps
+}
+
+func (d *middlewareTestDeps) do(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	return d.get("/protected", cookies...)
+}
+
+func (d *middlewareTestDeps) get(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	d.router.ServeHTTP(w, req)
+	return w
+}
+
+// expiredAccessToken signs an access token of the session of the refresh token that expired a minute ago.
+func (d *middlewareTestDeps) expiredAccessToken(t *testing.T, refreshUUID string, refreshExp int64) string {
+	claims := jwt.MapClaims{
+		"user_id":      d.userID.String(),
+		"access_uuid":  uuid.New().String(),
+		"refresh_uuid": refreshUUID,
+		"refresh_exp":  refreshExp,
+		"exp":          time.Now().Add(-time.Minute).Unix(),
+		"iat":          time.Now().Add(-time.Hour).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(d.service.jwtSecret))
+	assert.NoError(t, err)
+	return token
+}
+
+func renewedAccessToken(w *httptest.ResponseRecorder) string {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "access_token" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func TestAuthMiddleware_RenewsExpiredAccessToken(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	refreshToken, refreshUUID, refreshExp, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+	expired := deps.expiredAccessToken(t, refreshUUID, refreshExp)
+
+	// Act
+	w := deps.do(&http.Cookie{Name: "access_token", Value: expired}, &http.Cookie{Name: "refresh_token", Value: refreshToken})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, deps.userID.String(), w.Body.String())
+	renewed := renewedAccessToken(w)
+	assert.NotEmpty(t, renewed)
+	assert.NotEqual(t, expired, renewed)
+	assert.Equal(t, renewed, deps.seen)
+}
+
+func TestAuthMiddleware_RenewsMissingAccessToken(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	refreshToken, _, _, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+
+	// Act
+	// The browser dropped the access token cookie when it expired
+	w := deps.do(&http.Cookie{Name: "refresh_token", Value: refreshToken})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, renewedAccessToken(w))
+	assert.Equal(t, renewedAccessToken(w), deps.seen)
+}
+
+func TestAuthMiddleware_KeepsValidAccessToken(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	refreshToken, refreshUUID, refreshExp, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+	accessToken, _, err := deps.service.generateAccessToken(deps.userID, refreshUUID, refreshExp)
+	assert.NoError(t, err)
+
+	// Act
+	w := deps.do(&http.Cookie{Name: "access_token", Value: accessToken}, &http.Cookie{Name: "refresh_token", Value: refreshToken})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, renewedAccessToken(w))
+	assert.Equal(t, accessToken, deps.seen)
+}
+
+func TestAuthMiddleware_RejectsWithoutValidRefreshToken(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	_, refreshUUID, refreshExp, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+	expired := deps.expiredAccessToken(t, refreshUUID, refreshExp)
+
+	// Act
+	withoutRefresh := deps.do(&http.Cookie{Name: "access_token", Value: expired})
+	invalidRefresh := deps.do(&http.Cookie{Name: "access_token", Value: expired}, &http.Cookie{Name: "refresh_token", Value: "invalid"})
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, withoutRefresh.Code)
+	assert.Equal(t, http.StatusUnauthorized, invalidRefresh.Code)
+}
+
+func TestIsUserAuthenticated_RenewsMissingAccessToken(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	refreshToken, _, _, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+
+	// Act
+	renewed := deps.get("/is-user-authenticated", &http.Cookie{Name: "refresh_token", Value: refreshToken})
+	loggedOut := deps.get("/is-user-authenticated")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, renewed.Code)
+	assert.NotEmpty(t, renewedAccessToken(renewed))
+	assert.Equal(t, http.StatusUnauthorized, loggedOut.Code)
+}
+
+func TestDenyImpersonation_ChecksAccessTokenOnRoutesWithoutAuthMiddleware(t *testing.T) {
+	// Arrange
+	deps := newMiddlewareTestDeps(t)
+	deps.router.POST("/confirm-email-change", DenyImpersonation(NewHandler(deps.service, deps.cfg.UniformAuthResponses)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	now := time.Now()
+	impersonationToken, err := deps.service.generateImpersonationToken(&models.ImpersonationSession{
+		ID: uuid.New(), ActorID: uuid.New(), TargetID: deps.userID, StartedAt: now, ExpiresAt: now.Add(time.Minute),
+	})
+	assert.NoError(t, err)
+	_, refreshUUID, refreshExp, err := deps.service.generateRefreshToken(deps.userID)
+	assert.NoError(t, err)
+	ownToken, _, err := deps.service.generateAccessToken(deps.userID, refreshUUID, refreshExp)
+	assert.NoError(t, err)
+	post := func(cookies ...*http.Cookie) int {
+		req := httptest.NewRequest(http.MethodPost, "/confirm-email-change", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		deps.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Act
+	impersonated := post(&http.Cookie{Name: "access_token", Value: impersonationToken})
+	owner := post(&http.Cookie{Name: "access_token", Value: ownToken})
+	anonymous := post()
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, impersonated)
+	assert.Equal(t, http.StatusOK, owner)
+	assert.Equal(t, http.StatusOK, anonymous)
+}
@@ -3,62 +3,183 @@ package authentication
 import (
 	"automation-hub-idp/internal/app/config"
 	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/iprules"
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/metrics"
 	"automation-hub-idp/internal/app/models"
-	"automation-hub-idp/internal/app/services"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/risk"
 	"automation-hub-idp/internal/app/services/iservice"
 	"automation-hub-idp/internal/app/users"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
 	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	resetTokenSeparator     = "."
+	resetTokenVerifierBytes = 32
+	emailChangeTokenBytes   = 32
+	dummyPassword           = "timing-equalization-password"
+	blockReasonFailedLogins = "too many failed login attempts"
+	lockReasonEmailReverted = "email change reverted"
+	revokeReasonLogout      = "logout"
+	loginChallengeDigits    = 6
+)
+
+// ErrAccountExists is returned by Register when the email is taken. In uniform response mode
+// the handler must answer it exactly like a successful registration.
+var ErrAccountExists = errors.New("account already exists")
+
+// ErrRegistrationClosed is returned by Register in invite-only mode.
+var ErrRegistrationClosed = errors.New("registration is by invitation only")
+
+// ErrEmailDomainNotAllowed is returned by Register in domain allowlist mode for other domains.
+var ErrEmailDomainNotAllowed = errors.New("email domain is not allowed to register")
+
+// ErrStepUpRequired is returned by Login when the credentials are valid but the risk assessment asks
+// for an additional factor before tokens are issued. The error is a *StepUpRequiredError.
+var ErrStepUpRequired = errors.New("additional verification required")
+
+// StepUpRequiredError carries the login challenge a risky login has to complete. The code was sent to the
+// user's mailbox, CompleteLoginChallenge exchanges it for the tokens.
+type StepUpRequiredError struct {
+	ChallengeID uuid.UUID
+	ExpiresAt   time.Time
+}
+
+func (e *StepUpRequiredError) Error() string {
+	return ErrStepUpRequired.Error()
+}
+
+func (e *StepUpRequiredError) Unwrap() error {
+	return ErrStepUpRequired
+}
+
+// ErrEmailChangeRevertible is returned by RequestEmailChange while the previous address can still revert the
+// last change. A new change would replace the revert link of the previous address.
+var ErrEmailChangeRevertible = errors.New("the email was changed recently and can be changed again once the change can no longer be reverted")
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+var errInvalidLoginChallenge = errors.New("invalid login challenge")
+
 type service struct {
-	userService      users.UserService
-	hasher           utils.PasswordHasher
-	blockListService iservice.TokenBlockListService
-	logger           iservice.Logger
-	sender           iservice.MessageSender
-	jwtSecret        string
+	userService       users.UserService
+	resetTokenRepo    irepository.PasswordResetTokenRepository
+	challengeRepo     irepository.LoginChallengeRepository
+	impersonationRepo irepository.ImpersonationSessionRepository
+	loginHistory      loginhistory.Service
+	riskAssessor      risk.Assessor
+	ipRules           iprules.Service
+	audit             iservice.AuditRecorder
+	hasher            utils.PasswordHasher
+	tokenHasher       utils.TokenHasher
+	blockListService  iservice.TokenBlockListService
+	logger            iservice.Logger
+	clock             utils.Clock
+	ids               utils.IDGenerator
+	// sender delivers notifications directly. Account created and blocked events go through the outbox of the
+	// user service instead, notifications carrying a token stay here so the token is never stored in plaintext.
+	sender        iservice.MessageSender
+	cfg           *config.AuthenticationConfig
+	jwtSecret     string
+	dummyHash     string
+	dummyHashOnce sync.Once
+	// dispatch runs work whose outcome must not be observable by the caller, tracked by background so
+	// shutdown waits for it
+	dispatch   func(func())
+	background *sync.WaitGroup
 }
 
-func NewService(userService users.UserService, hasher utils.PasswordHasher, sender iservice.MessageSender,
-	blockListService iservice.TokenBlockListService, logger iservice.Logger, jwtSecret string) IService {
-	return &service{
-		userService:      userService,
-		hasher:           hasher,
-		blockListService: blockListService,
-		logger:           logger,
-		sender:           sender,
-		jwtSecret:        jwtSecret,
+func NewService(userService users.UserService, resetTokenRepo irepository.PasswordResetTokenRepository,
+	challengeRepo irepository.LoginChallengeRepository, impersonationRepo irepository.ImpersonationSessionRepository, loginHistory loginhistory.Service, riskAssessor risk.Assessor,
+	ipRules iprules.Service, audit iservice.AuditRecorder, hasher utils.PasswordHasher, tokenHasher utils.TokenHasher, sender iservice.MessageSender,
+	blockListService iservice.TokenBlockListService, logger iservice.Logger, cfg *config.AuthenticationConfig, clock utils.Clock, ids utils.IDGenerator,
+	background *sync.WaitGroup) IService {
+	a := &service{
+		userService:       userService,
+		resetTokenRepo:    resetTokenRepo,
+		challengeRepo:     challengeRepo,
+		impersonationRepo: impersonationRepo,
+		loginHistory:      loginHistory,
+		riskAssessor:      riskAssessor,
+		ipRules:           ipRules,
+		audit:             audit,
+		hasher:            hasher,
+		tokenHasher:       tokenHasher,
+		blockListService:  blockListService,
+		logger:            logger,
+		clock:             clock,
+		ids:               ids,
+		sender:            sender,
+		cfg:               cfg,
+		jwtSecret:         cfg.JwtSecret,
+		background:        background,
 	}
+	a.dispatch = a.runInBackground
+	return a
 }
 
-func GetDefaultAuthService() (IService, error) {
-	logger, err := services.NewKafkaLogger(config.KafkaConfig.BrokersAddr, config.KafkaConfig.LoggerTopic)
-	if err != nil {
-		return nil, err
-	}
-	if err != nil {
-		return nil, err
-	}
-	userService, err := users.GetDefaultUserService()
-	if err != nil {
+// runInBackground runs work after the caller returns, counted in the background group until it is done.
+func (a *service) runInBackground(work func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		work()
+	}()
+}
+
+func (a *service) Register(ctx context.Context, userDTO dto.UserDTO, client dto.ClientInfo) (*dto.UserResponse, error) {
+	if err := a.checkRegistrationAllowed(userDTO.Email); err != nil {
+		a.logger.Warn("Registration rejected for %s: %v", userDTO.Email, err)
+		a.audit.Record(ctx, dto.AuditEvent{
+			Type:    models.AuditEventRegistered,
+			Outcome: models.AuditOutcomeDenied,
+			IP:      client.IP,
+			Details: map[string]string{"email": userDTO.Email, "reason": err.Error()},
+		})
 		return nil, err
 	}
-	hasher := config.AuthenticationConfig.PasswordHasher
-	sender, err := services.NewKafkaMessageSender()
-	if err != nil {
-		return nil, err
+	return a.register(ctx, userDTO, models.RoleUser, nil, client)
+}
+
+// RegisterInvited registers an invited user with the role and organization of the invitation. It bypasses
+// the registration mode, the invitation itself is the permission to register.
+func (a *service) RegisterInvited(ctx context.Context, userDTO dto.UserDTO, role string, organizationID *uuid.UUID, client dto.ClientInfo) (*dto.UserResponse, error) {
+	return a.register(ctx, userDTO, role, organizationID, client)
+}
+
+func (a *service) checkRegistrationAllowed(email string) error {
+	switch a.cfg.RegistrationMode {
+	case config.RegistrationModeInviteOnly:
+		return ErrRegistrationClosed
+	case config.RegistrationModeDomainAllowlist:
+		_, domain, found := strings.Cut(email, "@")
+		if !found {
+			return ErrEmailDomainNotAllowed
+		}
+		domain = strings.ToLower(domain)
+		for _, allowed := range a.cfg.RegistrationAllowedDomains {
+			if domain == allowed {
+				return nil
+			}
+		}
+		return ErrEmailDomainNotAllowed
 	}
-	blockListService := services.NewRedisTokenBlockListService()
-	return NewService(userService, hasher, sender, blockListService, logger, config.AuthenticationConfig.JwtSecret), nil
+	return nil
 }
 
-func (a *service) Register(userDTO dto.UserDTO) (*dto.UserResponse, error) {
+func (a *service) register(ctx context.Context, userDTO dto.UserDTO, role string, organizationID *uuid.UUID, client dto.ClientInfo) (*dto.UserResponse, error) {
 	hashedPassword, err := a.hasher.Hash(userDTO.Password)
 	if err != nil {
 		a.logger.Error("Error generating hashed password for user with email: %s, %v", userDTO.Email, err)
@@ -66,26 +187,45 @@ func (a *service) Register(userDTO dto.UserDTO) (*dto.UserResponse, error) {
 	}
 
 	user := models.User{
-		Email:    userDTO.Email,
-		Password: hashedPassword,
+		Email:          userDTO.Email,
+		Password:       hashedPassword,
+		Role:           role,
+		OrganizationID: organizationID,
 	}
 
-	userCreated, err := a.userService.CreateUser(user)
+	if a.cfg.UniformAuthResponses {
+		if existingUser, _ := a.userService.GetUserByEmail(ctx, user.Email); existingUser != nil {
+			// Tell the owner instead of the caller, so the response cannot reveal the account. The notice
+			// outlives the request and keeps its context but not its cancellation.
+			background := context.WithoutCancel(ctx)
+			a.dispatch(func() { a.sendAccountExistsNotice(background, user.Email) })
+			a.audit.Record(ctx, dto.AuditEvent{
+				Type:       models.AuditEventRegistered,
+				Outcome:    models.AuditOutcomeFailure,
+				TargetType: models.AuditTargetUser,
+				TargetID:   &existingUser.ID,
+				IP:         client.IP,
+				Details:    map[string]string{"email": user.Email, "reason": ErrAccountExists.Error()},
+			})
+			return nil, ErrAccountExists
+		}
+	}
+
+	userCreated, err := a.userService.CreateUser(ctx, user)
 	if err != nil {
 		a.logger.Error("Error creating user: %v", err)
 		return nil, errors.New("failed to create user")
 	}
 
 	a.logger.Info("Successfully registered user: %s", user.Email)
-	msg := struct {
-		Email string
-	}{
-		Email: user.Email,
-	}
-	err = a.sender.Send(config.AuthenticationConfig.AccountCreatedTopic, msg)
-	if err != nil {
-		a.logger.Error("Error sending account created message: %v", err)
-	}
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventRegistered,
+		Outcome:    models.AuditOutcomeSuccess,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &userCreated.ID,
+		IP:         client.IP,
+		Details:    map[string]string{"email": user.Email, "role": role},
+	})
 
 	return &dto.UserResponse{
 		ID:    userCreated.ID,
@@ -93,97 +233,339 @@ func (a *service) Register(userDTO dto.UserDTO) (*dto.UserResponse, error) {
 	}, nil
 }
 
-func (a *service) Login(email, password string) (*dto.TokenDetails, error) {
-	user, err := a.userService.GetUserByEmail(email)
+func (a *service) Login(ctx context.Context, email, password string, client dto.ClientInfo) (*dto.TokenDetails, error) {
+	user, outcome, err := a.authenticate(ctx, email, password, client)
+	if err != nil {
+		a.recordLoginAttempt(ctx, user, email, outcome, client)
+		return nil, err
+	}
+
+	if outcome, err = a.assessLoginRisk(ctx, user, client); err != nil {
+		a.recordLoginAttempt(ctx, user, email, outcome, client)
+		return nil, err
+	}
+
+	return a.issueTokens(ctx, user, client)
+}
+
+// issueTokens completes a login, the user having passed every check.
+func (a *service) issueTokens(ctx context.Context, user *models.User, client dto.ClientInfo) (*dto.TokenDetails, error) {
+	var err error
+	td := &dto.TokenDetails{}
+	td.RefreshToken, td.RefreshUUID, td.RtExpires, err = a.generateRefreshToken(user.ID)
+	if err != nil {
+		a.logger.Error("Failed to generate refresh token for user %s: %v", user.Email, err)
+		a.recordLoginAttempt(ctx, user, user.Email, models.LoginOutcomeError, client)
+		return nil, errors.New("failed to generate refresh token")
+	}
+	td.AccessToken, td.AtExpires, err = a.generateAccessToken(user.ID, td.RefreshUUID, td.RtExpires)
+	if err != nil {
+		a.logger.Error("Failed to generate access token for user %s: %v", user.Email, err)
+		a.recordLoginAttempt(ctx, user, user.Email, models.LoginOutcomeError, client)
+		return nil, errors.New("failed to generate access token")
+	}
+
+	a.logger.Info("Successfully logged in user: %s", user.Email)
+	attempt := a.recordLoginAttempt(ctx, user, user.Email, models.LoginOutcomeSuccess, client)
+	if attempt != nil && attempt.NewDevice {
+		a.sendNewDeviceLoginAlert(ctx, user.ID, user.Email, attempt)
+	}
+
+	return td, nil
+}
+
+// authenticate checks the credentials and the account state. The returned outcome is recorded in the
+// login history; the user is nil when the email does not belong to an account.
+func (a *service) authenticate(ctx context.Context, email, password string, client dto.ClientInfo) (*models.User, string, error) {
+	user, err := a.userService.GetUserByEmail(ctx, email)
 	if err != nil {
 		a.logger.Error("Error fetching user by email: %v", err)
-		return nil, errors.New("invalid credentials")
+		return nil, models.LoginOutcomeUnknownUser, a.rejectLogin(ctx, password, errInvalidCredentials)
 	}
 
 	// Check if account is blocked and if the block time hasn't expired
-	now := time.Now()
+	now := a.clock.Now()
+	if user.IsLocked {
+		a.logger.Warn("Login attempt for locked user: %s", email)
+		return user, models.LoginOutcomeLocked, a.rejectLogin(ctx, password, errors.New("account is locked"))
+	}
 	if user.IsBlocked && user.BlockedUntil != nil && now.Before(*user.BlockedUntil) {
 		a.logger.Warn("Login attempt for blocked user: %s", email)
-		return nil, errors.New("account is blocked")
+		return user, models.LoginOutcomeBlocked, a.rejectLogin(ctx, password, errors.New("account is blocked"))
+	}
+
+	// Organizations can restrict logins of their members to their own networks
+	if user.OrganizationID != nil {
+		allowed, err := a.ipRules.IsAllowed(ctx, client.IP, models.IPRuleScopeOrganization, user.OrganizationID)
+		if err != nil {
+			a.logger.Error("Error checking IP rules for user %s: %v", email, err)
+			return user, models.LoginOutcomeError, a.rejectLogin(ctx, password, errors.New("failed to check login address"))
+		}
+		if !allowed {
+			a.logger.Warn("Login attempt for user %s from disallowed address %s", email, client.IP)
+			return user, models.LoginOutcomeIPDenied, a.rejectLogin(ctx, password, errors.New("login from this address is not allowed"))
+		}
 	}
 
 	// Check for rapid subsequent login attempts
-	if user.LastAttempt != nil && now.Sub(*user.LastAttempt) < config.AuthenticationConfig.MinTimeBetweenAttemptsSeconds*time.Second {
+	if user.LastAttempt != nil && now.Sub(*user.LastAttempt) < a.cfg.MinTimeBetweenAttemptsSeconds*time.Second {
 		a.logger.Warn("Rapid subsequent login attempt detected for user: %s", email)
-		return nil, errors.New("please wait a moment before trying again")
+		return user, models.LoginOutcomeThrottled, a.rejectLogin(ctx, password, errors.New("please wait a moment before trying again"))
 	}
 
 	// If the account was blocked but the block time has expired, unblock the account
 	if user.IsBlocked && (user.BlockedUntil == nil || now.After(*user.BlockedUntil)) {
-		user.IsBlocked = false
-		user.FailedAttempts = 0
-		user.BlockedUntil = nil
-		user, err = a.userService.UpdateUser(*user)
+		err = a.userService.UnblockIfExpired(ctx, user.ID, now)
 		if err != nil {
-			return nil, errors.New("failed to unblock account")
+			return user, models.LoginOutcomeError, errors.New("failed to unblock account")
 		}
 	}
 
+	// Reserve the attempt before checking the password. The counter is incremented atomically, so
+	// parallel requests each see a distinct count and no more than the allowed number get evaluated.
+	failedAttempts, err := a.userService.IncrementFailedAttempts(ctx, user.ID, now)
+	if err != nil {
+		a.logger.Error("Failed to record login attempt for user %s: %v", email, err)
+		return user, models.LoginOutcomeError, a.rejectLogin(ctx, password, errors.New("failed to record login attempt"))
+	}
+	maxAttempts := a.cfg.MaxLoginAttemptsBeforeBlock
+	if failedAttempts > maxAttempts {
+		a.blockUser(ctx, user.ID, email, failedAttempts, now, client)
+		a.logger.Warn("Login attempt beyond the allowed attempts for user: %s", email)
+		return user, models.LoginOutcomeBlocked, a.rejectLogin(ctx, password, errors.New("account is blocked"))
+	}
+
 	hashErr := a.hasher.Compare(user.Password, password)
 	if hashErr != nil {
-		user.FailedAttempts++
-		user.LastAttempt = &now
-		if user.FailedAttempts >= config.AuthenticationConfig.MaxLoginAttemptsBeforeBlock {
-			blockDuration := calculateBlockDuration(user.FailedAttempts)
-			blockedUntil := now.Add(blockDuration)
-			user.BlockedUntil = &blockedUntil
-			user.IsBlocked = true
-			a.logger.Warn("User %s is blocked until %s", email, blockedUntil.String())
-			msg := struct {
-				Email        string
-				BlockedUntil time.Time
-			}{
-				Email:        email,
-				BlockedUntil: blockedUntil,
-			}
-			err = a.sender.Send(config.AuthenticationConfig.AccountBlockedTopic, msg)
-		}
-		_, updateErr := a.userService.UpdateUser(*user)
-		if updateErr != nil {
-			a.logger.Error("Failed to update user after failed login: %v", updateErr)
+		if failedAttempts == maxAttempts {
+			a.blockUser(ctx, user.ID, email, failedAttempts, now, client)
 		}
 		a.logger.Warn("Hash comparison failed for user %s: %v", email, hashErr)
-		return nil, errors.New("invalid credentials")
+		return user, models.LoginOutcomeInvalidCredentials, errInvalidCredentials
 	}
 
 	// Reset FailedAttempts since login is successful
-	user.FailedAttempts = 0
-	user.LastAttempt = &now
-	_, updateErr := a.userService.UpdateUser(*user)
+	updateErr := a.userService.ResetFailedAttempts(ctx, user.ID, now)
 	if updateErr != nil {
 		a.logger.Error("Failed to reset failed attempts for user %s: %v", email, updateErr)
 	}
 
-	td := &dto.TokenDetails{}
-	td.RefreshToken, td.RefreshUUID, td.RtExpires, err = a.generateRefreshToken(user.ID)
+	return user, models.LoginOutcomeSuccess, nil
+}
+
+// assessLoginRisk scores a login with valid credentials and decides whether it may receive tokens.
+// A failing assessment lets the login through, as the credentials have already been verified.
+func (a *service) assessLoginRisk(ctx context.Context, user *models.User, client dto.ClientInfo) (string, error) {
+	assessment, err := a.riskAssessor.Assess(ctx, risk.Request{UserID: user.ID, Client: client, Time: a.clock.Now()})
 	if err != nil {
-		a.logger.Error("Failed to generate refresh token for user %s: %v", email, err)
-		return nil, errors.New("failed to generate refresh token")
+		a.logger.Error("Error assessing login risk for user %s: %v", user.Email, err)
+		return models.LoginOutcomeSuccess, nil
 	}
-	td.AccessToken, td.AtExpires, err = a.generateAccessToken(user.ID, td.RefreshUUID, td.RtExpires)
+
+	switch assessment.Decision {
+	case risk.DecisionDeny:
+		a.logger.Warn("Login denied for user %s, risk score %d %v", user.Email, assessment.Score, assessment.Reasons)
+		return models.LoginOutcomeRiskDenied, errInvalidCredentials
+	case risk.DecisionStepUp:
+		a.logger.Warn("Login for user %s requires step-up, risk score %d %v", user.Email, assessment.Score, assessment.Reasons)
+		stepUp, err := a.startLoginChallenge(ctx, user)
+		if err != nil {
+			return models.LoginOutcomeError, err
+		}
+		return models.LoginOutcomeStepUpRequired, stepUp
+	}
+	return models.LoginOutcomeSuccess, nil
+}
+
+// startLoginChallenge sends a one-time code to the user's mailbox and returns the challenge to complete the
+// login with.
+func (a *service) startLoginChallenge(ctx context.Context, user *models.User) (*StepUpRequiredError, error) {
+	code, err := utils.GenerateRandomCode(loginChallengeDigits)
 	if err != nil {
-		a.logger.Error("Failed to generate access token for user %s: %v", email, err)
-		return nil, errors.New("failed to generate access token")
+		a.logger.Error("Error generating login challenge code: %v", err)
+		return nil, errors.New("failed to start login challenge")
 	}
 
-	a.logger.Info("Successfully logged in user: %s", email)
+	challenge := &models.LoginChallenge{
+		ID:        a.ids.NewID(),
+		UserID:    user.ID,
+		CodeHash:  a.tokenHasher.Hash(code),
+		ExpiresAt: a.clock.Now().Add(time.Minute * a.cfg.LoginChallengeDurationMinutes),
+	}
+	if _, err = a.challengeRepo.Create(ctx, challenge); err != nil {
+		a.logger.Error("Error storing login challenge: %v", err)
+		return nil, errors.New("failed to start login challenge")
+	}
 
-	return td, nil
+	event := events.LoginChallengeRequested{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Code:      code,
+		ExpiresAt: challenge.ExpiresAt,
+	}
+	if err = a.sender.Send(ctx, a.cfg.LoginChallengeTopic, event); err != nil {
+		a.logger.Error("Error sending login challenge message: %v", err)
+		return nil, errors.New("failed to send login challenge")
+	}
+
+	return &StepUpRequiredError{ChallengeID: challenge.ID, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// CompleteLoginChallenge finishes a login held back for step-up, exchanging the code sent to the user for the
+// tokens. A challenge completes once, and no longer after MaxLoginChallengeAttempts wrong codes.
+func (a *service) CompleteLoginChallenge(ctx context.Context, challengeID uuid.UUID, code string, client dto.ClientInfo) (*dto.TokenDetails, error) {
+	challenge, err := a.challengeRepo.FindByID(ctx, challengeID)
+	if err != nil {
+		a.logger.Error("Error fetching login challenge: %v", err)
+		return nil, errInvalidLoginChallenge
+	}
+
+	now := a.clock.Now()
+	if challenge.CompletedAt != nil || challenge.FailedAttempts >= a.cfg.MaxLoginChallengeAttempts || challenge.ExpiresAt.Before(now) {
+		a.logger.Warn("Attempt to complete an invalidated login challenge for user: %s", challenge.UserID)
+		return nil, errInvalidLoginChallenge
+	}
+
+	user, err := a.userService.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		a.logger.Error("Error fetching user of login challenge: %v", err)
+		return nil, errInvalidLoginChallenge
+	}
+
+	if !a.tokenHasher.Compare(challenge.CodeHash, code) {
+		if err := a.challengeRepo.IncrementFailedAttempts(ctx, challenge.ID); err != nil {
+			a.logger.Error("Error recording failed login challenge attempt: %v", err)
+		}
+		a.logger.Warn("Login challenge verification failed for user: %s", user.Email)
+		a.recordLoginAttempt(ctx, user, user.Email, models.LoginOutcomeStepUpFailed, client)
+		return nil, errInvalidLoginChallenge
+	}
+
+	// Consume the challenge first so a concurrent request cannot exchange the same code again
+	completed, err := a.challengeRepo.MarkCompleted(ctx, challenge.ID, now)
+	if err != nil || !completed {
+		return nil, errInvalidLoginChallenge
+	}
+
+	// The account may have been locked or blocked while the code was on its way
+	if user.IsLocked || (user.IsBlocked && user.BlockedUntil != nil && now.Before(*user.BlockedUntil)) {
+		a.logger.Warn("Login challenge completed for locked or blocked user: %s", user.Email)
+		a.recordLoginAttempt(ctx, user, user.Email, models.LoginOutcomeBlocked, client)
+		return nil, errInvalidLoginChallenge
+	}
+
+	return a.issueTokens(ctx, user, client)
 }
 
-func calculateBlockDuration(failedLoginAttempts int) time.Duration {
-	exponent := float64(failedLoginAttempts - config.AuthenticationConfig.MaxLoginAttemptsBeforeBlock)
-	initialBlockDuration := time.Duration(config.AuthenticationConfig.BaseBlockDurationMinutes) * time.Minute
+// recordLoginAttempt adds the attempt to the login history and the audit log. A failure to record never
+// fails the login.
+func (a *service) recordLoginAttempt(ctx context.Context, user *models.User, email, outcome string, client dto.ClientInfo) *models.LoginAttempt {
+	result := metrics.ResultFailure
+	if outcome == models.LoginOutcomeSuccess {
+		result = metrics.ResultSuccess
+	}
+	metrics.Logins.WithLabelValues(result, outcome).Inc()
+
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+	event := dto.AuditEvent{
+		Type:       models.AuditEventLoginFailed,
+		Outcome:    models.AuditOutcomeFailure,
+		TargetType: models.AuditTargetUser,
+		TargetID:   userID,
+		IP:         client.IP,
+		Details:    map[string]string{"email": email, "reason": outcome, "user_agent": client.UserAgent},
+	}
+	if outcome == models.LoginOutcomeSuccess {
+		event.Type, event.Outcome, event.ActorID = models.AuditEventLoginSucceeded, models.AuditOutcomeSuccess, userID
+		delete(event.Details, "reason")
+	}
+	a.audit.Record(ctx, event)
+
+	attempt, err := a.loginHistory.RecordAttempt(ctx, userID, outcome, client)
+	if err != nil {
+		a.logger.Error("Error recording login attempt: %v", err)
+		return nil
+	}
+	return attempt
+}
+
+func (a *service) sendNewDeviceLoginAlert(ctx context.Context, userID uuid.UUID, email string, attempt *models.LoginAttempt) {
+	event := events.NewDeviceLogin{
+		UserID:    userID,
+		Email:     email,
+		IP:        attempt.IP,
+		UserAgent: attempt.UserAgent,
+		Country:   attempt.Country,
+		City:      attempt.City,
+		Time:      attempt.CreatedAt,
+	}
+	err := a.sender.Send(ctx, a.cfg.NewDeviceLoginTopic, event)
+	if err != nil {
+		a.logger.Error("Error sending new device login message: %v", err)
+	}
+}
+
+func (a *service) blockUser(ctx context.Context, userID uuid.UUID, email string, failedAttempts int, now time.Time, client dto.ClientInfo) {
+	blockedUntil := now.Add(a.calculateBlockDuration(failedAttempts))
+	blocked, err := a.userService.BlockUntil(ctx, userID, blockedUntil, blockReasonFailedLogins)
+	if err != nil {
+		a.logger.Error("Failed to block user %s: %v", email, err)
+		return
+	}
+	if !blocked {
+		// A concurrent attempt already holds a block that lasts at least as long
+		return
+	}
+	metrics.Lockouts.Inc()
+
+	a.logger.Warn("User %s is blocked until %s", email, blockedUntil.String())
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventAccountLocked,
+		Outcome:    models.AuditOutcomeSuccess,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &userID,
+		IP:         client.IP,
+		Details: map[string]string{
+			"reason":          blockReasonFailedLogins,
+			"failed_attempts": strconv.Itoa(failedAttempts),
+			"blocked_until":   blockedUntil.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// rejectLogin fails a login that never reached the password check. In uniform response mode it still
+// spends a password comparison and hides the reason, so the outcome looks like a wrong password.
+func (a *service) rejectLogin(ctx context.Context, password string, reason error) error {
+	if !a.cfg.UniformAuthResponses {
+		return reason
+	}
+	a.compareWithDummyHash(ctx, password)
+	return errInvalidCredentials
+}
+
+func (a *service) compareWithDummyHash(ctx context.Context, password string) {
+	a.dummyHashOnce.Do(func() {
+		hash, err := a.hasher.Hash(dummyPassword)
+		if err != nil {
+			a.logger.Error("Error generating dummy password hash: %v", err)
+			return
+		}
+		a.dummyHash = hash
+	})
+	_ = a.hasher.Compare(a.dummyHash, password)
+}
+
+func (a *service) calculateBlockDuration(failedLoginAttempts int) time.Duration {
+	exponent := float64(failedLoginAttempts - a.cfg.MaxLoginAttemptsBeforeBlock)
+	initialBlockDuration := time.Duration(a.cfg.BaseBlockDurationMinutes) * time.Minute
 	return initialBlockDuration * time.Duration(math.Pow(2, exponent))
 }
 
-func (a *service) Logout(accessToken string) error {
-	_, claims, err := a.parseAndValidateToken(accessToken)
+func (a *service) Logout(ctx context.Context, accessToken string, client dto.ClientInfo) error {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
 
 	userID, ok := claims["user_id"].(string)
 	if !ok {
@@ -210,7 +592,7 @@ func (a *service) Logout(accessToken string) error {
 		return errors.New("refresh expiration time not found in the token")
 	}
 	refreshExp := int64(refreshExpFloat)
-	rtDuration := time.Until(time.Unix(refreshExp, 0))
+	rtDuration := time.Unix(refreshExp, 0).Sub(a.clock.Now())
 
 	atExpiresFloat, ok := claims["exp"].(float64)
 	if !ok {
@@ -218,44 +600,93 @@ func (a *service) Logout(accessToken string) error {
 		return errors.New("expiration time not found in the token")
 	}
 	atExpires := int64(atExpiresFloat)
-	atDuration := time.Until(time.Unix(atExpires, 0))
+	atDuration := time.Unix(atExpires, 0).Sub(a.clock.Now())
 
 	// Add the access token and refresh token UUIDs to the block list
-	err = a.blockListService.AddToBlockList(accessUUID, atDuration)
+	err = a.blockListService.AddToBlockList(ctx, accessUUID, atDuration)
 	if err != nil {
 		a.logger.Error("Failed to add access token to block list for user: %s, Error: %v", userID, err)
 		return err
 	}
-	err = a.blockListService.AddToBlockList(refreshUUID, rtDuration)
+	err = a.blockListService.AddToBlockList(ctx, refreshUUID, rtDuration)
 	if err != nil {
 		a.logger.Error("Failed to add refresh token to block list for user: %s, Error: %v", userID, err)
 		return err
 	}
 
 	a.logger.Info("Successfully logged out and blocked tokens for user: %s with accessUUID: %s and refreshUUID: %s", userID, accessUUID, refreshUUID)
+	if id, err := uuid.Parse(userID); err == nil {
+		a.sendSessionRevoked(ctx, id, revokeReasonLogout, a.clock.Now())
+		a.audit.Record(ctx, dto.AuditEvent{
+			Type:       models.AuditEventLoggedOut,
+			Outcome:    models.AuditOutcomeSuccess,
+			ActorID:    actorFromClaims(claims, id),
+			TargetType: models.AuditTargetUser,
+			TargetID:   &id,
+			IP:         client.IP,
+		})
+	}
 	return nil
 }
 
-func (a *service) RefreshToken(refreshToken string) (*dto.TokenDetails, error) {
-	_, claims, err := a.parseAndValidateToken(refreshToken)
+func (a *service) RevokeSessions(ctx context.Context, userID uuid.UUID, reason string) error {
+	now := a.clock.Now()
+	err := a.blockListService.RevokeUserSessions(ctx, userID.String(), now, a.cfg.RefreshTokenDurationDays)
+	if err != nil {
+		a.logger.Error("Failed to revoke sessions for user: %s, Error: %v", userID, err)
+		return errors.New("failed to revoke sessions")
+	}
+	a.sendSessionRevoked(ctx, userID, reason, now)
+	a.logger.Info("Sessions revoked for user: %s, reason: %s", userID, reason)
+	return nil
+}
+
+func (a *service) RefreshToken(ctx context.Context, refreshToken string) (*dto.TokenDetails, error) {
+	td, err := a.refreshToken(ctx, refreshToken)
+	metrics.TokenRefreshes.WithLabelValues(metrics.Result(err)).Inc()
+	return td, err
+}
+
+func (a *service) refreshToken(ctx context.Context, refreshToken string) (*dto.TokenDetails, error) {
+	_, claims, err := a.parseAndValidateToken(ctx, refreshToken)
+	if err != nil {
+		a.logger.Warn("Invalid refresh token: %v", err)
+		return nil, err
+	}
 
 	refreshUUID, ok := claims["refresh_uuid"].(string)
 	if !ok {
 		a.logger.Warn("Refresh UUID not found in the token")
 		return nil, errors.New("refresh UUID not found in the token")
 	}
+	if _, impersonated := claims["act"]; impersonated {
+		a.logger.Warn("Attempt to refresh an impersonation token")
+		return nil, errors.New("impersonation tokens cannot be refreshed")
+	}
 
 	// Check if the refresh token is on the block list
-	isBlocked, err := a.blockListService.IsInBlockList(refreshUUID)
+	isBlocked, err := a.blockListService.IsInBlockList(ctx, refreshUUID)
 	if err != nil {
 		a.logger.Error("Failed to check blockList status: %v", err)
 		return nil, errors.New("error checking blockList status")
 	}
 	if isBlocked {
+		metrics.BlockListHits.WithLabelValues("refresh", "blocked").Inc()
 		a.logger.Warn("Refresh token is blocked")
 		return nil, errors.New("refresh token is blocked")
 	}
 
+	isRevoked, err := a.isSessionRevoked(ctx, claims)
+	if err != nil {
+		a.logger.Error("Failed to check session revocation status: %v", err)
+		return nil, errors.New("error checking session revocation status")
+	}
+	if isRevoked {
+		metrics.BlockListHits.WithLabelValues("refresh", "session_revoked").Inc()
+		a.logger.Warn("Refresh token belongs to a revoked session")
+		return nil, errors.New("refresh token is revoked")
+	}
+
 	// Renew the access token using the refresh token's claims
 	userID, err := uuid.Parse(claims["user_id"].(string))
 	if err != nil {
@@ -263,11 +694,13 @@ func (a *service) RefreshToken(refreshToken string) (*dto.TokenDetails, error) {
 		return nil, err
 	}
 
-	refreshExp, ok := claims["refresh_exp"].(int64)
+	// The access tokens renewed by the refresh token expire with it at the latest
+	refreshExpFloat, ok := claims["exp"].(float64)
 	if !ok {
 		a.logger.Warn("Refresh expiration time not found in the token for user: %s", userID)
 		return nil, errors.New("refresh expiration time not found in the token")
 	}
+	refreshExp := int64(refreshExpFloat)
 	newAccessToken, atExpires, err := a.generateAccessToken(userID, refreshUUID, refreshExp)
 	if err != nil {
 		a.logger.Error("Failed to generate new access token: %v", err)
@@ -287,8 +720,8 @@ func (a *service) RefreshToken(refreshToken string) (*dto.TokenDetails, error) {
 	return td, nil
 }
 
-func (a *service) IsUserAuthenticated(accessToken string) (bool, error) {
-	_, claims, err := a.parseAndValidateToken(accessToken)
+func (a *service) IsUserAuthenticated(ctx context.Context, accessToken string) (bool, error) {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
 	if err != nil {
 		a.logger.Error("Error parsing accessToken: %v", err)
 		return false, err
@@ -300,103 +733,199 @@ func (a *service) IsUserAuthenticated(accessToken string) (bool, error) {
 		return false, errors.New("invalid accessToken")
 	}
 
-	isBlocked, err := a.blockListService.IsInBlockList(accessUUID)
+	isBlocked, err := a.blockListService.IsInBlockList(ctx, accessUUID)
 	if err != nil {
 		a.logger.Error("Error checking accessToken in block list: %v", err)
 		return false, err
 	}
 
 	if isBlocked {
+		metrics.BlockListHits.WithLabelValues("access", "blocked").Inc()
 		a.logger.Warn("Token is blocked")
 		return false, errors.New("accessToken is blocked")
 	}
 
+	isRevoked, err := a.isSessionRevoked(ctx, claims)
+	if err != nil {
+		a.logger.Error("Error checking session revocation status: %v", err)
+		return false, err
+	}
+	if isRevoked {
+		metrics.BlockListHits.WithLabelValues("access", "session_revoked").Inc()
+		a.logger.Warn("Token belongs to a revoked session")
+		return false, errors.New("accessToken is revoked")
+	}
+
 	return true, nil
 }
 
-func (a *service) RequestPasswordReset(email string) (string, time.Time, error) {
-	user, err := a.userService.GetUserByEmail(email)
+func (a *service) RequestPasswordReset(ctx context.Context, email string) error {
+	metrics.PasswordResetsRequested.Inc()
+	if a.cfg.UniformAuthResponses {
+		// Unknown emails, send failures and timing all stay invisible to the caller
+		background := context.WithoutCancel(ctx)
+		a.dispatch(func() { _ = a.processPasswordReset(background, email) })
+		return nil
+	}
+	return a.processPasswordReset(ctx, email)
+}
+
+func (a *service) processPasswordReset(ctx context.Context, email string) error {
+	user, err := a.userService.GetUserByEmail(ctx, email)
 	if err != nil {
 		a.logger.Error("Error fetching user by email: %v", err)
-		return "", time.Time{}, errors.New("invalid email")
+		return errors.New("invalid email")
 	}
 
-	// Generate a reset token
-	resetToken := uuid.New().String()
-	resetTokenExpires := time.Now().Add(time.Hour * config.AuthenticationConfig.ExpirationTimeResetTokenHours)
-
-	// Add the reset token to the user
-	user.ResetPasswordToken = resetToken
-	user.ResetTokenExpires = &resetTokenExpires
+	// The token is "<selector>.<verifier>": the selector finds the record, only a keyed hash of the verifier is stored
+	verifier, err := utils.GenerateRandomToken(resetTokenVerifierBytes)
+	if err != nil {
+		a.logger.Error("Error generating reset token: %v", err)
+		return errors.New("failed to generate reset token")
+	}
+	resetTokenExpires := a.clock.Now().Add(time.Hour * a.cfg.ExpirationTimeResetTokenHours)
 
-	_, err = a.userService.UpdateUser(*user)
+	resetToken := &models.PasswordResetToken{
+		ID:        a.ids.NewID(),
+		UserID:    user.ID,
+		TokenHash: a.tokenHasher.Hash(verifier),
+		ExpiresAt: resetTokenExpires,
+	}
+	_, err = a.resetTokenRepo.Create(ctx, resetToken)
 	if err != nil {
-		a.logger.Error("Error updating user: %v", err)
-		return "", time.Time{}, errors.New("failed to update user")
+		a.logger.Error("Error storing reset token: %v", err)
+		return errors.New("failed to store reset token")
 	}
 
 	// Send the message with the reset token
-	msg := struct {
-		Email          string
-		ResetToken     string
-		TokenExpiresIn int64
-	}{
-		Email:          email,
-		ResetToken:     resetToken,
-		TokenExpiresIn: resetTokenExpires.Unix(),
+	event := events.PasswordResetRequested{
+		UserID:     user.ID,
+		Email:      email,
+		ResetToken: resetToken.ID.String() + resetTokenSeparator + verifier,
+		ExpiresAt:  resetTokenExpires,
 	}
-	err = a.sender.Send(config.AuthenticationConfig.PasswordResetTopic, msg)
+	err = a.sender.Send(ctx, a.cfg.PasswordResetTopic, event)
 	if err != nil {
 		a.logger.Error("Error sending reset token message: %v", err)
-		return "", time.Time{}, errors.New("failed to send reset token")
+		return errors.New("failed to send reset token")
 	}
 
 	a.logger.Info("Successfully sent reset token to user: %s", email)
-	return resetToken, resetTokenExpires, nil
+	return nil
+}
+
+func (a *service) sendAccountExistsNotice(ctx context.Context, email string) {
+	err := a.sender.Send(ctx, a.cfg.AccountExistsTopic, events.AccountExists{Email: email})
+	if err != nil {
+		a.logger.Error("Error sending account exists message: %v", err)
+	}
 }
 
-func (a *service) ConfirmPasswordReset(token, newPassword string) error {
-	user, err := a.userService.GetUserByResetToken(token)
+// sendSessionRevoked tells subscribers that sessions of the user ended early. The sessions are revoked
+// already, so a failure is only logged.
+func (a *service) sendSessionRevoked(ctx context.Context, userID uuid.UUID, reason string, revokedAt time.Time) {
+	event := events.SessionRevoked{UserID: userID, Reason: reason, RevokedAt: revokedAt}
+	if err := a.sender.Send(ctx, a.cfg.SessionRevokedTopic, event); err != nil {
+		a.logger.Error("Error sending session revoked message for user %s: %v", userID, err)
+	}
+}
+
+func (a *service) ConfirmPasswordReset(ctx context.Context, token, newPassword string, client dto.ClientInfo) error {
+	selector, verifier, found := strings.Cut(token, resetTokenSeparator)
+	if !found {
+		return errors.New("invalid token")
+	}
+	resetTokenID, err := uuid.Parse(selector)
+	if err != nil {
+		return errors.New("invalid token")
+	}
+
+	resetToken, err := a.resetTokenRepo.FindByID(ctx, resetTokenID)
 	if err != nil {
-		a.logger.Error("Error fetching user by reset token: %v", err)
+		a.logger.Error("Error fetching reset token: %v", err)
 		return errors.New("invalid token")
 	}
 
-	if user == nil {
+	if resetToken.UsedAt != nil || resetToken.FailedAttempts >= a.cfg.MaxResetTokenAttempts {
+		a.logger.Warn("Attempt to use an invalidated reset token for user: %s", resetToken.UserID)
 		return errors.New("invalid token")
 	}
 
-	if user.ResetTokenExpires.Before(time.Now()) {
+	now := a.clock.Now()
+	if resetToken.ExpiresAt.Before(now) {
 		return errors.New("token expired")
 	}
 
-	hashedPassword, err := a.hasher.Hash(newPassword)
+	if !a.tokenHasher.Compare(resetToken.TokenHash, verifier) {
+		if err := a.resetTokenRepo.IncrementFailedAttempts(ctx, resetToken.ID); err != nil {
+			a.logger.Error("Error recording failed reset token attempt: %v", err)
+		}
+		a.logger.Warn("Reset token verification failed for user: %s", resetToken.UserID)
+		a.audit.Record(ctx, dto.AuditEvent{
+			Type:       models.AuditEventPasswordReset,
+			Outcome:    models.AuditOutcomeFailure,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &resetToken.UserID,
+			IP:         client.IP,
+			Details:    map[string]string{"reason": "invalid token"},
+		})
+		return errors.New("invalid token")
+	}
+
+	if newPassword == "" {
+		return errors.New("new password must not be empty")
+	}
+
+	// Consume the token before changing anything so a concurrent confirmation cannot reuse it
+	consumed, err := a.resetTokenRepo.MarkUsed(ctx, resetToken.ID, now)
 	if err != nil {
-		a.logger.Error("Error generating hashed password for user with ID: %s, %v", user.ID, err)
-		return errors.New("failed to change password due to internal error")
+		a.logger.Error("Error consuming reset token: %v", err)
+		return errors.New("failed to change password")
+	}
+	if !consumed {
+		return errors.New("invalid token")
 	}
 
-	user.Password = hashedPassword
-	user.ResetPasswordToken = ""
-	user.ResetTokenExpires = nil
+	user, err := a.userService.GetUserByID(ctx, resetToken.UserID)
+	if err != nil {
+		a.logger.Error("Error fetching user by ID: %v", err)
+		return errors.New("invalid token")
+	}
 
-	err = a.userService.UpdatePassword(user.ID, newPassword)
+	err = a.userService.UpdatePassword(ctx, user.ID, newPassword)
 	if err != nil {
 		a.logger.Error("Error updating user: %v", err)
 		return errors.New("failed to change password")
 	}
 
-	_, err = a.userService.UpdateUser(*user)
+	// A successful reset proves ownership of the mailbox, so it also lifts a lock set by an email change revert.
+	if user.IsLocked {
+		user.IsLocked = false
+		_, err = a.userService.UpdateUser(ctx, *user)
+		if err != nil {
+			a.logger.Error("Error updating user: %v", err)
+			return errors.New("failed to update user")
+		}
+	}
+
+	err = a.resetTokenRepo.InvalidateAllForUser(ctx, user.ID, now)
 	if err != nil {
-		a.logger.Error("Error updating user: %v", err)
-		return errors.New("failed to update user")
+		a.logger.Error("Error invalidating outstanding reset tokens for user: %s, %v", user.ID, err)
 	}
 
+	metrics.PasswordResetsCompleted.Inc()
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventPasswordReset,
+		Outcome:    models.AuditOutcomeSuccess,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &user.ID,
+		IP:         client.IP,
+	})
 	return nil
 }
 
-func (a *service) ChangePassword(accessToken string, newPassword string) error {
-	_, claims, err := a.parseAndValidateToken(accessToken)
+func (a *service) ChangePassword(ctx context.Context, accessToken string, newPassword string, client dto.ClientInfo) error {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
 	if err != nil {
 		a.logger.Error("Error parsing accessToken: %v", err)
 		return errors.New("invalid accessToken")
@@ -411,40 +940,51 @@ func (a *service) ChangePassword(accessToken string, newPassword string) error {
 		a.logger.Error("Error parsing userID: %v", err)
 		return errors.New("invalid user ID format")
 	}
-	user, err := a.userService.GetUserByID(userID)
+	if _, impersonated := claims["act"]; impersonated {
+		a.logger.Warn("Password change attempted with an impersonation token")
+		a.audit.Record(ctx, dto.AuditEvent{
+			Type:       models.AuditEventPasswordChanged,
+			Outcome:    models.AuditOutcomeDenied,
+			ActorID:    actorFromClaims(claims, userID),
+			TargetType: models.AuditTargetUser,
+			TargetID:   &userID,
+			IP:         client.IP,
+			Details:    map[string]string{"reason": ErrImpersonatedSession.Error()},
+		})
+		return ErrImpersonatedSession
+	}
+	user, err := a.userService.GetUserByID(ctx, userID)
 	if err != nil {
 		a.logger.Error("Error fetching user by email: %v", err)
 		return errors.New("invalid email")
 	}
 
-	hashedPassword, hashErr := a.hasher.Hash(newPassword)
-	if hashErr != nil {
-		a.logger.Error("Error hashing new password: %v", hashErr)
-		return errors.New("failed to hash password")
-	}
-
-	user.Password = hashedPassword
-
-	user.ResetPasswordToken = ""
-	user.ResetTokenExpires = nil
-
-	updateErr := a.userService.UpdatePassword(user.ID, hashedPassword)
+	// UpdatePassword hashes the password itself
+	updateErr := a.userService.UpdatePassword(ctx, user.ID, newPassword)
 	if updateErr != nil {
 		a.logger.Error("Error updating user password: %v", updateErr)
 		return errors.New("failed to update password")
 	}
-	_, err = a.userService.UpdateUser(*user)
+
+	err = a.resetTokenRepo.InvalidateAllForUser(ctx, user.ID, a.clock.Now())
 	if err != nil {
-		a.logger.Error("Error updating user: %v", err)
-		return errors.New("failed to update user")
+		a.logger.Error("Error invalidating outstanding reset tokens for user: %s, %v", user.ID, err)
 	}
 
 	a.logger.Info("Successfully changed password for user: %s", userIDStr)
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventPasswordChanged,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &user.ID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &user.ID,
+		IP:         client.IP,
+	})
 	return nil
 }
 
-func (a *service) GetIdFromToken(accessToken string) (uuid.UUID, error) {
-	_, claims, err := a.parseAndValidateToken(accessToken)
+func (a *service) GetIdFromToken(ctx context.Context, accessToken string) (uuid.UUID, error) {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
 	if err != nil {
 		a.logger.Error("Error parsing accessToken: %v", err)
 		return uuid.UUID{}, errors.New("invalid accessToken")
@@ -462,15 +1002,204 @@ func (a *service) GetIdFromToken(accessToken string) (uuid.UUID, error) {
 	return userID, nil
 }
 
+func (a *service) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) (*models.User, error) {
+	user, err := a.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		a.logger.Error("Error fetching user by ID: %v", err)
+		return nil, errors.New("user not found")
+	}
+
+	if newEmail == "" || newEmail == user.Email {
+		return nil, errors.New("new email must be different from the current one")
+	}
+
+	if existingUser, _ := a.userService.GetUserByEmail(ctx, newEmail); existingUser != nil {
+		a.logger.Warn("Email change requested to an address already in use by user: %s", userID)
+		return nil, errors.New("email already exists")
+	}
+
+	now := a.clock.Now()
+	// Until a confirmed change can no longer be reverted, only the previous address holds a valid revert link
+	if user.PreviousEmail != "" && user.PreviousEmail != user.Email &&
+		user.EmailRevertExpires != nil && now.Before(*user.EmailRevertExpires) {
+		a.logger.Warn("Email change requested for user %s while the previous change can still be reverted", userID)
+		return nil, ErrEmailChangeRevertible
+	}
+
+	changeExpires := now.Add(time.Hour * a.cfg.ExpirationTimeEmailChangeHours)
+	revertExpires := now.Add(time.Hour * a.cfg.ExpirationTimeEmailRevertHours)
+
+	// Like the reset tokens, the tokens are only stored as a keyed hash. The hash is deterministic, so the
+	// confirmation and the revert find the user by the hash of the token they receive.
+	changeToken, err := utils.GenerateRandomToken(emailChangeTokenBytes)
+	if err != nil {
+		a.logger.Error("Error generating email change token: %v", err)
+		return nil, errors.New("failed to generate email change token")
+	}
+	revertToken, err := utils.GenerateRandomToken(emailChangeTokenBytes)
+	if err != nil {
+		a.logger.Error("Error generating email revert token: %v", err)
+		return nil, errors.New("failed to generate email revert token")
+	}
+
+	user.PendingEmail = newEmail
+	user.PreviousEmail = user.Email
+	user.EmailChangeToken = a.tokenHasher.Hash(changeToken)
+	user.EmailChangeExpires = &changeExpires
+	user.EmailRevertToken = a.tokenHasher.Hash(revertToken)
+	user.EmailRevertExpires = &revertExpires
+
+	updatedUser, err := a.userService.UpdateUser(ctx, *user)
+	if err != nil {
+		a.logger.Error("Error updating user: %v", err)
+		return nil, errors.New("failed to update user")
+	}
+
+	// The new address must prove ownership before the change takes effect
+	confirmation := events.EmailChangeRequested{
+		UserID:            user.ID,
+		Email:             newEmail,
+		ConfirmationToken: changeToken,
+		ExpiresAt:         changeExpires,
+	}
+	err = a.sender.Send(ctx, a.cfg.EmailChangeTopic, confirmation)
+	if err != nil {
+		a.logger.Error("Error sending email change confirmation message: %v", err)
+		return nil, errors.New("failed to send email change confirmation")
+	}
+
+	// The current address is told about the change and gets a way to undo it
+	notice := events.EmailChangeNotice{
+		UserID:      user.ID,
+		Email:       user.Email,
+		NewEmail:    newEmail,
+		RevertToken: revertToken,
+		RevertLink:  a.cfg.AppDomain + "/revert-email-change?token=" + revertToken,
+		ExpiresAt:   revertExpires,
+	}
+	err = a.sender.Send(ctx, a.cfg.EmailChangedNoticeTopic, notice)
+	if err != nil {
+		a.logger.Error("Error sending email change notice message: %v", err)
+		return nil, errors.New("failed to send email change notice")
+	}
+
+	a.logger.Info("Email change requested for user: %s", userID)
+	return updatedUser, nil
+}
+
+func (a *service) ConfirmEmailChange(ctx context.Context, token string) error {
+	// An empty token would match every user without a pending change
+	if token == "" {
+		return errors.New("invalid token")
+	}
+
+	user, err := a.userService.GetUserByEmailChangeToken(ctx, a.tokenHasher.Hash(token))
+	if err != nil {
+		a.logger.Error("Error fetching user by email change token: %v", err)
+		return errors.New("invalid token")
+	}
+
+	if user.EmailChangeExpires == nil || user.EmailChangeExpires.Before(a.clock.Now()) {
+		return errors.New("token expired")
+	}
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpires = nil
+
+	_, err = a.userService.UpdateUser(ctx, *user)
+	if err != nil {
+		a.logger.Error("Error updating user: %v", err)
+		return errors.New("failed to change email")
+	}
+
+	a.logger.Info("Email change confirmed for user: %s", user.ID)
+	return nil
+}
+
+func (a *service) RevertEmailChange(ctx context.Context, token string, client dto.ClientInfo) error {
+	// An empty token would match every user without a pending change
+	if token == "" {
+		return errors.New("invalid token")
+	}
+
+	user, err := a.userService.GetUserByEmailRevertToken(ctx, a.tokenHasher.Hash(token))
+	if err != nil {
+		a.logger.Error("Error fetching user by email revert token: %v", err)
+		return errors.New("invalid token")
+	}
+
+	now := a.clock.Now()
+	if user.EmailRevertExpires == nil || user.EmailRevertExpires.Before(now) {
+		return errors.New("token expired")
+	}
+
+	// Restore the original address and lock the account until its owner resets the password
+	user.Email = user.PreviousEmail
+	user.PreviousEmail = ""
+	user.PendingEmail = ""
+	user.EmailChangeToken = ""
+	user.EmailChangeExpires = nil
+	user.EmailRevertToken = ""
+	user.EmailRevertExpires = nil
+
+	_, err = a.userService.LockUser(ctx, *user, lockReasonEmailReverted)
+	if err != nil {
+		a.logger.Error("Error updating user: %v", err)
+		return errors.New("failed to revert email change")
+	}
+
+	err = a.blockListService.RevokeUserSessions(ctx, user.ID.String(), now, a.cfg.RefreshTokenDurationDays)
+	if err != nil {
+		a.logger.Error("Failed to revoke sessions for user: %s, Error: %v", user.ID, err)
+		return errors.New("failed to revoke sessions")
+	}
+	a.sendSessionRevoked(ctx, user.ID, lockReasonEmailReverted, now)
+
+	a.logger.Warn("Email change reverted, account locked and sessions revoked for user: %s", user.ID)
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventAccountLocked,
+		Outcome:    models.AuditOutcomeSuccess,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &user.ID,
+		IP:         client.IP,
+		Details:    map[string]string{"reason": lockReasonEmailReverted},
+	})
+	return nil
+}
+
+// isSessionRevoked reports whether the token was issued before the user's sessions were revoked.
+func (a *service) isSessionRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return false, errors.New("user ID not found in the token")
+	}
+	revokedAt, err := a.blockListService.GetSessionsRevokedAt(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if revokedAt == nil {
+		return false, nil
+	}
+	issuedAt, ok := claims["iat"].(float64)
+	if !ok {
+		return true, nil
+	}
+	return int64(issuedAt) <= revokedAt.Unix(), nil
+}
+
 func (a *service) generateAccessToken(userID uuid.UUID, refreshUUID string, refreshExp int64) (string, int64, error) {
-	expires := time.Now().Add(time.Minute * config.AuthenticationConfig.AccessTokenDurationMinutes).Unix()
+	now := a.clock.Now()
+	expires := now.Add(time.Minute * a.cfg.AccessTokenDurationMinutes).Unix()
 
 	claims := jwt.MapClaims{}
 	claims["user_id"] = userID.String()
-	claims["access_uuid"] = uuid.New().String()
+	claims["access_uuid"] = a.ids.NewID().String()
 	claims["refresh_uuid"] = refreshUUID
 	claims["refresh_exp"] = refreshExp
 	claims["exp"] = expires
+	claims["iat"] = now.Unix()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	accessToken, err := token.SignedString([]byte(a.jwtSecret))
@@ -478,21 +1207,26 @@ func (a *service) generateAccessToken(userID uuid.UUID, refreshUUID string, refr
 }
 
 func (a *service) generateRefreshToken(userID uuid.UUID) (string, string, int64, error) {
-	refreshUUID := uuid.New().String()
-	expires := time.Now().Add(config.AuthenticationConfig.RefreshTokenDurationDays).Unix()
+	refreshUUID := a.ids.NewID().String()
+	now := a.clock.Now()
+	expires := now.Add(a.cfg.RefreshTokenDurationDays).Unix()
 
 	claims := jwt.MapClaims{}
 	claims["refresh_uuid"] = refreshUUID
 	claims["user_id"] = userID.String()
 	claims["exp"] = expires
+	claims["iat"] = now.Unix()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	refreshToken, err := token.SignedString([]byte(a.jwtSecret))
 	return refreshToken, refreshUUID, expires, err
 }
 
-func (a *service) parseAndValidateToken(tokenString string) (*jwt.Token, jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// parseAndValidateToken checks the signature of the token and its time claims. The time claims are checked
+// against the clock of the service, so the parser skips its own check, which uses the time of the machine.
+func (a *service) parseAndValidateToken(ctx context.Context, tokenString string) (*jwt.Token, jwt.MapClaims, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			a.logger.Error("Unexpected signing method: %v", token.Header["alg"])
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -508,6 +1242,18 @@ func (a *service) parseAndValidateToken(tokenString string) (*jwt.Token, jwt.Map
 	if !ok || !token.Valid {
 		return nil, nil, errors.New("invalid token")
 	}
+	now := a.clock.Now()
+	if !claims.VerifyExpiresAt(now.Unix(), false) {
+		return nil, nil, errors.New("token is expired")
+	}
+	// Another instance may have issued the token with a clock that runs slightly ahead of ours
+	skewed := now.Add(a.cfg.TokenClockSkew).Unix()
+	if !claims.VerifyIssuedAt(skewed, false) {
+		return nil, nil, errors.New("token used before issued")
+	}
+	if !claims.VerifyNotBefore(skewed, false) {
+		return nil, nil, errors.New("token is not valid yet")
+	}
 
 	return token, claims, nil
 }
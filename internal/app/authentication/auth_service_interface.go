@@ -2,18 +2,32 @@ package authentication
 
 import (
 	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
 	"github.com/google/uuid"
-	"time"
 )
 
 type IService interface {
-	Register(userDTO dto.UserDTO) (*dto.UserResponse, error)
-	Login(email, password string) (*dto.TokenDetails, error)
-	Logout(accessToken string) error
-	RefreshToken(refreshToken string) (*dto.TokenDetails, error)
-	IsUserAuthenticated(accessToken string) (bool, error)
-	RequestPasswordReset(email string) (string, time.Time, error)
-	ConfirmPasswordReset(token, newPassword string) error
-	ChangePassword(accessToken string, newPassword string) error
-	GetIdFromToken(accessToken string) (uuid.UUID, error)
+	Register(ctx context.Context, userDTO dto.UserDTO, client dto.ClientInfo) (*dto.UserResponse, error)
+	RegisterInvited(ctx context.Context, userDTO dto.UserDTO, role string, organizationID *uuid.UUID, client dto.ClientInfo) (*dto.UserResponse, error)
+	Login(ctx context.Context, email, password string, client dto.ClientInfo) (*dto.TokenDetails, error)
+	// CompleteLoginChallenge issues the tokens of a login that returned a *StepUpRequiredError.
+	CompleteLoginChallenge(ctx context.Context, challengeID uuid.UUID, code string, client dto.ClientInfo) (*dto.TokenDetails, error)
+	Logout(ctx context.Context, accessToken string, client dto.ClientInfo) error
+	// RevokeSessions ends every session of the user, for logouts forced by other services.
+	RevokeSessions(ctx context.Context, userID uuid.UUID, reason string) error
+	RefreshToken(ctx context.Context, refreshToken string) (*dto.TokenDetails, error)
+	IsUserAuthenticated(ctx context.Context, accessToken string) (bool, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string, client dto.ClientInfo) error
+	ChangePassword(ctx context.Context, accessToken string, newPassword string, client dto.ClientInfo) error
+	GetIdFromToken(ctx context.Context, accessToken string) (uuid.UUID, error)
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) (*models.User, error)
+	ConfirmEmailChange(ctx context.Context, token string) error
+	RevertEmailChange(ctx context.Context, token string, client dto.ClientInfo) error
+	StartImpersonation(ctx context.Context, actorID, targetID uuid.UUID, reason string, client dto.ClientInfo) (*dto.ImpersonationDetails, error)
+	StopImpersonation(ctx context.Context, accessToken string, client dto.ClientInfo) error
+	GetImpersonatorFromToken(ctx context.Context, accessToken string) (*uuid.UUID, error)
+	GetImpersonationSessions(ctx context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error)
 }
@@ -1 +1,953 @@
 package authentication
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/risk"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/app/utils/utils_mock"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestConfig loads the authentication configuration of the tests from the environment.
+func newTestConfig(t *testing.T) *config.AuthenticationConfig {
+	t.Helper()
+	env := map[string]string{
+		"LOGGER_TOPIC":                       "logger",
+		"MAIL_TOPIC":                         "mail",
+		"BROKERS_ADDR":                       "localhost:9092",
+		"DB_HOST":                            "localhost",
+		"DB_NAME":                            "idp",
+		"DB_PORT":                            "5432",
+		"PASSWORD_RESET_TOPIC":               "password-reset",
+		"ACCOUNT_BLOCKED_TOPIC":              "account-blocked",
+		"ACCOUNT_CREATED_TOPIC":              "account-created",
+		"BLOCKING_TIME_EXPONENTIATION_BASIS": "2",
+		"MAX_LOGIN_ATTEMPTS_BEFORE_BLOCK":    "5",
+		"MAX_RESET_TOKEN_ATTEMPTS":           "3",
+		"JWT_SECRET":                         "secret",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Authentication
+}
+
+type resetTestDeps struct {
+	userService       *service_mock.MockUserService
+	resetTokenRepo    *repository_mock.MockPasswordResetTokenRepository
+	challengeRepo     *repository_mock.MockLoginChallengeRepository
+	impersonationRepo *repository_mock.MockImpersonationSessionRepository
+	loginHistory      *service_mock.MockLoginHistoryService
+	audit             *service_mock.MockAuditRecorder
+	sender            *service_mock.MockMessageSender
+	blockList         *service_mock.MockBlockListService
+	tokenHasher       utils.TokenHasher
+	clock             *utils_mock.FakeClock
+	service           IService
+	cfg               *config.AuthenticationConfig
+}
+
+func newResetTestDeps(t *testing.T) *resetTestDeps {
+	cfg := newTestConfig(t)
+	deps := &resetTestDeps{
+		userService:       new(service_mock.MockUserService),
+		resetTokenRepo:    new(repository_mock.MockPasswordResetTokenRepository),
+		challengeRepo:     new(repository_mock.MockLoginChallengeRepository),
+		impersonationRepo: new(repository_mock.MockImpersonationSessionRepository),
+		loginHistory:      service_mock.NewPermissiveMockLoginHistoryService(),
+		audit:             service_mock.NewPermissiveMockAuditRecorder(),
+		sender:            new(service_mock.MockMessageSender),
+		blockList:         new(service_mock.MockBlockListService),
+		tokenHasher:       utils.NewHmacTokenHasher("test-key"),
+		clock:             utils_mock.NewFakeClock(time.Now()),
+		cfg:               cfg,
+	}
+	deps.service = NewService(deps.userService, deps.resetTokenRepo, deps.challengeRepo, deps.impersonationRepo, deps.loginHistory,
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), deps.audit, new(utils_mock.MockHasher),
+		deps.tokenHasher, deps.sender, deps.blockList, service_mock.NewPermissiveMockLogger(), cfg, deps.clock,
+		utils_mock.NewSequentialIDGenerator(), new(sync.WaitGroup))
+	deps.service.(*service).dispatch = func(work func()) { work() }
+	return deps
+}
+
+func TestRequestPasswordReset_StoresOnlyHashedToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	deps.userService.On("GetUserByEmail", user.Email).Return(user, nil)
+
+	var stored *models.PasswordResetToken
+	deps.resetTokenRepo.On("Create", mock.AnythingOfType("*models.PasswordResetToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*models.PasswordResetToken)
+	}).Return(&models.PasswordResetToken{}, nil)
+
+	var sentToken string
+	deps.sender.On("Send", deps.cfg.PasswordResetTopic, mock.Anything).Run(func(args mock.Arguments) {
+		sentToken = args.Get(1).(events.PasswordResetRequested).ResetToken
+	}).Return(nil)
+
+	// Act
+	err := deps.service.RequestPasswordReset(context.Background(), user.Email)
+
+	// Assert
+	assert.NoError(t, err)
+	selector, verifier, found := strings.Cut(sentToken, resetTokenSeparator)
+	assert.True(t, found)
+	assert.Equal(t, stored.ID.String(), selector)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.NotContains(t, stored.TokenHash, verifier)
+	assert.True(t, deps.tokenHasher.Compare(stored.TokenHash, verifier))
+	assert.True(t, stored.ExpiresAt.After(time.Now()))
+}
+
+func TestRequestPasswordReset_TokenExpiresAfterConfiguredHours(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	deps.userService.On("GetUserByEmail", user.Email).Return(user, nil)
+	var stored *models.PasswordResetToken
+	deps.resetTokenRepo.On("Create", mock.AnythingOfType("*models.PasswordResetToken")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*models.PasswordResetToken)
+	}).Return(&models.PasswordResetToken{}, nil)
+	deps.sender.On("Send", deps.cfg.PasswordResetTopic, mock.Anything).Return(nil)
+
+	// Act
+	err := deps.service.RequestPasswordReset(context.Background(), user.Email)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, utils_mock.SequentialID(1), stored.ID)
+	assert.Equal(t, deps.clock.Now().Add(24*time.Hour), stored.ExpiresAt)
+}
+
+func TestConfirmPasswordReset_Success(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+	deps.resetTokenRepo.On("MarkUsed", resetToken.ID, mock.AnythingOfType("time.Time")).Return(true, nil)
+	deps.resetTokenRepo.On("InvalidateAllForUser", user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	deps.userService.On("UpdatePassword", user.ID, "new-password").Return(nil)
+
+	// Act
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".verifier", "new-password", dto.ClientInfo{IP: "192.0.2.1"})
+
+	// Assert
+	assert.NoError(t, err)
+	deps.resetTokenRepo.AssertExpectations(t)
+	deps.userService.AssertExpectations(t)
+	events := deps.audit.Recorded(models.AuditEventPasswordReset)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.AuditOutcomeSuccess, events[0].Outcome)
+		assert.Equal(t, &user.ID, events[0].TargetID)
+		assert.Equal(t, "192.0.2.1", events[0].IP)
+	}
+}
+
+func TestConfirmPasswordReset_UsedTokenIsRejected(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	usedAt := time.Now().Add(-time.Minute)
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		UsedAt:    &usedAt,
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+
+	// Act
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".verifier", "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "invalid token")
+	deps.userService.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func TestConfirmPasswordReset_WrongVerifierCountsAsFailedAttempt(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+	deps.resetTokenRepo.On("IncrementFailedAttempts", resetToken.ID).Return(nil)
+
+	// Act
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".guess", "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "invalid token")
+	deps.resetTokenRepo.AssertCalled(t, "IncrementFailedAttempts", resetToken.ID)
+	deps.resetTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
+
+func TestConfirmPasswordReset_TooManyFailedAttemptsInvalidatesToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	resetToken := &models.PasswordResetToken{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		TokenHash:      deps.tokenHasher.Hash("verifier"),
+		ExpiresAt:      time.Now().Add(time.Hour),
+		FailedAttempts: deps.cfg.MaxResetTokenAttempts,
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+
+	// Act
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".verifier", "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "invalid token")
+	deps.resetTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
+
+func TestConfirmPasswordReset_ExpiredToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+
+	// Act
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".verifier", "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "token expired")
+}
+
+func TestConfirmPasswordReset_TokenExpiresWithTheClock(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	resetToken := &models.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: deps.clock.Now().Add(time.Hour),
+	}
+	deps.resetTokenRepo.On("FindByID", resetToken.ID).Return(resetToken, nil)
+
+	// Act
+	deps.clock.Advance(time.Hour + time.Second)
+	err := deps.service.ConfirmPasswordReset(context.Background(), resetToken.ID.String()+".verifier", "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "token expired")
+	deps.resetTokenRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
+
+// lockoutUserService keeps the login counters behind a mutex, the way the database serializes
+// the atomic updates, and leaves every other method to the embedded mock.
+type lockoutUserService struct {
+	*service_mock.MockUserService
+	mu   sync.Mutex
+	user models.User
+	// blocks counts the blocks set, each of which announces the block through the outbox
+	blocks int
+}
+
+func (s *lockoutUserService) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := s.user
+	return &user, nil
+}
+
+func (s *lockoutUserService) IncrementFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user.FailedAttempts++
+	s.user.LastAttempt = &attemptAt
+	return s.user.FailedAttempts, nil
+}
+
+func (s *lockoutUserService) ResetFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user.FailedAttempts = 0
+	return nil
+}
+
+func (s *lockoutUserService) BlockUntil(_ context.Context, id uuid.UUID, blockedUntil time.Time, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.user.IsBlocked && s.user.BlockedUntil != nil && !s.user.BlockedUntil.Before(blockedUntil) {
+		return false, nil
+	}
+	s.user.IsBlocked = true
+	s.user.BlockedUntil = &blockedUntil
+	s.blocks++
+	return true, nil
+}
+
+// countingHasher counts comparisons against one specific hash, i.e. real password checks.
+type countingHasher struct {
+	utils.PasswordHasher
+	target      string
+	comparisons int32
+}
+
+func (h *countingHasher) Compare(hashedPassword, password string) error {
+	if hashedPassword == h.target {
+		atomic.AddInt32(&h.comparisons, 1)
+	}
+	return h.PasswordHasher.Compare(hashedPassword, password)
+}
+
+// requestEmailChange changes the email of the user to newEmail and returns the user as stored, with the
+// confirmation and revert token that were sent.
+func (d *resetTestDeps) requestEmailChange(t *testing.T, user *models.User, newEmail string) (*models.User, string, string) {
+	t.Helper()
+	d.userService.On("GetUserByID", user.ID).Return(user, nil).Once()
+	d.userService.On("GetUserByEmail", newEmail).Return((*models.User)(nil), errors.New("user not found")).Once()
+	stored := &models.User{}
+	d.userService.On("UpdateUser", mock.AnythingOfType("models.User")).Run(func(args mock.Arguments) {
+		*stored = args.Get(0).(models.User)
+	}).Return(stored, nil).Once()
+	var confirmationToken, revertToken string
+	d.sender.On("Send", d.cfg.EmailChangeTopic, mock.Anything).Run(func(args mock.Arguments) {
+		confirmationToken = args.Get(1).(events.EmailChangeRequested).ConfirmationToken
+	}).Return(nil).Once()
+	d.sender.On("Send", d.cfg.EmailChangedNoticeTopic, mock.Anything).Run(func(args mock.Arguments) {
+		revertToken = args.Get(1).(events.EmailChangeNotice).RevertToken
+	}).Return(nil).Once()
+
+	_, err := d.service.RequestEmailChange(context.Background(), user.ID, newEmail)
+	assert.NoError(t, err)
+	return stored, confirmationToken, revertToken
+}
+
+func TestRequestEmailChange_StoresOnlyHashedTokens(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "owner@example.com"}
+
+	// Act
+	stored, confirmationToken, revertToken := deps.requestEmailChange(t, user, "new@example.com")
+
+	// Assert
+	assert.Equal(t, "owner@example.com", stored.Email)
+	assert.Equal(t, "new@example.com", stored.PendingEmail)
+	assert.Equal(t, "owner@example.com", stored.PreviousEmail)
+	assert.NotEmpty(t, confirmationToken)
+	assert.NotEmpty(t, revertToken)
+	assert.NotEqual(t, confirmationToken, stored.EmailChangeToken)
+	assert.NotEqual(t, revertToken, stored.EmailRevertToken)
+	assert.True(t, deps.tokenHasher.Compare(stored.EmailChangeToken, confirmationToken))
+	assert.True(t, deps.tokenHasher.Compare(stored.EmailRevertToken, revertToken))
+	assert.Equal(t, deps.clock.Now().Add(24*time.Hour), *stored.EmailChangeExpires)
+	assert.Equal(t, deps.clock.Now().Add(72*time.Hour), *stored.EmailRevertExpires)
+}
+
+func TestConfirmEmailChange_AppliesPendingEmail(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, confirmationToken, _ := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "new@example.com")
+	deps.userService.On("GetUserByEmailChangeToken", stored.EmailChangeToken).Return(stored, nil)
+	var confirmed models.User
+	deps.userService.On("UpdateUser", mock.AnythingOfType("models.User")).Run(func(args mock.Arguments) {
+		confirmed = args.Get(0).(models.User)
+	}).Return(&confirmed, nil)
+
+	// Act
+	err := deps.service.ConfirmEmailChange(context.Background(), confirmationToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "new@example.com", confirmed.Email)
+	assert.Empty(t, confirmed.PendingEmail)
+	assert.Empty(t, confirmed.EmailChangeToken)
+	// The previous address can still revert the change
+	assert.Equal(t, "owner@example.com", confirmed.PreviousEmail)
+	assert.Equal(t, stored.EmailRevertToken, confirmed.EmailRevertToken)
+}
+
+func TestConfirmEmailChange_ExpiredToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, confirmationToken, _ := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "new@example.com")
+	deps.userService.On("GetUserByEmailChangeToken", stored.EmailChangeToken).Return(stored, nil)
+
+	// Act
+	deps.clock.Advance(24*time.Hour + time.Second)
+	err := deps.service.ConfirmEmailChange(context.Background(), confirmationToken)
+
+	// Assert
+	assert.EqualError(t, err, "token expired")
+}
+
+func TestConfirmEmailChange_RejectsStoredHashAsToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, _, _ := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "new@example.com")
+	deps.userService.On("GetUserByEmailChangeToken", stored.EmailChangeToken).Return(stored, nil)
+	deps.userService.On("GetUserByEmailChangeToken", mock.Anything).Return((*models.User)(nil), errors.New("user not found"))
+
+	// Act
+	// Someone who reads the stored hash cannot use it as the token
+	err := deps.service.ConfirmEmailChange(context.Background(), stored.EmailChangeToken)
+
+	// Assert
+	assert.EqualError(t, err, "invalid token")
+}
+
+func TestRevertEmailChange_RestoresPreviousEmailAndLocksAccount(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, _, revertToken := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "attacker@example.com")
+	stored.Email = stored.PendingEmail
+	deps.userService.On("GetUserByEmailRevertToken", stored.EmailRevertToken).Return(stored, nil)
+	var locked models.User
+	deps.userService.On("LockUser", mock.AnythingOfType("models.User"), lockReasonEmailReverted).Run(func(args mock.Arguments) {
+		locked = args.Get(0).(models.User)
+	}).Return(&locked, nil)
+	deps.blockList.On("RevokeUserSessions", stored.ID.String(), deps.clock.Now(), deps.cfg.RefreshTokenDurationDays).Return(nil)
+	deps.sender.On("Send", deps.cfg.SessionRevokedTopic, mock.Anything).Return(nil)
+
+	// Act
+	err := deps.service.RevertEmailChange(context.Background(), revertToken, dto.ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "owner@example.com", locked.Email)
+	assert.Empty(t, locked.EmailRevertToken)
+	deps.blockList.AssertExpectations(t)
+}
+
+func TestRevertEmailChange_ExpiredToken(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, _, revertToken := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "attacker@example.com")
+	deps.userService.On("GetUserByEmailRevertToken", stored.EmailRevertToken).Return(stored, nil)
+
+	// Act
+	deps.clock.Advance(72*time.Hour + time.Second)
+	err := deps.service.RevertEmailChange(context.Background(), revertToken, dto.ClientInfo{})
+
+	// Assert
+	assert.EqualError(t, err, "token expired")
+	deps.userService.AssertNotCalled(t, "LockUser", mock.Anything, mock.Anything)
+}
+
+func TestRequestEmailChange_ChainedChangeKeepsRevertLinkOfOwner(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	stored, _, _ := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "attacker@example.com")
+	// The attacker confirmed the change from their own address
+	stored.Email, stored.PendingEmail, stored.EmailChangeToken, stored.EmailChangeExpires = stored.PendingEmail, "", "", nil
+	deps.userService.On("GetUserByID", stored.ID).Return(stored, nil).Once()
+	deps.userService.On("GetUserByEmail", "other@example.com").Return((*models.User)(nil), errors.New("user not found")).Once()
+
+	// Act
+	_, chainedErr := deps.service.RequestEmailChange(context.Background(), stored.ID, "other@example.com")
+	deps.clock.Advance(72*time.Hour + time.Second)
+	afterWindow, _, _ := deps.requestEmailChange(t, stored, "other@example.com")
+
+	// Assert
+	assert.ErrorIs(t, chainedErr, ErrEmailChangeRevertible)
+	assert.Equal(t, "attacker@example.com", afterWindow.PreviousEmail)
+}
+
+func TestRequestEmailChange_UnconfirmedChangeCanBeReplaced(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	first, firstConfirmation, _ := deps.requestEmailChange(t, &models.User{ID: uuid.New(), Email: "owner@example.com"}, "typo@example.con")
+
+	// Act
+	second, secondConfirmation, secondRevert := deps.requestEmailChange(t, first, "new@example.com")
+
+	// Assert
+	assert.Equal(t, "new@example.com", second.PendingEmail)
+	assert.Equal(t, "owner@example.com", second.PreviousEmail)
+	assert.False(t, deps.tokenHasher.Compare(second.EmailChangeToken, firstConfirmation))
+	assert.True(t, deps.tokenHasher.Compare(second.EmailChangeToken, secondConfirmation))
+	assert.True(t, deps.tokenHasher.Compare(second.EmailRevertToken, secondRevert))
+}
+
+func TestLogin_ConcurrentFailedAttemptsHoldLockout(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	bcryptHasher := utils.NewBcryptHasher(bcrypt.MinCost)
+	hashedPassword, err := bcryptHasher.Hash("correct-password")
+	assert.NoError(t, err)
+	hasher := &countingHasher{PasswordHasher: bcryptHasher, target: hashedPassword}
+
+	userService := &lockoutUserService{
+		MockUserService: new(service_mock.MockUserService),
+		user:            models.User{ID: uuid.New(), Email: "victim@example.com", Password: hashedPassword},
+	}
+	sender := new(service_mock.MockMessageSender)
+	auditRecorder := service_mock.NewPermissiveMockAuditRecorder()
+	authService := NewService(userService, new(repository_mock.MockPasswordResetTokenRepository), new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository),
+		service_mock.NewPermissiveMockLoginHistoryService(), risk.NewAllowAllAssessor(),
+		service_mock.NewPermissiveMockIPRuleService(), auditRecorder, hasher, utils.NewHmacTokenHasher("test-key"),
+		sender, new(service_mock.MockBlockListService), service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	const attackers = 50
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attackers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, _ = authService.Login(context.Background(), "victim@example.com", "wrong-password", dto.ClientInfo{})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	_, loginErr := authService.Login(context.Background(), "victim@example.com", "correct-password", dto.ClientInfo{})
+
+	// Assert
+	maxAttempts := cfg.MaxLoginAttemptsBeforeBlock
+	assert.LessOrEqual(t, int(atomic.LoadInt32(&hasher.comparisons)), maxAttempts,
+		"more passwords were checked than the lockout allows")
+	assert.True(t, userService.user.IsBlocked)
+	assert.Error(t, loginErr)
+	assert.Equal(t, 1, userService.blocks, "the block was announced more than once")
+	sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	assert.Len(t, auditRecorder.Recorded(models.AuditEventAccountLocked), 1)
+	assert.Len(t, auditRecorder.Recorded(models.AuditEventLoginFailed), attackers+1)
+}
+
+func TestLogin_AlertsOnNewDevice(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Compare", "hashed", "password").Return(nil)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Password: "hashed"}
+	userService := new(service_mock.MockUserService)
+	userService.On("GetUserByEmail", user.Email).Return(user, nil)
+	userService.On("IncrementFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(1, nil)
+	userService.On("ResetFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	client := dto.ClientInfo{IP: "203.0.113.7", UserAgent: "NewBrowser/1.0", AcceptLanguage: "en"}
+	loginHistory := new(service_mock.MockLoginHistoryService)
+	loginHistory.On("RecordAttempt", &user.ID, models.LoginOutcomeSuccess, client).
+		Return(&models.LoginAttempt{IP: client.IP, UserAgent: client.UserAgent, Country: "NL", NewDevice: true}, nil)
+	sender := new(service_mock.MockMessageSender)
+	sender.On("Send", cfg.NewDeviceLoginTopic, mock.Anything).Return(nil)
+	auditRecorder := service_mock.NewPermissiveMockAuditRecorder()
+
+	authService := NewService(userService, new(repository_mock.MockPasswordResetTokenRepository), new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository), loginHistory,
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), auditRecorder, hasher,
+		utils.NewHmacTokenHasher("test-key"), sender, new(service_mock.MockBlockListService),
+		service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	tokens, err := authService.Login(context.Background(), user.Email, "password", client)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, tokens)
+	loginHistory.AssertExpectations(t)
+	sender.AssertCalled(t, "Send", cfg.NewDeviceLoginTopic, mock.Anything)
+	events := auditRecorder.Recorded(models.AuditEventLoginSucceeded)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, &user.ID, events[0].ActorID)
+		assert.Equal(t, client.IP, events[0].IP)
+	}
+}
+
+func TestLogin_RecordsFailedAttemptWithoutAlert(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	userService := new(service_mock.MockUserService)
+	userService.On("GetUserByEmail", "unknown@example.com").Return((*models.User)(nil), errors.New("user not found"))
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Hash", mock.Anything).Return("dummy", nil)
+	hasher.On("Compare", mock.Anything, mock.Anything).Return(errors.New("mismatch"))
+
+	client := dto.ClientInfo{IP: "203.0.113.7"}
+	loginHistory := new(service_mock.MockLoginHistoryService)
+	loginHistory.On("RecordAttempt", (*uuid.UUID)(nil), models.LoginOutcomeUnknownUser, client).
+		Return(&models.LoginAttempt{}, nil)
+	sender := new(service_mock.MockMessageSender)
+	auditRecorder := service_mock.NewPermissiveMockAuditRecorder()
+
+	authService := NewService(userService, new(repository_mock.MockPasswordResetTokenRepository), new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository), loginHistory,
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), auditRecorder, hasher,
+		utils.NewHmacTokenHasher("test-key"), sender, new(service_mock.MockBlockListService),
+		service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	_, err := authService.Login(context.Background(), "unknown@example.com", "password", client)
+
+	// Assert
+	assert.Error(t, err)
+	loginHistory.AssertExpectations(t)
+	sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	events := auditRecorder.Recorded(models.AuditEventLoginFailed)
+	if assert.Len(t, events, 1) {
+		assert.Nil(t, events[0].TargetID)
+		assert.Equal(t, client.IP, events[0].IP)
+		assert.Equal(t, "unknown@example.com", events[0].Details["email"])
+		assert.Equal(t, models.LoginOutcomeUnknownUser, events[0].Details["reason"])
+	}
+}
+
+func TestLogin_RiskAssessmentRequiresStepUp(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Compare", "hashed", "password").Return(nil)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Password: "hashed"}
+	userService := new(service_mock.MockUserService)
+	userService.On("GetUserByEmail", user.Email).Return(user, nil)
+	userService.On("IncrementFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(1, nil)
+	userService.On("ResetFailedAttempts", user.ID, mock.AnythingOfType("time.Time")).Return(nil)
+
+	client := dto.ClientInfo{IP: "198.51.100.23"}
+	loginHistory := new(service_mock.MockLoginHistoryService)
+	loginHistory.On("RecordAttempt", &user.ID, models.LoginOutcomeStepUpRequired, client).Return(&models.LoginAttempt{}, nil)
+	logger := service_mock.NewPermissiveMockLogger()
+	assessor := risk.NewAssessor(50, 90, logger, riskSignal{score: 60})
+	challengeRepo := new(repository_mock.MockLoginChallengeRepository)
+	var stored *models.LoginChallenge
+	challengeRepo.On("Create", mock.AnythingOfType("*models.LoginChallenge")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*models.LoginChallenge)
+	}).Return(&models.LoginChallenge{}, nil)
+	sender := new(service_mock.MockMessageSender)
+	var sent events.LoginChallengeRequested
+	sender.On("Send", cfg.LoginChallengeTopic, mock.AnythingOfType("events.LoginChallengeRequested")).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(events.LoginChallengeRequested)
+	}).Return(nil)
+	tokenHasher := utils.NewHmacTokenHasher("test-key")
+
+	authService := NewService(userService, new(repository_mock.MockPasswordResetTokenRepository), challengeRepo,
+		new(repository_mock.MockImpersonationSessionRepository), loginHistory,
+		assessor, service_mock.NewPermissiveMockIPRuleService(), service_mock.NewPermissiveMockAuditRecorder(), hasher,
+		tokenHasher, sender, new(service_mock.MockBlockListService), logger, cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	tokens, err := authService.Login(context.Background(), user.Email, "password", client)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrStepUpRequired)
+	assert.Nil(t, tokens)
+	loginHistory.AssertExpectations(t)
+	var stepUp *StepUpRequiredError
+	if assert.ErrorAs(t, err, &stepUp) && assert.NotNil(t, stored) {
+		assert.Equal(t, stored.ID, stepUp.ChallengeID)
+		assert.Equal(t, stored.ExpiresAt, stepUp.ExpiresAt)
+		assert.Equal(t, user.ID, stored.UserID)
+		assert.Equal(t, user.Email, sent.Email)
+		assert.Regexp(t, "^[0-9]{6}$", sent.Code)
+		assert.NotContains(t, stored.CodeHash, sent.Code)
+		assert.True(t, tokenHasher.Compare(stored.CodeHash, sent.Code))
+	}
+}
+
+func newLoginChallenge(deps *resetTestDeps, userID uuid.UUID, code string) *models.LoginChallenge {
+	challenge := &models.LoginChallenge{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CodeHash:  deps.tokenHasher.Hash(code),
+		ExpiresAt: deps.clock.Now().Add(10 * time.Minute),
+	}
+	deps.challengeRepo.On("FindByID", challenge.ID).Return(challenge, nil)
+	return challenge
+}
+
+func TestCompleteLoginChallenge_IssuesTokensForTheCode(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	challenge := newLoginChallenge(deps, user.ID, "123456")
+	deps.challengeRepo.On("MarkCompleted", challenge.ID, deps.clock.Now()).Return(true, nil)
+
+	// Act
+	tokens, err := deps.service.CompleteLoginChallenge(context.Background(), challenge.ID, "123456", dto.ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, tokens) {
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEmpty(t, tokens.RefreshToken)
+	}
+	deps.challengeRepo.AssertExpectations(t)
+}
+
+func TestCompleteLoginChallenge_WrongCodeCountsAgainstTheChallenge(t *testing.T) {
+	// Arrange
+	deps := newResetTestDeps(t)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	challenge := newLoginChallenge(deps, user.ID, "123456")
+	deps.challengeRepo.On("IncrementFailedAttempts", challenge.ID).Return(nil)
+
+	// Act
+	tokens, err := deps.service.CompleteLoginChallenge(context.Background(), challenge.ID, "654321", dto.ClientInfo{})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, tokens)
+	deps.challengeRepo.AssertCalled(t, "IncrementFailedAttempts", challenge.ID)
+	deps.challengeRepo.AssertNotCalled(t, "MarkCompleted", mock.Anything, mock.Anything)
+	events := deps.audit.Recorded(models.AuditEventLoginFailed)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.LoginOutcomeStepUpFailed, events[0].Details["reason"])
+	}
+}
+
+func TestCompleteLoginChallenge_RejectsSpentChallenges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(challenge *models.LoginChallenge, deps *resetTestDeps)
+	}{
+		{
+			name: "completed",
+			modify: func(challenge *models.LoginChallenge, deps *resetTestDeps) {
+				completedAt := deps.clock.Now()
+				challenge.CompletedAt = &completedAt
+			},
+		},
+		{
+			name: "too many attempts",
+			modify: func(challenge *models.LoginChallenge, deps *resetTestDeps) {
+				challenge.FailedAttempts = deps.cfg.MaxLoginChallengeAttempts
+			},
+		},
+		{
+			name: "expired",
+			modify: func(challenge *models.LoginChallenge, deps *resetTestDeps) {
+				challenge.ExpiresAt = deps.clock.Now().Add(-time.Second)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			deps := newResetTestDeps(t)
+			challenge := newLoginChallenge(deps, uuid.New(), "123456")
+			tt.modify(challenge, deps)
+
+			// Act
+			tokens, err := deps.service.CompleteLoginChallenge(context.Background(), challenge.ID, "123456", dto.ClientInfo{})
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, tokens)
+			deps.userService.AssertNotCalled(t, "GetUserByID", mock.Anything)
+			deps.challengeRepo.AssertNotCalled(t, "MarkCompleted", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+type riskSignal struct {
+	score int
+}
+
+func (s riskSignal) Name() string {
+	return "test_signal"
+}
+
+func (s riskSignal) Score(context.Context, risk.Request) (int, error) {
+	return s.score, nil
+}
+
+func TestLogin_RejectsAddressOutsideOrganizationRules(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	organizationID := uuid.New()
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Password: "hashed", OrganizationID: &organizationID}
+	userService := new(service_mock.MockUserService)
+	userService.On("GetUserByEmail", user.Email).Return(user, nil)
+	hasher := new(utils_mock.MockHasher)
+	hasher.On("Hash", mock.Anything).Return("dummy", nil)
+	hasher.On("Compare", mock.Anything, mock.Anything).Return(errors.New("mismatch"))
+
+	client := dto.ClientInfo{IP: "192.0.2.1"}
+	ipRules := new(service_mock.MockIPRuleService)
+	ipRules.On("IsAllowed", client.IP, models.IPRuleScopeOrganization, &organizationID).Return(false, nil)
+	loginHistory := new(service_mock.MockLoginHistoryService)
+	loginHistory.On("RecordAttempt", &user.ID, models.LoginOutcomeIPDenied, client).Return(&models.LoginAttempt{}, nil)
+
+	authService := NewService(userService, new(repository_mock.MockPasswordResetTokenRepository), new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository), loginHistory,
+		risk.NewAllowAllAssessor(), ipRules, service_mock.NewPermissiveMockAuditRecorder(), hasher,
+		utils.NewHmacTokenHasher("test-key"), new(service_mock.MockMessageSender), new(service_mock.MockBlockListService),
+		service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	tokens, err := authService.Login(context.Background(), user.Email, "password", client)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, tokens)
+	loginHistory.AssertExpectations(t)
+	// A login from a disallowed network must not count against the account
+	userService.AssertNotCalled(t, "IncrementFailedAttempts", mock.Anything, mock.Anything)
+}
+
+func TestRevokeSessions_RevokesAndPublishesEvent(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	userID := uuid.New()
+	blockList := new(service_mock.MockBlockListService)
+	blockList.On("RevokeUserSessions", userID.String(), mock.AnythingOfType("time.Time"),
+		cfg.RefreshTokenDurationDays).Return(nil)
+	sender := new(service_mock.MockMessageSender)
+	sender.On("Send", cfg.SessionRevokedTopic, mock.MatchedBy(func(event events.SessionRevoked) bool {
+		return event.UserID == userID && event.Reason == "device lost"
+	})).Return(nil)
+	svc := NewService(new(service_mock.MockUserService), new(repository_mock.MockPasswordResetTokenRepository),
+		new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository), service_mock.NewPermissiveMockLoginHistoryService(),
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), service_mock.NewPermissiveMockAuditRecorder(),
+		new(utils_mock.MockHasher), utils.NewHmacTokenHasher("test-key"), sender, blockList,
+		service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+
+	// Act
+	err := svc.RevokeSessions(context.Background(), userID, "device lost")
+
+	// Assert
+	assert.NoError(t, err)
+	blockList.AssertExpectations(t)
+	sender.AssertExpectations(t)
+}
+
+// newTokenService returns a service issuing and validating tokens with the clock, checking them against the block list.
+func newTokenService(cfg *config.AuthenticationConfig, blockList *service_mock.MockBlockListService, clock utils.Clock) *service {
+	return NewService(new(service_mock.MockUserService), new(repository_mock.MockPasswordResetTokenRepository),
+		new(repository_mock.MockLoginChallengeRepository),
+		new(repository_mock.MockImpersonationSessionRepository), service_mock.NewPermissiveMockLoginHistoryService(),
+		risk.NewAllowAllAssessor(), service_mock.NewPermissiveMockIPRuleService(), service_mock.NewPermissiveMockAuditRecorder(),
+		new(utils_mock.MockHasher), utils.NewHmacTokenHasher("test-key"), new(service_mock.MockMessageSender), blockList,
+		service_mock.NewPermissiveMockLogger(), cfg, clock, utils_mock.NewSequentialIDGenerator(), new(sync.WaitGroup)).(*service)
+}
+
+func TestRefreshToken_RenewsAccessTokenUntilRefreshTokenExpires(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	userID := uuid.New()
+	blockList := new(service_mock.MockBlockListService)
+	svc := newTokenService(cfg, blockList, utils.SystemClock())
+	refreshToken, refreshUUID, refreshExpires, err := svc.generateRefreshToken(userID)
+	assert.NoError(t, err)
+	blockList.On("IsInBlockList", refreshUUID).Return(false, nil)
+	blockList.On("GetSessionsRevokedAt", userID.String()).Return(nil, nil)
+
+	// Act
+	td, err := svc.RefreshToken(context.Background(), refreshToken)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, td) {
+		assert.Equal(t, refreshExpires, td.RtExpires)
+		assert.Equal(t, refreshUUID, td.RefreshUUID)
+		renewedUserID, err := svc.GetIdFromToken(context.Background(), td.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, renewedUserID)
+	}
+}
+
+func TestRefreshToken_RejectsInvalidToken(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	blockList := new(service_mock.MockBlockListService)
+	svc := newTokenService(cfg, blockList, utils.SystemClock())
+
+	// Act
+	td, err := svc.RefreshToken(context.Background(), "not-a-token")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, td)
+	blockList.AssertNotCalled(t, "IsInBlockList", mock.Anything)
+}
+
+func TestParseAndValidateToken_ToleratesClockSkew(t *testing.T) {
+	cfg := newTestConfig(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	skew := cfg.TokenClockSkew
+	tests := []struct {
+		name        string
+		issuerAhead time.Duration
+		expectedErr string
+	}{
+		{name: "same clock", issuerAhead: 0},
+		{name: "issuer ahead within the skew", issuerAhead: skew},
+		{name: "issuer ahead beyond the skew", issuerAhead: skew + time.Second, expectedErr: "token used before issued"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			issuer := newTokenService(cfg, new(service_mock.MockBlockListService), utils_mock.NewFakeClock(now.Add(tt.issuerAhead)))
+			validator := newTokenService(cfg, new(service_mock.MockBlockListService), utils_mock.NewFakeClock(now))
+			token, _, err := issuer.generateAccessToken(uuid.New(), "refresh", now.Add(time.Hour).Unix())
+			assert.NoError(t, err)
+
+			// Act
+			_, claims, err := validator.parseAndValidateToken(context.Background(), token)
+
+			// Assert
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, utils_mock.SequentialID(1).String(), claims["access_uuid"])
+		})
+	}
+}
+
+func TestParseAndValidateToken_ExpiryHasNoLeeway(t *testing.T) {
+	// Arrange
+	cfg := newTestConfig(t)
+	clock := utils_mock.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	svc := newTokenService(cfg, new(service_mock.MockBlockListService), clock)
+	token, expires, err := svc.generateAccessToken(uuid.New(), "refresh", clock.Now().Add(time.Hour).Unix())
+	assert.NoError(t, err)
+
+	// Act
+	clock.Set(time.Unix(expires, 0))
+	_, _, atExpiry := svc.parseAndValidateToken(context.Background(), token)
+	clock.Advance(time.Second)
+	_, _, afterExpiry := svc.parseAndValidateToken(context.Background(), token)
+
+	// Assert
+	assert.NoError(t, atExpiry)
+	assert.EqualError(t, afterExpiry, "token is expired")
+}
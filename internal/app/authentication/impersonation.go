@@ -0,0 +1,252 @@
+package authentication
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// ErrImpersonationNotAllowed is returned when the actor may not impersonate the target.
+var ErrImpersonationNotAllowed = errors.New("impersonation not allowed")
+
+// ErrImpersonatedSession is returned for account changes attempted with an impersonation token.
+var ErrImpersonatedSession = errors.New("not allowed during impersonation")
+
+// StartImpersonation issues a short-lived access token for the target user. The token carries the admin in an
+// RFC 8693 "act" claim and cannot be refreshed.
+func (a *service) StartImpersonation(ctx context.Context, actorID, targetID uuid.UUID, reason string, client dto.ClientInfo) (*dto.ImpersonationDetails, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("a reason is required")
+	}
+	denied := dto.AuditEvent{
+		Type:       models.AuditEventImpersonationStarted,
+		Outcome:    models.AuditOutcomeDenied,
+		ActorID:    &actorID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &targetID,
+		IP:         client.IP,
+		Details:    map[string]string{"reason": reason},
+	}
+	if actorID == targetID {
+		a.audit.Record(ctx, denied)
+		return nil, ErrImpersonationNotAllowed
+	}
+	actor, err := a.userService.GetUserByID(ctx, actorID)
+	if err != nil {
+		a.logger.Error("Error fetching impersonating user %s: %v", actorID, err)
+		a.audit.Record(ctx, denied)
+		return nil, ErrImpersonationNotAllowed
+	}
+	target, err := a.userService.GetUserByID(ctx, targetID)
+	if err != nil {
+		a.logger.Error("Error fetching impersonated user %s: %v", targetID, err)
+		return nil, errors.New("user not found")
+	}
+	// Admins cannot borrow the identity of other admins
+	if actor.Role != models.RoleAdmin || target.Role == models.RoleAdmin {
+		a.logger.Warn("User %s is not allowed to impersonate %s", actor.Email, target.Email)
+		a.audit.Record(ctx, denied)
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	now := a.clock.Now()
+	a.endExpiredImpersonations(ctx, now)
+	session, err := a.impersonationRepo.Create(ctx, &models.ImpersonationSession{
+		ID:         a.ids.NewID(),
+		ActorID:    actor.ID,
+		ActorEmail: actor.Email,
+		TargetID:   target.ID,
+		Reason:     reason,
+		StartedAt:  now,
+		ExpiresAt:  now.Add(time.Minute * a.cfg.ImpersonationDurationMinutes),
+	})
+	if err != nil {
+		return nil, errors.New("failed to start impersonation")
+	}
+
+	accessToken, err := a.generateImpersonationToken(session)
+	if err != nil {
+		a.logger.Error("Failed to generate impersonation token for session %s: %v", session.ID, err)
+		return nil, errors.New("failed to start impersonation")
+	}
+
+	a.logger.Info("User %s started impersonating %s (session %s): %s", actor.Email, target.Email, session.ID, reason)
+	a.sendImpersonationEvent(ctx, events.ImpersonationStarted(impersonationEvent(target.Email, session)))
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventImpersonationStarted,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &actor.ID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &target.ID,
+		IP:         client.IP,
+		Details: map[string]string{
+			"reason":     reason,
+			"session_id": session.ID.String(),
+			"expires_at": session.ExpiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+	return &dto.ImpersonationDetails{
+		SessionID:   session.ID,
+		AccessToken: accessToken,
+		ExpiresAt:   session.ExpiresAt,
+	}, nil
+}
+
+// StopImpersonation ends the session of an impersonation token and revokes the token.
+func (a *service) StopImpersonation(ctx context.Context, accessToken string, client dto.ClientInfo) error {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
+	if err != nil {
+		return errors.New("invalid accessToken")
+	}
+	sessionIDStr, ok := claims["impersonation_id"].(string)
+	if !ok {
+		return errors.New("not an impersonation session")
+	}
+	sessionID, err := uuid.Parse(sessionIDStr)
+	if err != nil {
+		return errors.New("invalid accessToken")
+	}
+	session, err := a.impersonationRepo.FindByID(ctx, sessionID)
+	if err != nil {
+		return errors.New("invalid accessToken")
+	}
+
+	ended, err := a.impersonationRepo.End(ctx, session.ID, a.clock.Now())
+	if err != nil {
+		return errors.New("failed to stop impersonation")
+	}
+	if accessUUID, ok := claims["access_uuid"].(string); ok {
+		err = a.blockListService.AddToBlockList(ctx, accessUUID, session.ExpiresAt.Sub(a.clock.Now()))
+		if err != nil {
+			a.logger.Error("Failed to block impersonation token of session %s: %v", session.ID, err)
+			return errors.New("failed to stop impersonation")
+		}
+	}
+	if !ended {
+		return nil
+	}
+
+	a.logger.Info("User %s stopped impersonating %s (session %s)", session.ActorEmail, session.TargetID, session.ID)
+	a.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventImpersonationStopped,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &session.ActorID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &session.TargetID,
+		IP:         client.IP,
+		Details:    map[string]string{"session_id": session.ID.String()},
+	})
+	if target, err := a.userService.GetUserByID(ctx, session.TargetID); err == nil {
+		a.sendImpersonationEvent(ctx, events.ImpersonationStopped(impersonationEvent(target.Email, session)))
+	}
+	return nil
+}
+
+// GetImpersonatorFromToken returns the admin behind an impersonation token, or nil for a regular token.
+func (a *service) GetImpersonatorFromToken(ctx context.Context, accessToken string) (*uuid.UUID, error) {
+	_, claims, err := a.parseAndValidateToken(ctx, accessToken)
+	if err != nil {
+		return nil, errors.New("invalid accessToken")
+	}
+	return impersonatorFromClaims(claims)
+}
+
+func (a *service) GetImpersonationSessions(ctx context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error) {
+	// The listing must not show a session as running past its expiry
+	a.endExpiredImpersonations(ctx, a.clock.Now())
+	return a.impersonationRepo.FindByTargetID(ctx, targetID, p)
+}
+
+// endExpiredImpersonations records the end of the sessions whose token expired without being stopped. They
+// are swept whenever sessions are started or listed, so the end is recorded at the expiry of the session.
+func (a *service) endExpiredImpersonations(ctx context.Context, now time.Time) {
+	sessions, err := a.impersonationRepo.EndExpired(ctx, now)
+	if err != nil {
+		a.logger.Error("Failed to end expired impersonation sessions: %v", err)
+		return
+	}
+	for _, session := range sessions {
+		a.logger.Info("Impersonation of %s by %s expired (session %s)", session.TargetID, session.ActorEmail, session.ID)
+		a.audit.Record(ctx, dto.AuditEvent{
+			Type:       models.AuditEventImpersonationExpired,
+			Outcome:    models.AuditOutcomeSuccess,
+			ActorID:    &session.ActorID,
+			TargetType: models.AuditTargetUser,
+			TargetID:   &session.TargetID,
+			Details: map[string]string{
+				"session_id": session.ID.String(),
+				"expired_at": session.ExpiresAt.UTC().Format(time.RFC3339),
+			},
+		})
+		if target, err := a.userService.GetUserByID(ctx, session.TargetID); err == nil {
+			a.sendImpersonationEvent(ctx, events.ImpersonationStopped(impersonationEvent(target.Email, session)))
+		}
+	}
+}
+
+// actorFromClaims returns who acts with a token: the impersonating admin, or else the user itself.
+func actorFromClaims(claims jwt.MapClaims, userID uuid.UUID) *uuid.UUID {
+	if impersonator, err := impersonatorFromClaims(claims); err == nil && impersonator != nil {
+		return impersonator
+	}
+	return &userID
+}
+
+func impersonatorFromClaims(claims jwt.MapClaims) (*uuid.UUID, error) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	sub, ok := act["sub"].(string)
+	if !ok {
+		return nil, errors.New("invalid actor claim")
+	}
+	actorID, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, errors.New("invalid actor claim")
+	}
+	return &actorID, nil
+}
+
+func (a *service) generateImpersonationToken(session *models.ImpersonationSession) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["user_id"] = session.TargetID.String()
+	claims["access_uuid"] = a.ids.NewID().String()
+	// There is no refresh token, the session ends when the access token expires
+	claims["refresh_uuid"] = session.ID.String()
+	claims["refresh_exp"] = session.ExpiresAt.Unix()
+	claims["exp"] = session.ExpiresAt.Unix()
+	claims["iat"] = session.StartedAt.Unix()
+	claims["act"] = map[string]interface{}{"sub": session.ActorID.String()}
+	claims["impersonation_id"] = session.ID.String()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(a.jwtSecret))
+}
+
+func impersonationEvent(targetEmail string, session *models.ImpersonationSession) events.Impersonation {
+	return events.Impersonation{
+		SessionID:  session.ID,
+		UserID:     session.TargetID,
+		Email:      targetEmail,
+		ActorEmail: session.ActorEmail,
+		Reason:     session.Reason,
+		StartedAt:  session.StartedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+}
+
+func (a *service) sendImpersonationEvent(ctx context.Context, event events.Event) {
+	err := a.sender.Send(ctx, a.cfg.ImpersonationTopic, event)
+	if err != nil {
+		a.logger.Error("Error sending %s message: %v", event.Contract().Type(), err)
+	}
+}
@@ -0,0 +1,151 @@
+package authentication
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+// StartImpersonation
+// @Summary StartImpersonation
+// @Description Issues a time-boxed access token for the target user, marked with the admin as actor. The impersonated user is notified.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body dto.ImpersonationRequest true "Target user and reason"
+// @Success 200 {object} dto.ImpersonationDetails
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /admin/impersonations [post]
+func (h *Handler) StartImpersonation(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	var request dto.ImpersonationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	// Impersonation cannot be chained
+	if _, impersonated := c.Get("impersonatorID"); impersonated {
+		errorResponse.Message = ErrImpersonatedSession.Error()
+		errorResponse.ErrorCode = http.StatusForbidden
+		c.JSON(http.StatusForbidden, errorResponse)
+		return
+	}
+
+	details, err := h.authService.StartImpersonation(c.Request.Context(), c.MustGet("userID").(uuid.UUID), request.TargetUserID, request.Reason,
+		utils.ClientInfo(c))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrImpersonationNotAllowed) {
+			status = http.StatusForbidden
+		}
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = status
+		c.JSON(status, errorResponse)
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "access_token",
+		Value:    details.AccessToken,
+		Expires:  details.ExpiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+	c.JSON(http.StatusOK, details)
+}
+
+// StopImpersonation
+// @Summary StopImpersonation
+// @Description Ends the impersonation session of the current access token and revokes the token
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} dto.SuccessResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/stop-impersonation [post]
+func (h *Handler) StopImpersonation(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	accessToken, err := c.Cookie("access_token")
+	if err != nil {
+		errorResponse.Message = "Unauthorized"
+		errorResponse.ErrorCode = http.StatusUnauthorized
+		c.JSON(http.StatusUnauthorized, errorResponse)
+		return
+	}
+
+	err = h.authService.StopImpersonation(c.Request.Context(), accessToken, utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     "access_token",
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Path:     "/",
+	})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message:    "Impersonation stopped",
+		StatusCode: http.StatusOK,
+	})
+}
+
+// GetImpersonationSessions
+// @Summary GetImpersonationSessions
+// @Description Lists who impersonated the current user, when and why, newest first
+// @Tags Users
+// @Produce json
+// @Param limit query int false "Page size, at most 100"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} dto.ImpersonationSessionResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /user/impersonations [get]
+func (h *Handler) GetImpersonationSessions(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	temp, ok := c.Get("userID")
+	if !ok {
+		errorResponse.Message = "Unauthorized"
+		errorResponse.ErrorCode = http.StatusUnauthorized
+		c.JSON(http.StatusUnauthorized, errorResponse)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	sessions, err := h.authService.GetImpersonationSessions(c.Request.Context(), temp.(uuid.UUID), utils.NewPagination(limit, offset))
+	if err != nil {
+		errorResponse.Message = "Error fetching impersonation sessions"
+		errorResponse.ErrorCode = http.StatusInternalServerError
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	response := make([]dto.ImpersonationSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, dto.ImpersonationSessionResponse{
+			ID:         session.ID,
+			ActorEmail: session.ActorEmail,
+			Reason:     session.Reason,
+			StartedAt:  session.StartedAt,
+			ExpiresAt:  session.ExpiresAt,
+			EndedAt:    session.EndedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,177 @@
+package authentication
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/risk"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/app/utils/utils_mock"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"sync"
+	"testing"
+	"time"
+)
+
+type impersonationTestDeps struct {
+	userService       *service_mock.MockUserService
+	impersonationRepo *repository_mock.MockImpersonationSessionRepository
+	blockList         *service_mock.MockBlockListService
+	sender            *service_mock.MockMessageSender
+	audit             *service_mock.MockAuditRecorder
+	service           IService
+	admin             *models.User
+	target            *models.User
+	cfg               *config.AuthenticationConfig
+}
+
+func newImpersonationTestDeps(t *testing.T) *impersonationTestDeps {
+	cfg := newTestConfig(t)
+	deps := &impersonationTestDeps{
+		userService:       new(service_mock.MockUserService),
+		impersonationRepo: new(repository_mock.MockImpersonationSessionRepository),
+		blockList:         new(service_mock.MockBlockListService),
+		sender:            new(service_mock.MockMessageSender),
+		audit:             service_mock.NewPermissiveMockAuditRecorder(),
+		admin:             &models.User{ID: uuid.New(), Email: "admin@example.com", Role: models.RoleAdmin},
+		target:            &models.User{ID: uuid.New(), Email: "target@example.com", Role: models.RoleUser},
+		cfg:               cfg,
+	}
+	deps.service = NewService(deps.userService, new(repository_mock.MockPasswordResetTokenRepository), new(repository_mock.MockLoginChallengeRepository),
+		deps.impersonationRepo, service_mock.NewPermissiveMockLoginHistoryService(), risk.NewAllowAllAssessor(),
+		service_mock.NewPermissiveMockIPRuleService(), deps.audit, new(utils_mock.MockHasher),
+		utils.NewHmacTokenHasher("test-key"), deps.sender, deps.blockList, service_mock.NewPermissiveMockLogger(), cfg, utils.SystemClock(), utils.RandomIDGenerator(), new(sync.WaitGroup))
+	deps.userService.On("GetUserByID", deps.admin.ID).Return(deps.admin, nil)
+	deps.userService.On("GetUserByID", deps.target.ID).Return(deps.target, nil)
+	deps.sender.On("Send", cfg.ImpersonationTopic, mock.Anything).Return(nil)
+	return deps
+}
+
+func (d *impersonationTestDeps) start(t *testing.T) (string, *models.ImpersonationSession) {
+	d.impersonationRepo.On("EndExpired", mock.AnythingOfType("time.Time")).Return([]*models.ImpersonationSession{}, nil)
+	// The repository returns the session it stored
+	session := &models.ImpersonationSession{}
+	d.impersonationRepo.On("Create", mock.AnythingOfType("*models.ImpersonationSession")).
+		Run(func(args mock.Arguments) { *session = *args.Get(0).(*models.ImpersonationSession) }).
+		Return(session, nil)
+	details, err := d.service.StartImpersonation(context.Background(), d.admin.ID, d.target.ID, "debugging a failing workflow", dto.ClientInfo{})
+	assert.NoError(t, err)
+	return details.AccessToken, session
+}
+
+func TestStartImpersonation_IssuesTokenWithActorClaim(t *testing.T) {
+	// Arrange
+	deps := newImpersonationTestDeps(t)
+
+	// Act
+	accessToken, session := deps.start(t)
+
+	// Assert
+	userID, err := deps.service.GetIdFromToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, deps.target.ID, userID)
+	impersonatorID, err := deps.service.GetImpersonatorFromToken(context.Background(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, deps.admin.ID, *impersonatorID)
+	events := deps.audit.Recorded(models.AuditEventImpersonationStarted)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, models.AuditOutcomeSuccess, events[0].Outcome)
+		assert.Equal(t, &deps.admin.ID, events[0].ActorID)
+		assert.Equal(t, &deps.target.ID, events[0].TargetID)
+	}
+	assert.Equal(t, deps.admin.Email, session.ActorEmail)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), session.ExpiresAt, time.Minute)
+	deps.sender.AssertCalled(t, "Send", deps.cfg.ImpersonationTopic, mock.Anything)
+}
+
+func TestStartImpersonation_Denied(t *testing.T) {
+	deps := newImpersonationTestDeps(t)
+	otherAdmin := &models.User{ID: uuid.New(), Email: "other-admin@example.com", Role: models.RoleAdmin}
+	deps.userService.On("GetUserByID", otherAdmin.ID).Return(otherAdmin, nil)
+	tests := []struct {
+		name     string
+		actorID  uuid.UUID
+		targetID uuid.UUID
+		reason   string
+	}{
+		{"not an admin", deps.target.ID, deps.admin.ID, "curious"},
+		{"target is an admin", deps.admin.ID, otherAdmin.ID, "curious"},
+		{"self", deps.admin.ID, deps.admin.ID, "curious"},
+		{"no reason", deps.admin.ID, deps.target.ID, " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			details, err := deps.service.StartImpersonation(context.Background(), tt.actorID, tt.targetID, tt.reason, dto.ClientInfo{})
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, details)
+		})
+	}
+	deps.impersonationRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestImpersonationToken_CannotChangePasswordOrRefresh(t *testing.T) {
+	// Arrange
+	deps := newImpersonationTestDeps(t)
+	accessToken, _ := deps.start(t)
+
+	// Act
+	changeErr := deps.service.ChangePassword(context.Background(), accessToken, "new-password", dto.ClientInfo{})
+	_, refreshErr := deps.service.RefreshToken(context.Background(), accessToken)
+
+	// Assert
+	assert.ErrorIs(t, changeErr, ErrImpersonatedSession)
+	assert.Error(t, refreshErr)
+	deps.userService.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func TestStopImpersonation_EndsSessionAndRevokesToken(t *testing.T) {
+	// Arrange
+	deps := newImpersonationTestDeps(t)
+	accessToken, session := deps.start(t)
+	deps.impersonationRepo.On("FindByID", session.ID).Return(session, nil)
+	deps.impersonationRepo.On("End", session.ID, mock.AnythingOfType("time.Time")).Return(true, nil)
+	deps.blockList.On("AddToBlockList", mock.Anything, mock.AnythingOfType("time.Duration")).Return(nil)
+
+	// Act
+	err := deps.service.StopImpersonation(context.Background(), accessToken, dto.ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	deps.impersonationRepo.AssertCalled(t, "End", session.ID, mock.AnythingOfType("time.Time"))
+	deps.blockList.AssertNumberOfCalls(t, "AddToBlockList", 1)
+	deps.sender.AssertNumberOfCalls(t, "Send", 2)
+}
+
+func TestGetImpersonationSessions_RecordsExpiredSessions(t *testing.T) {
+	// Arrange
+	deps := newImpersonationTestDeps(t)
+	startedAt := time.Now().Add(-time.Hour)
+	expired := &models.ImpersonationSession{ID: uuid.New(), ActorID: deps.admin.ID, ActorEmail: deps.admin.Email,
+		TargetID: deps.target.ID, StartedAt: startedAt, ExpiresAt: startedAt.Add(15 * time.Minute)}
+	deps.impersonationRepo.On("EndExpired", mock.AnythingOfType("time.Time")).Return([]*models.ImpersonationSession{expired}, nil)
+	deps.impersonationRepo.On("FindByTargetID", deps.target.ID, utils.DefaultPagination()).
+		Return([]*models.ImpersonationSession{expired}, nil)
+
+	// Act
+	sessions, err := deps.service.GetImpersonationSessions(context.Background(), deps.target.ID, utils.DefaultPagination())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	events := deps.audit.Recorded(models.AuditEventImpersonationExpired)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, deps.admin.ID, *events[0].ActorID)
+		assert.Equal(t, deps.target.ID, *events[0].TargetID)
+		assert.Equal(t, expired.ID.String(), events[0].Details["session_id"])
+	}
+	deps.sender.AssertNumberOfCalls(t, "Send", 1)
+}
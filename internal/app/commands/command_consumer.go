@@ -0,0 +1,357 @@
+package commands
+
+import (
+	"automation-hub-idp/internal/app/authentication"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
+	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+// The headers added to a message moved to the dead-letter topic, next to the headers it came with.
+const (
+	HeaderDeadLetterReason    = "dlq_reason"
+	HeaderDeadLetterTopic     = "dlq_original_topic"
+	HeaderDeadLetterPartition = "dlq_original_partition"
+	HeaderDeadLetterOffset    = "dlq_original_offset"
+
+	// HeaderSignature carries the signature of a command, see Sign.
+	HeaderSignature  = "signature"
+	signatureVersion = "v1"
+
+	// maxRetryBackoff caps the wait between two attempts to record or answer a command
+	maxRetryBackoff = 30 * time.Second
+	maxCommandIDLen = 255
+)
+
+// Sign returns the signature header of a command: "v1=" followed by the hex encoded HMAC-SHA256 of the
+// message value keyed with the key of the producer its envelope names as source.
+func Sign(key string, value []byte) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(value)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// handler executes a command and returns the user it was about. A rejected error is the reason the command
+// failed, which is answered to the sender and not retried; any other error is a failing dependency and the
+// command is executed again.
+type handler func(ctx context.Context, envelope *events.Envelope) (*uuid.UUID, error)
+
+// rejectedError is the failure of a command that executing it again cannot change, such as an unknown user.
+type rejectedError struct {
+	err error
+}
+
+func rejected(err error) error {
+	return &rejectedError{err: err}
+}
+
+func (e *rejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rejectedError) Unwrap() error {
+	return e.err
+}
+
+// consumer executes each command once. The result is stored under the command ID before it is answered
+// and the offset committed, so a command read again is answered with the stored result; a command that
+// failed on a dependency or whose result could not be stored is executed again, which the commands
+// tolerate. Messages that are not a known command signed by an allowed producer go to the dead-letter
+// topic, as do commands that could not be executed, stored or answered after maxAttempts tries.
+type consumer struct {
+	source          iservice.MessageConsumer
+	repo            irepository.ProcessedCommandRepository
+	userService     users.UserService
+	authService     authentication.IService
+	replies         iservice.MessageSender
+	deadLetters     iservice.RawMessageSender
+	audit           iservice.AuditRecorder
+	logger          iservice.Logger
+	producerKeys    map[string]string
+	replyTopic      string
+	deadLetterTopic string
+	maxAttempts     int
+	retryBackoff    time.Duration
+	handlers        map[string]handler
+	now             func() time.Time
+}
+
+func NewConsumer(source iservice.MessageConsumer, repo irepository.ProcessedCommandRepository,
+	userService users.UserService, authService authentication.IService, replies iservice.MessageSender,
+	deadLetters iservice.RawMessageSender, auditRecorder iservice.AuditRecorder, logger iservice.Logger,
+	producerKeys map[string]string, replyTopic, deadLetterTopic string, maxAttempts int,
+	retryBackoff time.Duration) Consumer {
+	c := &consumer{
+		source:          source,
+		repo:            repo,
+		userService:     userService,
+		authService:     authService,
+		replies:         replies,
+		deadLetters:     deadLetters,
+		audit:           auditRecorder,
+		logger:          logger,
+		producerKeys:    producerKeys,
+		replyTopic:      replyTopic,
+		deadLetterTopic: deadLetterTopic,
+		maxAttempts:     maxAttempts,
+		retryBackoff:    retryBackoff,
+		now:             time.Now,
+	}
+	c.handlers = map[string]handler{
+		events.DisableUser{}.Contract().Type():          c.disableUser,
+		events.ForceLogout{}.Contract().Type():          c.forceLogout,
+		events.TriggerPasswordReset{}.Contract().Type(): c.triggerPasswordReset,
+	}
+	return c
+}
+
+func (c *consumer) Run(ctx context.Context) {
+	defer func() {
+		if err := c.source.Close(); err != nil {
+			c.logger.Error("Failed to close the command consumer: %v", err)
+		}
+	}()
+	for {
+		message, err := c.source.Fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.logger.Error("Failed to fetch commands: %v", err)
+			_ = wait(ctx, c.retryBackoff)
+			continue
+		}
+		// The message is not skipped: later commands wait until it was handled
+		for {
+			err = c.Process(ctx, message)
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			c.logger.Error("Failed to handle command message at %s/%d/%d: %v", message.Topic, message.Partition,
+				message.Offset, err)
+			_ = wait(ctx, c.retryBackoff)
+		}
+		if err != nil {
+			return
+		}
+		if err := c.source.Commit(message); err != nil {
+			// The command is read again after a restart and answered from the stored result
+			c.logger.Error("Failed to commit command message at %s/%d/%d: %v", message.Topic, message.Partition,
+				message.Offset, err)
+		}
+	}
+}
+
+func (c *consumer) Process(ctx context.Context, message *iservice.ConsumedMessage) (err error) {
+	// Continue the trace of the service that sent the command
+	ctx, span := tracing.StartConsumerSpan(ctx, message.Topic, message.Headers)
+	defer func() { tracing.End(span, err) }()
+
+	envelope, handle, err := c.decode(message.Value)
+	if err != nil {
+		return c.deadLetter(ctx, message, err)
+	}
+	if err := c.authenticate(message, envelope); err != nil {
+		return c.deadLetter(ctx, message, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = c.execute(ctx, envelope, handle)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.maxAttempts {
+			break
+		}
+		c.logger.Warn("Failed to handle command %s (attempt %d), retrying: %v", envelope.ID, attempt, err)
+		if err := wait(ctx, utils.ExponentialBackoff(c.retryBackoff, maxRetryBackoff, attempt)); err != nil {
+			return err
+		}
+	}
+	return c.deadLetter(ctx, message, fmt.Errorf("failed %d times: %w", c.maxAttempts, err))
+}
+
+// decode returns the envelope of a command and the handler of its type.
+func (c *consumer) decode(value []byte) (*events.Envelope, handler, error) {
+	var envelope events.Envelope
+	if err := json.Unmarshal(value, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if envelope.SpecVersion != events.SpecVersion || envelope.ID == "" || envelope.Source == "" {
+		return nil, nil, errors.New("invalid envelope: specversion 1.0, id and source are required")
+	}
+	if len(envelope.ID) > maxCommandIDLen {
+		return nil, nil, errors.New("invalid envelope: id is too long")
+	}
+	handle, found := c.handlers[envelope.Type]
+	if !found {
+		return nil, nil, fmt.Errorf("unknown command type %q", envelope.Type)
+	}
+	if err := envelope.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &envelope, handle, nil
+}
+
+// authenticate checks that the command was signed by the producer its envelope names as source.
+func (c *consumer) authenticate(message *iservice.ConsumedMessage, envelope *events.Envelope) error {
+	key, allowed := c.producerKeys[envelope.Source]
+	if !allowed {
+		return fmt.Errorf("source %q is not allowed to send commands", envelope.Source)
+	}
+	if !hmac.Equal([]byte(message.Headers[HeaderSignature]), []byte(Sign(key, message.Value))) {
+		return fmt.Errorf("invalid signature for source %q", envelope.Source)
+	}
+	return nil
+}
+
+// execute runs the command unless it was processed before, stores the result and answers it. A command
+// failing on a dependency stores no result, so it is executed again by the next attempt.
+func (c *consumer) execute(ctx context.Context, envelope *events.Envelope, handle handler) error {
+	processed, err := c.repo.FindByID(ctx, envelope.ID)
+	if err != nil {
+		return err
+	}
+	if processed != nil {
+		c.logger.Info("Command %s was processed at %s, answering it again", envelope.ID,
+			processed.ProcessedAt.String())
+		return c.reply(ctx, processed)
+	}
+
+	targetID, err := handle(ctx, envelope)
+	var rejection *rejectedError
+	if err != nil && !errors.As(err, &rejection) {
+		return fmt.Errorf("command %s of type %s: %w", envelope.ID, envelope.Type, err)
+	}
+	processed = &models.ProcessedCommand{
+		ID:          envelope.ID,
+		Type:        envelope.Type,
+		Source:      envelope.Source,
+		Status:      models.CommandSucceeded,
+		ProcessedAt: c.now(),
+	}
+	outcome := models.AuditOutcomeSuccess
+	details := map[string]string{"command_id": envelope.ID, "command_type": envelope.Type, "source": envelope.Source}
+	if err != nil {
+		processed.Status = models.CommandFailed
+		processed.Error = err.Error()
+		outcome = models.AuditOutcomeFailure
+		details["error"] = err.Error()
+		c.logger.Warn("Command %s of type %s from %s failed: %v", envelope.ID, envelope.Type, envelope.Source, err)
+	} else {
+		c.logger.Info("Executed command %s of type %s from %s", envelope.ID, envelope.Type, envelope.Source)
+	}
+	c.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventCommandExecuted,
+		Outcome:    outcome,
+		TargetType: models.AuditTargetUser,
+		TargetID:   targetID,
+		Details:    details,
+	})
+
+	// A command stored concurrently by another consumer is answered with this result all the same
+	if _, err := c.repo.Create(ctx, processed); err != nil {
+		return err
+	}
+	return c.reply(ctx, processed)
+}
+
+func (c *consumer) reply(ctx context.Context, processed *models.ProcessedCommand) error {
+	return c.replies.Send(ctx, c.replyTopic, events.CommandResult{
+		CommandID:   processed.ID,
+		CommandType: processed.Type,
+		Status:      processed.Status,
+		Error:       processed.Error,
+	})
+}
+
+// deadLetter moves the message as it was read to the dead-letter topic, with the reason in its headers.
+func (c *consumer) deadLetter(ctx context.Context, message *iservice.ConsumedMessage, reason error) error {
+	headers := make(map[string]string, len(message.Headers)+4)
+	for name, value := range message.Headers {
+		headers[name] = value
+	}
+	headers[HeaderDeadLetterReason] = reason.Error()
+	headers[HeaderDeadLetterTopic] = message.Topic
+	headers[HeaderDeadLetterPartition] = strconv.FormatInt(int64(message.Partition), 10)
+	headers[HeaderDeadLetterOffset] = strconv.FormatInt(message.Offset, 10)
+	if err := c.deadLetters.SendRaw(ctx, c.deadLetterTopic, message.Key, message.Value, headers); err != nil {
+		return err
+	}
+	c.logger.Error("Moved command message at %s/%d/%d to %s: %v", message.Topic, message.Partition,
+		message.Offset, c.deadLetterTopic, reason)
+	return nil
+}
+
+func (c *consumer) disableUser(ctx context.Context, envelope *events.Envelope) (*uuid.UUID, error) {
+	var command events.DisableUser
+	if err := json.Unmarshal(envelope.Data, &command); err != nil {
+		return nil, rejected(err)
+	}
+	user, err := c.findUser(ctx, command.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// The sessions are revoked first: the user is only found while active, so a command that failed after
+	// the user was disabled could not revoke them when executed again
+	if err := c.authService.RevokeSessions(ctx, user.ID, command.Reason); err != nil {
+		return &user.ID, err
+	}
+	return &user.ID, c.userService.DeleteUser(ctx, user.ID)
+}
+
+func (c *consumer) forceLogout(ctx context.Context, envelope *events.Envelope) (*uuid.UUID, error) {
+	var command events.ForceLogout
+	if err := json.Unmarshal(envelope.Data, &command); err != nil {
+		return nil, rejected(err)
+	}
+	return &command.UserID, c.authService.RevokeSessions(ctx, command.UserID, command.Reason)
+}
+
+func (c *consumer) triggerPasswordReset(ctx context.Context, envelope *events.Envelope) (*uuid.UUID, error) {
+	var command events.TriggerPasswordReset
+	if err := json.Unmarshal(envelope.Data, &command); err != nil {
+		return nil, rejected(err)
+	}
+	user, err := c.findUser(ctx, command.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &user.ID, c.authService.RequestPasswordReset(ctx, user.Email)
+}
+
+// findUser returns the active user a command is about and rejects the command when there is none.
+func (c *consumer) findUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := c.userService.GetUserByID(ctx, id)
+	if errors.Is(err, irepository.ErrUserNotFound) {
+		return nil, rejected(err)
+	}
+	return user, err
+}
+
+// wait sleeps for d and returns early with the error of ctx once it is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
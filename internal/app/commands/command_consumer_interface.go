@@ -0,0 +1,14 @@
+package commands
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+)
+
+type Consumer interface {
+	// Run consumes the command topic until the context is cancelled.
+	Run(ctx context.Context)
+	// Process executes, answers or dead-letters one message. After an error the message was not handled and
+	// must not be committed.
+	Process(ctx context.Context, message *iservice.ConsumedMessage) error
+}
@@ -0,0 +1,440 @@
+package commands
+
+import (
+	"automation-hub-idp/internal/app/authentication"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	commandTopic    = "idp-commands"
+	replyTopic      = "idp-command-results"
+	deadLetterTopic = "idp-commands-dead-letter"
+	adminSource     = "automation-hub-admin"
+	adminKey        = "admin-signing-key-of-the-tests"
+)
+
+type memoryProcessedCommands struct {
+	mu       sync.Mutex
+	commands map[string]models.ProcessedCommand
+}
+
+func (m *memoryProcessedCommands) FindByID(_ context.Context, id string) (*models.ProcessedCommand, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	command, found := m.commands[id]
+	if !found {
+		return nil, nil
+	}
+	return &command, nil
+}
+
+func (m *memoryProcessedCommands) Create(_ context.Context, command *models.ProcessedCommand) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, found := m.commands[command.ID]; found {
+		return false, nil
+	}
+	m.commands[command.ID] = *command
+	return true, nil
+}
+
+// fakeAuthService records the sessions revoked and the password resets requested, the other methods of
+// the interface are not used by the consumer. The first revokeFailures revocations fail.
+type fakeAuthService struct {
+	authentication.IService
+	mu             sync.Mutex
+	revoked        []uuid.UUID
+	resets         []string
+	revokeFailures int
+}
+
+func (f *fakeAuthService) RevokeSessions(_ context.Context, userID uuid.UUID, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.revokeFailures > 0 {
+		f.revokeFailures--
+		return errors.New("failed to revoke sessions")
+	}
+	f.revoked = append(f.revoked, userID)
+	return nil
+}
+
+func (f *fakeAuthService) RequestPasswordReset(_ context.Context, email string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resets = append(f.resets, email)
+	return nil
+}
+
+type consumerTestDeps struct {
+	source      *service_mock.FakeMessageConsumer
+	repo        *memoryProcessedCommands
+	userService *service_mock.MockUserService
+	authService *fakeAuthService
+	sender      *service_mock.FakeMessageSender
+	audit       *service_mock.MockAuditRecorder
+}
+
+func newConsumerTestDeps() *consumerTestDeps {
+	return &consumerTestDeps{
+		source:      service_mock.NewFakeMessageConsumer(),
+		repo:        &memoryProcessedCommands{commands: make(map[string]models.ProcessedCommand)},
+		userService: new(service_mock.MockUserService),
+		authService: &fakeAuthService{},
+		sender:      service_mock.NewFakeMessageSender(),
+		audit:       service_mock.NewPermissiveMockAuditRecorder(),
+	}
+}
+
+func (d *consumerTestDeps) newConsumer() Consumer {
+	return NewConsumer(d.source, d.repo, d.userService, d.authService, d.sender, d.sender, d.audit,
+		service_mock.NewPermissiveMockLogger(), map[string]string{adminSource: adminKey}, replyTopic, deadLetterTopic, 3, 0)
+}
+
+// command returns a message carrying the command in an envelope, as the admin service would send it.
+func command(t *testing.T, id string, command events.Event) *iservice.ConsumedMessage {
+	return commandFrom(t, adminSource, adminKey, id, command)
+}
+
+// commandFrom returns a message carrying the command in an envelope, sent by source and signed with key.
+func commandFrom(t *testing.T, source, key, id string, command events.Event) *iservice.ConsumedMessage {
+	origin := events.Origin{Source: source, SchemaBaseURI: service_mock.FakeOrigin.SchemaBaseURI}
+	envelope, err := events.NewEnvelope(origin, command, uuid.New(), time.Now())
+	assert.NoError(t, err)
+	envelope.ID = id
+	value, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	return &iservice.ConsumedMessage{Topic: commandTopic, Offset: 7, Key: []byte(envelope.Subject), Value: value,
+		Headers: map[string]string{HeaderSignature: Sign(key, value)}}
+}
+
+func resultOf(t *testing.T, message service_mock.SentMessage) events.CommandResult {
+	var result events.CommandResult
+	assert.NoError(t, json.Unmarshal(message.Envelope.Data, &result))
+	return result
+}
+
+func TestProcess_DisablesUserAndReplies(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil)
+	deps.userService.On("DeleteUser", user.ID).Return(nil)
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(),
+		command(t, "cmd-1", events.DisableUser{UserID: user.ID, Reason: "offboarded"}))
+
+	// Assert
+	assert.NoError(t, err)
+	deps.userService.AssertExpectations(t)
+	assert.Equal(t, []uuid.UUID{user.ID}, deps.authService.revoked)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, replyTopic, sent[0].Topic)
+		assert.Equal(t, "cmd-1", sent[0].Key)
+		assert.Equal(t, events.CommandResult{CommandID: "cmd-1", CommandType: events.DisableUser{}.Contract().Type(),
+			Status: models.CommandSucceeded}, resultOf(t, sent[0]))
+	}
+	stored, _ := deps.repo.FindByID(context.Background(), "cmd-1")
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, models.CommandSucceeded, stored.Status)
+		assert.Equal(t, "automation-hub-admin", stored.Source)
+	}
+	recorded := deps.audit.Recorded(models.AuditEventCommandExecuted)
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, models.AuditOutcomeSuccess, recorded[0].Outcome)
+		assert.Equal(t, &user.ID, recorded[0].TargetID)
+		assert.Equal(t, "cmd-1", recorded[0].Details["command_id"])
+	}
+	assert.Empty(t, deps.sender.RawSent())
+}
+
+func TestProcess_DuplicateIsAnsweredWithoutExecutingAgain(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	consumer := deps.newConsumer()
+	message := command(t, "cmd-2", events.ForceLogout{UserID: uuid.New(), Reason: "compromised"})
+	assert.NoError(t, consumer.Process(context.Background(), message))
+
+	// Act
+	err := consumer.Process(context.Background(), message)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, deps.authService.revoked, 1)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, resultOf(t, sent[0]), resultOf(t, sent[1]))
+	}
+	assert.Len(t, deps.audit.Recorded(models.AuditEventCommandExecuted), 1)
+}
+
+func TestProcess_FailedCommandIsAnsweredAndNotRetried(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	userID := uuid.New()
+	deps.userService.On("GetUserByID", userID).Return((*models.User)(nil), irepository.ErrUserNotFound).Once()
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(),
+		command(t, "cmd-3", events.TriggerPasswordReset{UserID: userID}))
+
+	// Assert
+	assert.NoError(t, err)
+	deps.userService.AssertExpectations(t)
+	assert.Empty(t, deps.authService.resets)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		result := resultOf(t, sent[0])
+		assert.Equal(t, models.CommandFailed, result.Status)
+		assert.Equal(t, "user not found", result.Error)
+	}
+	recorded := deps.audit.Recorded(models.AuditEventCommandExecuted)
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, models.AuditOutcomeFailure, recorded[0].Outcome)
+	}
+	assert.Empty(t, deps.sender.RawSent())
+}
+
+func TestProcess_RetriesCommandFailingOnDependency(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	deps.userService.On("GetUserByID", user.ID).Return((*models.User)(nil), errors.New("failed to fetch user")).Once()
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil).Once()
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(),
+		command(t, "cmd-8", events.TriggerPasswordReset{UserID: user.ID}))
+
+	// Assert
+	assert.NoError(t, err)
+	deps.userService.AssertExpectations(t)
+	assert.Equal(t, []string{user.Email}, deps.authService.resets)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, models.CommandSucceeded, resultOf(t, sent[0]).Status)
+	}
+	recorded := deps.audit.Recorded(models.AuditEventCommandExecuted)
+	if assert.Len(t, recorded, 1) {
+		assert.Equal(t, models.AuditOutcomeSuccess, recorded[0].Outcome)
+	}
+	assert.Empty(t, deps.sender.RawSent())
+}
+
+func TestProcess_DisableFailingAfterRevocationIsExecutedAgain(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	deps.authService.revokeFailures = 1
+	user := &models.User{ID: uuid.New(), Email: "jane@example.com"}
+	deps.userService.On("GetUserByID", user.ID).Return(user, nil).Twice()
+	deps.userService.On("DeleteUser", user.ID).Return(nil).Once()
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(),
+		command(t, "cmd-9", events.DisableUser{UserID: user.ID, Reason: "offboarded"}))
+
+	// Assert
+	assert.NoError(t, err)
+	deps.userService.AssertExpectations(t)
+	assert.Equal(t, []uuid.UUID{user.ID}, deps.authService.revoked)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, models.CommandSucceeded, resultOf(t, sent[0]).Status)
+	}
+}
+
+func TestProcess_DeadLettersCommandWhenDependencyKeepsFailing(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	userID := uuid.New()
+	deps.userService.On("GetUserByID", userID).Return((*models.User)(nil), errors.New("failed to fetch user")).Times(3)
+	message := command(t, "cmd-10", events.TriggerPasswordReset{UserID: userID})
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(), message)
+
+	// Assert
+	assert.NoError(t, err)
+	deps.userService.AssertExpectations(t)
+	stored, _ := deps.repo.FindByID(context.Background(), "cmd-10")
+	assert.Nil(t, stored, "no failed result is stored for a failing dependency")
+	assert.Empty(t, deps.sender.Sent())
+	assert.Empty(t, deps.audit.Recorded(models.AuditEventCommandExecuted))
+	raw := deps.sender.RawSent()
+	if assert.Len(t, raw, 1) {
+		assert.Equal(t, message.Value, raw[0].Value)
+		assert.Contains(t, raw[0].Headers[HeaderDeadLetterReason], "failed 3 times")
+		assert.Contains(t, raw[0].Headers[HeaderDeadLetterReason], "failed to fetch user")
+	}
+}
+
+func TestProcess_MovesPoisonMessagesToDeadLetterTopic(t *testing.T) {
+	valid := command(t, "cmd-4", events.ForceLogout{UserID: uuid.New(), Reason: "compromised"})
+	var envelope map[string]interface{}
+	assert.NoError(t, json.Unmarshal(valid.Value, &envelope))
+	with := func(name string, value interface{}) []byte {
+		changed := make(map[string]interface{}, len(envelope))
+		for key, v := range envelope {
+			changed[key] = v
+		}
+		changed[name] = value
+		encoded, _ := json.Marshal(changed)
+		return encoded
+	}
+
+	tests := []struct {
+		name   string
+		value  []byte
+		reason string
+	}{
+		{"not json", []byte("disable jane"), "invalid envelope"},
+		{"missing id", with("id", ""), "id and source are required"},
+		{"unknown type", with("type", "com.automation-hub.idp.command.user.delete.v1"), "unknown command type"},
+		{"invalid payload", with("data", map[string]string{"userId": "jane", "reason": "compromised"}), "userId"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			deps := newConsumerTestDeps()
+			message := &iservice.ConsumedMessage{Topic: commandTopic, Partition: 2, Offset: 41, Key: []byte("k"),
+				Value: tt.value, Headers: map[string]string{"trace": "abc"}}
+
+			// Act
+			err := deps.newConsumer().Process(context.Background(), message)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Empty(t, deps.sender.Sent())
+			assert.Empty(t, deps.authService.revoked)
+			raw := deps.sender.RawSent()
+			if assert.Len(t, raw, 1) {
+				assert.Equal(t, deadLetterTopic, raw[0].Topic)
+				assert.Equal(t, tt.value, raw[0].Value)
+				assert.Equal(t, []byte("k"), raw[0].Key)
+				assert.Contains(t, raw[0].Headers[HeaderDeadLetterReason], tt.reason)
+				assert.Equal(t, commandTopic, raw[0].Headers[HeaderDeadLetterTopic])
+				assert.Equal(t, "2", raw[0].Headers[HeaderDeadLetterPartition])
+				assert.Equal(t, "41", raw[0].Headers[HeaderDeadLetterOffset])
+				assert.Equal(t, "abc", raw[0].Headers["trace"])
+			}
+		})
+	}
+}
+
+func TestProcess_DeadLettersCommandsOfUnauthenticatedProducers(t *testing.T) {
+	forceLogout := events.ForceLogout{UserID: uuid.New(), Reason: "compromised"}
+	tampered := command(t, "cmd-6", forceLogout)
+	tampered.Value = []byte(strings.Replace(string(tampered.Value), "compromised", "tampered", 1))
+	unsigned := command(t, "cmd-6", forceLogout)
+	delete(unsigned.Headers, HeaderSignature)
+
+	tests := []struct {
+		name    string
+		message *iservice.ConsumedMessage
+		reason  string
+	}{
+		{"unknown source", commandFrom(t, "automation-hub-partner", adminKey, "cmd-6", forceLogout), "not allowed"},
+		{"signed with another key", commandFrom(t, adminSource, "another-key-of-the-tests", "cmd-6", forceLogout), "invalid signature"},
+		{"unsigned", unsigned, "invalid signature"},
+		{"tampered", tampered, "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			deps := newConsumerTestDeps()
+
+			// Act
+			err := deps.newConsumer().Process(context.Background(), tt.message)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Empty(t, deps.authService.revoked)
+			assert.Empty(t, deps.sender.Sent())
+			processed, _ := deps.repo.FindByID(context.Background(), "cmd-6")
+			assert.Nil(t, processed)
+			raw := deps.sender.RawSent()
+			if assert.Len(t, raw, 1) {
+				assert.Equal(t, deadLetterTopic, raw[0].Topic)
+				assert.Contains(t, raw[0].Headers[HeaderDeadLetterReason], tt.reason)
+			}
+		})
+	}
+}
+
+func TestProcess_DeadLettersCommandWhenReplyKeepsFailing(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	deps.sender.FailKey("cmd-5", errors.New("broker unavailable"))
+	message := command(t, "cmd-5", events.ForceLogout{UserID: uuid.New(), Reason: "compromised"})
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(), message)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, deps.authService.revoked, 1, "the stored command was executed again")
+	raw := deps.sender.RawSent()
+	if assert.Len(t, raw, 1) {
+		assert.Equal(t, message.Value, raw[0].Value)
+		assert.Equal(t, "failed 3 times: broker unavailable", raw[0].Headers[HeaderDeadLetterReason])
+	}
+}
+
+func TestProcess_KeepsMessageWhenDeadLetterTopicIsUnavailable(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	deps.sender.Fail(errors.New("broker unavailable"))
+
+	// Act
+	err := deps.newConsumer().Process(context.Background(),
+		&iservice.ConsumedMessage{Topic: commandTopic, Value: []byte("{")})
+
+	// Assert
+	assert.EqualError(t, err, "broker unavailable")
+}
+
+func TestRun_CommitsHandledMessages(t *testing.T) {
+	// Arrange
+	deps := newConsumerTestDeps()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		deps.newConsumer().Run(ctx)
+		close(done)
+	}()
+
+	// Act
+	userID := uuid.New()
+	valid := command(t, "cmd-6", events.ForceLogout{UserID: userID, Reason: "compromised"})
+	deps.source.Add(commandTopic, valid.Key, valid.Value, valid.Headers)
+	deps.source.Add(commandTopic, nil, []byte("not a command"), nil)
+
+	// Assert
+	assert.Eventually(t, func() bool { return len(deps.source.Committed()) == 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	committed := deps.source.Committed()
+	assert.Equal(t, []int64{0, 1}, []int64{committed[0].Offset, committed[1].Offset})
+	assert.Len(t, deps.sender.Sent(), 1)
+	assert.Len(t, deps.sender.RawSent(), 1)
+	deps.authService.mu.Lock()
+	assert.Equal(t, []uuid.UUID{userID}, deps.authService.revoked)
+	deps.authService.mu.Unlock()
+	deps.userService.AssertNotCalled(t, "GetUserByID", mock.Anything)
+}
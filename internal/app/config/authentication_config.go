@@ -4,9 +4,19 @@ import (
 	"automation-hub-idp/internal/app/utils"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
+const (
+	// RegistrationModeOpen lets anyone register
+	RegistrationModeOpen = "open"
+	// RegistrationModeDomainAllowlist only lets addresses of RegistrationAllowedDomains register
+	RegistrationModeDomainAllowlist = "domain_allowlist"
+	// RegistrationModeInviteOnly only lets invited users register, through the accept-invitation endpoint
+	RegistrationModeInviteOnly = "invite_only"
+)
+
 const (
 	baseBlockDurationMinutes        string = "BLOCKING_TIME_EXPONENTIATION_BASIS"
 	maxLoginAttemptsBeforeBlock     string = "MAX_LOGIN_ATTEMPTS_BEFORE_BLOCK"
@@ -14,27 +24,71 @@ const (
 	expirationTimeResetTokenInHours string = "EXPIRATION_TIME_RESET_TOKEN_IN_HOURS"
 	accessTokenDurationMinutes      string = "ACCESS_TOKEN_DURATION_MINUTES"
 	refreshTokenDurationDays        string = "REFRESH_TOKEN_DURATION_DAYS"
+	tokenClockSkewSeconds           string = "TOKEN_CLOCK_SKEW_SECONDS"
 	passwordResetTopic              string = "PASSWORD_RESET_TOPIC"
 	accountBlockedTopic             string = "ACCOUNT_BLOCKED_TOPIC"
 	accountCreatedTopic             string = "ACCOUNT_CREATED_TOPIC"
+	emailChangeTopic                string = "EMAIL_CHANGE_TOPIC"
+	emailChangedNoticeTopic         string = "EMAIL_CHANGED_NOTICE_TOPIC"
+	expirationTimeEmailChangeHours  string = "EXPIRATION_TIME_EMAIL_CHANGE_TOKEN_IN_HOURS"
+	expirationTimeEmailRevertHours  string = "EXPIRATION_TIME_EMAIL_REVERT_TOKEN_IN_HOURS"
+	appDomain                       string = "APP_DOMAIN"
+	maxResetTokenAttempts           string = "MAX_RESET_TOKEN_ATTEMPTS"
+	tokenHashKey                    string = "TOKEN_HASH_KEY"
+	uniformAuthResponses            string = "UNIFORM_AUTH_RESPONSES"
+	accountExistsTopic              string = "ACCOUNT_EXISTS_TOPIC"
+	newDeviceLoginTopic             string = "NEW_DEVICE_LOGIN_TOPIC"
+	geoIPDatabasePath               string = "GEOIP_DATABASE_PATH"
+	registrationMode                string = "REGISTRATION_MODE"
+	registrationAllowedDomains      string = "REGISTRATION_ALLOWED_DOMAINS"
+	invitationTopic                 string = "INVITATION_TOPIC"
+	expirationTimeInvitationHours   string = "EXPIRATION_TIME_INVITATION_IN_HOURS"
+	impersonationDurationMinutes    string = "IMPERSONATION_DURATION_MINUTES"
+	impersonationTopic              string = "IMPERSONATION_TOPIC"
+	sessionRevokedTopic             string = "SESSION_REVOKED_TOPIC"
+	loginChallengeTopic             string = "LOGIN_CHALLENGE_TOPIC"
+	loginChallengeDurationMinutes   string = "LOGIN_CHALLENGE_DURATION_MINUTES"
+	maxLoginChallengeAttempts       string = "MAX_LOGIN_CHALLENGE_ATTEMPTS"
 	jwtSecret                              = "JWT_SECRET"
 )
 
-type authenticationConfig struct {
-	BaseBlockDurationMinutes      int
-	MaxLoginAttemptsBeforeBlock   int
-	MinTimeBetweenAttemptsSeconds time.Duration
-	ExpirationTimeResetTokenHours time.Duration
-	AccessTokenDurationMinutes    time.Duration
-	RefreshTokenDurationDays      time.Duration
-	PasswordResetTopic            string
-	AccountBlockedTopic           string
-	AccountCreatedTopic           string
-	JwtSecret                     string
-	PasswordHasher                utils.PasswordHasher
+type AuthenticationConfig struct {
+	BaseBlockDurationMinutes       int
+	MaxLoginAttemptsBeforeBlock    int
+	MinTimeBetweenAttemptsSeconds  time.Duration
+	ExpirationTimeResetTokenHours  time.Duration
+	AccessTokenDurationMinutes     time.Duration
+	RefreshTokenDurationDays       time.Duration
+	TokenClockSkew                 time.Duration
+	PasswordResetTopic             string
+	AccountBlockedTopic            string
+	AccountCreatedTopic            string
+	AccountExistsTopic             string
+	NewDeviceLoginTopic            string
+	GeoIPDatabasePath              string
+	RegistrationMode               string
+	RegistrationAllowedDomains     []string
+	InvitationTopic                string
+	ExpirationTimeInvitationHours  time.Duration
+	ImpersonationDurationMinutes   time.Duration
+	ImpersonationTopic             string
+	SessionRevokedTopic            string
+	LoginChallengeTopic            string
+	LoginChallengeDurationMinutes  time.Duration
+	MaxLoginChallengeAttempts      int
+	EmailChangeTopic               string
+	EmailChangedNoticeTopic        string
+	ExpirationTimeEmailChangeHours time.Duration
+	ExpirationTimeEmailRevertHours time.Duration
+	AppDomain                      string
+	MaxResetTokenAttempts          int
+	UniformAuthResponses           bool
+	JwtSecret                      string
+	PasswordHasher                 utils.PasswordHasher
+	TokenHasher                    utils.TokenHasher
 }
 
-func newAuthenticationConfig() (*authenticationConfig, error) {
+func newAuthenticationConfig() (*AuthenticationConfig, error) {
 	passwordResetTopicValue := getEnvString(passwordResetTopic, "NULL")
 	accountBlockedTopicValue := getEnvString(accountBlockedTopic, "NULL")
 	accountCreatedTopicValue := getEnvString(accountCreatedTopic, "NULL")
@@ -59,17 +113,58 @@ func newAuthenticationConfig() (*authenticationConfig, error) {
 		return nil, errors.New(errorMessage)
 	}
 
-	return &authenticationConfig{
-		BaseBlockDurationMinutes:      baseBlockDurationMinutesValue,
-		MaxLoginAttemptsBeforeBlock:   maxLoginAttemptsBeforeBlockValue,
-		MinTimeBetweenAttemptsSeconds: time.Duration(getEnvInt(minTimeBetweenAttemptsInSeconds, 0)),
-		ExpirationTimeResetTokenHours: time.Duration(getEnvInt(expirationTimeResetTokenInHours, 24)),
-		AccessTokenDurationMinutes:    time.Duration(getEnvInt(accessTokenDurationMinutes, 15)),
-		RefreshTokenDurationDays:      time.Duration(24*getEnvInt(refreshTokenDurationDays, 4)) * time.Hour,
-		PasswordResetTopic:            passwordResetTopicValue,
-		AccountBlockedTopic:           accountBlockedTopicValue,
-		AccountCreatedTopic:           accountCreatedTopicValue,
-		JwtSecret:                     jwtSecret,
-		PasswordHasher:                utils.DefaultBcryptHasher(),
+	registrationModeValue := getEnvString(registrationMode, RegistrationModeOpen)
+	var allowedDomains []string
+	for _, domain := range strings.Split(getEnvString(registrationAllowedDomains, ""), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			allowedDomains = append(allowedDomains, domain)
+		}
+	}
+	switch registrationModeValue {
+	case RegistrationModeOpen, RegistrationModeInviteOnly:
+	case RegistrationModeDomainAllowlist:
+		if len(allowedDomains) == 0 {
+			errorMessage := fmt.Sprintf("error: Registration mode %s needs at least one domain, please check the environment variable: %s", registrationModeValue, registrationAllowedDomains)
+			return nil, errors.New(errorMessage)
+		}
+	default:
+		errorMessage := fmt.Sprintf("error: Registration mode %q is not valid, please check the environment variable: %s", registrationModeValue, registrationMode)
+		return nil, errors.New(errorMessage)
+	}
+
+	return &AuthenticationConfig{
+		BaseBlockDurationMinutes:       baseBlockDurationMinutesValue,
+		MaxLoginAttemptsBeforeBlock:    maxLoginAttemptsBeforeBlockValue,
+		MinTimeBetweenAttemptsSeconds:  time.Duration(getEnvInt(minTimeBetweenAttemptsInSeconds, 0)),
+		ExpirationTimeResetTokenHours:  time.Duration(getEnvInt(expirationTimeResetTokenInHours, 24)),
+		AccessTokenDurationMinutes:     time.Duration(getEnvInt(accessTokenDurationMinutes, 15)),
+		RefreshTokenDurationDays:       time.Duration(24*getEnvInt(refreshTokenDurationDays, 4)) * time.Hour,
+		TokenClockSkew:                 time.Duration(getEnvInt(tokenClockSkewSeconds, 30)) * time.Second,
+		PasswordResetTopic:             passwordResetTopicValue,
+		AccountBlockedTopic:            accountBlockedTopicValue,
+		AccountCreatedTopic:            accountCreatedTopicValue,
+		AccountExistsTopic:             getEnvString(accountExistsTopic, "account-exists"),
+		NewDeviceLoginTopic:            getEnvString(newDeviceLoginTopic, "new-device-login"),
+		GeoIPDatabasePath:              getEnvString(geoIPDatabasePath, ""),
+		RegistrationMode:               registrationModeValue,
+		RegistrationAllowedDomains:     allowedDomains,
+		InvitationTopic:                getEnvString(invitationTopic, "invitation"),
+		ExpirationTimeInvitationHours:  time.Duration(getEnvInt(expirationTimeInvitationHours, 168)),
+		ImpersonationDurationMinutes:   time.Duration(getEnvInt(impersonationDurationMinutes, 30)),
+		ImpersonationTopic:             getEnvString(impersonationTopic, "impersonation"),
+		SessionRevokedTopic:            getEnvString(sessionRevokedTopic, "session-revoked"),
+		LoginChallengeTopic:            getEnvString(loginChallengeTopic, "login-challenge"),
+		LoginChallengeDurationMinutes:  time.Duration(getEnvInt(loginChallengeDurationMinutes, 10)),
+		MaxLoginChallengeAttempts:      getEnvInt(maxLoginChallengeAttempts, 5),
+		EmailChangeTopic:               getEnvString(emailChangeTopic, "email-change"),
+		EmailChangedNoticeTopic:        getEnvString(emailChangedNoticeTopic, "email-changed-notice"),
+		ExpirationTimeEmailChangeHours: time.Duration(getEnvInt(expirationTimeEmailChangeHours, 24)),
+		ExpirationTimeEmailRevertHours: time.Duration(getEnvInt(expirationTimeEmailRevertHours, 72)),
+		AppDomain:                      getEnvString(appDomain, utils.DefaultAppDomain),
+		MaxResetTokenAttempts:          getEnvInt(maxResetTokenAttempts, 5),
+		UniformAuthResponses:           getEnvBool(uniformAuthResponses, true),
+		JwtSecret:                      jwtSecret,
+		PasswordHasher:                 utils.DefaultBcryptHasher(),
+		TokenHasher:                    utils.NewHmacTokenHasher(getEnvString(tokenHashKey, jwtSecret)),
 	}, nil
 }
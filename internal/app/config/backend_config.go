@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+)
+
+const (
+	storageBackend   string = "STORAGE_BACKEND"
+	cacheBackend     string = "CACHE_BACKEND"
+	messagingBackend string = "MESSAGING_BACKEND"
+
+	BackendPostgres = "postgres"
+	BackendRedis    = "redis"
+	BackendKafka    = "kafka"
+	// BackendMemory keeps the state in the process, lost on restart and not shared with other instances. It
+	// is meant for development and tests, which then need no infrastructure.
+	BackendMemory = "memory"
+)
+
+// BackendConfig selects what each adapter of the service talks to: the repositories (Storage), the token
+// block list and the rate limiter (Cache), and the event producer and command consumer (Messaging).
+type BackendConfig struct {
+	Storage   string
+	Cache     string
+	Messaging string
+}
+
+func newBackendConfig() (*BackendConfig, error) {
+	cfg := &BackendConfig{
+		Storage:   getEnvString(storageBackend, BackendPostgres),
+		Cache:     getEnvString(cacheBackend, BackendRedis),
+		Messaging: getEnvString(messagingBackend, BackendKafka),
+	}
+	if cfg.Storage != BackendPostgres && cfg.Storage != BackendMemory {
+		return nil, errors.New("error: STORAGE_BACKEND must be one of postgres and memory")
+	}
+	if cfg.Cache != BackendRedis && cfg.Cache != BackendMemory {
+		return nil, errors.New("error: CACHE_BACKEND must be one of redis and memory")
+	}
+	if cfg.Messaging != BackendKafka && cfg.Messaging != BackendMemory {
+		return nil, errors.New("error: MESSAGING_BACKEND must be one of kafka and memory")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	commandTopic              string = "COMMAND_TOPIC"
+	commandReplyTopic         string = "COMMAND_REPLY_TOPIC"
+	commandDeadLetterTopic    string = "COMMAND_DEAD_LETTER_TOPIC"
+	commandConsumerGroup      string = "COMMAND_CONSUMER_GROUP"
+	commandMaxAttempts        string = "COMMAND_MAX_ATTEMPTS"
+	commandRetryBackoffMillis string = "COMMAND_RETRY_BACKOFF_MS"
+	commandProducerKeys       string = "COMMAND_PRODUCER_KEYS"
+
+	// minProducerKeyLength keeps the signing keys of the producers out of reach of guessing
+	minProducerKeyLength = 16
+)
+
+// CommandConfig controls the consumer of the command topic. When recording or answering a command fails it
+// is tried again after RetryBackoff, doubling every time, until it failed MaxAttempts times and goes to the
+// dead-letter topic. Only the producers in ProducerKeys may send commands, each signing them with its key;
+// the others go to the dead-letter topic unexecuted.
+type CommandConfig struct {
+	Topic           string
+	ReplyTopic      string
+	DeadLetterTopic string
+	ConsumerGroup   string
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	// ProducerKeys are the signing keys by CloudEvents source, read from "source=key" pairs separated by commas
+	ProducerKeys map[string]string
+}
+
+func newCommandConfig() (*CommandConfig, error) {
+	cfg := &CommandConfig{
+		Topic:           getEnvString(commandTopic, "idp-commands"),
+		ReplyTopic:      getEnvString(commandReplyTopic, "idp-command-results"),
+		DeadLetterTopic: getEnvString(commandDeadLetterTopic, "idp-commands-dead-letter"),
+		ConsumerGroup:   getEnvString(commandConsumerGroup, "automation-hub-idp"),
+		MaxAttempts:     getEnvInt(commandMaxAttempts, 5),
+		RetryBackoff:    time.Duration(getEnvInt(commandRetryBackoffMillis, 500)) * time.Millisecond,
+	}
+	producerKeys, err := parseProducerKeys(getEnvString(commandProducerKeys, ""))
+	if err != nil {
+		return nil, err
+	}
+	cfg.ProducerKeys = producerKeys
+	if cfg.MaxAttempts <= 0 || cfg.RetryBackoff < 0 {
+		return nil, errors.New("error: COMMAND_MAX_ATTEMPTS must be positive and COMMAND_RETRY_BACKOFF_MS must not be negative")
+	}
+	if cfg.Topic == cfg.ReplyTopic || cfg.Topic == cfg.DeadLetterTopic {
+		return nil, errors.New("error: COMMAND_REPLY_TOPIC and COMMAND_DEAD_LETTER_TOPIC must differ from COMMAND_TOPIC")
+	}
+	return cfg, nil
+}
+
+func parseProducerKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		source, key, found := strings.Cut(pair, "=")
+		source = strings.TrimSpace(source)
+		if !found || source == "" || len(key) < minProducerKeyLength {
+			return nil, errors.New("error: COMMAND_PRODUCER_KEYS must be source=key pairs with keys of at least 16 characters")
+		}
+		keys[source] = key
+	}
+	return keys, nil
+}
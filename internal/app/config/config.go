@@ -5,38 +5,85 @@ import (
 	"strconv"
 )
 
-var (
-	AuthenticationConfig *authenticationConfig
-	ServerConfig         *serverConfig
-	KafkaConfig          *kafkaConfig
-	PostgresConfig       *postgresConfig
-	RedisConfig          *redisConfig
-)
+// Config is the whole configuration of the service, read from the environment by Load.
+type Config struct {
+	Kafka          *KafkaConfig
+	Postgres       *PostgresConfig
+	Redis          *RedisConfig
+	Server         *ServerConfig
+	Authentication *AuthenticationConfig
+	RateLimit      *RateLimitConfig
+	Risk           *RiskConfig
+	Outbox         *OutboxConfig
+	Webhook        *WebhookConfig
+	Command        *CommandConfig
+	Logging        *LoggingConfig
+	Tracing        *TracingConfig
+	Health         *HealthConfig
+	Backend        *BackendConfig
+}
 
-func Setup() error {
+// Load reads the configuration from the environment.
+func Load() (*Config, error) {
 	var err error
-	KafkaConfig, err = newKafkaConfig()
+	cfg := &Config{}
+	cfg.Kafka, err = newKafkaConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	PostgresConfig, err = newPostgresConfig()
+	cfg.Postgres, err = newPostgresConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	RedisConfig, err = newRedisConfig()
+	cfg.Redis, err = newRedisConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	ServerConfig, err = newServerConfig()
+	cfg.Server, err = newServerConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	AuthenticationConfig, err = newAuthenticationConfig()
+	cfg.Authentication, err = newAuthenticationConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	cfg.RateLimit, err = newRateLimitConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Risk, err = newRiskConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Outbox, err = newOutboxConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Webhook, err = newWebhookConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Command, err = newCommandConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Logging, err = newLoggingConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Tracing, err = newTracingConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Health, err = newHealthConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Backend, err = newBackendConfig()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 func getEnvInt(key string, defaultValue int) int {
@@ -55,3 +102,13 @@ func getEnvString(key string, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		boolVal, err := strconv.ParseBool(value)
+		if err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
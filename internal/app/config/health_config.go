@@ -0,0 +1,26 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	healthCheckTimeoutMillis string = "HEALTH_CHECK_TIMEOUT_MS"
+)
+
+// HealthConfig bounds the dependency checks of the readiness probe. Every check gets CheckTimeout, and the
+// checks run in parallel, so the probe answers within about that time even when a dependency hangs.
+type HealthConfig struct {
+	CheckTimeout time.Duration
+}
+
+func newHealthConfig() (*HealthConfig, error) {
+	cfg := &HealthConfig{
+		CheckTimeout: time.Duration(getEnvInt(healthCheckTimeoutMillis, 2000)) * time.Millisecond,
+	}
+	if cfg.CheckTimeout <= 0 {
+		return nil, errors.New("error: HEALTH_CHECK_TIMEOUT_MS must be positive")
+	}
+	return cfg, nil
+}
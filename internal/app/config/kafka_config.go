@@ -1,7 +1,9 @@
 package config
 
 import (
+	"automation-hub-idp/internal/app/events"
 	"errors"
+	"net/url"
 	"strings"
 )
 
@@ -10,16 +12,22 @@ const (
 	mailTopic   string = "MAIL_TOPIC"
 	clientID    string = "KAFKA_CLIENT_ID"
 	brokersAddr string = "BROKERS_ADDR"
+	eventSource string = "EVENT_SOURCE"
+	schemaBase  string = "EVENT_SCHEMA_BASE_URI"
 )
 
-type kafkaConfig struct {
+type KafkaConfig struct {
 	LoggerTopic string
 	MailTopic   string
 	ClientID    string
 	BrokersAddr []string
+	// EventSource is the CloudEvents source of the events this service publishes
+	EventSource string
+	// EventSchemaBaseURI is the absolute URI the event schemas are published under
+	EventSchemaBaseURI string
 }
 
-func newKafkaConfig() (*kafkaConfig, error) {
+func newKafkaConfig() (*KafkaConfig, error) {
 	logTopic := getEnvString(loggerTopic, "NULL")
 	if logTopic == "NULL" {
 		return nil, errors.New("error: LoggerTopic is not set, please check the environment variable: " + loggerTopic)
@@ -35,10 +43,22 @@ func newKafkaConfig() (*kafkaConfig, error) {
 
 	brokersList := strings.Split(brokers, ",")
 
-	return &kafkaConfig{
-		LoggerTopic: logTopic,
-		MailTopic:   emailTopic,
-		ClientID:    getEnvString(clientID, "IDP-AUTOMATIONS-HUB"),
-		BrokersAddr: brokersList,
+	schemaBaseURI := getEnvString(schemaBase, "https://schemas.automation-hub.example/idp/")
+	if base, err := url.Parse(schemaBaseURI); err != nil || !base.IsAbs() {
+		return nil, errors.New("error: EventSchemaBaseURI must be an absolute URI, please check the environment variable: " + schemaBase)
+	}
+
+	return &KafkaConfig{
+		LoggerTopic:        logTopic,
+		MailTopic:          emailTopic,
+		ClientID:           getEnvString(clientID, "IDP-AUTOMATIONS-HUB"),
+		BrokersAddr:        brokersList,
+		EventSource:        getEnvString(eventSource, "automation-hub-idp"),
+		EventSchemaBaseURI: schemaBaseURI,
 	}, nil
 }
+
+// Origin is who the events this service publishes come from.
+func (c *KafkaConfig) Origin() events.Origin {
+	return events.Origin{Source: c.EventSource, SchemaBaseURI: c.EventSchemaBaseURI}
+}
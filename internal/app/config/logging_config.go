@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	logLevel                    string = "LOG_LEVEL"
+	logSinks                    string = "LOG_SINKS"
+	logKafkaBufferSize          string = "LOG_KAFKA_BUFFER_SIZE"
+	logKafkaBatchSize           string = "LOG_KAFKA_BATCH_SIZE"
+	logKafkaFlushIntervalMillis string = "LOG_KAFKA_FLUSH_INTERVAL_MS"
+	logPseudonymKey             string = "LOG_PSEUDONYM_KEY"
+
+	// LogSinkStdout writes log records as JSON lines to stdout
+	LogSinkStdout = "stdout"
+	// LogSinkKafka publishes log records to the logger topic in batches, in the background
+	LogSinkKafka = "kafka"
+)
+
+// LoggingConfig controls the logger. Records below Level are dropped, the others go to every sink. The
+// Kafka sink buffers up to KafkaBufferSize records and drops new ones while the buffer is full, so a slow
+// broker never holds up a request; the buffer is published every KafkaFlushInterval, or as soon as
+// KafkaBatchSize records are waiting. With a PseudonymKey emails in logs are replaced by a keyed hash, which
+// keeps the lines of one user correlatable, without one they are masked.
+type LoggingConfig struct {
+	Level              slog.Level
+	Sinks              []string
+	KafkaBufferSize    int
+	KafkaBatchSize     int
+	KafkaFlushInterval time.Duration
+	PseudonymKey       string
+}
+
+func newLoggingConfig() (*LoggingConfig, error) {
+	cfg := &LoggingConfig{
+		KafkaBufferSize:    getEnvInt(logKafkaBufferSize, 10000),
+		KafkaBatchSize:     getEnvInt(logKafkaBatchSize, 100),
+		KafkaFlushInterval: time.Duration(getEnvInt(logKafkaFlushIntervalMillis, 1000)) * time.Millisecond,
+		PseudonymKey:       getEnvString(logPseudonymKey, ""),
+	}
+	if err := cfg.Level.UnmarshalText([]byte(getEnvString(logLevel, "info"))); err != nil {
+		return nil, errors.New("error: LOG_LEVEL must be one of debug, info, warn and error")
+	}
+	for _, sink := range strings.Split(getEnvString(logSinks, LogSinkStdout+","+LogSinkKafka), ",") {
+		sink = strings.TrimSpace(sink)
+		if sink == "" {
+			continue
+		}
+		if sink != LogSinkStdout && sink != LogSinkKafka {
+			return nil, errors.New("error: LOG_SINKS must be a comma separated list of stdout and kafka, got " + sink)
+		}
+		cfg.Sinks = append(cfg.Sinks, sink)
+	}
+	if cfg.KafkaBufferSize <= 0 || cfg.KafkaBatchSize <= 0 || cfg.KafkaFlushInterval <= 0 {
+		return nil, errors.New("error: LOG_KAFKA_BUFFER_SIZE, LOG_KAFKA_BATCH_SIZE and LOG_KAFKA_FLUSH_INTERVAL_MS must be positive")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	outboxPollIntervalMillis  string = "OUTBOX_POLL_INTERVAL_MS"
+	outboxBatchSize           string = "OUTBOX_BATCH_SIZE"
+	outboxRetryBackoffSeconds string = "OUTBOX_RETRY_BACKOFF_SECONDS"
+	outboxMaxBackoffSeconds   string = "OUTBOX_MAX_BACKOFF_SECONDS"
+	outboxMaxAttempts         string = "OUTBOX_MAX_ATTEMPTS"
+	outboxDeadLetterTopic     string = "OUTBOX_DEAD_LETTER_TOPIC"
+)
+
+// OutboxConfig controls the relay that publishes the outbox. A failed message is retried after RetryBackoff,
+// doubling with every further failure up to MaxBackoff, until it failed MaxAttempts times and goes to the
+// dead-letter topic.
+type OutboxConfig struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	RetryBackoff    time.Duration
+	MaxBackoff      time.Duration
+	MaxAttempts     int
+	DeadLetterTopic string
+}
+
+func newOutboxConfig() (*OutboxConfig, error) {
+	cfg := &OutboxConfig{
+		PollInterval:    time.Duration(getEnvInt(outboxPollIntervalMillis, 1000)) * time.Millisecond,
+		BatchSize:       getEnvInt(outboxBatchSize, 100),
+		RetryBackoff:    time.Duration(getEnvInt(outboxRetryBackoffSeconds, 1)) * time.Second,
+		MaxBackoff:      time.Duration(getEnvInt(outboxMaxBackoffSeconds, 300)) * time.Second,
+		MaxAttempts:     getEnvInt(outboxMaxAttempts, 20),
+		DeadLetterTopic: getEnvString(outboxDeadLetterTopic, "idp-outbox-dead-letter"),
+	}
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.MaxAttempts <= 0 {
+		return nil, errors.New("error: OUTBOX_POLL_INTERVAL_MS, OUTBOX_BATCH_SIZE and OUTBOX_MAX_ATTEMPTS must be positive")
+	}
+	if cfg.RetryBackoff > cfg.MaxBackoff {
+		return nil, errors.New("error: OUTBOX_RETRY_BACKOFF_SECONDS must not be greater than OUTBOX_MAX_BACKOFF_SECONDS")
+	}
+	return cfg, nil
+}
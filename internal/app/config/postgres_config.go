@@ -13,7 +13,7 @@ const (
 	dbPort     string = "DB_PORT"
 )
 
-type postgresConfig struct {
+type PostgresConfig struct {
 	User     string
 	Password string
 	DbName   string
@@ -21,7 +21,7 @@ type postgresConfig struct {
 	DbPort   int
 }
 
-func newPostgresConfig() (*postgresConfig, error) {
+func newPostgresConfig() (*PostgresConfig, error) {
 
 	port := getEnvInt(dbPort, -1)
 	if port == -1 {
@@ -43,7 +43,7 @@ func newPostgresConfig() (*postgresConfig, error) {
 		return nil, errors.New(errorMessage)
 	}
 
-	return &postgresConfig{
+	return &PostgresConfig{
 		User:     getEnvString(userDb, ""),
 		Password: getEnvString(passwordDb, ""),
 		DbName:   name,
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rateLimitEnabled                 string = "RATE_LIMIT_ENABLED"
+	rateLimitDefaultPerIP            string = "RATE_LIMIT_DEFAULT_PER_IP"
+	rateLimitLoginPerIP              string = "RATE_LIMIT_LOGIN_PER_IP"
+	rateLimitLoginPerAccount         string = "RATE_LIMIT_LOGIN_PER_ACCOUNT"
+	rateLimitRegisterPerIP           string = "RATE_LIMIT_REGISTER_PER_IP"
+	rateLimitPasswordResetPerIP      string = "RATE_LIMIT_PASSWORD_RESET_PER_IP"
+	rateLimitPasswordResetPerAccount string = "RATE_LIMIT_PASSWORD_RESET_PER_ACCOUNT"
+)
+
+// RateLimitConfig holds the limits applied per route. Each limit is written as
+// "<requests>/<window>", e.g. "10/1m" allows ten requests per minute.
+type RateLimitConfig struct {
+	Enabled                 bool
+	DefaultPerIP            iservice.RateLimit
+	LoginPerIP              iservice.RateLimit
+	LoginPerAccount         iservice.RateLimit
+	RegisterPerIP           iservice.RateLimit
+	PasswordResetPerIP      iservice.RateLimit
+	PasswordResetPerAccount iservice.RateLimit
+}
+
+func newRateLimitConfig() (*RateLimitConfig, error) {
+	cfg := &RateLimitConfig{Enabled: getEnvBool(rateLimitEnabled, true)}
+	limits := []struct {
+		key          string
+		defaultValue string
+		target       *iservice.RateLimit
+	}{
+		{rateLimitDefaultPerIP, "300/1m", &cfg.DefaultPerIP},
+		{rateLimitLoginPerIP, "20/1m", &cfg.LoginPerIP},
+		{rateLimitLoginPerAccount, "10/15m", &cfg.LoginPerAccount},
+		{rateLimitRegisterPerIP, "10/1h", &cfg.RegisterPerIP},
+		{rateLimitPasswordResetPerIP, "10/1h", &cfg.PasswordResetPerIP},
+		{rateLimitPasswordResetPerAccount, "3/1h", &cfg.PasswordResetPerAccount},
+	}
+
+	for _, limit := range limits {
+		value := getEnvString(limit.key, limit.defaultValue)
+		parsed, err := parseRateLimit(value)
+		if err != nil {
+			errorMessage := fmt.Sprintf("error: Rate limit %q is not valid (%v), please check the environment variable: %s", value, err, limit.key)
+			return nil, errors.New(errorMessage)
+		}
+		*limit.target = parsed
+	}
+
+	return cfg, nil
+}
+
+func parseRateLimit(value string) (iservice.RateLimit, error) {
+	requestsStr, windowStr, found := strings.Cut(value, "/")
+	if !found {
+		return iservice.RateLimit{}, errors.New("expected <requests>/<window>")
+	}
+	requests, err := strconv.Atoi(requestsStr)
+	if err != nil || requests <= 0 {
+		return iservice.RateLimit{}, errors.New("requests must be a positive number")
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return iservice.RateLimit{}, errors.New("window must be a positive duration")
+	}
+	return iservice.RateLimit{Requests: requests, Window: window}, nil
+}
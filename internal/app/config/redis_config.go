@@ -4,12 +4,12 @@ const (
 	redisAddr string = "REDIS_ADDR"
 )
 
-type redisConfig struct {
+type RedisConfig struct {
 	RedisAddr string
 }
 
-func newRedisConfig() (*redisConfig, error) {
-	return &redisConfig{
+func newRedisConfig() (*RedisConfig, error) {
+	return &RedisConfig{
 		RedisAddr: getEnvString(redisAddr, "redis:6379"),
 	}, nil
 }
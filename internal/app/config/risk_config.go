@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	riskEnabled               string = "RISK_ENABLED"
+	riskEnforce               string = "RISK_ENFORCE"
+	riskStepUpThreshold       string = "RISK_STEP_UP_THRESHOLD"
+	riskDenyThreshold         string = "RISK_DENY_THRESHOLD"
+	riskNewDeviceScore        string = "RISK_NEW_DEVICE_SCORE"
+	riskImpossibleTravelScore string = "RISK_IMPOSSIBLE_TRAVEL_SCORE"
+	riskMaxTravelSpeedKmh     string = "RISK_MAX_TRAVEL_SPEED_KMH"
+	riskFailureRateScore      string = "RISK_FAILURE_RATE_SCORE"
+	riskFailureRateThreshold  string = "RISK_FAILURE_RATE_THRESHOLD"
+	riskFailureRateWindowMins string = "RISK_FAILURE_RATE_WINDOW_MINUTES"
+	riskAnonymizerScore       string = "RISK_ANONYMIZER_SCORE"
+	riskAnonymizerListPath    string = "RISK_ANONYMIZER_LIST_PATH"
+)
+
+// RiskConfig holds the thresholds and signal weights of the login risk assessment. A login scoring at
+// least StepUpThreshold has to complete a login challenge sent to the user's mailbox, one scoring at least
+// DenyThreshold is refused. With Enforce unset the decisions are only logged.
+type RiskConfig struct {
+	Enabled               bool
+	Enforce               bool
+	StepUpThreshold       int
+	DenyThreshold         int
+	NewDeviceScore        int
+	ImpossibleTravelScore int
+	MaxTravelSpeedKmh     float64
+	FailureRateScore      int
+	FailureRateThreshold  int
+	FailureRateWindow     time.Duration
+	AnonymizerScore       int
+	AnonymizerListPath    string
+}
+
+func newRiskConfig() (*RiskConfig, error) {
+	cfg := &RiskConfig{
+		Enabled:               getEnvBool(riskEnabled, true),
+		Enforce:               getEnvBool(riskEnforce, true),
+		StepUpThreshold:       getEnvInt(riskStepUpThreshold, 50),
+		DenyThreshold:         getEnvInt(riskDenyThreshold, 90),
+		NewDeviceScore:        getEnvInt(riskNewDeviceScore, 20),
+		ImpossibleTravelScore: getEnvInt(riskImpossibleTravelScore, 60),
+		MaxTravelSpeedKmh:     float64(getEnvInt(riskMaxTravelSpeedKmh, 1000)),
+		FailureRateScore:      getEnvInt(riskFailureRateScore, 30),
+		FailureRateThreshold:  getEnvInt(riskFailureRateThreshold, 10),
+		FailureRateWindow:     time.Duration(getEnvInt(riskFailureRateWindowMins, 15)) * time.Minute,
+		AnonymizerScore:       getEnvInt(riskAnonymizerScore, 40),
+		AnonymizerListPath:    getEnvString(riskAnonymizerListPath, ""),
+	}
+	if cfg.StepUpThreshold > cfg.DenyThreshold {
+		return nil, errors.New("error: RISK_STEP_UP_THRESHOLD must not be greater than RISK_DENY_THRESHOLD")
+	}
+	return cfg, nil
+}
@@ -1,22 +1,47 @@
 package config
 
 import (
+	"automation-hub-idp/internal/app/utils"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	webServerPort string = "WEB_SERVER_PORT"
-	baseURL       string = "BASE_URL"
+	webServerPort  string = "WEB_SERVER_PORT"
+	baseURL        string = "BASE_URL"
+	trustedProxies string = "TRUSTED_PROXIES"
+	ipRuleCacheTTL string = "IP_RULE_CACHE_TTL_SECONDS"
+	readinessDelay string = "SHUTDOWN_READINESS_DELAY_SECONDS"
+	drainTimeout   string = "SHUTDOWN_DRAIN_TIMEOUT_SECONDS"
+	closeTimeout   string = "SHUTDOWN_CLOSE_TIMEOUT_SECONDS"
+	metricsToken   string = "METRICS_TOKEN"
 )
 
-type serverConfig struct {
+// minMetricsTokenLength keeps the token of the metrics out of reach of guessing.
+const minMetricsTokenLength = 16
+
+type ServerConfig struct {
 	Port    string
 	BaseURL string
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For header is believed
+	TrustedProxies []string
+	IPRuleCacheTTL time.Duration
+	// ReadinessDelay is how long readiness fails on shutdown before the server stops accepting connections,
+	// time for the load balancer to stop routing to it
+	ReadinessDelay time.Duration
+	// DrainTimeout bounds the wait for in-flight requests and background workers on shutdown
+	DrainTimeout time.Duration
+	// CloseTimeout bounds the release of the infrastructure after the drain, the flush of the Kafka producer
+	// included, so a drain using all of its time still leaves the producer time to deliver
+	CloseTimeout time.Duration
+	// MetricsToken is the bearer token scrapers send to /metrics. Without one the metrics are not served.
+	MetricsToken string
 }
 
-func newServerConfig() (*serverConfig, error) {
+func newServerConfig() (*ServerConfig, error) {
 	port := getEnvString(webServerPort, "8080")
 	// validate port (if port is just numbers and is between 0 and 65535)
 	if len(port) < 1 {
@@ -35,8 +60,35 @@ func newServerConfig() (*serverConfig, error) {
 
 	baseURL := getEnvString(baseURL, "/api")
 
-	return &serverConfig{
-		Port:    port,
-		BaseURL: baseURL,
-	}, nil
+	var proxies []string
+	for _, proxy := range strings.Split(getEnvString(trustedProxies, ""), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if _, err := utils.ParseNetwork(proxy); err != nil {
+			errorMessage := fmt.Sprintf("error: Trusted proxy %q is not valid, please check the environment variable: %s", proxy, trustedProxies)
+			return nil, errors.New(errorMessage)
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	cfg := &ServerConfig{
+		Port:           port,
+		BaseURL:        baseURL,
+		TrustedProxies: proxies,
+		IPRuleCacheTTL: time.Duration(getEnvInt(ipRuleCacheTTL, 30)) * time.Second,
+		ReadinessDelay: time.Duration(getEnvInt(readinessDelay, 5)) * time.Second,
+		DrainTimeout:   time.Duration(getEnvInt(drainTimeout, 20)) * time.Second,
+		CloseTimeout:   time.Duration(getEnvInt(closeTimeout, 10)) * time.Second,
+		MetricsToken:   getEnvString(metricsToken, ""),
+	}
+	if cfg.ReadinessDelay < 0 || cfg.DrainTimeout <= 0 || cfg.CloseTimeout <= 0 {
+		return nil, errors.New("error: SHUTDOWN_READINESS_DELAY_SECONDS must not be negative and SHUTDOWN_DRAIN_TIMEOUT_SECONDS and SHUTDOWN_CLOSE_TIMEOUT_SECONDS must be positive")
+	}
+	if cfg.MetricsToken != "" && len(cfg.MetricsToken) < minMetricsTokenLength {
+		errorMessage := fmt.Sprintf("error: The metrics token must have at least %d characters, please check the environment variable: %s", minMetricsTokenLength, metricsToken)
+		return nil, errors.New(errorMessage)
+	}
+	return cfg, nil
 }
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+)
+
+const (
+	tracingExporter      string = "TRACING_EXPORTER"
+	tracingOTLPEndpoint  string = "TRACING_OTLP_ENDPOINT"
+	tracingOTLPInsecure  string = "TRACING_OTLP_INSECURE"
+	tracingServiceName   string = "TRACING_SERVICE_NAME"
+	tracingSamplePercent string = "TRACING_SAMPLE_PERCENT"
+
+	// TracingExporterNone records no spans but still propagates the trace context of callers
+	TracingExporterNone = "none"
+	// TracingExporterStdout writes finished spans as JSON to stdout, for local debugging
+	TracingExporterStdout = "stdout"
+	// TracingExporterOTLP sends spans over OTLP/HTTP to a collector
+	TracingExporterOTLP = "otlp"
+)
+
+// TracingConfig controls where spans go. Traces started by this service are kept with SampleRatio, traces
+// continued from a caller follow the caller's sampling decision.
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	ServiceName  string
+	SampleRatio  float64
+}
+
+func newTracingConfig() (*TracingConfig, error) {
+	cfg := &TracingConfig{
+		Exporter:     getEnvString(tracingExporter, TracingExporterNone),
+		OTLPEndpoint: getEnvString(tracingOTLPEndpoint, "otel-collector:4318"),
+		OTLPInsecure: getEnvBool(tracingOTLPInsecure, true),
+		ServiceName:  getEnvString(tracingServiceName, "automation-hub-idp"),
+		SampleRatio:  float64(getEnvInt(tracingSamplePercent, 100)) / 100,
+	}
+	switch cfg.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		return nil, errors.New("error: TRACING_EXPORTER must be one of none, stdout and otlp")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, errors.New("error: TRACING_SAMPLE_PERCENT must be between 0 and 100")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	webhookPollIntervalMillis   string = "WEBHOOK_POLL_INTERVAL_MS"
+	webhookBatchSize            string = "WEBHOOK_BATCH_SIZE"
+	webhookTimeoutSeconds       string = "WEBHOOK_TIMEOUT_SECONDS"
+	webhookMaxAttempts          string = "WEBHOOK_MAX_ATTEMPTS"
+	webhookRetryBackoffSeconds  string = "WEBHOOK_RETRY_BACKOFF_SECONDS"
+	webhookMaxBackoffSeconds    string = "WEBHOOK_MAX_BACKOFF_SECONDS"
+	webhookAllowPrivateNetworks string = "WEBHOOK_ALLOW_PRIVATE_NETWORKS"
+)
+
+// WebhookConfig controls the webhook dispatcher. A failed delivery is retried after RetryBackoff, doubling
+// with every further failure up to MaxBackoff, until it failed MaxAttempts times. Webhook URLs are entered
+// by organization owners, so unless AllowPrivateNetworks is set deliveries to loopback and private addresses
+// are refused.
+type WebhookConfig struct {
+	PollInterval         time.Duration
+	BatchSize            int
+	Timeout              time.Duration
+	MaxAttempts          int
+	RetryBackoff         time.Duration
+	MaxBackoff           time.Duration
+	AllowPrivateNetworks bool
+}
+
+func newWebhookConfig() (*WebhookConfig, error) {
+	cfg := &WebhookConfig{
+		PollInterval:         time.Duration(getEnvInt(webhookPollIntervalMillis, 1000)) * time.Millisecond,
+		BatchSize:            getEnvInt(webhookBatchSize, 20),
+		Timeout:              time.Duration(getEnvInt(webhookTimeoutSeconds, 10)) * time.Second,
+		MaxAttempts:          getEnvInt(webhookMaxAttempts, 8),
+		RetryBackoff:         time.Duration(getEnvInt(webhookRetryBackoffSeconds, 30)) * time.Second,
+		MaxBackoff:           time.Duration(getEnvInt(webhookMaxBackoffSeconds, 3600)) * time.Second,
+		AllowPrivateNetworks: getEnvBool(webhookAllowPrivateNetworks, false),
+	}
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.Timeout <= 0 || cfg.MaxAttempts <= 0 {
+		return nil, errors.New("error: WEBHOOK_POLL_INTERVAL_MS, WEBHOOK_BATCH_SIZE, WEBHOOK_TIMEOUT_SECONDS and WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	if cfg.RetryBackoff > cfg.MaxBackoff {
+		return nil, errors.New("error: WEBHOOK_RETRY_BACKOFF_SECONDS must not be greater than WEBHOOK_MAX_BACKOFF_SECONDS")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuditEvent is a security relevant event to be added to the audit log. ActorID is nil for unauthenticated
+// callers, TargetID is nil when the event has no target.
+type AuditEvent struct {
+	Type       string
+	Outcome    string
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   *uuid.UUID
+	IP         string
+	Details    map[string]string
+}
+
+type AuditRecordResponse struct {
+	ID         uuid.UUID         `json:"id"`
+	Sequence   int64             `json:"sequence"`
+	EventType  string            `json:"eventType"`
+	Outcome    string            `json:"outcome"`
+	ActorID    *uuid.UUID        `json:"actorId,omitempty"`
+	TargetType string            `json:"targetType,omitempty"`
+	TargetID   *uuid.UUID        `json:"targetId,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	PrevHash   string            `json:"prevHash"`
+	Hash       string            `json:"hash"`
+}
+
+type AuditVerification struct {
+	Valid        bool   `json:"valid"`
+	Checked      int64  `json:"checked"`
+	FirstInvalid *int64 `json:"firstInvalid,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
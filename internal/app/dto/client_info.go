@@ -0,0 +1,8 @@
+package dto
+
+// ClientInfo describes where a request came from.
+type ClientInfo struct {
+	IP             string
+	UserAgent      string
+	AcceptLanguage string
+}
@@ -0,0 +1,15 @@
+package dto
+
+// HealthResponse is the answer of the health probes. Readiness adds the result of every dependency check.
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+type ComponentHealth struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"durationMs"`
+	// Error says whether a failed check timed out or failed, the details are only logged
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ImpersonationRequest struct {
+	TargetUserID uuid.UUID `json:"targetUserId" binding:"required"`
+	Reason       string    `json:"reason" binding:"required"`
+}
+
+type ImpersonationDetails struct {
+	SessionID   uuid.UUID `json:"sessionId"`
+	AccessToken string    `json:"accessToken" log:"sensitive"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+type ImpersonationSessionResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	ActorEmail string     `json:"actorEmail"`
+	Reason     string     `json:"reason"`
+	StartedAt  time.Time  `json:"startedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	EndedAt    *time.Time `json:"endedAt,omitempty"`
+}
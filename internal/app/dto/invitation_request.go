@@ -0,0 +1,13 @@
+package dto
+
+import "github.com/google/uuid"
+
+type InvitationRequest struct {
+	Email          string     `json:"email" binding:"required"`
+	Role           string     `json:"role" binding:"required"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+}
+
+type AcceptInvitationDTO struct {
+	Password string `json:"password" binding:"required" log:"sensitive"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type InvitationResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+}
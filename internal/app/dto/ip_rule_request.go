@@ -0,0 +1,11 @@
+package dto
+
+import "github.com/google/uuid"
+
+type IPRuleRequest struct {
+	Scope          string     `json:"scope" binding:"required"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+	Action         string     `json:"action" binding:"required"`
+	CIDR           string     `json:"cidr" binding:"required"`
+	Description    string     `json:"description,omitempty"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type IPRuleResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Scope          string     `json:"scope"`
+	OrganizationID *uuid.UUID `json:"organizationId,omitempty"`
+	Action         string     `json:"action"`
+	CIDR           string     `json:"cidr"`
+	Description    string     `json:"description,omitempty"`
+	CreatedBy      uuid.UUID  `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "time"
+
+type LoginAttemptResponse struct {
+	Outcome   string    `json:"outcome"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+	NewDevice bool      `json:"newDevice"`
+	CreatedAt time.Time `json:"createdAt"`
+}
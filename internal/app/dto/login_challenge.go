@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LoginChallengeResponse answers a login that needs a step-up. The code was sent to the user's mailbox.
+type LoginChallengeResponse struct {
+	StepUpRequired bool      `json:"stepUpRequired"`
+	ChallengeID    uuid.UUID `json:"challengeId"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+type LoginChallengeDTO struct {
+	ChallengeID uuid.UUID `json:"challengeId" binding:"required"`
+	Code        string    `json:"code" binding:"required" log:"sensitive"`
+}
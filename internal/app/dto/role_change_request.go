@@ -0,0 +1,13 @@
+package dto
+
+import "github.com/google/uuid"
+
+type RoleChangeRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UserRoleResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+	Role  string    `json:"role"`
+}
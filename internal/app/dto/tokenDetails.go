@@ -1,8 +1,8 @@
 package dto
 
 type TokenDetails struct {
-	AccessToken  string
-	RefreshToken string
+	AccessToken  string `log:"sensitive"`
+	RefreshToken string `log:"sensitive"`
 	AccessUUID   string
 	RefreshUUID  string
 	AtExpires    int64
@@ -1,6 +1,6 @@
 package dto
 
 type TokenDto struct {
-	AccessToken  string `json:"access_token,omitempty"`
-	RefreshToken string `json:"refresh_token,omitempty"`
+	AccessToken  string `json:"access_token,omitempty" log:"sensitive"`
+	RefreshToken string `json:"refresh_token,omitempty" log:"sensitive"`
 }
@@ -7,5 +7,5 @@ import (
 type UserDTO struct {
 	ID       uuid.UUID `json:"id,omitempty"`
 	Email    string    `json:"email"`
-	Password string    `json:"password,omitempty"`
+	Password string    `json:"password,omitempty" log:"sensitive"`
 }
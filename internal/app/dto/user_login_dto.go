@@ -2,5 +2,5 @@ package dto
 
 type UserLoginDTO struct {
 	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password" binding:"required" log:"sensitive"`
 }
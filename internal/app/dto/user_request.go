@@ -5,5 +5,5 @@ import "github.com/google/uuid"
 type UserRequest struct {
 	ID       uuid.UUID `json:"id,omitempty"`
 	Email    string    `json:"email,omitempty"`
-	Password string    `json:"password,omitempty"`
+	Password string    `json:"password,omitempty" log:"sensitive"`
 }
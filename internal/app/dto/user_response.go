@@ -5,6 +5,7 @@ import (
 )
 
 type UserResponse struct {
-	ID    uuid.UUID `json:"id"`
-	Email string    `json:"email"`
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	PendingEmail string    `json:"pendingEmail,omitempty"`
 }
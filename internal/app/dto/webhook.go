@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// WebhookRequest subscribes a URL to events. Without a secret one is generated, without event types the
+// subscription receives every event type offered to webhooks.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	Secret     string   `json:"secret,omitempty" log:"sensitive"`
+	EventTypes []string `json:"eventTypes,omitempty"`
+}
+
+// WebhookResponse only carries the secret in the response to its creation.
+type WebhookResponse struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organizationId"`
+	URL            string    `json:"url"`
+	Secret         string    `json:"secret,omitempty" log:"sensitive"`
+	EventTypes     []string  `json:"eventTypes"`
+	CreatedBy      uuid.UUID `json:"createdBy"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	SubscriptionID uuid.UUID  `json:"subscriptionId"`
+	EventID        string     `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	RedeliveryOf   *uuid.UUID `json:"redeliveryOf,omitempty"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int        `json:"lastStatusCode,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,197 @@
+package e2e
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminRoutes_RequireAdminRole(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+
+	// Act
+	recorder := c.get("/admin/ip-rules")
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestIPRules_DenyRuleBlocksAddressUntilDeleted(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	admin := h.admin()
+	blocked := h.clientFrom("198.51.100.7")
+
+	// Act
+	created := admin.postJSON("/admin/ip-rules", map[string]string{
+		"scope":       models.IPRuleScopeGlobal,
+		"action":      models.IPRuleActionDeny,
+		"cidr":        "198.51.100.0/24",
+		"description": "abuse",
+	})
+	var rule struct {
+		ID   string `json:"id"`
+		CIDR string `json:"cidr"`
+	}
+	decode(t, created, &rule)
+	whileDenied := blocked.login(adminEmail, adminPassword)
+	listed := admin.get("/admin/ip-rules")
+	deleted := admin.delete("/admin/ip-rules/" + rule.ID)
+	afterDelete := blocked.login(adminEmail, adminPassword)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, created.Code)
+	assert.Equal(t, http.StatusForbidden, whileDenied.Code)
+	assert.Equal(t, http.StatusOK, listed.Code)
+	assert.Contains(t, listed.Body.String(), rule.ID)
+	assert.Equal(t, http.StatusOK, deleted.Code)
+	assert.Equal(t, http.StatusOK, afterDelete.Code)
+	assert.Equal(t, http.StatusNotFound, admin.delete("/admin/ip-rules/"+rule.ID).Code)
+}
+
+func TestChangeRole_GrantsAdminRoutes(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	userID := h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+
+	// Act
+	recorder := h.admin().sendJSON(http.MethodPut, "/admin/users/"+userID+"/role", map[string]string{"role": models.RoleAdmin})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var changed struct {
+		Role string `json:"role"`
+	}
+	decode(t, recorder, &changed)
+	assert.Equal(t, models.RoleAdmin, changed.Role)
+	assert.Equal(t, http.StatusOK, c.get("/admin/ip-rules").Code)
+}
+
+func TestImpersonation_ActsAsTargetUntilStopped(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	targetID := h.register("someone@example.com", "s3cret")
+	admin := h.admin()
+
+	// Act
+	started := admin.postJSON("/admin/impersonations", map[string]string{"targetUserId": targetID, "reason": "support ticket 42"})
+	impersonated := currentUser(t, admin)
+	accountChange := admin.sendJSON(http.MethodPatch, "/user/", map[string]string{"password": "taken-over"})
+	stopped := admin.postJSON("/auth/stop-impersonation", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, started.Code)
+	assert.Equal(t, "someone@example.com", impersonated.Email)
+	assert.Equal(t, http.StatusForbidden, accountChange.Code)
+	assert.Equal(t, http.StatusOK, stopped.Code)
+	// The access token cookie is cleared, the refresh token of the administrator renews their own session
+	assert.Equal(t, adminEmail, currentUser(t, admin).Email)
+	target := h.loggedIn("someone@example.com", "s3cret")
+	var sessions []struct {
+		ActorEmail string     `json:"actorEmail"`
+		Reason     string     `json:"reason"`
+		EndedAt    *time.Time `json:"endedAt"`
+	}
+	recorder := target.get("/user/impersonations")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	decode(t, recorder, &sessions)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, adminEmail, sessions[0].ActorEmail)
+		assert.Equal(t, "support ticket 42", sessions[0].Reason)
+		assert.NotNil(t, sessions[0].EndedAt)
+	}
+}
+
+func TestImpersonation_EndsWhenTokenExpires(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	targetID := h.register("someone@example.com", "s3cret")
+	admin := h.admin()
+	assert.Equal(t, http.StatusOK,
+		admin.postJSON("/admin/impersonations", map[string]string{"targetUserId": targetID, "reason": "support"}).Code)
+
+	// Act
+	h.clock.Advance(29 * time.Minute)
+	during := currentUser(t, admin)
+	h.clock.Advance(2 * time.Minute)
+	after := currentUser(t, admin)
+
+	// Assert
+	assert.Equal(t, "someone@example.com", during.Email)
+	assert.Equal(t, adminEmail, after.Email)
+	var sessions []struct {
+		ExpiresAt time.Time  `json:"expiresAt"`
+		EndedAt   *time.Time `json:"endedAt"`
+	}
+	recorder := h.loggedIn("someone@example.com", "s3cret").get("/user/impersonations")
+	decode(t, recorder, &sessions)
+	if assert.Len(t, sessions, 1) && assert.NotNil(t, sessions[0].EndedAt) {
+		assert.True(t, sessions[0].ExpiresAt.Equal(*sessions[0].EndedAt))
+	}
+}
+
+func TestStopImpersonation_WithoutImpersonation(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	admin := h.admin()
+
+	// Act
+	recorder := admin.postJSON("/auth/stop-impersonation", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestAuditEvents_QueryExportAndVerify(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	h.client().login("someone@example.com", "wrong")
+	admin := h.admin()
+
+	// Act
+	queried := admin.get("/admin/audit-events?eventType=" + models.AuditEventLoginFailed)
+	exported := admin.get("/admin/audit-events/export")
+	verified := admin.get("/admin/audit-events/verify")
+	invalidFilter := admin.get("/admin/audit-events?from=yesterday")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, queried.Code)
+	var records []struct {
+		EventType string            `json:"eventType"`
+		Details   map[string]string `json:"details"`
+	}
+	decode(t, queried, &records)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "someone@example.com", records[0].Details["email"])
+	}
+
+	assert.Equal(t, http.StatusOK, exported.Code)
+	assert.Equal(t, "application/x-ndjson", exported.Header().Get("Content-Type"))
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(exported.Body.String()))
+	for scanner.Scan() {
+		lines++
+	}
+	// registered, two successful logins and the failed one
+	assert.GreaterOrEqual(t, lines, 4)
+
+	assert.Equal(t, http.StatusOK, verified.Code)
+	var verification struct {
+		Valid   bool  `json:"valid"`
+		Checked int64 `json:"checked"`
+	}
+	decode(t, verified, &verification)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, int64(lines), verification.Checked)
+
+	assert.Equal(t, http.StatusBadRequest, invalidFilter.Code)
+}
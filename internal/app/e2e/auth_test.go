@@ -0,0 +1,388 @@
+package e2e
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRegister_ThenLogin_SetsSessionCookies(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	c := h.client()
+
+	// Act
+	registered := c.postJSON("/auth/register", map[string]string{"email": "someone@example.com", "password": "s3cret"})
+	login := c.login("someone@example.com", "s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, registered.Code)
+	assert.Equal(t, http.StatusOK, login.Code)
+	for _, name := range []string{"access_token", "refresh_token"} {
+		cookie, ok := c.cookies[name]
+		if assert.True(t, ok, "%s cookie not set", name) {
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+			assert.Equal(t, "/", cookie.Path)
+		}
+	}
+	assert.Equal(t, h.clock.Now().Add(15*time.Minute).Unix(), c.cookies["access_token"].Expires.Unix())
+	assert.Equal(t, h.clock.Now().Add(4*24*time.Hour).Unix(), c.cookies["refresh_token"].Expires.Unix())
+	var user struct {
+		Email string `json:"email"`
+	}
+	decode(t, c.get("/user/"), &user)
+	assert.Equal(t, "someone@example.com", user.Email)
+}
+
+func TestRegister_TakenEmail_AnswersLikeSuccess(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+
+	// Act
+	recorder := h.client().postJSON("/auth/register", map[string]string{"email": "someone@example.com", "password": "other"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, http.StatusUnauthorized, h.client().login("someone@example.com", "other").Code)
+}
+
+func TestLogin_WrongPassword_SetsNoCookies(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.client()
+
+	// Act
+	recorder := c.login("someone@example.com", "wrong")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Empty(t, c.cookies)
+	assert.Equal(t, http.StatusUnauthorized, c.get("/user/").Code)
+}
+
+func TestLogin_BlocksAccountAfterMaxFailedAttemptsUntilBlockExpires(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.client()
+
+	// Act
+	var failed []int
+	for attempt := 0; attempt < 3; attempt++ {
+		failed = append(failed, c.login("someone@example.com", "wrong").Code)
+	}
+	// BLOCKING_TIME_EXPONENTIATION_BASIS is 2 minutes
+	h.clock.Advance(2*time.Minute - time.Second)
+	whileBlocked := c.login("someone@example.com", "s3cret")
+	h.clock.Advance(2 * time.Second)
+	afterBlock := c.login("someone@example.com", "s3cret")
+
+	// Assert
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized}, failed)
+	assert.Equal(t, http.StatusUnauthorized, whileBlocked.Code)
+	assert.Equal(t, http.StatusOK, afterBlock.Code)
+}
+
+func TestLogin_SuccessResetsFailedAttempts(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.client()
+	c.login("someone@example.com", "wrong")
+	c.login("someone@example.com", "wrong")
+	assert.Equal(t, http.StatusOK, c.login("someone@example.com", "s3cret").Code)
+
+	// Act
+	c.login("someone@example.com", "wrong")
+	c.login("someone@example.com", "wrong")
+	recorder := c.login("someone@example.com", "s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestLogin_ThrottlesAttemptsInQuickSuccession(t *testing.T) {
+	// Arrange
+	h := newHarness(t, map[string]string{"MIN_TIME_BETWEEN_ATTEMPTS_IN_SECONDS": "60"})
+	h.register("someone@example.com", "s3cret")
+	c := h.client()
+	assert.Equal(t, http.StatusUnauthorized, c.login("someone@example.com", "wrong").Code)
+
+	// Act
+	h.clock.Advance(59 * time.Second)
+	throttled := c.login("someone@example.com", "s3cret")
+	h.clock.Advance(time.Second)
+	allowed := c.login("someone@example.com", "s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, throttled.Code)
+	assert.Equal(t, http.StatusOK, allowed.Code)
+}
+
+func TestLogin_RiskyLogin_CompletesWithTheCodeSentToTheUser(t *testing.T) {
+	// Arrange
+	h := newHarness(t, map[string]string{
+		"RISK_ENABLED":           "true",
+		"RISK_STEP_UP_THRESHOLD": "20",
+		"RISK_NEW_DEVICE_SCORE":  "20",
+	})
+	h.register("someone@example.com", "s3cret")
+	c := h.client()
+	c.headers["User-Agent"] = "another-browser"
+
+	// Act
+	login := c.login("someone@example.com", "s3cret")
+	var challenge struct {
+		StepUpRequired bool   `json:"stepUpRequired"`
+		ChallengeID    string `json:"challengeId"`
+	}
+	decode(t, login, &challenge)
+	var requested events.LoginChallengeRequested
+	h.awaitEvent(h.cfg.Authentication.LoginChallengeTopic, &requested)
+	wrongCode := c.postJSON("/auth/login/verify", map[string]string{"challengeId": challenge.ChallengeID, "code": "not-the-code"})
+	verified := c.postJSON("/auth/login/verify", map[string]string{"challengeId": challenge.ChallengeID, "code": requested.Code})
+	reused := h.client().postJSON("/auth/login/verify", map[string]string{"challengeId": challenge.ChallengeID, "code": requested.Code})
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, login.Code)
+	assert.True(t, challenge.StepUpRequired)
+	assert.Equal(t, "someone@example.com", requested.Email)
+	assert.Equal(t, http.StatusUnauthorized, wrongCode.Code)
+	assert.Equal(t, http.StatusOK, verified.Code)
+	assert.Equal(t, http.StatusOK, c.get("/user/").Code)
+	assert.Equal(t, http.StatusUnauthorized, reused.Code)
+	// The device is known once the challenge was completed from it
+	sameDevice := h.client()
+	sameDevice.headers["User-Agent"] = "another-browser"
+	assert.Equal(t, http.StatusOK, sameDevice.login("someone@example.com", "s3cret").Code)
+}
+
+func TestAuthMiddleware_RenewsExpiredAccessToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	expired := c.cookie("access_token")
+
+	// Act
+	h.clock.Advance(16 * time.Minute)
+	recorder := c.get("/user/")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	renewed := c.cookie("access_token")
+	assert.NotEmpty(t, renewed)
+	assert.NotEqual(t, expired, renewed)
+	assert.Equal(t, h.clock.Now().Add(15*time.Minute).Unix(), c.cookies["access_token"].Expires.Unix())
+	// The renewed token is used as is, without another renewal
+	assert.Equal(t, http.StatusOK, c.get("/user/").Code)
+	assert.Equal(t, renewed, c.cookie("access_token"))
+}
+
+func TestAuthMiddleware_RenewsAccessTokenTheBrowserStillSends(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	// A client that ignores the expiry of the cookie still sends the expired token
+	c.cookies["access_token"].Expires = time.Time{}
+
+	// Act
+	h.clock.Advance(16 * time.Minute)
+	recorder := c.get("/user/")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, c.cookies["access_token"].Expires.IsZero())
+}
+
+func TestAuthMiddleware_ExpiredRefreshToken_AsksToLoginAgain(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	// A client that ignores the expiry of the cookies still sends the expired tokens
+	c.cookies["access_token"].Expires = time.Time{}
+	c.cookies["refresh_token"].Expires = time.Time{}
+
+	// Act
+	h.clock.Advance(4*24*time.Hour + time.Minute)
+	recorder := c.get("/user/")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestAuthMiddleware_RenewsDroppedAccessToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	previous := c.cookie("access_token")
+
+	// Act
+	// The browser drops the access token cookie when it expires
+	delete(c.cookies, "access_token")
+	recorder := c.get("/user/")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	renewed := c.cookie("access_token")
+	assert.NotEmpty(t, renewed)
+	assert.NotEqual(t, previous, renewed)
+	// The renewed token is used as is, without another renewal
+	assert.Equal(t, http.StatusOK, c.get("/user/").Code)
+	assert.Equal(t, renewed, c.cookie("access_token"))
+}
+
+func TestAuthMiddleware_NoCookies_AsksToLogin(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+
+	// Act
+	recorder := h.client().get("/user/")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestIsUserAuthenticated(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	anonymous := h.client()
+
+	// Act
+	valid := c.get("/auth/is-user-authenticated")
+	validCookie := c.cookie("access_token")
+	delete(c.cookies, "access_token")
+	renewed := c.get("/auth/is-user-authenticated")
+	notLoggedIn := anonymous.get("/auth/is-user-authenticated")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, valid.Code)
+	assert.Equal(t, http.StatusOK, renewed.Code)
+	assert.NotEqual(t, validCookie, c.cookie("access_token"))
+	assert.Equal(t, http.StatusUnauthorized, notLoggedIn.Code)
+}
+
+func TestLogout_RevokesAccessAndRefreshToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	stolen := h.client()
+	for name, cookie := range c.cookies {
+		copied := *cookie
+		stolen.cookies[name] = &copied
+	}
+
+	// Act
+	recorder := c.get("/auth/logout")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, http.StatusUnauthorized, stolen.get("/user/").Code)
+	// Without the access token the refresh token is tried, it is revoked too
+	delete(stolen.cookies, "access_token")
+	assert.Equal(t, http.StatusUnauthorized, stolen.get("/user/").Code)
+	assert.Equal(t, http.StatusUnauthorized, stolen.get("/auth/is-user-authenticated").Code)
+}
+
+func TestLogout_NotLoggedIn(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+
+	// Act
+	recorder := h.client().get("/auth/logout")
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func requestPasswordReset(h *harness, email string) string {
+	h.t.Helper()
+	recorder := h.client().postForm("/auth/request-password-reset", url.Values{"email": {email}})
+	if recorder.Code != http.StatusOK {
+		h.t.Fatalf("requesting a password reset: status %d", recorder.Code)
+	}
+	var requested events.PasswordResetRequested
+	h.awaitEvent(h.cfg.Authentication.PasswordResetTopic, &requested)
+	return requested.ResetToken
+}
+
+func confirmPasswordReset(c *client, token, newPassword string) *httptest.ResponseRecorder {
+	return c.postForm("/auth/confirm-password-reset/"+url.PathEscape(token), url.Values{"newPassword": {newPassword}})
+}
+
+func TestPasswordReset_ChangesPasswordOnce(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	token := requestPasswordReset(h, "someone@example.com")
+	c := h.client()
+
+	// Act
+	confirmed := confirmPasswordReset(c, token, "n3w-s3cret")
+	reused := confirmPasswordReset(c, token, "an0ther")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, confirmed.Code)
+	assert.Equal(t, http.StatusBadRequest, reused.Code)
+	assert.Equal(t, http.StatusUnauthorized, c.login("someone@example.com", "s3cret").Code)
+	assert.Equal(t, http.StatusOK, c.login("someone@example.com", "n3w-s3cret").Code)
+}
+
+func TestPasswordReset_UnknownEmail_AnswersLikeSuccess(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+
+	// Act
+	recorder := h.client().postForm("/auth/request-password-reset", url.Values{"email": {"nobody@example.com"}})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestPasswordReset_ExpiredToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	token := requestPasswordReset(h, "someone@example.com")
+
+	// Act
+	h.clock.Advance(24*time.Hour + time.Second)
+	recorder := confirmPasswordReset(h.client(), token, "n3w-s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "token expired")
+}
+
+func TestPasswordReset_InvalidatesTokenAfterMaxWrongVerifiers(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	token := requestPasswordReset(h, "someone@example.com")
+	selector := token[:36]
+	c := h.client()
+
+	// Act
+	for attempt := 0; attempt < 3; attempt++ {
+		assert.Equal(t, http.StatusBadRequest, confirmPasswordReset(c, selector+".guessed", "n3w-s3cret").Code)
+	}
+	recorder := confirmPasswordReset(c, token, "n3w-s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, http.StatusOK, c.login("someone@example.com", "s3cret").Code)
+}
@@ -0,0 +1,342 @@
+package e2e
+
+import (
+	"automation-hub-idp/internal/app/application"
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/services"
+	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/app/utils/utils_mock"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The administrator seeded on the first start of the memory storage
+const (
+	adminEmail    = "admin@admin.nl"
+	adminPassword = "1234"
+)
+
+// metricsToken is the token the scrapers of the metrics send
+const metricsToken = "metrics-scrape-token"
+
+// clientIP is the address requests come from unless a test picks another one
+const clientIP = "203.0.113.10"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	// A filtered run leaves routes untested on purpose
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" && flag.Lookup("test.skip").Value.String() == "" {
+		if untested := routes.untested(); len(untested) > 0 {
+			fmt.Fprintf(os.Stderr, "routes without an end-to-end test:\n\t%s\n", strings.Join(untested, "\n\t"))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// harness runs the service in the process, on the memory backends of the dev mode and a fake clock.
+type harness struct {
+	t        *testing.T
+	cfg      *config.Config
+	handler  http.Handler
+	clock    *utils_mock.FakeClock
+	messages *services.MemoryMessageSender
+	// background counts the work the service still runs after responding
+	background *sync.WaitGroup
+}
+
+// newHarness starts the service with the test environment, overridden by env.
+func newHarness(t *testing.T, env map[string]string) *harness {
+	t.Helper()
+	for key, value := range testEnv() {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Logins hash and compare passwords, the lowest cost keeps the suite fast
+	cfg.Authentication.PasswordHasher = utils.NewBcryptHasher(bcrypt.MinCost)
+
+	infrastructure, err := application.NewInfrastructure(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = infrastructure.Commands.Close()
+		_ = infrastructure.Close(context.Background())
+	})
+	clock := utils_mock.NewFakeClock(time.Now())
+	infrastructure.Clock = clock
+	app, err := application.New(cfg, infrastructure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes.register(app.Handler().(*gin.Engine))
+
+	return &harness{
+		t:          t,
+		cfg:        cfg,
+		handler:    app.Handler(),
+		clock:      clock,
+		messages:   infrastructure.Events.(*services.MemoryMessageSender),
+		background: &infrastructure.Background,
+	}
+}
+
+func testEnv() map[string]string {
+	return map[string]string{
+		"STORAGE_BACKEND":                    config.BackendMemory,
+		"CACHE_BACKEND":                      config.BackendMemory,
+		"MESSAGING_BACKEND":                  config.BackendMemory,
+		"LOGGER_TOPIC":                       "logger",
+		"MAIL_TOPIC":                         "mail",
+		"BROKERS_ADDR":                       "localhost:9092",
+		"DB_HOST":                            "localhost",
+		"DB_NAME":                            "idp",
+		"DB_PORT":                            "5432",
+		"WEB_SERVER_PORT":                    "8080",
+		"BASE_URL":                           "/api",
+		"METRICS_TOKEN":                      metricsToken,
+		"JWT_SECRET":                         "secret",
+		"PASSWORD_RESET_TOPIC":               "password-reset",
+		"ACCOUNT_BLOCKED_TOPIC":              "account-blocked",
+		"ACCOUNT_CREATED_TOPIC":              "account-created",
+		"BLOCKING_TIME_EXPONENTIATION_BASIS": "2",
+		"MAX_LOGIN_ATTEMPTS_BEFORE_BLOCK":    "3",
+		"MAX_RESET_TOKEN_ATTEMPTS":           "3",
+		"LOG_SINKS":                          "stdout",
+		"LOG_LEVEL":                          "error",
+		"TRACING_EXPORTER":                   "none",
+		"RATE_LIMIT_ENABLED":                 "false",
+		// The risk assessment has its own tests, here it would only make logins depend on earlier tests
+		"RISK_ENABLED": "false",
+	}
+}
+
+// api returns the path of an API route.
+func (h *harness) api(path string) string {
+	return h.cfg.Server.BaseURL + "/v1" + path
+}
+
+// client is a browser: it keeps the cookies the service sets and drops them when they expire.
+func (h *harness) client() *client {
+	return h.clientFrom(clientIP)
+}
+
+func (h *harness) clientFrom(ip string) *client {
+	return &client{h: h, ip: ip, cookies: make(map[string]*http.Cookie), headers: make(map[string]string)}
+}
+
+// register creates an account and returns its ID.
+func (h *harness) register(email, password string) string {
+	h.t.Helper()
+	recorder := h.client().postJSON("/auth/register", map[string]string{"email": email, "password": password})
+	if recorder.Code != http.StatusOK {
+		h.t.Fatalf("registering %s: status %d", email, recorder.Code)
+	}
+	return h.userID(email, password)
+}
+
+// userID logs in as the user to find its ID.
+func (h *harness) userID(email, password string) string {
+	h.t.Helper()
+	var user struct {
+		ID string `json:"id"`
+	}
+	decode(h.t, h.loggedIn(email, password).get("/user/"), &user)
+	return user.ID
+}
+
+// loggedIn returns a client with the session of the user.
+func (h *harness) loggedIn(email, password string) *client {
+	h.t.Helper()
+	c := h.client()
+	if recorder := c.login(email, password); recorder.Code != http.StatusOK {
+		h.t.Fatalf("logging in as %s: status %d", email, recorder.Code)
+	}
+	return c
+}
+
+func (h *harness) admin() *client {
+	h.t.Helper()
+	return h.loggedIn(adminEmail, adminPassword)
+}
+
+// awaitEvent waits for the work the service runs after responding, like the password reset in uniform
+// response mode, and decodes the data of the latest event published on the topic.
+func (h *harness) awaitEvent(topic string, data interface{}) {
+	h.t.Helper()
+	h.background.Wait()
+	messages := h.messages.MessagesOn(topic)
+	if len(messages) == 0 {
+		h.t.Fatalf("no event was published on %s", topic)
+	}
+	if err := json.Unmarshal(messages[len(messages)-1].Envelope.Data, data); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+type client struct {
+	h       *harness
+	ip      string
+	cookies map[string]*http.Cookie
+	// headers are sent with every request
+	headers map[string]string
+}
+
+func (c *client) do(method, path, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, body)
+	request.RemoteAddr = c.ip + ":40000"
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range c.headers {
+		request.Header.Set(name, value)
+	}
+	now := c.h.clock.Now()
+	for name, cookie := range c.cookies {
+		if !cookie.Expires.IsZero() && !now.Before(cookie.Expires) {
+			delete(c.cookies, name)
+			continue
+		}
+		request.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	recorder := httptest.NewRecorder()
+	c.h.handler.ServeHTTP(recorder, request)
+	routes.visit(method, request.URL.Path)
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+			continue
+		}
+		c.cookies[cookie.Name] = cookie
+	}
+	return recorder
+}
+
+func (c *client) get(path string) *httptest.ResponseRecorder {
+	return c.do(http.MethodGet, c.h.api(path), "", nil)
+}
+
+func (c *client) delete(path string) *httptest.ResponseRecorder {
+	return c.do(http.MethodDelete, c.h.api(path), "", nil)
+}
+
+func (c *client) postJSON(path string, body interface{}) *httptest.ResponseRecorder {
+	return c.sendJSON(http.MethodPost, path, body)
+}
+
+func (c *client) sendJSON(method, path string, body interface{}) *httptest.ResponseRecorder {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		c.h.t.Fatal(err)
+	}
+	return c.do(method, c.h.api(path), "application/json", bytes.NewReader(encoded))
+}
+
+func (c *client) postForm(path string, values url.Values) *httptest.ResponseRecorder {
+	return c.do(http.MethodPost, c.h.api(path), "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+func (c *client) login(email, password string) *httptest.ResponseRecorder {
+	return c.postJSON("/auth/login", map[string]string{"email": email, "password": password})
+}
+
+func (c *client) cookie(name string) string {
+	if cookie, ok := c.cookies[name]; ok {
+		return cookie.Value
+	}
+	return ""
+}
+
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", recorder.Body.String(), err)
+	}
+}
+
+// routes tracks which routes of the router the suite requested.
+var routes = &routeCoverage{visited: make(map[string]bool)}
+
+type routeCoverage struct {
+	mu      sync.Mutex
+	routes  gin.RoutesInfo
+	visited map[string]bool
+}
+
+func (r *routeCoverage) register(engine *gin.Engine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.routes == nil {
+		r.routes = engine.Routes()
+	}
+}
+
+func (r *routeCoverage) visit(method, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, route := range r.routes {
+		if route.Method == method && matchRoute(route.Path, path) {
+			r.visited[route.Method+" "+route.Path] = true
+		}
+	}
+}
+
+func (r *routeCoverage) untested() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var untested []string
+	for _, route := range r.routes {
+		if key := route.Method + " " + route.Path; !r.visited[key] {
+			untested = append(untested, key)
+		}
+	}
+	sort.Strings(untested)
+	return untested
+}
+
+// matchRoute reports whether the path matches the route pattern of gin, with its :param and *wildcard segments.
+func matchRoute(pattern, path string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for index, segment := range patternSegments {
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+		if index >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[index] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[index] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
@@ -0,0 +1,156 @@
+package e2e
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// invite invites the email into the organization and returns the token of the invitation.
+func invite(h *harness, inviter *client, email, role string, organizationID uuid.UUID) string {
+	h.t.Helper()
+	recorder := inviter.postJSON("/invitations", map[string]interface{}{
+		"email":          email,
+		"role":           role,
+		"organizationId": organizationID,
+	})
+	if recorder.Code != http.StatusCreated {
+		h.t.Fatalf("inviting %s: status %d", email, recorder.Code)
+	}
+	var invitation events.InvitationCreated
+	h.awaitEvent(h.cfg.Authentication.InvitationTopic, &invitation)
+	link, err := url.Parse(invitation.Link)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return link.Query().Get("token")
+}
+
+func acceptInvitation(c *client, token, password string) *httptest.ResponseRecorder {
+	return c.postJSON("/auth/accept-invitation?token="+url.QueryEscape(token), map[string]string{"password": password})
+}
+
+func TestInvitation_AcceptedInvitationCreatesAccount(t *testing.T) {
+	// Arrange
+	h := newHarness(t, map[string]string{"REGISTRATION_MODE": "invite_only"})
+	token := invite(h, h.admin(), "member@example.com", models.RoleUser, uuid.New())
+	c := h.client()
+
+	// Act
+	accepted := acceptInvitation(c, token, "s3cret")
+	reused := acceptInvitation(c, token, "other")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, accepted.Code)
+	assert.Equal(t, http.StatusBadRequest, reused.Code)
+	assert.Equal(t, http.StatusOK, c.login("member@example.com", "s3cret").Code)
+	registered := c.postJSON("/auth/register", map[string]string{"email": "uninvited@example.com", "password": "s3cret"})
+	assert.Equal(t, http.StatusForbidden, registered.Code)
+}
+
+func TestInvitation_ExpiredInvitation(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	token := invite(h, h.admin(), "member@example.com", models.RoleUser, uuid.New())
+
+	// Act
+	h.clock.Advance(7*24*time.Hour + time.Second)
+	recorder := acceptInvitation(h.client(), token, "s3cret")
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestInvitation_OrganizationOwnerInvitesIntoOwnOrganizationOnly(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	organizationID := uuid.New()
+	token := invite(h, h.admin(), "owner@example.com", models.RoleOrgOwner, organizationID)
+	assert.Equal(t, http.StatusOK, acceptInvitation(h.client(), token, "s3cret").Code)
+	owner := h.loggedIn("owner@example.com", "s3cret")
+
+	// Act
+	own := owner.postJSON("/invitations", map[string]interface{}{
+		"email": "member@example.com", "role": models.RoleUser, "organizationId": organizationID,
+	})
+	other := owner.postJSON("/invitations", map[string]interface{}{
+		"email": "member@example.com", "role": models.RoleUser, "organizationId": uuid.New(),
+	})
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, own.Code)
+	assert.Equal(t, http.StatusForbidden, other.Code)
+}
+
+func TestWebhooks_QueueDeliveriesOfOrganizationEvents(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	organizationID := uuid.New()
+	admin := h.admin()
+	token := invite(h, admin, "member@example.com", models.RoleUser, organizationID)
+	assert.Equal(t, http.StatusOK, acceptInvitation(h.client(), token, "s3cret").Code)
+	webhooks := "/organizations/" + organizationID.String() + "/webhooks"
+
+	// Act
+	created := admin.postJSON(webhooks, map[string]interface{}{
+		"url":        "https://hooks.example.com/idp",
+		"eventTypes": []string{events.SessionRevoked{}.Contract().Type()},
+	})
+	var webhook struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+	decode(t, created, &webhook)
+	listed := admin.get(webhooks)
+	member := h.loggedIn("member@example.com", "s3cret")
+	assert.Equal(t, http.StatusOK, member.get("/auth/logout").Code)
+	deliveries := admin.get(webhooks + "/" + webhook.ID + "/deliveries")
+	var queued []struct {
+		ID        string `json:"id"`
+		EventType string `json:"eventType"`
+	}
+	decode(t, deliveries, &queued)
+	if !assert.Len(t, queued, 1) {
+		return
+	}
+	redelivered := admin.postJSON(webhooks+"/"+webhook.ID+"/deliveries/"+queued[0].ID+"/redeliver", nil)
+	deleted := admin.delete(webhooks + "/" + webhook.ID)
+	afterDelete := admin.get(webhooks)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, created.Code)
+	assert.NotEmpty(t, webhook.Secret)
+	assert.Equal(t, http.StatusOK, listed.Code)
+	assert.Contains(t, listed.Body.String(), webhook.ID)
+	assert.Equal(t, http.StatusOK, deliveries.Code)
+	assert.Equal(t, events.SessionRevoked{}.Contract().Type(), queued[0].EventType)
+	assert.Equal(t, http.StatusAccepted, redelivered.Code)
+	var redelivery struct {
+		RedeliveryOf string `json:"redeliveryOf"`
+	}
+	decode(t, redelivered, &redelivery)
+	assert.Equal(t, queued[0].ID, redelivery.RedeliveryOf)
+	assert.Equal(t, http.StatusOK, deleted.Code)
+	assert.JSONEq(t, `[]`, afterDelete.Body.String())
+}
+
+func TestWebhooks_OrganizationMembersCannotManageWebhooks(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	organizationID := uuid.New()
+	token := invite(h, h.admin(), "member@example.com", models.RoleUser, organizationID)
+	assert.Equal(t, http.StatusOK, acceptInvitation(h.client(), token, "s3cret").Code)
+	member := h.loggedIn("member@example.com", "s3cret")
+
+	// Act
+	recorder := member.get("/organizations/" + organizationID.String() + "/webhooks")
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
@@ -0,0 +1,88 @@
+package e2e
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestProbes(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	c := h.client()
+
+	// Act
+	live := c.do(http.MethodGet, "/healthz", "", nil)
+	ready := c.do(http.MethodGet, "/readyz", "", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, live.Code)
+	assert.Equal(t, http.StatusOK, ready.Code)
+}
+
+func TestApplications_KeepTheirOwnConfiguration(t *testing.T) {
+	// Arrange
+	open := newHarness(t, nil)
+	inviteOnly := newHarness(t, map[string]string{"REGISTRATION_MODE": "invite_only"})
+	credentials := map[string]string{"email": "new@example.com", "password": "s3cret"}
+
+	// Act
+	openRegistration := open.client().postJSON("/auth/register", credentials)
+	inviteOnlyRegistration := inviteOnly.client().postJSON("/auth/register", credentials)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, openRegistration.Code)
+	assert.Equal(t, http.StatusForbidden, inviteOnlyRegistration.Code)
+}
+
+func TestMetrics_CountRequests(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	c := h.client()
+	c.login(adminEmail, adminPassword)
+	scraper := h.client()
+	scraper.headers["Authorization"] = "Bearer " + metricsToken
+
+	// Act
+	anonymous := c.do(http.MethodGet, "/metrics", "", nil)
+	recorder := scraper.do(http.MethodGet, "/metrics", "", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), h.api("/auth/login"))
+}
+
+func TestSwagger_ServesDocumentation(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+
+	// Act
+	recorder := h.client().do(http.MethodGet, "/swagger/doc.json", "", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "/auth/confirm-password-reset/{reset-token}")
+}
+
+func TestRateLimit_LimitsLoginsPerAddress(t *testing.T) {
+	// Arrange
+	h := newHarness(t, map[string]string{
+		"RATE_LIMIT_ENABLED":      "true",
+		"RATE_LIMIT_LOGIN_PER_IP": "2/1m",
+	})
+	c := h.client()
+
+	// Act
+	first := c.login(adminEmail, "wrong")
+	second := c.login(adminEmail, "wrong")
+	limited := c.login(adminEmail, adminPassword)
+	otherAddress := h.clientFrom("198.51.100.30").login(adminEmail, adminPassword)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, first.Code)
+	assert.Equal(t, http.StatusUnauthorized, second.Code)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.NotEmpty(t, limited.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, otherAddress.Code)
+}
@@ -0,0 +1,194 @@
+package e2e
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type userResponse struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	PendingEmail string `json:"pendingEmail"`
+}
+
+func currentUser(t *testing.T, c *client) userResponse {
+	t.Helper()
+	var user userResponse
+	recorder := c.get("/user/")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("fetching the current user: status %d", recorder.Code)
+	}
+	decode(t, recorder, &user)
+	return user
+}
+
+// requestEmailChange changes the email of the client's user and returns the confirmation token sent to the
+// new address and the revert token sent to the current one.
+func requestEmailChange(h *harness, c *client, newEmail string) (string, string) {
+	h.t.Helper()
+	recorder := c.sendJSON(http.MethodPatch, "/user/", map[string]string{"email": newEmail})
+	if recorder.Code != http.StatusOK {
+		h.t.Fatalf("changing the email: status %d", recorder.Code)
+	}
+	var confirmation events.EmailChangeRequested
+	h.awaitEvent(h.cfg.Authentication.EmailChangeTopic, &confirmation)
+	var notice events.EmailChangeNotice
+	h.awaitEvent(h.cfg.Authentication.EmailChangedNoticeTopic, &notice)
+	return confirmation.ConfirmationToken, notice.RevertToken
+}
+
+func TestUpdateUser_ChangesPassword(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+
+	// Act
+	recorder := c.sendJSON(http.MethodPatch, "/user/", map[string]string{"password": "n3w-s3cret"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, http.StatusUnauthorized, h.client().login("someone@example.com", "s3cret").Code)
+	assert.Equal(t, http.StatusOK, h.client().login("someone@example.com", "n3w-s3cret").Code)
+}
+
+func TestUpdateUser_EmailChangeTakesEffectOnceConfirmed(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+
+	// Act
+	recorder := c.sendJSON(http.MethodPatch, "/user/", map[string]string{"email": "new@example.com"})
+	var pending userResponse
+	decode(t, recorder, &pending)
+	beforeConfirmation := currentUser(t, c)
+	var confirmation events.EmailChangeRequested
+	h.awaitEvent(h.cfg.Authentication.EmailChangeTopic, &confirmation)
+	confirmed := h.client().postForm("/auth/confirm-email-change?token="+url.QueryEscape(confirmation.ConfirmationToken), nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "new@example.com", pending.PendingEmail)
+	assert.Equal(t, "someone@example.com", beforeConfirmation.Email)
+	assert.Equal(t, "new@example.com", confirmation.Email)
+	assert.Equal(t, http.StatusOK, confirmed.Code)
+	assert.Equal(t, "new@example.com", currentUser(t, c).Email)
+	assert.Equal(t, http.StatusOK, h.client().login("new@example.com", "s3cret").Code)
+}
+
+func TestUpdateUser_RejectsEmailOfAnotherAccount(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("taken@example.com", "s3cret")
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+
+	// Act
+	recorder := c.sendJSON(http.MethodPatch, "/user/", map[string]string{"email": "taken@example.com"})
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestConfirmEmailChange_ExpiredToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.loggedIn("someone@example.com", "s3cret")
+	confirmationToken, _ := requestEmailChange(h, c, "new@example.com")
+
+	// Act
+	h.clock.Advance(24*time.Hour + time.Second)
+	recorder := h.client().postForm("/auth/confirm-email-change?token="+url.QueryEscape(confirmationToken), nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "token expired")
+}
+
+func TestRevertEmailChange_LocksAccountUntilPasswordReset(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	attacker := h.loggedIn("someone@example.com", "s3cret")
+	confirmationToken, revertToken := requestEmailChange(h, attacker, "attacker@example.com")
+	assert.Equal(t, http.StatusOK,
+		h.client().postForm("/auth/confirm-email-change?token="+url.QueryEscape(confirmationToken), nil).Code)
+
+	// Act
+	reverted := h.client().postForm("/auth/revert-email-change?token="+url.QueryEscape(revertToken), nil)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, reverted.Code)
+	assert.Equal(t, http.StatusUnauthorized, attacker.get("/user/").Code)
+	owner := h.client()
+	assert.Equal(t, http.StatusUnauthorized, owner.login("someone@example.com", "s3cret").Code)
+	assert.Equal(t, http.StatusUnauthorized, owner.login("attacker@example.com", "s3cret").Code)
+	// The owner proves to hold the mailbox, after reading the mail
+	h.clock.Advance(time.Minute)
+	token := requestPasswordReset(h, "someone@example.com")
+	assert.Equal(t, http.StatusOK, confirmPasswordReset(owner, token, "n3w-s3cret").Code)
+	assert.Equal(t, http.StatusOK, owner.login("someone@example.com", "n3w-s3cret").Code)
+	assert.Equal(t, "someone@example.com", currentUser(t, owner).Email)
+}
+
+func TestRevertEmailChange_SurvivesChainedChange(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	attacker := h.loggedIn("someone@example.com", "s3cret")
+	confirmationToken, revertToken := requestEmailChange(h, attacker, "attacker@example.com")
+	assert.Equal(t, http.StatusOK,
+		h.client().postForm("/auth/confirm-email-change?token="+url.QueryEscape(confirmationToken), nil).Code)
+
+	// Act
+	chained := attacker.sendJSON(http.MethodPatch, "/user/", map[string]string{"email": "other@example.com"})
+	reverted := h.client().postForm("/auth/revert-email-change?token="+url.QueryEscape(revertToken), nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, chained.Code)
+	assert.Equal(t, http.StatusOK, reverted.Code)
+}
+
+func TestRevertEmailChange_InvalidToken(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+
+	// Act
+	recorder := h.client().postForm("/auth/revert-email-change?token=unknown", nil)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestGetLoginHistory_ListsAttemptsNewestFirst(t *testing.T) {
+	// Arrange
+	h := newHarness(t, nil)
+	h.register("someone@example.com", "s3cret")
+	c := h.clientFrom("198.51.100.20")
+	c.login("someone@example.com", "wrong")
+	c.login("someone@example.com", "s3cret")
+
+	// Act
+	recorder := c.get("/user/login-history")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var attempts []struct {
+		Outcome string `json:"outcome"`
+		IP      string `json:"ip"`
+	}
+	decode(t, recorder, &attempts)
+	// The registration logged in to find the user ID
+	if assert.Len(t, attempts, 3) {
+		assert.Equal(t, models.LoginOutcomeSuccess, attempts[0].Outcome)
+		assert.Equal(t, "198.51.100.20", attempts[0].IP)
+		assert.Equal(t, models.LoginOutcomeInvalidCredentials, attempts[1].Outcome)
+	}
+}
@@ -0,0 +1,259 @@
+package events
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// The payloads below are the published contracts. Their JSON must match the schema of their version, the
+// contract tests lock down both.
+
+type AccountCreated struct {
+	UserID uuid.UUID `json:"userId"`
+	Email  string    `json:"email"`
+}
+
+func (AccountCreated) Contract() Contract {
+	return Contract{Name: "account.created", Version: 1}
+}
+
+func (e AccountCreated) Subject() string {
+	return e.UserID.String()
+}
+
+// AccountBlocked is published when an account is blocked after failed logins, with BlockedUntil set, or
+// locked until its owner resets the password.
+type AccountBlocked struct {
+	UserID       uuid.UUID  `json:"userId"`
+	Email        string     `json:"email"`
+	Reason       string     `json:"reason"`
+	BlockedUntil *time.Time `json:"blockedUntil,omitempty"`
+}
+
+func (AccountBlocked) Contract() Contract {
+	return Contract{Name: "account.blocked", Version: 1}
+}
+
+func (e AccountBlocked) Subject() string {
+	return e.UserID.String()
+}
+
+// AccountExists tells the owner of an address that someone tried to register it again.
+type AccountExists struct {
+	Email string `json:"email"`
+}
+
+func (AccountExists) Contract() Contract {
+	return Contract{Name: "account.exists", Version: 1}
+}
+
+func (AccountExists) Subject() string {
+	return ""
+}
+
+type NewDeviceLogin struct {
+	UserID    uuid.UUID `json:"userId"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Country   string    `json:"country"`
+	City      string    `json:"city"`
+	Time      time.Time `json:"time"`
+}
+
+func (NewDeviceLogin) Contract() Contract {
+	return Contract{Name: "login.new_device", Version: 1}
+}
+
+func (e NewDeviceLogin) Subject() string {
+	return e.UserID.String()
+}
+
+type PasswordResetRequested struct {
+	UserID     uuid.UUID `json:"userId"`
+	Email      string    `json:"email"`
+	ResetToken string    `json:"resetToken"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func (PasswordResetRequested) Contract() Contract {
+	return Contract{Name: "password_reset.requested", Version: 1}
+}
+
+func (e PasswordResetRequested) Subject() string {
+	return e.UserID.String()
+}
+
+// LoginChallengeRequested sends the one-time code a risky login has to be completed with.
+type LoginChallengeRequested struct {
+	UserID    uuid.UUID `json:"userId"`
+	Email     string    `json:"email"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (LoginChallengeRequested) Contract() Contract {
+	return Contract{Name: "login.challenge_requested", Version: 1}
+}
+
+func (e LoginChallengeRequested) Subject() string {
+	return e.UserID.String()
+}
+
+// EmailChangeRequested asks the new address to confirm the change.
+type EmailChangeRequested struct {
+	UserID            uuid.UUID `json:"userId"`
+	Email             string    `json:"email"`
+	ConfirmationToken string    `json:"confirmationToken"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+func (EmailChangeRequested) Contract() Contract {
+	return Contract{Name: "email_change.requested", Version: 1}
+}
+
+func (e EmailChangeRequested) Subject() string {
+	return e.UserID.String()
+}
+
+// EmailChangeNotice tells the current address about a requested change and how to revert it.
+type EmailChangeNotice struct {
+	UserID      uuid.UUID `json:"userId"`
+	Email       string    `json:"email"`
+	NewEmail    string    `json:"newEmail"`
+	RevertToken string    `json:"revertToken"`
+	RevertLink  string    `json:"revertLink"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+func (EmailChangeNotice) Contract() Contract {
+	return Contract{Name: "email_change.notice", Version: 1}
+}
+
+func (e EmailChangeNotice) Subject() string {
+	return e.UserID.String()
+}
+
+// Impersonation is the payload shared by the impersonation events. Email is the impersonated user's.
+type Impersonation struct {
+	SessionID  uuid.UUID `json:"sessionId"`
+	UserID     uuid.UUID `json:"userId"`
+	Email      string    `json:"email"`
+	ActorEmail string    `json:"actorEmail"`
+	Reason     string    `json:"reason"`
+	StartedAt  time.Time `json:"startedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type ImpersonationStarted Impersonation
+
+func (ImpersonationStarted) Contract() Contract {
+	return Contract{Name: "impersonation.started", Version: 1}
+}
+
+func (e ImpersonationStarted) Subject() string {
+	return e.UserID.String()
+}
+
+type ImpersonationStopped Impersonation
+
+func (ImpersonationStopped) Contract() Contract {
+	return Contract{Name: "impersonation.stopped", Version: 1}
+}
+
+func (e ImpersonationStopped) Subject() string {
+	return e.UserID.String()
+}
+
+type InvitationCreated struct {
+	InvitationID uuid.UUID `json:"invitationId"`
+	Email        string    `json:"email"`
+	InvitedBy    string    `json:"invitedBy"`
+	Role         string    `json:"role"`
+	Link         string    `json:"link"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+func (InvitationCreated) Contract() Contract {
+	return Contract{Name: "invitation.created", Version: 1}
+}
+
+func (e InvitationCreated) Subject() string {
+	return e.InvitationID.String()
+}
+
+// SessionRevoked is published when a session ends before it expires: on logout, or for all sessions of a
+// user when an account takeover is reverted.
+type SessionRevoked struct {
+	UserID    uuid.UUID `json:"userId"`
+	Reason    string    `json:"reason"`
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+func (SessionRevoked) Contract() Contract {
+	return Contract{Name: "session.revoked", Version: 1}
+}
+
+func (e SessionRevoked) Subject() string {
+	return e.UserID.String()
+}
+
+// The commands below are consumed from the command topic. Other services send them in a CloudEvents envelope
+// whose ID is the command ID.
+
+// DisableUser deactivates the account and revokes its sessions.
+type DisableUser struct {
+	UserID uuid.UUID `json:"userId"`
+	Reason string    `json:"reason"`
+}
+
+func (DisableUser) Contract() Contract {
+	return Contract{Name: "command.user.disable", Version: 1}
+}
+
+func (e DisableUser) Subject() string {
+	return e.UserID.String()
+}
+
+// ForceLogout revokes every session of the user.
+type ForceLogout struct {
+	UserID uuid.UUID `json:"userId"`
+	Reason string    `json:"reason"`
+}
+
+func (ForceLogout) Contract() Contract {
+	return Contract{Name: "command.user.force_logout", Version: 1}
+}
+
+func (e ForceLogout) Subject() string {
+	return e.UserID.String()
+}
+
+// TriggerPasswordReset sends the user a password reset link.
+type TriggerPasswordReset struct {
+	UserID uuid.UUID `json:"userId"`
+}
+
+func (TriggerPasswordReset) Contract() Contract {
+	return Contract{Name: "command.user.trigger_password_reset", Version: 1}
+}
+
+func (e TriggerPasswordReset) Subject() string {
+	return e.UserID.String()
+}
+
+// CommandResult answers a command on the reply topic. Error is set when Status is failed.
+type CommandResult struct {
+	CommandID   string `json:"commandId"`
+	CommandType string `json:"commandType"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+func (CommandResult) Contract() Contract {
+	return Contract{Name: "command.result", Version: 1}
+}
+
+func (e CommandResult) Subject() string {
+	return e.CommandID
+}
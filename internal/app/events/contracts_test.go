@@ -0,0 +1,192 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+var (
+	sampleUserID = uuid.MustParse("7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11")
+	sampleID     = uuid.MustParse("0b9e5c36-6a7d-4b8e-8f1e-52d4c9a0e7f3")
+	sampleTime   = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	sampleOrigin = Origin{Source: "automation-hub-idp", SchemaBaseURI: "https://schemas.automation-hub.example/idp/"}
+)
+
+// samples holds one event of every contract. Its encoding is compared to testdata/<type>.json, so a change
+// to a published payload fails here instead of in a consumer.
+var samples = []Event{
+	AccountCreated{UserID: sampleUserID, Email: "jane@example.com"},
+	AccountBlocked{UserID: sampleUserID, Email: "jane@example.com", Reason: "too many failed login attempts",
+		BlockedUntil: &sampleTime},
+	AccountExists{Email: "jane@example.com"},
+	NewDeviceLogin{UserID: sampleUserID, Email: "jane@example.com", IP: "192.0.2.1", UserAgent: "Mozilla/5.0",
+		Country: "NL", City: "Amsterdam", Time: sampleTime},
+	LoginChallengeRequested{UserID: sampleUserID, Email: "jane@example.com", Code: "123456", ExpiresAt: sampleTime},
+	PasswordResetRequested{UserID: sampleUserID, Email: "jane@example.com", ResetToken: "selector.verifier", ExpiresAt: sampleTime},
+	EmailChangeRequested{UserID: sampleUserID, Email: "new@example.com", ConfirmationToken: "confirm", ExpiresAt: sampleTime},
+	EmailChangeNotice{UserID: sampleUserID, Email: "jane@example.com", NewEmail: "new@example.com", RevertToken: "revert",
+		RevertLink: "https://idp.example.com/revert-email-change?token=revert", ExpiresAt: sampleTime},
+	ImpersonationStarted{SessionID: sampleID, UserID: sampleUserID, Email: "jane@example.com", ActorEmail: "admin@example.com",
+		Reason: "support ticket 42", StartedAt: sampleTime, ExpiresAt: sampleTime.Add(30 * time.Minute)},
+	ImpersonationStopped{SessionID: sampleID, UserID: sampleUserID, Email: "jane@example.com", ActorEmail: "admin@example.com",
+		Reason: "support ticket 42", StartedAt: sampleTime, ExpiresAt: sampleTime.Add(30 * time.Minute)},
+	InvitationCreated{InvitationID: sampleID, Email: "new@example.com", InvitedBy: "admin@example.com", Role: "user",
+		Link: "https://idp.example.com/accept-invitation?token=token", ExpiresAt: sampleTime},
+	SessionRevoked{UserID: sampleUserID, Reason: "logout", RevokedAt: sampleTime},
+	DisableUser{UserID: sampleUserID, Reason: "left the company"},
+	ForceLogout{UserID: sampleUserID, Reason: "device lost"},
+	TriggerPasswordReset{UserID: sampleUserID},
+	CommandResult{CommandID: sampleID.String(), CommandType: "com.automation-hub.idp.command.user.disable.v1", Status: "failed",
+		Error: "user not found"},
+}
+
+func TestContracts_PayloadsMatchGoldenFiles(t *testing.T) {
+	for _, event := range samples {
+		contract := event.Contract()
+		t.Run(contract.Type(), func(t *testing.T) {
+			// Arrange
+			golden, err := os.ReadFile("testdata/" + contract.Type() + ".json")
+			assert.NoError(t, err)
+
+			// Act
+			data, err := json.Marshal(event)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(golden), string(data))
+			assert.NoError(t, Validate(contract, data))
+		})
+	}
+}
+
+func TestContracts_EverySchemaHasAContract(t *testing.T) {
+	// Arrange
+	covered := make(map[string]bool)
+	for _, event := range samples {
+		covered[event.Contract().SchemaFile()] = true
+	}
+
+	// Act
+	files, err := fs.Glob(schemaFiles, "schemas/*.json")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, files, len(samples))
+	for _, file := range files {
+		assert.True(t, covered[file], "schema %s has no sample event", file)
+	}
+}
+
+func TestValidate_RejectsPayloadsBreakingTheContract(t *testing.T) {
+	contract := AccountCreated{}.Contract()
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"missing field", `{"userId":"7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11"}`},
+		{"renamed field", `{"UserID":"7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11","email":"jane@example.com"}`},
+		{"unknown field", `{"userId":"7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11","email":"jane@example.com","role":"user"}`},
+		{"wrong format", `{"userId":"not-a-uuid","email":"jane@example.com"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			err := Validate(contract, []byte(tt.payload))
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewEnvelope_RejectsInvalidEvent(t *testing.T) {
+	// Act
+	envelope, err := NewEnvelope(sampleOrigin, AccountBlocked{UserID: sampleUserID, Email: "jane@example.com"}, sampleID, sampleTime)
+
+	// Assert
+	assert.Error(t, err, "an event without a reason must not be published")
+	assert.Nil(t, envelope)
+}
+
+func TestNewEnvelope_SetsAttributesAndHeaders(t *testing.T) {
+	// Arrange
+	event := AccountCreated{UserID: sampleUserID, Email: "jane@example.com"}
+
+	// Act
+	envelope, err := NewEnvelope(sampleOrigin, event, sampleID, sampleTime)
+
+	// Assert
+	assert.NoError(t, err)
+	body, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "0b9e5c36-6a7d-4b8e-8f1e-52d4c9a0e7f3",
+		"source": "automation-hub-idp",
+		"type": "com.automation-hub.idp.account.created.v1",
+		"subject": "7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11",
+		"time": "2024-03-01T09:30:00Z",
+		"datacontenttype": "application/json",
+		"dataschema": "https://schemas.automation-hub.example/idp/schemas/account.created.v1.json",
+		"data": {"userId": "7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11", "email": "jane@example.com"}
+	}`, string(body))
+	assert.Equal(t, map[string]string{
+		"content-type":   ContentType,
+		"ce_specversion": "1.0",
+		"ce_id":          "0b9e5c36-6a7d-4b8e-8f1e-52d4c9a0e7f3",
+		"ce_source":      "automation-hub-idp",
+		"ce_type":        "com.automation-hub.idp.account.created.v1",
+		"ce_subject":     "7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11",
+		"ce_time":        "2024-03-01T09:30:00Z",
+	}, envelope.Headers())
+	assert.NoError(t, envelope.Validate())
+}
+
+func TestNewEnvelope_RejectsRelativeSchemaBaseURI(t *testing.T) {
+	// Act
+	envelope, err := NewEnvelope(Origin{Source: "automation-hub-idp", SchemaBaseURI: "/idp/"},
+		AccountCreated{UserID: sampleUserID, Email: "jane@example.com"}, sampleID, sampleTime)
+
+	// Assert
+	assert.Error(t, err, "the dataschema of a CloudEvent must be an absolute URI")
+	assert.Nil(t, envelope)
+}
+
+func TestEnvelopeRedacted_PointsAtRedactedSchema(t *testing.T) {
+	// Arrange
+	envelope, err := NewEnvelope(sampleOrigin, PasswordResetRequested{UserID: sampleUserID, Email: "jane@example.com",
+		ResetToken: "selector.verifier", ExpiresAt: sampleTime}, sampleID, sampleTime)
+	assert.NoError(t, err)
+	withoutToken := `{"userId":"7f1c8f4e-2f5a-4d2b-9a57-3c1d0e6b8a11","email":"jane@example.com","expiresAt":"2024-03-01T09:30:00Z"}`
+
+	// Act
+	redacted, err := envelope.Redacted([]byte(withoutToken))
+	_, withTokenErr := envelope.Redacted(envelope.Data)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "https://schemas.automation-hub.example/idp/schemas/redacted/password_reset.requested.v1.json", redacted.DataSchema)
+	assert.JSONEq(t, withoutToken, string(redacted.Data))
+	assert.Equal(t, "https://schemas.automation-hub.example/idp/schemas/password_reset.requested.v1.json", envelope.DataSchema)
+	assert.Error(t, withTokenErr, "a redacted payload must not carry the redacted fields")
+}
+
+func TestParseType_RoundTrips(t *testing.T) {
+	for _, event := range samples {
+		// Act
+		contract, err := ParseType(event.Contract().Type())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, event.Contract(), contract)
+	}
+
+	_, err := ParseType("com.example.account.created.v1")
+	assert.Error(t, err)
+}
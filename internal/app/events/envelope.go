@@ -0,0 +1,127 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SpecVersion = "1.0"
+	// ContentType is the content type of a structured mode CloudEvent, an envelope with the payload inside.
+	ContentType     = "application/cloudevents+json"
+	dataContentType = "application/json"
+)
+
+// Envelope is a CloudEvents 1.0 event in structured mode.
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	DataSchema      string          `json:"dataschema"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Origin is who publishes the events: the CloudEvents source, and the absolute URI the schema files are
+// published under, which the dataschema of every envelope points into.
+type Origin struct {
+	Source        string
+	SchemaBaseURI string
+}
+
+// SchemaURI is the absolute URI of the schema file of the contract.
+func (o Origin) SchemaURI(contract Contract) (string, error) {
+	return SchemaURI(o.SchemaBaseURI, contract.SchemaFile())
+}
+
+// SchemaURI resolves a schema file against the base URI the schemas are published under.
+func SchemaURI(baseURI string, file string) (string, error) {
+	base, err := url.Parse(baseURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid schema base URI %q: %w", baseURI, err)
+	}
+	if !base.IsAbs() {
+		return "", fmt.Errorf("schema base URI %q is not absolute", baseURI)
+	}
+	return base.JoinPath(file).String(), nil
+}
+
+// NewEnvelope encodes the event and validates it against the schema of its contract.
+func NewEnvelope(origin Origin, event Event, id uuid.UUID, at time.Time) (*Envelope, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	contract := event.Contract()
+	if err := Validate(contract, data); err != nil {
+		return nil, err
+	}
+	schema, err := origin.SchemaURI(contract)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              id.String(),
+		Source:          origin.Source,
+		Type:            contract.Type(),
+		Subject:         event.Subject(),
+		Time:            at.UTC(),
+		DataContentType: dataContentType,
+		DataSchema:      schema,
+		Data:            data,
+	}, nil
+}
+
+// Redacted returns a copy of the envelope carrying data, the payload with fields left out, and pointing its
+// dataschema at the redacted schema of its type, which data is validated against.
+func (e *Envelope) Redacted(data json.RawMessage) (*Envelope, error) {
+	contract, err := ParseType(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateRedacted(contract, data); err != nil {
+		return nil, err
+	}
+	base, found := strings.CutSuffix(e.DataSchema, contract.SchemaFile())
+	if !found {
+		return nil, fmt.Errorf("dataschema %s is not the schema of %s", e.DataSchema, e.Type)
+	}
+	redacted := *e
+	redacted.Data = data
+	redacted.DataSchema = base + contract.RedactedSchemaFile()
+	return &redacted, nil
+}
+
+// Headers repeats the envelope attributes as Kafka headers, named as in the CloudEvents Kafka binding, so
+// consumers can route and filter without parsing the value.
+func (e *Envelope) Headers() map[string]string {
+	headers := map[string]string{
+		"content-type":   ContentType,
+		"ce_specversion": e.SpecVersion,
+		"ce_id":          e.ID,
+		"ce_source":      e.Source,
+		"ce_type":        e.Type,
+		"ce_time":        e.Time.Format(time.RFC3339Nano),
+	}
+	if e.Subject != "" {
+		headers["ce_subject"] = e.Subject
+	}
+	return headers
+}
+
+// Validate checks the payload against the schema of the envelope's type.
+func (e *Envelope) Validate() error {
+	contract, err := ParseType(e.Type)
+	if err != nil {
+		return err
+	}
+	return Validate(contract, e.Data)
+}
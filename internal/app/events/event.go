@@ -0,0 +1,117 @@
+package events
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// typePrefix namespaces the CloudEvents type of every event this service publishes.
+const typePrefix = "com.automation-hub.idp."
+
+//go:embed schemas/*.json schemas/redacted/*.json
+var schemaFiles embed.FS
+
+// Contract identifies an event definition. A payload change that is not backwards compatible needs a new
+// version with its own schema, consumers rely on the payload of a version never changing.
+type Contract struct {
+	Name    string
+	Version int
+}
+
+// Type is the CloudEvents type, e.g. com.automation-hub.idp.account.created.v1.
+func (c Contract) Type() string {
+	return typePrefix + c.Name + ".v" + strconv.Itoa(c.Version)
+}
+
+// ParseType returns the contract of a CloudEvents type built by Contract.Type.
+func ParseType(eventType string) (Contract, error) {
+	rest, found := strings.CutPrefix(eventType, typePrefix)
+	separator := strings.LastIndex(rest, ".v")
+	if !found || separator < 0 {
+		return Contract{}, fmt.Errorf("unknown event type %s", eventType)
+	}
+	version, err := strconv.Atoi(rest[separator+2:])
+	if err != nil {
+		return Contract{}, fmt.Errorf("unknown event type %s", eventType)
+	}
+	return Contract{Name: rest[:separator], Version: version}, nil
+}
+
+// SchemaFile is the name of the JSON Schema the payload is validated against.
+func (c Contract) SchemaFile() string {
+	return "schemas/" + c.Name + ".v" + strconv.Itoa(c.Version) + ".json"
+}
+
+// RedactedSchemaFile is the name of the JSON Schema of the payload without the fields that are left out
+// where it leaves the service's own consumers, like the reset token in webhooks.
+func (c Contract) RedactedSchemaFile() string {
+	return "schemas/redacted/" + c.Name + ".v" + strconv.Itoa(c.Version) + ".json"
+}
+
+// Event is the typed payload of a contract.
+type Event interface {
+	Contract() Contract
+	// Subject is the ID of the entity the event is about, or empty if there is none.
+	Subject() string
+}
+
+var (
+	schemasMu sync.Mutex
+	schemas   = make(map[string]*jsonschema.Schema)
+)
+
+// Validate checks an encoded payload against the schema of its contract.
+func Validate(contract Contract, data []byte) error {
+	return validate(contract, contract.SchemaFile(), data)
+}
+
+// ValidateRedacted checks an encoded payload against the redacted schema of its contract.
+func ValidateRedacted(contract Contract, data []byte) error {
+	return validate(contract, contract.RedactedSchemaFile(), data)
+}
+
+func validate(contract Contract, file string, data []byte) error {
+	schema, err := schemaFor(contract, file)
+	if err != nil {
+		return err
+	}
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", contract.Type(), err)
+	}
+	if err := schema.Validate(payload); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", contract.Type(), err)
+	}
+	return nil
+}
+
+func schemaFor(contract Contract, file string) (*jsonschema.Schema, error) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	if schema, ok := schemas[file]; ok {
+		return schema, nil
+	}
+	content, err := schemaFiles.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("no schema for event type %s", contract.Type())
+	}
+	defer content.Close()
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	compiler.AssertFormat = true
+	if err := compiler.AddResource(file, content); err != nil {
+		return nil, err
+	}
+	schema, err := compiler.Compile(file)
+	if err != nil {
+		return nil, err
+	}
+	schemas[file] = schema
+	return schema, nil
+}
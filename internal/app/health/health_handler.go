@@ -0,0 +1,45 @@
+package health
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type Handler struct {
+	healthService Service
+}
+
+func NewHandler(healthService Service) *Handler {
+	return &Handler{
+		healthService: healthService,
+	}
+}
+
+// Live
+// @Summary Live
+// @Description Liveness probe. Answers as long as the server handles requests, without checking dependencies
+// @Tags Health
+// @Produce json
+// @Success 200 {object} dto.HealthResponse
+// @Router /healthz [get]
+func (h *Handler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.HealthResponse{Status: StatusOK})
+}
+
+// Ready
+// @Summary Ready
+// @Description Readiness probe. Checks Postgres, Redis and Kafka and reports each of them. A degraded service is still ready, one shutting down is not
+// @Tags Health
+// @Produce json
+// @Success 200 {object} dto.HealthResponse
+// @Failure 503 {object} dto.HealthResponse
+// @Router /readyz [get]
+func (h *Handler) Ready(c *gin.Context) {
+	response := h.healthService.Ready(c.Request.Context())
+	status := http.StatusOK
+	if response.Status == StatusUnavailable || response.Status == StatusDraining {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overall states of the service
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// States of a component
+const (
+	ComponentUp   = "up"
+	ComponentDown = "down"
+)
+
+// Check probes one dependency. A failing critical check makes the service unavailable, a failing non-critical
+// one only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    func(ctx context.Context) error
+}
+
+type service struct {
+	checks   []Check
+	timeout  time.Duration
+	logger   iservice.Logger
+	draining atomic.Bool
+}
+
+func NewService(timeout time.Duration, logger iservice.Logger, checks ...Check) Service {
+	return &service{
+		checks:  checks,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+func (s *service) Ready(ctx context.Context) dto.HealthResponse {
+	if s.draining.Load() {
+		return dto.HealthResponse{Status: StatusDraining}
+	}
+
+	components := make(map[string]dto.ComponentHealth, len(s.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range s.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			component := s.run(ctx, check)
+			mu.Lock()
+			components[check.Name] = component
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status := StatusOK
+	for _, component := range components {
+		if component.Status == ComponentUp {
+			continue
+		}
+		if component.Critical {
+			status = StatusUnavailable
+			break
+		}
+		status = StatusDegraded
+	}
+	return dto.HealthResponse{Status: status, Components: components}
+}
+
+func (s *service) Drain() {
+	s.draining.Store(true)
+}
+
+// run probes the dependency within the timeout. A probe that ignores its context is abandoned when the
+// timeout expires.
+func (s *service) run(ctx context.Context, check Check) dto.ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Probe(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	component := dto.ComponentHealth{
+		Status:     ComponentUp,
+		Critical:   check.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		component.Status = ComponentDown
+		component.Error = "failed"
+		if errors.Is(err, context.DeadlineExceeded) {
+			component.Error = "timeout"
+		}
+		s.logger.Warn("Health check %s failed: %v", check.Name, err)
+	}
+	return component
+}
@@ -0,0 +1,15 @@
+package health
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"context"
+)
+
+type Service interface {
+	// Ready runs the dependency checks in parallel and reports StatusOK when all passed, StatusDegraded when
+	// only non-critical ones failed and StatusUnavailable when a critical one failed.
+	Ready(ctx context.Context) dto.HealthResponse
+	// Drain makes Ready report StatusDraining from now on, without running the checks, so the load balancer
+	// stops routing requests before the server shuts down.
+	Drain()
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func passing(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused to 10.0.0.5:5432") }
+
+func hanging(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestService_Ready(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   []Check
+		expected string
+	}{
+		{"all up", []Check{{Name: "postgres", Critical: true, Probe: passing}, {Name: "kafka_logs", Probe: passing}}, StatusOK},
+		{"non-critical down", []Check{{Name: "postgres", Critical: true, Probe: passing}, {Name: "kafka_logs", Probe: failing}}, StatusDegraded},
+		{"critical down", []Check{{Name: "postgres", Critical: true, Probe: failing}, {Name: "kafka_logs", Probe: failing}}, StatusUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(time.Second, service_mock.NewPermissiveMockLogger(), tt.checks...)
+
+			response := svc.Ready(context.Background())
+
+			assert.Equal(t, tt.expected, response.Status)
+			assert.Len(t, response.Components, len(tt.checks))
+		})
+	}
+}
+
+func TestService_Ready_ReportsEachComponentWithoutDetails(t *testing.T) {
+	// Arrange
+	svc := NewService(50*time.Millisecond, service_mock.NewPermissiveMockLogger(),
+		Check{Name: "postgres", Critical: true, Probe: failing},
+		Check{Name: "redis", Critical: true, Probe: hanging},
+		Check{Name: "kafka_logs", Probe: passing})
+
+	// Act
+	start := time.Now()
+	response := svc.Ready(context.Background())
+
+	// Assert
+	assert.Less(t, time.Since(start), time.Second, "the checks did not run in parallel within the timeout")
+	assert.Equal(t, StatusUnavailable, response.Status)
+	assert.Equal(t, dto.ComponentHealth{Status: ComponentDown, Critical: true, Error: "failed"},
+		withoutDuration(response.Components["postgres"]))
+	assert.Equal(t, dto.ComponentHealth{Status: ComponentDown, Critical: true, Error: "timeout"},
+		withoutDuration(response.Components["redis"]))
+	assert.Equal(t, dto.ComponentHealth{Status: ComponentUp}, withoutDuration(response.Components["kafka_logs"]))
+}
+
+func TestService_Ready_AbandonsProbeIgnoringContext(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	defer close(release)
+	stuck := func(context.Context) error {
+		<-release
+		return nil
+	}
+	svc := NewService(20*time.Millisecond, service_mock.NewPermissiveMockLogger(), Check{Name: "kafka_events", Critical: true, Probe: stuck})
+
+	// Act
+	response := svc.Ready(context.Background())
+
+	// Assert
+	assert.Equal(t, StatusUnavailable, response.Status)
+	assert.Equal(t, "timeout", response.Components["kafka_events"].Error)
+}
+
+func TestHandler_Ready(t *testing.T) {
+	tests := []struct {
+		name     string
+		probe    func(context.Context) error
+		critical bool
+		expected int
+	}{
+		{"ready", passing, true, http.StatusOK},
+		{"degraded is still ready", failing, false, http.StatusOK},
+		{"unavailable", failing, true, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			svc := NewService(time.Second, service_mock.NewPermissiveMockLogger(), Check{Name: "redis", Critical: tt.critical, Probe: tt.probe})
+			handler := NewHandler(svc)
+			router.GET("/readyz", handler.Ready)
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tt.expected, recorder.Code)
+			var response dto.HealthResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Contains(t, response.Components, "redis")
+			assert.NotContains(t, recorder.Body.String(), "10.0.0.5")
+		})
+	}
+}
+
+func TestRedisProbe(t *testing.T) {
+	// Arrange
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	probe := RedisProbe(client)
+
+	// Act
+	up := probe(context.Background())
+	server.Close()
+	down := probe(context.Background())
+
+	// Assert
+	assert.NoError(t, up)
+	assert.Error(t, down)
+}
+
+func withoutDuration(component dto.ComponentHealth) dto.ComponentHealth {
+	component.DurationMs = 0
+	return component
+}
+
+func TestService_Drain_FailsReadinessWithoutChecking(t *testing.T) {
+	// Arrange
+	probed := false
+	svc := NewService(time.Second, service_mock.NewPermissiveMockLogger(), Check{Name: "postgres", Critical: true,
+		Probe: func(context.Context) error {
+			probed = true
+			return nil
+		}})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/readyz", NewHandler(svc).Ready)
+	recorder := httptest.NewRecorder()
+
+	// Act
+	svc.Drain()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.JSONEq(t, `{"status":"draining"}`, recorder.Body.String())
+	assert.False(t, probed)
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"automation-hub-idp/internal/infra"
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"gorm.io/gorm"
+	"time"
+)
+
+// PostgresProbe pings the database and checks that its schema was migrated to at least schemaVersion. A
+// newer schema is fine, it is what pods of the previous release see during a rollout.
+func PostgresProbe(db *gorm.DB, schemaVersion int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return err
+		}
+		applied, err := infra.AppliedSchemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if applied < schemaVersion {
+			return fmt.Errorf("schema version %d is behind the expected version %d", applied, schemaVersion)
+		}
+		return nil
+	}
+}
+
+// RedisProbe sends a PING.
+func RedisProbe(client *redis.Client) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// KafkaProducerProbe asks the brokers of the producer for the cluster metadata.
+func KafkaProducerProbe(producer *kafka.Producer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		timeout := time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		_, err := producer.GetMetadata(nil, false, int(timeout.Milliseconds()))
+		return err
+	}
+}
@@ -0,0 +1,94 @@
+package invitations
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+type Handler struct {
+	invitationService Service
+}
+
+func NewHandler(invitationService Service) *Handler {
+	return &Handler{
+		invitationService: invitationService,
+	}
+}
+
+// CreateInvitation
+// @Summary CreateInvitation
+// @Description Invites an email address with a pre-assigned role. Organization owners can only invite into their own organization.
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Param body body dto.InvitationRequest true "Invitation"
+// @Success 201 {object} dto.InvitationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /invitations [post]
+func (h *Handler) CreateInvitation(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	var request dto.InvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(c.Request.Context(), c.MustGet("userID").(uuid.UUID), request.Email,
+		request.Role, request.OrganizationID, utils.ClientInfo(c))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotAllowedToInvite) {
+			status = http.StatusForbidden
+		}
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = status
+		c.JSON(status, errorResponse)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.InvitationResponse{
+		ID:             invitation.ID,
+		Email:          invitation.Email,
+		Role:           invitation.Role,
+		OrganizationID: invitation.OrganizationID,
+		ExpiresAt:      invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation
+// @Summary AcceptInvitation
+// @Description Creates the account of an invitation with the chosen password
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param token query string true "Invitation token"
+// @Param body body dto.AcceptInvitationDTO true "Password"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /auth/accept-invitation [post]
+func (h *Handler) AcceptInvitation(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	var request dto.AcceptInvitationDTO
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	userResponse, err := h.invitationService.AcceptInvitation(c.Request.Context(), c.Query("token"), request.Password, utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	c.JSON(http.StatusOK, userResponse)
+}
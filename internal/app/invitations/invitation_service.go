@@ -0,0 +1,194 @@
+package invitations
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+const (
+	invitationTokenSeparator     = "."
+	invitationTokenVerifierBytes = 32
+)
+
+// ErrNotAllowedToInvite is returned when the inviter may not hand out the requested role or organization.
+var ErrNotAllowedToInvite = errors.New("not allowed to create this invitation")
+
+type service struct {
+	repo        irepository.InvitationRepository
+	userService users.UserService
+	registrar   Registrar
+	tokenHasher utils.TokenHasher
+	sender      iservice.MessageSender
+	audit       iservice.AuditRecorder
+	logger      iservice.Logger
+	clock       utils.Clock
+	cfg         *config.AuthenticationConfig
+}
+
+func NewService(repo irepository.InvitationRepository, userService users.UserService, registrar Registrar,
+	tokenHasher utils.TokenHasher, sender iservice.MessageSender, audit iservice.AuditRecorder, logger iservice.Logger,
+	clock utils.Clock, cfg *config.AuthenticationConfig) Service {
+	return &service{
+		repo:        repo,
+		userService: userService,
+		registrar:   registrar,
+		tokenHasher: tokenHasher,
+		sender:      sender,
+		audit:       audit,
+		logger:      logger,
+		clock:       clock,
+		cfg:         cfg,
+	}
+}
+
+func (s *service) CreateInvitation(ctx context.Context, inviterID uuid.UUID, email, role string, organizationID *uuid.UUID,
+	client dto.ClientInfo) (*models.Invitation, error) {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return nil, errors.New("invalid email")
+	}
+	inviter, err := s.userService.GetUserByID(ctx, inviterID)
+	if err != nil {
+		s.logger.Error("Error fetching inviter %s: %v", inviterID, err)
+		return nil, errors.New("failed to create invitation")
+	}
+	organizationID, err = checkInvitePermission(inviter, role, organizationID)
+	if err != nil {
+		s.logger.Warn("User %s may not invite %s as %s: %v", inviter.Email, email, role, err)
+		if errors.Is(err, ErrNotAllowedToInvite) {
+			s.audit.Record(ctx, dto.AuditEvent{
+				Type:    models.AuditEventInvitationCreated,
+				Outcome: models.AuditOutcomeDenied,
+				ActorID: &inviter.ID,
+				IP:      client.IP,
+				Details: map[string]string{"email": email, "role": role},
+			})
+		}
+		return nil, err
+	}
+
+	// The token is "<selector>.<verifier>": the selector finds the record, only a keyed hash of the verifier is stored
+	verifier, err := utils.GenerateRandomToken(invitationTokenVerifierBytes)
+	if err != nil {
+		s.logger.Error("Error generating invitation token: %v", err)
+		return nil, errors.New("failed to create invitation")
+	}
+	invitation, err := s.repo.Create(ctx, &models.Invitation{
+		ID:             uuid.New(),
+		Email:          email,
+		Role:           role,
+		OrganizationID: organizationID,
+		TokenHash:      s.tokenHasher.Hash(verifier),
+		InvitedBy:      inviter.ID,
+		ExpiresAt:      s.clock.Now().Add(time.Hour * s.cfg.ExpirationTimeInvitationHours),
+	})
+	if err != nil {
+		return nil, errors.New("failed to create invitation")
+	}
+
+	token := invitation.ID.String() + invitationTokenSeparator + verifier
+	event := events.InvitationCreated{
+		InvitationID: invitation.ID,
+		Email:        invitation.Email,
+		InvitedBy:    inviter.Email,
+		Role:         invitation.Role,
+		Link:         s.cfg.AppDomain + "/accept-invitation?token=" + token,
+		ExpiresAt:    invitation.ExpiresAt,
+	}
+	err = s.sender.Send(ctx, s.cfg.InvitationTopic, event)
+	if err != nil {
+		s.logger.Error("Error sending invitation message: %v", err)
+		return nil, errors.New("failed to send invitation")
+	}
+
+	s.logger.Info("Invitation %s for %s as %s created by %s", invitation.ID, invitation.Email, invitation.Role, inviter.Email)
+	s.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventInvitationCreated,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &inviter.ID,
+		TargetType: models.AuditTargetInvitation,
+		TargetID:   &invitation.ID,
+		IP:         client.IP,
+		Details:    map[string]string{"email": invitation.Email, "role": invitation.Role},
+	})
+	return invitation, nil
+}
+
+// checkInvitePermission returns the organization the invitee joins. Admins may invite anyone anywhere,
+// organization owners only users and owners of their own organization.
+func checkInvitePermission(inviter *models.User, role string, organizationID *uuid.UUID) (*uuid.UUID, error) {
+	if !models.IsValidRole(role) {
+		return nil, errors.New("invalid role")
+	}
+
+	switch inviter.Role {
+	case models.RoleAdmin:
+		return organizationID, nil
+	case models.RoleOrgOwner:
+		if role == models.RoleAdmin || inviter.OrganizationID == nil {
+			return nil, ErrNotAllowedToInvite
+		}
+		if organizationID != nil && *organizationID != *inviter.OrganizationID {
+			return nil, ErrNotAllowedToInvite
+		}
+		return inviter.OrganizationID, nil
+	}
+	return nil, ErrNotAllowedToInvite
+}
+
+func (s *service) AcceptInvitation(ctx context.Context, token, password string, client dto.ClientInfo) (*dto.UserResponse, error) {
+	selector, verifier, found := strings.Cut(token, invitationTokenSeparator)
+	if !found {
+		return nil, errors.New("invalid token")
+	}
+	invitationID, err := uuid.Parse(selector)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	invitation, err := s.repo.FindByID(ctx, invitationID)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if invitation.AcceptedAt != nil || !s.tokenHasher.Compare(invitation.TokenHash, verifier) {
+		s.logger.Warn("Attempt to use an invalid invitation token for invitation: %s", invitation.ID)
+		return nil, errors.New("invalid token")
+	}
+	now := s.clock.Now()
+	if invitation.ExpiresAt.Before(now) {
+		return nil, errors.New("invitation expired")
+	}
+	if password == "" {
+		return nil, errors.New("password must not be empty")
+	}
+
+	// Register before consuming the invitation, so a failed registration leaves it usable. A concurrent
+	// acceptance cannot register twice, the second account with the email of the invitation is refused.
+	userResponse, err := s.registrar.RegisterInvited(ctx, dto.UserDTO{Email: invitation.Email, Password: password},
+		invitation.Role, invitation.OrganizationID, client)
+	if err != nil {
+		s.logger.Error("Error registering invited user %s: %v", invitation.Email, err)
+		return nil, errors.New("failed to accept invitation")
+	}
+
+	accepted, err := s.repo.MarkAccepted(ctx, invitation.ID, now)
+	if err != nil || !accepted {
+		// The account exists, which keeps the invitation from registering another one
+		s.logger.Error("Error consuming invitation %s accepted by %s: accepted %t, %v", invitation.ID,
+			invitation.Email, accepted, err)
+	}
+
+	s.logger.Info("Invitation %s accepted by %s", invitation.ID, invitation.Email)
+	return userResponse, nil
+}
@@ -0,0 +1,18 @@
+package invitations
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	CreateInvitation(ctx context.Context, inviterID uuid.UUID, email, role string, organizationID *uuid.UUID, client dto.ClientInfo) (*models.Invitation, error)
+	AcceptInvitation(ctx context.Context, token, password string, client dto.ClientInfo) (*dto.UserResponse, error)
+}
+
+// Registrar creates the account of an accepted invitation.
+type Registrar interface {
+	RegisterInvited(ctx context.Context, userDTO dto.UserDTO, role string, organizationID *uuid.UUID, client dto.ClientInfo) (*dto.UserResponse, error)
+}
@@ -0,0 +1,213 @@
+package invitations
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type mockRegistrar struct {
+	mock.Mock
+}
+
+func (m *mockRegistrar) RegisterInvited(_ context.Context, userDTO dto.UserDTO, role string, organizationID *uuid.UUID,
+	client dto.ClientInfo) (*dto.UserResponse, error) {
+	args := m.Called(userDTO, role, organizationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dto.UserResponse), args.Error(1)
+}
+
+type invitationTestDeps struct {
+	repo        *repository_mock.MockInvitationRepository
+	userService *service_mock.MockUserService
+	registrar   *mockRegistrar
+	sender      *service_mock.MockMessageSender
+	audit       *service_mock.MockAuditRecorder
+	tokenHasher utils.TokenHasher
+	cfg         *config.AuthenticationConfig
+	service     Service
+}
+
+func newInvitationTestDeps(t *testing.T) *invitationTestDeps {
+	env := map[string]string{
+		"LOGGER_TOPIC":                       "logger",
+		"MAIL_TOPIC":                         "mail",
+		"BROKERS_ADDR":                       "localhost:9092",
+		"DB_HOST":                            "localhost",
+		"DB_NAME":                            "idp",
+		"DB_PORT":                            "5432",
+		"PASSWORD_RESET_TOPIC":               "password-reset",
+		"ACCOUNT_BLOCKED_TOPIC":              "account-blocked",
+		"ACCOUNT_CREATED_TOPIC":              "account-created",
+		"BLOCKING_TIME_EXPONENTIATION_BASIS": "2",
+		"MAX_LOGIN_ATTEMPTS_BEFORE_BLOCK":    "5",
+		"JWT_SECRET":                         "secret",
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deps := &invitationTestDeps{
+		repo:        new(repository_mock.MockInvitationRepository),
+		userService: new(service_mock.MockUserService),
+		registrar:   new(mockRegistrar),
+		sender:      new(service_mock.MockMessageSender),
+		audit:       service_mock.NewPermissiveMockAuditRecorder(),
+		tokenHasher: utils.NewHmacTokenHasher("test-key"),
+		cfg:         cfg.Authentication,
+	}
+	deps.service = NewService(deps.repo, deps.userService, deps.registrar, deps.tokenHasher, deps.sender,
+		deps.audit, service_mock.NewPermissiveMockLogger(), utils.SystemClock(), deps.cfg)
+	return deps
+}
+
+func TestCreateInvitation_Permissions(t *testing.T) {
+	organizationID, otherOrganizationID := uuid.New(), uuid.New()
+	admin := &models.User{ID: uuid.New(), Email: "admin@example.com", Role: models.RoleAdmin}
+	owner := &models.User{ID: uuid.New(), Email: "owner@example.com", Role: models.RoleOrgOwner, OrganizationID: &organizationID}
+	member := &models.User{ID: uuid.New(), Email: "member@example.com", Role: models.RoleUser, OrganizationID: &organizationID}
+	tests := []struct {
+		name           string
+		inviter        *models.User
+		role           string
+		organizationID *uuid.UUID
+		wantErr        bool
+		wantOrg        *uuid.UUID
+	}{
+		{"admin invites admin", admin, models.RoleAdmin, nil, false, nil},
+		{"admin invites into any organization", admin, models.RoleOrgOwner, &otherOrganizationID, false, &otherOrganizationID},
+		{"owner invites into own organization", owner, models.RoleUser, nil, false, &organizationID},
+		{"owner cannot invite admin", owner, models.RoleAdmin, nil, true, nil},
+		{"owner cannot invite into other organization", owner, models.RoleUser, &otherOrganizationID, true, nil},
+		{"member cannot invite", member, models.RoleUser, nil, true, nil},
+		{"unknown role", admin, "superuser", nil, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			deps := newInvitationTestDeps(t)
+			deps.userService.On("GetUserByID", tt.inviter.ID).Return(tt.inviter, nil)
+			deps.repo.On("Create", mock.AnythingOfType("*models.Invitation")).Return(&models.Invitation{ID: uuid.New()}, nil)
+			deps.sender.On("Send", deps.cfg.InvitationTopic, mock.Anything).Return(nil)
+
+			// Act
+			_, err := deps.service.CreateInvitation(context.Background(), tt.inviter.ID, "invitee@example.com", tt.role, tt.organizationID, dto.ClientInfo{})
+
+			// Assert
+			if tt.wantErr {
+				assert.Error(t, err)
+				deps.repo.AssertNotCalled(t, "Create", mock.Anything)
+				deps.sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			invitation := deps.repo.Calls[0].Arguments.Get(0).(*models.Invitation)
+			assert.Equal(t, tt.role, invitation.Role)
+			assert.Equal(t, tt.wantOrg, invitation.OrganizationID)
+			assert.Equal(t, tt.inviter.ID, invitation.InvitedBy)
+			assert.NotContains(t, invitation.TokenHash, ".")
+			deps.sender.AssertCalled(t, "Send", deps.cfg.InvitationTopic, mock.Anything)
+		})
+	}
+}
+
+func TestAcceptInvitation_RegistersWithInvitedRoleOnce(t *testing.T) {
+	// Arrange
+	deps := newInvitationTestDeps(t)
+	organizationID := uuid.New()
+	invitation := &models.Invitation{
+		ID:             uuid.New(),
+		Email:          "invitee@example.com",
+		Role:           models.RoleOrgOwner,
+		OrganizationID: &organizationID,
+		TokenHash:      deps.tokenHasher.Hash("verifier"),
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}
+	token := invitation.ID.String() + "." + "verifier"
+	// Both acceptances get past the token check, as concurrent ones would
+	deps.repo.On("FindByID", invitation.ID).Return(invitation, nil)
+	deps.repo.On("MarkAccepted", invitation.ID, mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	registration := dto.UserDTO{Email: invitation.Email, Password: "new-password"}
+	deps.registrar.On("RegisterInvited", registration, models.RoleOrgOwner, &organizationID).
+		Return(&dto.UserResponse{ID: uuid.New(), Email: invitation.Email}, nil).Once()
+	deps.registrar.On("RegisterInvited", registration, models.RoleOrgOwner, &organizationID).
+		Return(nil, errors.New("failed to create user")).Once()
+
+	// Act
+	first, firstErr := deps.service.AcceptInvitation(context.Background(), token, "new-password", dto.ClientInfo{})
+	_, secondErr := deps.service.AcceptInvitation(context.Background(), token, "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, invitation.Email, first.Email)
+	assert.Error(t, secondErr)
+	deps.repo.AssertNumberOfCalls(t, "MarkAccepted", 1)
+}
+
+func TestAcceptInvitation_FailedRegistrationKeepsInvitation(t *testing.T) {
+	// Arrange
+	deps := newInvitationTestDeps(t)
+	invitation := &models.Invitation{
+		ID:        uuid.New(),
+		Email:     "invitee@example.com",
+		Role:      models.RoleUser,
+		TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	token := invitation.ID.String() + "." + "verifier"
+	deps.repo.On("FindByID", invitation.ID).Return(invitation, nil)
+	deps.registrar.On("RegisterInvited", mock.Anything, models.RoleUser, (*uuid.UUID)(nil)).
+		Return(nil, errors.New("failed to register user due to internal error"))
+
+	// Act
+	_, err := deps.service.AcceptInvitation(context.Background(), token, "new-password", dto.ClientInfo{})
+
+	// Assert
+	assert.Error(t, err)
+	deps.repo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything)
+}
+
+func TestAcceptInvitation_RejectsInvalidTokens(t *testing.T) {
+	// Arrange
+	deps := newInvitationTestDeps(t)
+	expired := &models.Invitation{ID: uuid.New(), TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(-time.Minute)}
+	valid := &models.Invitation{ID: uuid.New(), TokenHash: deps.tokenHasher.Hash("verifier"),
+		ExpiresAt: time.Now().Add(time.Hour)}
+	deps.repo.On("FindByID", expired.ID).Return(expired, nil)
+	deps.repo.On("FindByID", valid.ID).Return(valid, nil)
+
+	tokens := []string{
+		"",
+		"not-a-token",
+		expired.ID.String() + ".verifier",
+		valid.ID.String() + ".wrong-verifier",
+	}
+
+	for _, token := range tokens {
+		// Act
+		_, err := deps.service.AcceptInvitation(context.Background(), token, "new-password", dto.ClientInfo{})
+
+		// Assert
+		assert.Error(t, err, token)
+	}
+	deps.repo.AssertNotCalled(t, "MarkAccepted", mock.Anything, mock.Anything)
+	deps.registrar.AssertNotCalled(t, "RegisterInvited", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,127 @@
+package iprules
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+type Handler struct {
+	ipRuleService Service
+}
+
+func NewHandler(ipRuleService Service) *Handler {
+	return &Handler{
+		ipRuleService: ipRuleService,
+	}
+}
+
+// ListRules
+// @Summary ListRules
+// @Description Lists all IP allow and deny rules
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} dto.IPRuleResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /admin/ip-rules [get]
+func (h *Handler) ListRules(c *gin.Context) {
+	rules, err := h.ipRuleService.ListRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Message:   "Error fetching IP rules",
+			ErrorCode: http.StatusInternalServerError,
+		})
+		return
+	}
+
+	response := make([]dto.IPRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		response = append(response, toResponse(rule))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateRule
+// @Summary CreateRule
+// @Description Creates an IP allow or deny rule for the global, admin or organization scope
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param body body dto.IPRuleRequest true "IP rule"
+// @Success 201 {object} dto.IPRuleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /admin/ip-rules [post]
+func (h *Handler) CreateRule(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	var request dto.IPRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	rule, err := h.ipRuleService.CreateRule(c.Request.Context(), models.IPRule{
+		Scope:          request.Scope,
+		OrganizationID: request.OrganizationID,
+		Action:         request.Action,
+		CIDR:           request.CIDR,
+		Description:    request.Description,
+	}, c.MustGet("userID").(uuid.UUID), utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	c.JSON(http.StatusCreated, toResponse(rule))
+}
+
+// DeleteRule
+// @Summary DeleteRule
+// @Description Deletes an IP rule
+// @Tags Admin
+// @Produce json
+// @Param id path string true "IP rule ID"
+// @Success 200 {object} dto.SuccessResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /admin/ip-rules/{id} [delete]
+func (h *Handler) DeleteRule(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errorResponse.Message = "Invalid rule ID"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	err = h.ipRuleService.DeleteRule(c.Request.Context(), id, c.MustGet("userID").(uuid.UUID), utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusNotFound
+		c.JSON(http.StatusNotFound, errorResponse)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message:    "IP rule deleted",
+		StatusCode: http.StatusOK,
+	})
+}
+
+func toResponse(rule *models.IPRule) dto.IPRuleResponse {
+	return dto.IPRuleResponse{
+		ID:             rule.ID,
+		Scope:          rule.Scope,
+		OrganizationID: rule.OrganizationID,
+		Action:         rule.Action,
+		CIDR:           rule.CIDR,
+		Description:    rule.Description,
+		CreatedBy:      rule.CreatedBy,
+		CreatedAt:      rule.CreatedAt,
+	}
+}
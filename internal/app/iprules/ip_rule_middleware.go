@@ -0,0 +1,23 @@
+package iprules
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Middleware rejects requests whose client IP is not allowed in the scope. The client IP honours
+// X-Forwarded-For only from the trusted proxies configured on the engine.
+func Middleware(ipRuleService Service, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := ipRuleService.IsAllowed(c.Request.Context(), c.ClientIP(), scope, nil)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access from this address is not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,210 @@
+package iprules
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"net"
+	"sync"
+	"time"
+)
+
+type compiledRule struct {
+	scope          string
+	organizationID *uuid.UUID
+	action         string
+	network        *net.IPNet
+}
+
+type service struct {
+	repo     irepository.IPRuleRepository
+	audit    iservice.AuditRecorder
+	logger   iservice.Logger
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	rules    []compiledRule
+	loadedAt time.Time
+	loaded   bool
+}
+
+func NewService(repo irepository.IPRuleRepository, audit iservice.AuditRecorder, logger iservice.Logger, cacheTTL time.Duration) Service {
+	return &service{
+		repo:     repo,
+		audit:    audit,
+		logger:   logger,
+		cacheTTL: cacheTTL,
+		now:      time.Now,
+	}
+}
+
+func (s *service) CreateRule(ctx context.Context, rule models.IPRule, actorID uuid.UUID, client dto.ClientInfo) (*models.IPRule, error) {
+	network, err := utils.ParseNetwork(rule.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	rule.CIDR = network.String()
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+	rule.ID = uuid.Nil
+	rule.CreatedBy = actorID
+
+	created, err := s.repo.Create(ctx, &rule)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate()
+	s.logger.Info("IP rule %s created by %s: %s %s in scope %s", created.ID, actorID, created.Action, created.CIDR, created.Scope)
+	s.audit.Record(ctx, ruleEvent(models.AuditEventIPRuleCreated, created, actorID, client))
+	return created, nil
+}
+
+func (s *service) DeleteRule(ctx context.Context, id uuid.UUID, actorID uuid.UUID, client dto.ClientInfo) error {
+	rule, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate()
+	s.logger.Info("IP rule %s deleted by %s: %s %s in scope %s", rule.ID, actorID, rule.Action, rule.CIDR, rule.Scope)
+	s.audit.Record(ctx, ruleEvent(models.AuditEventIPRuleDeleted, rule, actorID, client))
+	return nil
+}
+
+func ruleEvent(eventType string, rule *models.IPRule, actorID uuid.UUID, client dto.ClientInfo) dto.AuditEvent {
+	details := map[string]string{"scope": rule.Scope, "action": rule.Action, "cidr": rule.CIDR}
+	if rule.OrganizationID != nil {
+		details["organization_id"] = rule.OrganizationID.String()
+	}
+	return dto.AuditEvent{
+		Type:       eventType,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &actorID,
+		TargetType: models.AuditTargetIPRule,
+		TargetID:   &rule.ID,
+		IP:         client.IP,
+		Details:    details,
+	}
+}
+
+func (s *service) ListRules(ctx context.Context) ([]*models.IPRule, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *service) IsAllowed(ctx context.Context, ip string, scope string, organizationID *uuid.UUID) (bool, error) {
+	rules, err := s.cachedRules(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var applicable []compiledRule
+	for _, rule := range rules {
+		if rule.scope != scope {
+			continue
+		}
+		if scope == models.IPRuleScopeOrganization &&
+			(organizationID == nil || rule.organizationID == nil || *rule.organizationID != *organizationID) {
+			continue
+		}
+		applicable = append(applicable, rule)
+	}
+	if len(applicable) == 0 {
+		return true, nil
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, nil
+	}
+	hasAllowRules, allowed := false, false
+	for _, rule := range applicable {
+		matches := rule.network.Contains(parsed)
+		if rule.action == models.IPRuleActionDeny && matches {
+			return false, nil
+		}
+		if rule.action == models.IPRuleActionAllow {
+			hasAllowRules = true
+			allowed = allowed || matches
+		}
+	}
+	return !hasAllowRules || allowed, nil
+}
+
+// cachedRules returns the rules from memory, reloading them once the cache has expired. When a reload
+// fails the previous rules stay in use, so a database hiccup does not drop every restriction.
+func (s *service) cachedRules(ctx context.Context) ([]compiledRule, error) {
+	s.mu.RLock()
+	if s.loaded && s.now().Sub(s.loadedAt) < s.cacheTTL {
+		rules := s.rules
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded && s.now().Sub(s.loadedAt) < s.cacheTTL {
+		return s.rules, nil
+	}
+	rules, err := s.repo.FindAll(ctx)
+	if err != nil {
+		if s.loaded {
+			s.logger.Error("Error reloading IP rules, keeping the cached rules: %v", err)
+			return s.rules, nil
+		}
+		return nil, errors.New("failed to load IP rules")
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		network, err := utils.ParseNetwork(rule.CIDR)
+		if err != nil {
+			s.logger.Error("Skipping IP rule %s with invalid range %q: %v", rule.ID, rule.CIDR, err)
+			continue
+		}
+		compiled = append(compiled, compiledRule{
+			scope:          rule.Scope,
+			organizationID: rule.OrganizationID,
+			action:         rule.Action,
+			network:        network,
+		})
+	}
+	s.rules = compiled
+	s.loadedAt = s.now()
+	s.loaded = true
+	return s.rules, nil
+}
+
+func (s *service) invalidate() {
+	s.mu.Lock()
+	s.loaded = false
+	s.mu.Unlock()
+}
+
+func validateRule(rule models.IPRule) error {
+	switch rule.Scope {
+	case models.IPRuleScopeGlobal, models.IPRuleScopeAdmin:
+		if rule.OrganizationID != nil {
+			return errors.New("organization is only allowed for organization rules")
+		}
+	case models.IPRuleScopeOrganization:
+		if rule.OrganizationID == nil {
+			return errors.New("organization rules need an organization")
+		}
+	default:
+		return errors.New("invalid scope")
+	}
+	if rule.Action != models.IPRuleActionAllow && rule.Action != models.IPRuleActionDeny {
+		return errors.New("invalid action")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package iprules
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	CreateRule(ctx context.Context, rule models.IPRule, actorID uuid.UUID, client dto.ClientInfo) (*models.IPRule, error)
+	DeleteRule(ctx context.Context, id uuid.UUID, actorID uuid.UUID, client dto.ClientInfo) error
+	ListRules(ctx context.Context) ([]*models.IPRule, error)
+	// IsAllowed evaluates the rules of a scope for an IP. organizationID is only used by the organization scope.
+	IsAllowed(ctx context.Context, ip string, scope string, organizationID *uuid.UUID) (bool, error)
+}
@@ -0,0 +1,173 @@
+package iprules
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestService(repo *repository_mock.MockIPRuleRepository) *service {
+	return NewService(repo, service_mock.NewPermissiveMockAuditRecorder(), service_mock.NewPermissiveMockLogger(), time.Minute).(*service)
+}
+
+func TestIsAllowed(t *testing.T) {
+	orgID, otherOrgID := uuid.New(), uuid.New()
+	rules := []*models.IPRule{
+		{Scope: models.IPRuleScopeGlobal, Action: models.IPRuleActionDeny, CIDR: "198.51.100.0/24"},
+		{Scope: models.IPRuleScopeAdmin, Action: models.IPRuleActionAllow, CIDR: "10.8.0.0/16"},
+		{Scope: models.IPRuleScopeAdmin, Action: models.IPRuleActionDeny, CIDR: "10.8.1.0/24"},
+		{Scope: models.IPRuleScopeOrganization, OrganizationID: &orgID, Action: models.IPRuleActionAllow, CIDR: "203.0.113.0/24"},
+	}
+	tests := []struct {
+		name           string
+		ip             string
+		scope          string
+		organizationID *uuid.UUID
+		allowed        bool
+	}{
+		{"global deny matches", "198.51.100.7", models.IPRuleScopeGlobal, nil, false},
+		{"global without match", "192.0.2.1", models.IPRuleScopeGlobal, nil, true},
+		{"admin from vpn", "10.8.3.4", models.IPRuleScopeAdmin, nil, true},
+		{"admin deny wins over allow", "10.8.1.4", models.IPRuleScopeAdmin, nil, false},
+		{"admin outside vpn", "192.0.2.1", models.IPRuleScopeAdmin, nil, false},
+		{"organization inside range", "203.0.113.9", models.IPRuleScopeOrganization, &orgID, true},
+		{"organization outside range", "192.0.2.1", models.IPRuleScopeOrganization, &orgID, false},
+		{"other organization has no rules", "192.0.2.1", models.IPRuleScopeOrganization, &otherOrgID, true},
+		{"invalid address with allow rules", "not-an-ip", models.IPRuleScopeAdmin, nil, false},
+	}
+
+	repo := new(repository_mock.MockIPRuleRepository)
+	repo.On("FindAll").Return(rules, nil).Once()
+	svc := newTestService(repo)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			allowed, err := svc.IsAllowed(context.Background(), tt.ip, tt.scope, tt.organizationID)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, allowed)
+		})
+	}
+	repo.AssertNumberOfCalls(t, "FindAll", 1)
+}
+
+func TestIsAllowed_KeepsCachedRulesWhenReloadFails(t *testing.T) {
+	// Arrange
+	repo := new(repository_mock.MockIPRuleRepository)
+	repo.On("FindAll").Return([]*models.IPRule{
+		{Scope: models.IPRuleScopeGlobal, Action: models.IPRuleActionDeny, CIDR: "198.51.100.0/24"},
+	}, nil).Once()
+	repo.On("FindAll").Return(nil, errors.New("database unavailable"))
+	svc := newTestService(repo)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	_, err := svc.IsAllowed(context.Background(), "192.0.2.1", models.IPRuleScopeGlobal, nil)
+	assert.NoError(t, err)
+
+	// Act
+	now = now.Add(2 * time.Minute)
+	allowed, err := svc.IsAllowed(context.Background(), "198.51.100.7", models.IPRuleScopeGlobal, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	repo.AssertNumberOfCalls(t, "FindAll", 2)
+}
+
+func TestCreateRule_InvalidatesCache(t *testing.T) {
+	// Arrange
+	actorID := uuid.New()
+	repo := new(repository_mock.MockIPRuleRepository)
+	repo.On("FindAll").Return([]*models.IPRule{}, nil).Once()
+	repo.On("Create", mock.AnythingOfType("*models.IPRule")).Return(&models.IPRule{}, nil)
+	svc := newTestService(repo)
+	allowed, _ := svc.IsAllowed(context.Background(), "198.51.100.7", models.IPRuleScopeGlobal, nil)
+	assert.True(t, allowed)
+	repo.On("FindAll").Return([]*models.IPRule{
+		{Scope: models.IPRuleScopeGlobal, Action: models.IPRuleActionDeny, CIDR: "198.51.100.0/24"},
+	}, nil)
+
+	// Act
+	_, err := svc.CreateRule(context.Background(), models.IPRule{Scope: models.IPRuleScopeGlobal, Action: models.IPRuleActionDeny,
+		CIDR: "198.51.100.7/24"}, actorID, dto.ClientInfo{})
+	allowed, _ = svc.IsAllowed(context.Background(), "198.51.100.7", models.IPRuleScopeGlobal, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	created := repo.Calls[1].Arguments.Get(0).(*models.IPRule)
+	assert.Equal(t, "198.51.100.0/24", created.CIDR)
+	assert.Equal(t, actorID, created.CreatedBy)
+}
+
+func TestCreateRule_RejectsInvalidRules(t *testing.T) {
+	orgID := uuid.New()
+	tests := []struct {
+		name string
+		rule models.IPRule
+	}{
+		{"invalid range", models.IPRule{Scope: models.IPRuleScopeGlobal, Action: models.IPRuleActionDeny, CIDR: "10.0.0.0/33"}},
+		{"invalid scope", models.IPRule{Scope: "everything", Action: models.IPRuleActionDeny, CIDR: "10.0.0.0/8"}},
+		{"invalid action", models.IPRule{Scope: models.IPRuleScopeGlobal, Action: "block", CIDR: "10.0.0.0/8"}},
+		{"organization missing", models.IPRule{Scope: models.IPRuleScopeOrganization, Action: models.IPRuleActionAllow, CIDR: "10.0.0.0/8"}},
+		{"organization on admin rule", models.IPRule{Scope: models.IPRuleScopeAdmin, OrganizationID: &orgID, Action: models.IPRuleActionAllow, CIDR: "10.0.0.0/8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			repo := new(repository_mock.MockIPRuleRepository)
+			svc := newTestService(repo)
+
+			// Act
+			_, err := svc.CreateRule(context.Background(), tt.rule, uuid.New(), dto.ClientInfo{})
+
+			// Assert
+			assert.Error(t, err)
+			repo.AssertNotCalled(t, "Create", mock.Anything)
+		})
+	}
+}
+
+func TestMiddleware_TrustsForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	repo := new(repository_mock.MockIPRuleRepository)
+	repo.On("FindAll").Return([]*models.IPRule{
+		{Scope: models.IPRuleScopeAdmin, Action: models.IPRuleActionAllow, CIDR: "10.8.0.0/16"},
+	}, nil)
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies([]string{"192.0.2.10"}))
+	router.GET("/admin", Middleware(newTestService(repo), models.IPRuleScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Act
+	viaTrustedProxy := request("192.0.2.10:5000", "10.8.0.5")
+	spoofed := request("203.0.113.50:5000", "10.8.0.5")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, viaTrustedProxy)
+	assert.Equal(t, http.StatusForbidden, spoofed)
+}
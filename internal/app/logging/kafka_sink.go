@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Publisher sends a batch of encoded log records. The batch is reused once Publish returned.
+type Publisher interface {
+	Publish(records [][]byte) error
+	Close() error
+}
+
+// KafkaSink is the writer of a handler that publishes its records in the background. Writes never block: a
+// record arriving while the buffer is full is dropped and counted, as are the records of batches that could
+// not be published.
+type KafkaSink struct {
+	publisher     Publisher
+	records       chan []byte
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+	// reported is the dropped count of the last report, drops are reported once per flush
+	reported uint64
+}
+
+func NewKafkaSink(publisher Publisher, bufferSize, batchSize int, flushInterval time.Duration) *KafkaSink {
+	sink := &KafkaSink{
+		publisher:     publisher,
+		records:       make(chan []byte, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go sink.run()
+	return sink
+}
+
+// Write queues one record. The handler reuses p, so it is copied.
+func (s *KafkaSink) Write(p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return 0, errors.New("log sink is closed")
+	}
+	record := make([]byte, len(p))
+	copy(record, p)
+	select {
+	case s.records <- record:
+	default:
+		s.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns how many records were dropped because the buffer was full.
+func (s *KafkaSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Failed returns how many records were lost because their batch could not be published.
+func (s *KafkaSink) Failed() uint64 {
+	return s.failed.Load()
+}
+
+// Close publishes the buffered records and stops the sink.
+func (s *KafkaSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.records)
+	s.mu.Unlock()
+	<-s.done
+	return s.publisher.Close()
+}
+
+func (s *KafkaSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, s.batchSize)
+	for {
+		select {
+		case record, ok := <-s.records:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= s.batchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		}
+	}
+}
+
+// flush publishes the batch and returns it emptied. The sink cannot log its own failures through itself,
+// so they go to the standard logger.
+func (s *KafkaSink) flush(batch [][]byte) [][]byte {
+	if dropped := s.dropped.Load(); dropped > s.reported {
+		log.Printf("Dropped %d log records, the Kafka log buffer is full", dropped-s.reported)
+		s.reported = dropped
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := s.publisher.Publish(batch); err != nil {
+		s.failed.Add(uint64(len(batch)))
+		log.Printf("Failed to publish %d log records to Kafka: %v", len(batch), err)
+	}
+	return batch[:0]
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePublisher records the published batches. While blocked is set Publish waits until it is released.
+type fakePublisher struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+	blocked chan struct{}
+	closed  bool
+}
+
+func (p *fakePublisher) Publish(records [][]byte) error {
+	if p.blocked != nil {
+		<-p.blocked
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	batch := make([]string, 0, len(records))
+	for _, record := range records {
+		batch = append(batch, string(record))
+	}
+	p.batches = append(p.batches, batch)
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *fakePublisher) published() [][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]string(nil), p.batches...)
+}
+
+func TestKafkaSink_PublishesFullBatches(t *testing.T) {
+	// Arrange
+	publisher := &fakePublisher{}
+	sink := NewKafkaSink(publisher, 10, 2, time.Hour)
+
+	// Act
+	for _, record := range []string{"a", "b", "c"} {
+		_, err := sink.Write([]byte(record))
+		assert.NoError(t, err)
+	}
+
+	// Assert
+	assert.Eventually(t, func() bool { return len(publisher.published()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, [][]string{{"a", "b"}}, publisher.published())
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, publisher.published(), "the buffer was not flushed on close")
+	assert.True(t, publisher.closed)
+}
+
+func TestKafkaSink_FlushesEveryInterval(t *testing.T) {
+	// Arrange
+	publisher := &fakePublisher{}
+	sink := NewKafkaSink(publisher, 10, 100, 10*time.Millisecond)
+	defer sink.Close()
+
+	// Act
+	_, _ = sink.Write([]byte("lonely"))
+
+	// Assert
+	assert.Eventually(t, func() bool { return len(publisher.published()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestKafkaSink_DropsWhenBufferIsFull(t *testing.T) {
+	// Arrange
+	publisher := &fakePublisher{blocked: make(chan struct{})}
+	sink := NewKafkaSink(publisher, 2, 1, time.Hour)
+	logger := New(NewRedactor(""), JSONSink(sink, slog.LevelInfo))
+
+	// Act
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		logger.Info("burst", "i", i)
+	}
+
+	// Assert
+	assert.Less(t, time.Since(start), time.Second, "logging blocked on the publisher")
+	// One record is held by the blocked publisher and two are buffered, the others are dropped
+	assert.GreaterOrEqual(t, sink.Dropped(), uint64(7))
+	close(publisher.blocked)
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, uint64(10), uint64(len(publisher.published()))+sink.Dropped())
+}
+
+func TestKafkaSink_CountsFailedRecords(t *testing.T) {
+	// Arrange
+	publisher := &fakePublisher{err: errors.New("broker unavailable")}
+	sink := NewKafkaSink(publisher, 10, 3, time.Hour)
+
+	// Act
+	for i := 0; i < 3; i++ {
+		_, _ = sink.Write([]byte("record"))
+	}
+	assert.NoError(t, sink.Close())
+
+	// Assert
+	assert.Equal(t, uint64(3), sink.Failed())
+	_, err := sink.Write([]byte("late"))
+	assert.Error(t, err)
+}
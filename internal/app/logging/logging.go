@@ -0,0 +1,224 @@
+// Package logging is the structured logger of the service, built on log/slog. Records are scrubbed of
+// personal data and secrets, go to every sink whose level they reach and carry the request and user IDs of
+// the context they were logged with.
+package logging
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// ErrNoKafkaSink is returned by PingKafkaSink when the logger does not write to Kafka.
+var ErrNoKafkaSink = errors.New("the logger has no Kafka sink")
+
+// New returns a logger writing to all the sinks. Each sink filters by its own level and gets the records
+// after the redactor masked them.
+func New(redactor *Redactor, sinks ...slog.Handler) *slog.Logger {
+	return slog.New(&contextHandler{next: &redactingHandler{next: &fanoutHandler{handlers: sinks}, redactor: redactor}})
+}
+
+// JSONSink writes the records of level and above as JSON lines to w.
+func JSONSink(w io.Writer, level slog.Leveler) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+}
+
+// Root is the logger of the process together with the sinks it owns. There is a single Kafka producer for
+// all logs.
+type Root struct {
+	*slog.Logger
+	// closers flush the sinks
+	closers []io.Closer
+	// kafkaPublisher publishes the records of the Kafka sink, if there is one
+	kafkaPublisher *saramaPublisher
+}
+
+// NewRoot returns the logger with the sinks of the configuration. The counters of its Kafka sink are
+// registered with the metrics, so it is built once per process.
+func NewRoot(cfg *config.Config) (*Root, error) {
+	root := &Root{}
+	var sinks []slog.Handler
+	for _, sink := range cfg.Logging.Sinks {
+		switch sink {
+		case config.LogSinkStdout:
+			sinks = append(sinks, JSONSink(os.Stdout, cfg.Logging.Level))
+		case config.LogSinkKafka:
+			publisher, err := newSaramaPublisher(cfg.Kafka.BrokersAddr, cfg.Kafka.LoggerTopic)
+			if err != nil {
+				return nil, errors.Join(err, root.Close())
+			}
+			root.kafkaPublisher = publisher
+			kafkaSink := NewKafkaSink(publisher, cfg.Logging.KafkaBufferSize, cfg.Logging.KafkaBatchSize, cfg.Logging.KafkaFlushInterval)
+			root.closers = append(root.closers, kafkaSink)
+			if err := metrics.RegisterLogSink(kafkaSink.Dropped, kafkaSink.Failed); err != nil {
+				return nil, errors.Join(err, root.Close())
+			}
+			sinks = append(sinks, JSONSink(kafkaSink, cfg.Logging.Level))
+		}
+	}
+	root.Logger = New(NewRedactor(cfg.Logging.PseudonymKey), sinks...)
+	return root, nil
+}
+
+// Printf returns the logger behind the printf style interface of the services.
+func (r *Root) Printf() iservice.Logger {
+	return NewPrintfLogger(r.Logger)
+}
+
+// PingKafkaSink checks that the broker of the Kafka sink is reachable and knows the logger topic.
+func (r *Root) PingKafkaSink(ctx context.Context) error {
+	if r.kafkaPublisher == nil {
+		return ErrNoKafkaSink
+	}
+	return r.kafkaPublisher.Ping(ctx)
+}
+
+// Close publishes what the sinks still buffer and stops them.
+func (r *Root) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// fanoutHandler hands every record to the handlers it is enabled for.
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, record.Level) {
+			err = errors.Join(err, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return err
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithAttrs(attrs))
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, 0, len(h.handlers))
+	for _, handler := range h.handlers {
+		handlers = append(handlers, handler.WithGroup(name))
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+// saramaPublisher publishes batches of log records to a topic.
+type saramaPublisher struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaPublisher returns the publisher of the Kafka sink.
+func NewKafkaPublisher(brokers []string, topic string) (Publisher, error) {
+	return newSaramaPublisher(brokers, topic)
+}
+
+func newSaramaPublisher(brokers []string, topic string) (*saramaPublisher, error) {
+	cfg := sarama.NewConfig()
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+
+	client, err := sarama.NewClient(brokers, cfg)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &saramaPublisher{client: client, producer: producer, topic: topic}, nil
+}
+
+// Publish sends the batch in one span, whose trace context every record carries. The records were logged in
+// many requests, so the span starts a trace of its own, linked to the spans the records were logged in.
+func (p *saramaPublisher) Publish(records [][]byte) (err error) {
+	traceHeaders := make(map[string]string)
+	span := tracing.StartProducerSpan(context.Background(), p.topic, traceHeaders, recordLinks(records)...)
+	defer func() { tracing.End(span, err) }()
+
+	headers := make([]sarama.RecordHeader, 0, len(traceHeaders))
+	for name, value := range traceHeaders {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+	}
+	messages := make([]*sarama.ProducerMessage, 0, len(records))
+	for _, record := range records {
+		messages = append(messages, &sarama.ProducerMessage{Topic: p.topic, Value: sarama.ByteEncoder(record), Headers: headers})
+	}
+	return p.producer.SendMessages(messages)
+}
+
+// recordLinks returns a link to every span the records were logged in, found in their trace_id and span_id.
+func recordLinks(records [][]byte) []trace.Link {
+	var links []trace.Link
+	seen := make(map[trace.SpanID]bool)
+	for _, record := range records {
+		var ids struct {
+			TraceID string `json:"trace_id"`
+			SpanID  string `json:"span_id"`
+		}
+		if err := json.Unmarshal(record, &ids); err != nil {
+			continue
+		}
+		traceID, traceErr := trace.TraceIDFromHex(ids.TraceID)
+		spanID, spanErr := trace.SpanIDFromHex(ids.SpanID)
+		if traceErr != nil || spanErr != nil || seen[spanID] {
+			continue
+		}
+		seen[spanID] = true
+		links = append(links, trace.Link{SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID, SpanID: spanID, Remote: true,
+		})})
+	}
+	return links
+}
+
+// Ping refreshes the metadata of the topic. Sarama bounds the refresh by its own timeouts, Ping returns
+// early when ctx is done first.
+func (p *saramaPublisher) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p.client.RefreshMetadata(p.topic)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *saramaPublisher) Close() error {
+	// A producer made from a client leaves closing the client to its owner
+	return errors.Join(p.producer.Close(), p.client.Close())
+}
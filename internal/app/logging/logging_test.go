@@ -0,0 +1,206 @@
+package logging
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// lines decodes the JSON lines written to buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNew_FiltersEachSinkByItsLevel(t *testing.T) {
+	// Arrange
+	var verbose, quiet bytes.Buffer
+	logger := New(NewRedactor(""), JSONSink(&verbose, slog.LevelDebug), JSONSink(&quiet, slog.LevelWarn))
+
+	// Act
+	logger.Debug("cache miss", "key", "user:42")
+	logger.Warn("slow query", "duration_ms", 1200)
+
+	// Assert
+	assert.Len(t, lines(t, &verbose), 2)
+	records := lines(t, &quiet)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.Equal(t, "slow query", records[0]["msg"])
+		assert.Equal(t, float64(1200), records[0]["duration_ms"])
+	}
+}
+
+func TestNew_AddsRequestAndUserIDOfContext(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := New(NewRedactor(""), JSONSink(&buf, slog.LevelInfo)).With("component", "auth")
+	userID := uuid.New()
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), userID)
+
+	// Act
+	logger.InfoContext(ctx, "logged in")
+	logger.Info("no request")
+
+	// Assert
+	records := lines(t, &buf)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "req-1", records[0]["request_id"])
+		assert.Equal(t, userID.String(), records[0]["user_id"])
+		assert.Equal(t, "auth", records[0]["component"])
+		assert.NotContains(t, records[1], "request_id")
+		assert.NotContains(t, records[1], "user_id")
+	}
+}
+
+func TestNew_AddsTraceIDOfContext(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := New(NewRedactor(""), JSONSink(&buf, slog.LevelInfo))
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	// Act
+	logger.InfoContext(ctx, "token refreshed")
+	logger.Info("no span")
+
+	// Assert
+	records := lines(t, &buf)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0]["trace_id"])
+		assert.Equal(t, "00f067aa0ba902b7", records[0]["span_id"])
+		assert.NotContains(t, records[1], "trace_id")
+	}
+}
+
+func TestRecordLinks_LinksSpansOfRecordsOnce(t *testing.T) {
+	// Arrange
+	records := [][]byte{
+		[]byte(`{"msg":"login","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"}`),
+		[]byte(`{"msg":"login again","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"}`),
+		[]byte(`{"msg":"no span"}`),
+		[]byte(`not json`),
+	}
+
+	// Act
+	links := recordLinks(records)
+
+	// Assert
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", links[0].SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", links[0].SpanContext.SpanID().String())
+	}
+}
+
+func TestPrintfLogger_FormatsMessageAtLevel(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := NewPrintfLogger(New(NewRedactor(""), JSONSink(&buf, slog.LevelInfo)))
+
+	// Act
+	logger.Debug("dropped %d", 1)
+	logger.Info("user %s logged in", "jane")
+	logger.Error("failed: %v", "timeout")
+
+	// Assert
+	records := lines(t, &buf)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "INFO", records[0]["level"])
+		assert.Equal(t, "user jane logged in", records[0]["msg"])
+		assert.Equal(t, "ERROR", records[1]["level"])
+		assert.Equal(t, "failed: timeout", records[1]["msg"])
+	}
+}
+
+func TestPrintfLogger_LoggerWithCarriesIDsOfContext(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := NewPrintfLogger(New(NewRedactor(""), JSONSink(&buf, slog.LevelInfo)))
+	userID := uuid.New()
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), userID)
+
+	// Act
+	iservice.LoggerWith(ctx, logger).Info("user %s logged in", "jane")
+	logger.Info("no request")
+
+	// Assert
+	records := lines(t, &buf)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "req-1", records[0]["request_id"])
+		assert.Equal(t, userID.String(), records[0]["user_id"])
+		assert.Equal(t, "user jane logged in", records[0]["msg"])
+		assert.NotContains(t, records[1], "request_id")
+	}
+}
+
+func TestMiddleware_LogsRequestWithRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name      string
+		requestID string
+		keep      bool
+	}{
+		{"keeps valid ID", "abc-123", true},
+		{"replaces missing ID", "", false},
+		{"replaces unsafe ID", "abc\ninjected", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var buf bytes.Buffer
+			router := gin.New()
+			router.Use(Middleware(New(NewRedactor(""), JSONSink(&buf, slog.LevelInfo))))
+			var seen string
+			router.GET("/users/:id", func(c *gin.Context) {
+				seen = RequestID(c.Request.Context())
+				c.Status(http.StatusNotFound)
+			})
+			request := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			if tt.requestID != "" {
+				request.Header.Set(HeaderRequestID, tt.requestID)
+			}
+			recorder := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(recorder, request)
+
+			// Assert
+			returned := recorder.Header().Get(HeaderRequestID)
+			assert.Equal(t, seen, returned)
+			if tt.keep {
+				assert.Equal(t, tt.requestID, returned)
+			} else {
+				_, err := uuid.Parse(returned)
+				assert.NoError(t, err)
+			}
+			records := lines(t, &buf)
+			if assert.Len(t, records, 1) {
+				assert.Equal(t, "WARN", records[0]["level"])
+				assert.Equal(t, "/users/:id", records[0]["route"])
+				assert.Equal(t, float64(http.StatusNotFound), records[0]["status"])
+				assert.Equal(t, returned, records[0]["request_id"])
+			}
+		})
+	}
+}
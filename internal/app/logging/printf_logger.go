@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// PrintfLogger adapts a structured logger to the printf style iservice.Logger. The message is only
+// formatted when its level is enabled, with the fields of struct arguments tagged sensitive masked.
+type PrintfLogger struct {
+	logger *slog.Logger
+	// ctx is logged with every record, for the request and user IDs and the trace it carries
+	ctx context.Context
+}
+
+func NewPrintfLogger(logger *slog.Logger) iservice.Logger {
+	return &PrintfLogger{logger: logger, ctx: context.Background()}
+}
+
+// With implements iservice.ContextLogger, the records of the returned logger carry what ctx identifies.
+func (l *PrintfLogger) With(ctx context.Context) iservice.Logger {
+	return &PrintfLogger{logger: l.logger, ctx: ctx}
+}
+
+func (l *PrintfLogger) Info(message string, args ...interface{}) {
+	l.log(slog.LevelInfo, message, args...)
+}
+
+func (l *PrintfLogger) Error(message string, args ...interface{}) {
+	l.log(slog.LevelError, message, args...)
+}
+
+func (l *PrintfLogger) Warn(message string, args ...interface{}) {
+	l.log(slog.LevelWarn, message, args...)
+}
+
+func (l *PrintfLogger) Debug(message string, args ...interface{}) {
+	l.log(slog.LevelDebug, message, args...)
+}
+
+func (l *PrintfLogger) log(level slog.Level, message string, args ...interface{}) {
+	if !l.logger.Enabled(l.ctx, level) {
+		return
+	}
+	masked := make([]interface{}, len(args))
+	for i, arg := range args {
+		masked[i], _ = maskSensitiveFields(arg, nil)
+	}
+	l.logger.Log(l.ctx, level, fmt.Sprintf(message, masked...))
+}
@@ -0,0 +1,175 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	redacted = "[REDACTED]"
+	// SensitiveTag is the struct tag that keeps a field out of the logs: `log:"sensitive"`
+	SensitiveTag   = "log"
+	sensitiveValue = "sensitive"
+	pseudonymBytes = 8
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	// resetTokenPattern is the ID of a password reset token followed by its verifier
+	resetTokenPattern = regexp.MustCompile(
+		`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\.[A-Za-z0-9_\-]{16,}`)
+	// secretAssignmentPattern is a secret written as key=value or "key":"value", the key is kept
+	secretAssignmentPattern = regexp.MustCompile(
+		`(?i)\b(password|passwd|pwd|secret|api_?key|access_?token|refresh_?token|reset_?token)("?\s*[:=]\s*"?)([^\s",}]+)`)
+	// sensitiveKeys are attribute keys whose values are dropped whatever they look like
+	sensitiveKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "api_key", "apikey",
+		"verifier"}
+)
+
+// Redactor masks personal data and secrets in log records. Emails become a pseudonym when a key is set, so
+// the lines of one user can still be told apart, and are masked otherwise. JWTs, reset tokens, secrets
+// written as key=value, attributes with a sensitive key and struct fields tagged `log:"sensitive"` are
+// masked.
+type Redactor struct {
+	pseudonymKey []byte
+}
+
+// NewRedactor returns a redactor pseudonymizing emails with pseudonymKey, or masking them when it is empty.
+func NewRedactor(pseudonymKey string) *Redactor {
+	return &Redactor{pseudonymKey: []byte(pseudonymKey)}
+}
+
+// Pseudonym returns the stable pseudonym of an email, "user:" followed by a keyed hash of it, or a mask
+// when there is no key.
+func (r *Redactor) Pseudonym(email string) string {
+	if len(r.pseudonymKey) == 0 {
+		return "[EMAIL]"
+	}
+	mac := hmac.New(sha256.New, r.pseudonymKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "user:" + hex.EncodeToString(mac.Sum(nil)[:pseudonymBytes])
+}
+
+// String masks what looks like personal data or a secret in s.
+func (r *Redactor) String(s string) string {
+	s = jwtPattern.ReplaceAllString(s, "[JWT]")
+	s = resetTokenPattern.ReplaceAllString(s, "[TOKEN]")
+	s = secretAssignmentPattern.ReplaceAllString(s, "${1}${2}"+redacted)
+	return emailPattern.ReplaceAllStringFunc(s, r.Pseudonym)
+}
+
+// Attr masks the value of an attribute, and of the attributes in it when it is a group.
+func (r *Redactor) Attr(attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.String(value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		masked := make([]slog.Attr, 0, len(attrs))
+		for _, nested := range attrs {
+			masked = append(masked, r.Attr(nested))
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(masked...)}
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, r.String(err.Error()))
+		}
+		if masked, ok := maskSensitiveFields(value.Any(), r.String); ok {
+			return slog.Any(attr.Key, masked)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// maskSensitiveFields returns a copy of a struct, or of the struct a pointer points to, with its exported
+// fields tagged sensitive masked and, when scrub is set, its other string fields scrubbed. Other values are
+// returned as they are, with false.
+func maskSensitiveFields(v interface{}, scrub func(string) string) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !hasSensitiveField(rv.Type()) {
+		return v, false
+	}
+	masked := reflect.New(rv.Type()).Elem()
+	masked.Set(rv)
+	for i := 0; i < masked.NumField(); i++ {
+		field := masked.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case rv.Type().Field(i).Tag.Get(SensitiveTag) != sensitiveValue:
+			if scrub != nil && field.Kind() == reflect.String {
+				field.SetString(scrub(field.String()))
+			}
+		case field.Kind() == reflect.String:
+			field.SetString(redacted)
+		default:
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+	return masked.Interface(), true
+}
+
+func hasSensitiveField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(SensitiveTag) == sensitiveValue {
+			return true
+		}
+	}
+	return false
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactingHandler masks the message and the attributes of every record before passing it on.
+type redactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		masked.AddAttrs(h.redactor.Attr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		masked = append(masked, h.redactor.Attr(attr))
+	}
+	return &redactingHandler{next: h.next.WithAttrs(masked), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
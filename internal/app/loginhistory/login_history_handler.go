@@ -0,0 +1,68 @@
+package loginhistory
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	loginHistoryService Service
+}
+
+func NewHandler(loginHistoryService Service) *Handler {
+	return &Handler{
+		loginHistoryService: loginHistoryService,
+	}
+}
+
+// GetLoginHistory
+// @Summary GetLoginHistory
+// @Description Lists the login attempts of the current user, newest first
+// @Tags Users
+// @Produce json
+// @Param limit query int false "Page size, at most 100"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} dto.LoginAttemptResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /user/login-history [get]
+func (h *Handler) GetLoginHistory(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	temp, ok := c.Get("userID")
+	if !ok {
+		errorResponse.Message = "Unauthorized"
+		errorResponse.ErrorCode = http.StatusUnauthorized
+		c.JSON(http.StatusUnauthorized, errorResponse)
+		return
+	}
+	userID := temp.(uuid.UUID)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	attempts, err := h.loginHistoryService.GetHistory(c.Request.Context(), userID, utils.NewPagination(limit, offset))
+	if err != nil {
+		errorResponse.Message = "Error fetching login history"
+		errorResponse.ErrorCode = http.StatusInternalServerError
+		c.JSON(http.StatusInternalServerError, errorResponse)
+		return
+	}
+
+	response := make([]dto.LoginAttemptResponse, 0, len(attempts))
+	for _, attempt := range attempts {
+		response = append(response, dto.LoginAttemptResponse{
+			Outcome:   attempt.Outcome,
+			IP:        attempt.IP,
+			UserAgent: attempt.UserAgent,
+			Country:   attempt.Country,
+			City:      attempt.City,
+			NewDevice: attempt.NewDevice,
+			CreatedAt: attempt.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,107 @@
+package loginhistory
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+type service struct {
+	repo       irepository.LoginAttemptRepository
+	geoLocator iservice.GeoLocator
+	logger     iservice.Logger
+}
+
+func NewService(repo irepository.LoginAttemptRepository, geoLocator iservice.GeoLocator, logger iservice.Logger) Service {
+	return &service{
+		repo:       repo,
+		geoLocator: geoLocator,
+		logger:     logger,
+	}
+}
+
+func (s *service) RecordAttempt(ctx context.Context, userID *uuid.UUID, outcome string, client dto.ClientInfo) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{
+		UserID:            userID,
+		Outcome:           outcome,
+		IP:                client.IP,
+		UserAgent:         truncate(client.UserAgent, 512),
+		DeviceFingerprint: DeviceFingerprint(client),
+	}
+
+	if outcome == models.LoginOutcomeSuccess && userID != nil {
+		newDevice, err := s.IsNewDevice(ctx, *userID, client)
+		if err != nil {
+			s.logger.Error("Error checking device for user %s: %v", userID, err)
+		}
+		attempt.NewDevice = newDevice
+	}
+
+	location, err := s.geoLocator.Lookup(client.IP)
+	if err != nil {
+		s.logger.Warn("Geo lookup failed for login attempt: %v", err)
+	}
+	if location != nil {
+		attempt.Country = location.Country
+		attempt.City = location.City
+		attempt.Latitude = &location.Latitude
+		attempt.Longitude = &location.Longitude
+	}
+
+	created, err := s.repo.Create(ctx, attempt)
+	if err != nil {
+		s.logger.Error("Error recording login attempt: %v", err)
+		return nil, errors.New("failed to record login attempt")
+	}
+	return created, nil
+}
+
+// IsNewDevice reports whether the user has logged in before, but never from this device.
+// The very first login of an account is not treated as a new device.
+func (s *service) IsNewDevice(ctx context.Context, userID uuid.UUID, client dto.ClientInfo) (bool, error) {
+	hasHistory, err := s.repo.HasSuccessfulLogin(ctx, userID)
+	if err != nil || !hasHistory {
+		return false, err
+	}
+	knownDevice, err := s.repo.HasSuccessfulLoginFromDevice(ctx, userID, DeviceFingerprint(client))
+	if err != nil {
+		return false, err
+	}
+	return !knownDevice, nil
+}
+
+func (s *service) GetHistory(ctx context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error) {
+	return s.repo.FindByUserID(ctx, userID, p)
+}
+
+func (s *service) GetLastSuccessfulLogin(ctx context.Context, userID uuid.UUID) (*models.LoginAttempt, error) {
+	return s.repo.FindLastSuccessful(ctx, userID)
+}
+
+func (s *service) CountRecentFailuresFromIP(ctx context.Context, ip string, since time.Time) (int64, error) {
+	return s.repo.CountFailuresFromIP(ctx, ip, since)
+}
+
+// DeviceFingerprint derives a stable identifier for the client software from its request headers.
+func DeviceFingerprint(client dto.ClientInfo) string {
+	normalized := strings.ToLower(strings.TrimSpace(client.UserAgent)) + "|" +
+		strings.ToLower(strings.TrimSpace(client.AcceptLanguage))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
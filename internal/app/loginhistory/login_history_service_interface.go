@@ -0,0 +1,18 @@
+package loginhistory
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type Service interface {
+	RecordAttempt(ctx context.Context, userID *uuid.UUID, outcome string, client dto.ClientInfo) (*models.LoginAttempt, error)
+	IsNewDevice(ctx context.Context, userID uuid.UUID, client dto.ClientInfo) (bool, error)
+	GetHistory(ctx context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error)
+	GetLastSuccessfulLogin(ctx context.Context, userID uuid.UUID) (*models.LoginAttempt, error)
+	CountRecentFailuresFromIP(ctx context.Context, ip string, since time.Time) (int64, error)
+}
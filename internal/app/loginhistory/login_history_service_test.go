@@ -0,0 +1,76 @@
+package loginhistory
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type stubGeoLocator struct {
+	location *iservice.GeoLocation
+}
+
+func (s *stubGeoLocator) Lookup(ip string) (*iservice.GeoLocation, error) {
+	return s.location, nil
+}
+
+func TestRecordAttempt_FlagsNewDeviceAndLocation(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	client := dto.ClientInfo{IP: "203.0.113.7", UserAgent: "NewBrowser/1.0", AcceptLanguage: "en"}
+	repo := new(repository_mock.MockLoginAttemptRepository)
+	repo.On("HasSuccessfulLogin", userID).Return(true, nil)
+	repo.On("HasSuccessfulLoginFromDevice", userID, DeviceFingerprint(client)).Return(false, nil)
+	repo.On("Create", mock.AnythingOfType("*models.LoginAttempt")).Return(&models.LoginAttempt{}, nil)
+	geoLocator := &stubGeoLocator{location: &iservice.GeoLocation{Country: "NL", City: "Amsterdam"}}
+	svc := NewService(repo, geoLocator, service_mock.NewPermissiveMockLogger())
+
+	// Act
+	_, err := svc.RecordAttempt(context.Background(), &userID, models.LoginOutcomeSuccess, client)
+
+	// Assert
+	assert.NoError(t, err)
+	recorded := repo.Calls[len(repo.Calls)-1].Arguments.Get(0).(*models.LoginAttempt)
+	assert.True(t, recorded.NewDevice)
+	assert.Equal(t, "NL", recorded.Country)
+	assert.Equal(t, "Amsterdam", recorded.City)
+	assert.Equal(t, DeviceFingerprint(client), recorded.DeviceFingerprint)
+}
+
+func TestIsNewDevice_FirstLoginIsNotNew(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	repo := new(repository_mock.MockLoginAttemptRepository)
+	repo.On("HasSuccessfulLogin", userID).Return(false, nil)
+	svc := NewService(repo, &stubGeoLocator{}, service_mock.NewPermissiveMockLogger())
+
+	// Act
+	newDevice, err := svc.IsNewDevice(context.Background(), userID, dto.ClientInfo{UserAgent: "Browser/1.0"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, newDevice)
+	repo.AssertNotCalled(t, "HasSuccessfulLoginFromDevice", mock.Anything, mock.Anything)
+}
+
+func TestRecordAttempt_FailedAttemptSkipsDeviceCheck(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	repo := new(repository_mock.MockLoginAttemptRepository)
+	repo.On("Create", mock.AnythingOfType("*models.LoginAttempt")).Return(&models.LoginAttempt{}, nil)
+	svc := NewService(repo, &stubGeoLocator{}, service_mock.NewPermissiveMockLogger())
+
+	// Act
+	_, err := svc.RecordAttempt(context.Background(), &userID, models.LoginOutcomeInvalidCredentials, dto.ClientInfo{})
+
+	// Assert
+	assert.NoError(t, err)
+	repo.AssertNotCalled(t, "HasSuccessfulLogin", mock.Anything)
+}
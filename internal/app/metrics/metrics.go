@@ -0,0 +1,164 @@
+// Package metrics holds the Prometheus metrics of the service and the instrumentation of its HTTP server,
+// database and Redis client. The metrics are registered on Registry, which /metrics exposes to the scrapers
+// that present its token.
+package metrics
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strings"
+)
+
+const namespace = "idp"
+
+// Values of the result label.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Registry holds the metrics of the service together with the Go runtime and process metrics.
+var Registry = newRegistry()
+
+var factory = promauto.With(Registry)
+
+// HTTP server
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to handle HTTP requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Authentication
+var (
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result and by the outcome recorded in the login history.",
+	}, []string{"result", "reason"})
+	Lockouts = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Accounts blocked after too many failed logins.",
+	})
+	TokenRefreshes = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Access token refreshes by result.",
+	}, []string{"result"})
+	BlockListHits = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "block_list_hits_total",
+		Help:      "Tokens rejected because they were on the block list or their session was revoked.",
+	}, []string{"token", "reason"})
+	PasswordResetsRequested = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "password_resets_requested_total",
+		Help:      "Password resets requested, including requests for unknown emails.",
+	})
+	PasswordResetsCompleted = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "password_resets_completed_total",
+		Help:      "Passwords changed with a reset token.",
+	})
+	PasswordHashDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "Time to hash a password or compare one with its hash.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+)
+
+// Dependencies
+var (
+	KafkaSendDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_send_duration_seconds",
+		Help:      "Time until Kafka acknowledged a message, by topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+	KafkaSendErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_send_errors_total",
+		Help:      "Messages Kafka did not acknowledge, by topic.",
+	}, []string{"topic"})
+	RedisCommandDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_duration_seconds",
+		Help:      "Time of Redis commands and pipelines, by command.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25},
+	}, []string{"command", "result"})
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time of Postgres statements, by operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table", "result"})
+)
+
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return registry
+}
+
+// Result returns the result label of an operation that returned err.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// RegisterLogSink exports the counters of the Kafka log sink, which cannot log its own losses.
+func RegisterLogSink(dropped, failed func() uint64) error {
+	for _, counter := range []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "log_records_dropped_total",
+			Help:      "Log records dropped because the Kafka log buffer was full.",
+		}, func() float64 { return float64(dropped()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "log_records_failed_total",
+			Help:      "Log records lost because Kafka rejected their batch.",
+		}, func() float64 { return float64(failed()) }),
+	} {
+		if err := Registry.Register(counter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// RequireToken lets through the requests that carry token as their bearer token, so only the scrapers read
+// the metrics.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid metrics token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware_CountsRequestsByRoute(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/reset/:token", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	counter := HTTPRequests.WithLabelValues(http.MethodGet, "/reset/:token", "204")
+	unmatched := HTTPRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")
+	before, beforeUnmatched := testutil.ToFloat64(counter), testutil.ToFloat64(unmatched)
+
+	// Act
+	for _, path := range []string{"/reset/first-secret", "/reset/second-secret", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Assert
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+	assert.Equal(t, beforeUnmatched+1, testutil.ToFloat64(unmatched))
+}
+
+func TestHandler_ExposesMetrics(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/metrics", Handler())
+	Lockouts.Inc()
+	recorder := httptest.NewRecorder()
+
+	// Act
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "idp_lockouts_total")
+	assert.Contains(t, body, "go_goroutines")
+	assert.False(t, strings.Contains(body, "first-secret"))
+}
+
+func TestRequireToken_RejectsRequestsWithoutToken(t *testing.T) {
+	// Arrange
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/metrics", RequireToken("metrics-scrape-token"), Handler())
+	scrape := func(authorization string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Act
+	anonymous := scrape("")
+	wrong := scrape("Bearer metrics-scrape-tokem")
+	basic := scrape("Basic metrics-scrape-token")
+	scraper := scrape("Bearer metrics-scrape-token")
+
+	// Assert
+	for _, rejected := range []*httptest.ResponseRecorder{anonymous, wrong, basic} {
+		assert.Equal(t, http.StatusUnauthorized, rejected.Code)
+		assert.NotContains(t, rejected.Body.String(), "idp_")
+	}
+	assert.Equal(t, http.StatusOK, scraper.Code)
+	assert.Contains(t, scraper.Body.String(), "go_goroutines")
+}
+
+func TestRedisHook_TimesCommands(t *testing.T) {
+	// Arrange
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	client.AddHook(RedisHook{})
+	before := testutil.CollectAndCount(RedisCommandDuration)
+
+	// Act
+	ctx := context.Background()
+	assert.NoError(t, client.Set(ctx, "key", "value", 0).Err())
+	assert.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, "counter")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, before+3, testutil.CollectAndCount(RedisCommandDuration))
+	assert.NotContains(t, redisResults(t), ResultFailure)
+}
+
+func TestRegisterLogSink_RejectsSecondSink(t *testing.T) {
+	// Arrange
+	var dropped uint64 = 3
+	count := func() uint64 { return dropped }
+
+	// Act
+	first := RegisterLogSink(count, count)
+	second := RegisterLogSink(count, count)
+
+	// Assert
+	assert.NoError(t, first)
+	assert.Error(t, second)
+	assert.Contains(t, gatherNames(t), "idp_log_records_dropped_total")
+}
+
+func gatherNames(t *testing.T) []string {
+	families, err := Registry.Gather()
+	assert.NoError(t, err)
+	names := make([]string, 0, len(families))
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	return names
+}
+
+func redisResults(t *testing.T) []string {
+	families, err := Registry.Gather()
+	assert.NoError(t, err)
+	var results []string
+	for _, family := range families {
+		if family.GetName() != "idp_redis_command_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "result" {
+					results = append(results, label.GetValue())
+				}
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditEventRegistered           = "user.registered"
+	AuditEventRoleChanged          = "user.role_changed"
+	AuditEventLoginSucceeded       = "auth.login_succeeded"
+	AuditEventLoginFailed          = "auth.login_failed"
+	AuditEventAccountLocked        = "auth.account_locked"
+	AuditEventLoggedOut            = "auth.logged_out"
+	AuditEventPasswordChanged      = "auth.password_changed"
+	AuditEventPasswordReset        = "auth.password_reset"
+	AuditEventIPRuleCreated        = "admin.ip_rule_created"
+	AuditEventIPRuleDeleted        = "admin.ip_rule_deleted"
+	AuditEventInvitationCreated    = "admin.invitation_created"
+	AuditEventImpersonationStarted = "admin.impersonation_started"
+	AuditEventImpersonationStopped = "admin.impersonation_stopped"
+	AuditEventImpersonationExpired = "admin.impersonation_expired"
+	AuditEventWebhookCreated       = "admin.webhook_created"
+	AuditEventWebhookDeleted       = "admin.webhook_deleted"
+	AuditEventWebhookRedelivered   = "admin.webhook_redelivered"
+	AuditEventCommandExecuted      = "system.command_executed"
+
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeDenied  = "denied"
+
+	AuditTargetUser                 = "user"
+	AuditTargetIPRule               = "ip_rule"
+	AuditTargetInvitation           = "invitation"
+	AuditTargetImpersonationSession = "impersonation_session"
+	AuditTargetWebhook              = "webhook"
+)
+
+// AuditRecord is one entry of the append-only audit log. Records form a hash chain in Sequence order:
+// each one stores the hash of its predecessor, so changing or removing a record breaks the chain.
+type AuditRecord struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Sequence   int64      `gorm:"not null;uniqueIndex"`
+	EventType  string     `gorm:"type:varchar(64);not null;index"`
+	Outcome    string     `gorm:"type:varchar(16);not null"`
+	ActorID    *uuid.UUID `gorm:"type:uuid;index"`
+	TargetType string     `gorm:"type:varchar(32)"`
+	TargetID   *uuid.UUID `gorm:"type:uuid;index"`
+	IP         string     `gorm:"type:varchar(45)"`
+	// Details is a JSON object with event specific fields
+	Details   string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"not null;index"`
+	PrevHash  string    `gorm:"type:varchar(64);not null"`
+	Hash      string    `gorm:"type:varchar(64);not null"`
+}
+
+// ComputeHash returns the hash of the record's content and its predecessor's hash. CreatedAt must already be
+// truncated to the microsecond precision of the database, or a stored record will not verify.
+func (r *AuditRecord) ComputeHash() string {
+	fields := []string{
+		r.PrevHash,
+		strconv.FormatInt(r.Sequence, 10),
+		r.ID.String(),
+		r.EventType,
+		r.Outcome,
+		optionalUUID(r.ActorID),
+		r.TargetType,
+		optionalUUID(r.TargetID),
+		r.IP,
+		r.Details,
+		r.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	// A JSON array keeps the field boundaries unambiguous
+	content, _ := json.Marshal(fields)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// ImpersonationSession records an admin acting as another user. It is kept after the session ends so
+// the impersonated user can review who looked at their account and why.
+type ImpersonationSession struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ActorID    uuid.UUID `gorm:"type:uuid;not null"`
+	ActorEmail string    `gorm:"type:varchar(255);not null"`
+	TargetID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	Reason     string    `gorm:"type:varchar(512);not null"`
+	StartedAt  time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	EndedAt    *time.Time
+}
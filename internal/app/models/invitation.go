@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Invitation lets one email address register with a pre-assigned role. Like password reset tokens,
+// only a keyed hash of the secret part of the token is stored and the ID doubles as the selector.
+type Invitation struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	Email          string     `gorm:"type:varchar(255);not null;index"`
+	Role           string     `gorm:"type:varchar(32);not null"`
+	OrganizationID *uuid.UUID `gorm:"type:uuid;index"`
+	TokenHash      string     `gorm:"type:varchar(255);not null"`
+	InvitedBy      uuid.UUID  `gorm:"type:uuid;not null"`
+	ExpiresAt      time.Time  `gorm:"not null"`
+	AcceptedAt     *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
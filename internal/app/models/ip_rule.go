@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// IPRuleScopeGlobal rules apply to every request
+	IPRuleScopeGlobal = "global"
+	// IPRuleScopeAdmin rules apply to the admin routes
+	IPRuleScopeAdmin = "admin"
+	// IPRuleScopeOrganization rules apply to logins of the members of one organization
+	IPRuleScopeOrganization = "organization"
+
+	IPRuleActionAllow = "allow"
+	IPRuleActionDeny  = "deny"
+)
+
+// IPRule allows or denies a CIDR range within a scope. Deny rules win; once a scope has allow rules,
+// only addresses matching one of them are let through.
+type IPRule struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Scope          string     `gorm:"type:varchar(32);not null;index"`
+	OrganizationID *uuid.UUID `gorm:"type:uuid;index"`
+	Action         string     `gorm:"type:varchar(16);not null"`
+	CIDR           string     `gorm:"type:varchar(64);not null"`
+	Description    string     `gorm:"type:varchar(255)"`
+	CreatedBy      uuid.UUID  `gorm:"type:uuid;not null"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	LoginOutcomeSuccess            = "success"
+	LoginOutcomeUnknownUser        = "unknown_user"
+	LoginOutcomeInvalidCredentials = "invalid_credentials"
+	LoginOutcomeLocked             = "locked"
+	LoginOutcomeBlocked            = "blocked"
+	LoginOutcomeThrottled          = "throttled"
+	LoginOutcomeError              = "error"
+	LoginOutcomeStepUpRequired     = "step_up_required"
+	LoginOutcomeStepUpFailed       = "step_up_failed"
+	LoginOutcomeRiskDenied         = "risk_denied"
+	LoginOutcomeIPDenied           = "ip_denied"
+)
+
+// FailedLoginOutcomes are the outcomes that count against the client in failure rate checks.
+var FailedLoginOutcomes = []string{
+	LoginOutcomeUnknownUser,
+	LoginOutcomeInvalidCredentials,
+	LoginOutcomeLocked,
+	LoginOutcomeBlocked,
+	LoginOutcomeIPDenied,
+	LoginOutcomeStepUpFailed,
+}
+
+// LoginAttempt records one login attempt. UserID is nil when the email did not match an account.
+type LoginAttempt struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID            *uuid.UUID `gorm:"type:uuid;index:idx_login_attempts_user_created"`
+	Outcome           string     `gorm:"type:varchar(32);not null"`
+	IP                string     `gorm:"type:varchar(45)"`
+	UserAgent         string     `gorm:"type:varchar(512)"`
+	DeviceFingerprint string     `gorm:"type:varchar(64);index"`
+	NewDevice         bool       `gorm:"default:false"`
+	Country           string     `gorm:"type:varchar(2)"`
+	City              string     `gorm:"type:varchar(255)"`
+	Latitude          *float64
+	Longitude         *float64
+	CreatedAt         time.Time `gorm:"autoCreateTime;index:idx_login_attempts_user_created"`
+}
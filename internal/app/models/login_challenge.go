@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// LoginChallenge is the step-up a risky login has to complete before tokens are issued. The code is sent
+// to the mailbox of the user, only a keyed hash of it is stored.
+type LoginChallenge struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash       string    `gorm:"type:varchar(255);not null"`
+	ExpiresAt      time.Time `gorm:"not null"`
+	CompletedAt    *time.Time
+	FailedAttempts int       `gorm:"default:0"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
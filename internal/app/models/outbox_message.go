@@ -0,0 +1,57 @@
+package models
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	AggregateUser = "user"
+)
+
+// OutboxMessage is a domain event stored in the same transaction as the change it describes. The outbox
+// relay publishes it afterwards, so the event is neither lost when Kafka is down nor sent for a change that
+// was rolled back.
+type OutboxMessage struct {
+	ID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	// Sequence orders the messages. Messages of one aggregate are published in this order.
+	Sequence      int64     `gorm:"autoIncrement;uniqueIndex"`
+	AggregateType string    `gorm:"type:varchar(32);not null"`
+	AggregateID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	Topic         string    `gorm:"type:varchar(255);not null"`
+	// Payload is the JSON encoded CloudEvents envelope
+	Payload       string     `gorm:"type:text;not null"`
+	Attempts      int        `gorm:"not null;default:0"`
+	LastError     string     `gorm:"type:text"`
+	NextAttemptAt time.Time  `gorm:"not null"`
+	DeliveredAt   *time.Time `gorm:"index"`
+	// DeadLetteredAt is when the relay gave up on the message and moved it to the dead-letter topic
+	DeadLetteredAt *time.Time
+	CreatedAt      time.Time `gorm:"not null"`
+	// TraceParent is the W3C trace context of the request that stored the message, publishing it joins that
+	// trace
+	TraceParent string `gorm:"type:varchar(55)"`
+}
+
+// NewOutboxMessage stores the envelope under its event ID. The message is due immediately.
+func NewOutboxMessage(aggregateType string, aggregateID uuid.UUID, topic string, envelope *events.Envelope) (*OutboxMessage, error) {
+	id, err := uuid.Parse(envelope.ID)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		ID:            id,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Topic:         topic,
+		Payload:       string(body),
+		NextAttemptAt: envelope.Time,
+		CreatedAt:     envelope.Time,
+	}, nil
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PasswordResetToken is one outstanding password reset request. Only a keyed hash of the
+// secret part of the token is stored, the ID doubles as the public selector.
+type PasswordResetToken struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash      string    `gorm:"type:varchar(255);not null"`
+	ExpiresAt      time.Time `gorm:"not null"`
+	UsedAt         *time.Time
+	FailedAttempts int       `gorm:"default:0"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
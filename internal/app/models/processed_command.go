@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	CommandSucceeded = "succeeded"
+	CommandFailed    = "failed"
+)
+
+// ProcessedCommand remembers the result of a command consumed from Kafka. A command read again, after a
+// rebalance or because its sender retried, is answered with this result instead of being executed twice.
+type ProcessedCommand struct {
+	// ID is the command ID, the ID of its CloudEvents envelope
+	ID          string    `gorm:"type:varchar(255);primaryKey"`
+	Type        string    `gorm:"type:varchar(255);not null"`
+	Source      string    `gorm:"type:varchar(255);not null"`
+	Status      string    `gorm:"type:varchar(16);not null"`
+	Error       string    `gorm:"type:text"`
+	ProcessedAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,17 @@
+package models
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleOrgOwner manages the members of its own organization
+	RoleOrgOwner = "org_owner"
+)
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleAdmin, RoleOrgOwner:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration records that the migrations of a schema version ran. Readiness compares the latest version
+// with the one the running binary expects.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
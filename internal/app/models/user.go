@@ -8,7 +8,7 @@ import (
 type User struct {
 	ID                 uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	Email              string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_email_active"`
-	Password           string    `gorm:"type:varchar(255);not null"`
+	Password           string    `gorm:"type:varchar(255);not null" log:"sensitive"`
 	FirstAccess        bool      `gorm:"default:true"`
 	FailedAttempts     int       `gorm:"default:0"`
 	LastAttempt        *time.Time
@@ -16,9 +16,16 @@ type User struct {
 	BlockedUntil       *time.Time
 	CreatedAt          time.Time `gorm:"autoUpdateTime"`
 	UpdatedAt          time.Time `gorm:"autoUpdateTime"`
-	ResetPasswordToken string    `gorm:"type:varchar(255);index"`
-	ResetTokenExpires  *time.Time
-	IsActive           bool `gorm:"default:true;uniqueIndex:idx_email_active"`
+	IsActive           bool      `gorm:"default:true;uniqueIndex:idx_email_active"`
+	IsLocked           bool      `gorm:"default:false"`
+	PendingEmail       string    `gorm:"type:varchar(255)"`
+	PreviousEmail      string    `gorm:"type:varchar(255)"`
+	EmailChangeToken   string    `gorm:"type:varchar(255);index" log:"sensitive"`
+	EmailChangeExpires *time.Time
+	EmailRevertToken   string `gorm:"type:varchar(255);index" log:"sensitive"`
+	EmailRevertExpires *time.Time
+	Role               string     `gorm:"type:varchar(32);not null;default:user"`
+	OrganizationID     *uuid.UUID `gorm:"type:uuid;index"`
 }
 
 func SimulateUser() User {
@@ -26,18 +33,16 @@ func SimulateUser() User {
 	futureTime := currentTime.Add(time.Hour * 2)
 
 	return User{
-		ID:                 uuid.New(),
-		Email:              "john.doe@example.com",
-		Password:           "hashedPasswordHere",
-		FirstAccess:        true,
-		FailedAttempts:     1,
-		LastAttempt:        &currentTime,
-		IsBlocked:          false,
-		BlockedUntil:       &futureTime,
-		CreatedAt:          currentTime,
-		UpdatedAt:          currentTime,
-		ResetPasswordToken: "randomTokenHere",
-		ResetTokenExpires:  &futureTime,
-		IsActive:           true,
+		ID:             uuid.New(),
+		Email:          "john.doe@example.com",
+		Password:       "hashedPasswordHere",
+		FirstAccess:    true,
+		FailedAttempts: 1,
+		LastAttempt:    &currentTime,
+		IsBlocked:      false,
+		BlockedUntil:   &futureTime,
+		CreatedAt:      currentTime,
+		UpdatedAt:      currentTime,
+		IsActive:       true,
 	}
 }
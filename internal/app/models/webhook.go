@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription sends the events of an organization's users to a partner's URL. Unlike tokens, the
+// secret is stored as is: deliveries are signed with it, so it cannot be hashed.
+type WebhookSubscription struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index"`
+	URL            string    `gorm:"type:varchar(2048);not null"`
+	Secret         string    `gorm:"type:varchar(255);not null" log:"sensitive"`
+	// EventTypes is a comma separated list of the CloudEvents types sent to the URL
+	EventTypes string    `gorm:"type:text;not null"`
+	CreatedBy  uuid.UUID `gorm:"type:uuid;not null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+func (s *WebhookSubscription) EventTypeList() []string {
+	return strings.Split(s.EventTypes, ",")
+}
+
+// Subscribes reports whether events of the type are sent to the subscription.
+func (s *WebhookSubscription) Subscribes(eventType string) bool {
+	for _, subscribed := range s.EventTypeList() {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event on its way to a subscription, and its entry in the delivery log. An event
+// is queued once per subscription even when Kafka saw it twice; a manual redelivery is a new delivery of
+// the same event that points to the one it repeats.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_webhook_deliveries_event,where:redelivery_of IS NULL"`
+	EventID        string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_webhook_deliveries_event,where:redelivery_of IS NULL"`
+	RedeliveryOf   *uuid.UUID `gorm:"type:uuid"`
+	EventType      string     `gorm:"type:varchar(255);not null"`
+	// Payload is the JSON encoded CloudEvents envelope, sent as the request body
+	Payload        string    `gorm:"type:text;not null"`
+	Status         string    `gorm:"type:varchar(16);not null;index"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"not null;index"`
+	LastStatusCode int       `gorm:"not null;default:0"`
+	LastError      string    `gorm:"type:text"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time `gorm:"not null;index"`
+}
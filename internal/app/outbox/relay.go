@@ -0,0 +1,153 @@
+package outbox
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+// Headers of a message moved to the dead-letter topic
+const (
+	HeaderDeadLetterReason   = "dlq_reason"
+	HeaderDeadLetterTopic    = "dlq_original_topic"
+	HeaderDeadLetterAttempts = "dlq_attempts"
+)
+
+// relay publishes outbox messages at least once. A message is marked delivered after Kafka acknowledged it,
+// so a crash in between publishes it again. Messages of one aggregate are published in sequence order: after
+// a failure the later ones wait until the failed message went out, or until it failed maxAttempts times and
+// was moved to the dead-letter topic.
+type relay struct {
+	repo            irepository.OutboxRepository
+	sender          iservice.MessageSender
+	deadLetters     iservice.RawMessageSender
+	logger          iservice.Logger
+	pollInterval    time.Duration
+	batchSize       int
+	retryBackoff    time.Duration
+	maxBackoff      time.Duration
+	maxAttempts     int
+	deadLetterTopic string
+	now             func() time.Time
+}
+
+func NewRelay(repo irepository.OutboxRepository, sender iservice.MessageSender, deadLetters iservice.RawMessageSender,
+	logger iservice.Logger, pollInterval time.Duration, batchSize int, retryBackoff, maxBackoff time.Duration,
+	maxAttempts int, deadLetterTopic string) Relay {
+	return &relay{
+		repo:            repo,
+		sender:          sender,
+		deadLetters:     deadLetters,
+		logger:          logger,
+		pollInterval:    pollInterval,
+		batchSize:       batchSize,
+		retryBackoff:    retryBackoff,
+		maxBackoff:      maxBackoff,
+		maxAttempts:     maxAttempts,
+		deadLetterTopic: deadLetterTopic,
+		now:             time.Now,
+	}
+}
+
+func (r *relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	for {
+		// A batch that started goes out completely, shutdown only stops the next one
+		if _, err := r.RelayPending(context.WithoutCancel(ctx)); err != nil {
+			r.logger.Error("Failed to relay outbox messages: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *relay) RelayPending(ctx context.Context) (int, error) {
+	delivered := 0
+	// Only one relay publishes at a time, two could overtake each other within an aggregate
+	_, err := r.repo.RunExclusive(ctx, func(repo irepository.OutboxRepository) error {
+		now := r.now()
+		messages, err := repo.FindPending(ctx, now, r.batchSize)
+		if err != nil {
+			return err
+		}
+		// An aggregate whose message failed in this batch holds back its later messages
+		held := make(map[uuid.UUID]bool)
+		for _, message := range messages {
+			if held[message.AggregateID] {
+				continue
+			}
+
+			// Publishing continues the trace of the request that stored the message
+			messageCtx := tracing.ContinueTrace(ctx, message.TraceParent)
+			if err := r.publish(messageCtx, message); err != nil {
+				attempts := message.Attempts + 1
+				if attempts >= r.maxAttempts {
+					deadLetterErr := r.deadLetter(messageCtx, message, attempts, err)
+					if deadLetterErr == nil {
+						// The later messages of the aggregate no longer wait for this one
+						if err := repo.MarkDeadLettered(ctx, message.ID, attempts, err.Error(), now); err != nil {
+							return err
+						}
+						continue
+					}
+					iservice.LoggerWith(messageCtx, r.logger).Error("Failed to dead-letter outbox message %s: %v", message.ID, deadLetterErr)
+				}
+				held[message.AggregateID] = true
+				nextAttemptAt := now.Add(r.backoff(attempts))
+				iservice.LoggerWith(messageCtx, r.logger).Warn("Failed to publish outbox message %s (attempt %d), retrying at %s: %v",
+					message.ID, attempts, nextAttemptAt.String(), err)
+				if err := repo.MarkFailed(ctx, message.ID, attempts, err.Error(), nextAttemptAt); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := repo.MarkDelivered(ctx, message.ID, r.now()); err != nil {
+				return err
+			}
+			delivered++
+		}
+		return nil
+	})
+	return delivered, err
+}
+
+func (r *relay) publish(ctx context.Context, message *models.OutboxMessage) error {
+	var envelope events.Envelope
+	if err := json.Unmarshal([]byte(message.Payload), &envelope); err != nil {
+		return err
+	}
+	return r.sender.SendEnvelope(ctx, message.Topic, message.AggregateID.String(), &envelope)
+}
+
+// deadLetter moves the stored envelope as it is to the dead-letter topic, with the reason in its headers.
+func (r *relay) deadLetter(ctx context.Context, message *models.OutboxMessage, attempts int, reason error) error {
+	headers := map[string]string{
+		HeaderDeadLetterReason:   reason.Error(),
+		HeaderDeadLetterTopic:    message.Topic,
+		HeaderDeadLetterAttempts: strconv.Itoa(attempts),
+	}
+	if err := r.deadLetters.SendRaw(ctx, r.deadLetterTopic, []byte(message.AggregateID.String()), []byte(message.Payload),
+		headers); err != nil {
+		return err
+	}
+	r.logger.Error("Moved outbox message %s for %s to %s after %d attempts: %v", message.ID, message.Topic,
+		r.deadLetterTopic, attempts, reason)
+	return nil
+}
+
+// backoff doubles the retry delay with every failed attempt, up to the maximum.
+func (r *relay) backoff(attempts int) time.Duration {
+	return utils.ExponentialBackoff(r.retryBackoff, r.maxBackoff, attempts)
+}
@@ -0,0 +1,10 @@
+package outbox
+
+import "context"
+
+type Relay interface {
+	// Run publishes the outbox every poll interval until ctx is done.
+	Run(ctx context.Context)
+	// RelayPending publishes the due messages of one batch and returns how many were delivered.
+	RelayPending(ctx context.Context) (int, error)
+}
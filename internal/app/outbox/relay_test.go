@@ -0,0 +1,399 @@
+package outbox
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryOutbox is a transactional outbox in memory: RunExclusive undoes the changes of fn when it fails,
+// like the rolled back transaction of the Gorm repository.
+type memoryOutbox struct {
+	mu       sync.Mutex
+	messages []*models.OutboxMessage
+	sequence int64
+	// locked simulates a relay in another instance holding the lock
+	locked bool
+	// crashOnMarkDelivered simulates the process dying after a message was sent but before it was marked
+	crashOnMarkDelivered bool
+}
+
+func (m *memoryOutbox) Add(_ context.Context, message *models.OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sequence++
+	message.Sequence = m.sequence
+	copied := *message
+	m.messages = append(m.messages, &copied)
+	return nil
+}
+
+func (m *memoryOutbox) FindPending(_ context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	messages := append([]*models.OutboxMessage(nil), m.messages...)
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Sequence < messages[j].Sequence })
+	var pending []*models.OutboxMessage
+	held := make(map[uuid.UUID]bool)
+	for _, message := range messages {
+		if message.DeliveredAt != nil || message.DeadLetteredAt != nil {
+			continue
+		}
+		if held[message.AggregateID] || message.NextAttemptAt.After(now) {
+			held[message.AggregateID] = true
+			continue
+		}
+		if len(pending) < limit {
+			copied := *message
+			pending = append(pending, &copied)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryOutbox) MarkDelivered(_ context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.crashOnMarkDelivered {
+		return errors.New("connection lost")
+	}
+	m.find(id).DeliveredAt = &deliveredAt
+	return nil
+}
+
+func (m *memoryOutbox) MarkFailed(_ context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	message := m.find(id)
+	message.Attempts = attempts
+	message.LastError = lastError
+	message.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (m *memoryOutbox) MarkDeadLettered(_ context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	message := m.find(id)
+	message.Attempts = attempts
+	message.LastError = lastError
+	message.DeadLetteredAt = &deadLetteredAt
+	return nil
+}
+
+func (m *memoryOutbox) RunExclusive(_ context.Context, fn func(repo irepository.OutboxRepository) error) (bool, error) {
+	m.mu.Lock()
+	if m.locked {
+		m.mu.Unlock()
+		return false, nil
+	}
+	snapshot := make([]models.OutboxMessage, len(m.messages))
+	for i, message := range m.messages {
+		snapshot[i] = *message
+	}
+	m.mu.Unlock()
+
+	err := fn(m)
+	if err != nil {
+		m.mu.Lock()
+		for i := range snapshot {
+			*m.messages[i] = snapshot[i]
+		}
+		m.mu.Unlock()
+	}
+	return true, err
+}
+
+func (m *memoryOutbox) find(id uuid.UUID) *models.OutboxMessage {
+	for _, message := range m.messages {
+		if message.ID == id {
+			return message
+		}
+	}
+	return nil
+}
+
+func (m *memoryOutbox) get(id uuid.UUID) models.OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.find(id)
+}
+
+type relayTestDeps struct {
+	outbox *memoryOutbox
+	sender *service_mock.FakeMessageSender
+	now    time.Time
+}
+
+func newRelayTestDeps() *relayTestDeps {
+	return &relayTestDeps{
+		outbox: &memoryOutbox{},
+		sender: service_mock.NewFakeMessageSender(),
+		now:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// newRelay starts a relay the way a freshly started instance would, with nothing but the stored outbox.
+func (d *relayTestDeps) newRelay() Relay {
+	r := NewRelay(d.outbox, d.sender, d.sender, service_mock.NewPermissiveMockLogger(), time.Second, 10, time.Second,
+		4*time.Second, 3, "outbox-dead-letter")
+	r.(*relay).now = func() time.Time { return d.now }
+	return r
+}
+
+func (d *relayTestDeps) add(t *testing.T, aggregateID uuid.UUID, topic string, event events.Event) *models.OutboxMessage {
+	envelope, err := events.NewEnvelope(service_mock.FakeOrigin, event, uuid.New(), d.now)
+	assert.NoError(t, err)
+	message, err := models.NewOutboxMessage(models.AggregateUser, aggregateID, topic, envelope)
+	assert.NoError(t, err)
+	assert.NoError(t, d.outbox.Add(context.Background(), message))
+	return message
+}
+
+func sentPayloads(sender *service_mock.FakeMessageSender) []string {
+	var payloads []string
+	for _, sent := range sender.Sent() {
+		payloads = append(payloads, string(sent.Envelope.Data))
+	}
+	return payloads
+}
+
+func TestRelayPending_PublishesInOrderAndMarksDelivered(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	userID := uuid.New()
+	created := deps.add(t, userID, "account-created", events.AccountCreated{UserID: userID, Email: "a@example.com"})
+	blocked := deps.add(t, userID, "account-blocked",
+		events.AccountBlocked{UserID: userID, Email: "a@example.com", Reason: "locked"})
+
+	// Act
+	delivered, err := deps.newRelay().RelayPending(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "account-created", sent[0].Topic)
+		assert.Equal(t, "account-blocked", sent[1].Topic)
+		assert.Equal(t, userID.String(), sent[0].Key)
+		// The stored envelope is sent as is
+		assert.Equal(t, created.ID.String(), sent[0].Envelope.ID)
+	}
+	assert.Equal(t, []string{
+		`{"userId":"` + userID.String() + `","email":"a@example.com"}`,
+		`{"userId":"` + userID.String() + `","email":"a@example.com","reason":"locked"}`,
+	}, sentPayloads(deps.sender))
+	assert.NotNil(t, deps.outbox.get(created.ID).DeliveredAt)
+	assert.NotNil(t, deps.outbox.get(blocked.ID).DeliveredAt)
+
+	delivered, err = deps.newRelay().RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Len(t, deps.sender.Sent(), 2)
+}
+
+func TestRelayPending_PublishesMessagesStoredBeforeRestart(t *testing.T) {
+	// Arrange
+	// The user change committed together with its event, then the process died before the relay ran
+	deps := newRelayTestDeps()
+	message := deps.add(t, uuid.New(), "account-created", events.AccountCreated{UserID: uuid.New(), Email: "a@example.com"})
+
+	// Act
+	delivered, err := deps.newRelay().RelayPending(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.NotNil(t, deps.outbox.get(message.ID).DeliveredAt)
+}
+
+func TestRelayPending_RedeliversAfterCrashBeforeMarkDelivered(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	message := deps.add(t, uuid.New(), "account-created", events.AccountCreated{UserID: uuid.New(), Email: "a@example.com"})
+	deps.outbox.crashOnMarkDelivered = true
+
+	// Act
+	_, crashErr := deps.newRelay().RelayPending(context.Background())
+	deps.outbox.crashOnMarkDelivered = false
+	delivered, err := deps.newRelay().RelayPending(context.Background())
+
+	// Assert
+	assert.Error(t, crashErr)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	// At least once: Kafka got the message before the crash and again from the restarted relay
+	assert.Len(t, deps.sender.Sent(), 2)
+	assert.NotNil(t, deps.outbox.get(message.ID).DeliveredAt)
+}
+
+func TestRelayPending_RetriesWithBackoffWhileKafkaIsDown(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	message := deps.add(t, uuid.New(), "account-created", events.AccountCreated{UserID: uuid.New(), Email: "a@example.com"})
+	relay := deps.newRelay()
+	deps.sender.Fail(errors.New("kafka unavailable"))
+
+	// Act & Assert
+	delivered, err := relay.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	stored := deps.outbox.get(message.ID)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, "kafka unavailable", stored.LastError)
+	assert.Equal(t, deps.now.Add(time.Second), stored.NextAttemptAt)
+
+	deps.now = deps.now.Add(time.Second)
+	_, _ = relay.RelayPending(context.Background())
+	stored = deps.outbox.get(message.ID)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Equal(t, deps.now.Add(2*time.Second), stored.NextAttemptAt)
+
+	// Kafka is back, but the message is not due yet
+	deps.sender.Fail(nil)
+	delivered, _ = relay.RelayPending(context.Background())
+	assert.Zero(t, delivered)
+
+	deps.now = deps.now.Add(2 * time.Second)
+	delivered, err = relay.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Len(t, deps.sender.Sent(), 1)
+}
+
+func TestRelayPending_HoldsBackLaterMessagesOfFailedAggregate(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	failing, other := uuid.New(), uuid.New()
+	first := deps.add(t, failing, "account-created", events.AccountCreated{UserID: failing, Email: "a@example.com"})
+	second := deps.add(t, failing, "account-blocked",
+		events.AccountBlocked{UserID: failing, Email: "a@example.com", Reason: "locked"})
+	deps.add(t, other, "account-created", events.AccountCreated{UserID: other, Email: "b@example.com"})
+	relay := deps.newRelay()
+	deps.sender.FailKey(failing.String(), errors.New("partition unavailable"))
+
+	// Act
+	delivered, err := relay.RelayPending(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, other.String(), sent[0].Key)
+	}
+	assert.Equal(t, 1, deps.outbox.get(first.ID).Attempts)
+	assert.Zero(t, deps.outbox.get(second.ID).Attempts, "a later message was tried before the failed one")
+
+	// Once the failed message goes out, the rest follows in order
+	deps.sender.FailKey(failing.String(), nil)
+	deps.now = deps.now.Add(time.Second)
+	delivered, err = relay.RelayPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, delivered)
+	sent = deps.sender.Sent()
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, "account-created", sent[1].Topic)
+		assert.Equal(t, "account-blocked", sent[2].Topic)
+	}
+}
+
+func TestRelayPending_AggregateWaitingForRetryDoesNotBlockOthers(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	stuck, other := uuid.New(), uuid.New()
+	// More messages of the stuck aggregate than fit in a batch
+	for i := 0; i <= 10; i++ {
+		deps.add(t, stuck, "account-blocked", events.AccountBlocked{UserID: stuck, Email: "a@example.com", Reason: "locked"})
+	}
+	waiting := deps.add(t, other, "account-created", events.AccountCreated{UserID: other, Email: "b@example.com"})
+	relay := deps.newRelay()
+	deps.sender.FailKey(stuck.String(), errors.New("partition unavailable"))
+	_, err := relay.RelayPending(context.Background())
+	assert.NoError(t, err)
+
+	// Act
+	delivered, err := relay.RelayPending(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	sent := deps.sender.Sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, other.String(), sent[0].Key)
+	}
+	assert.NotNil(t, deps.outbox.get(waiting.ID).DeliveredAt)
+}
+
+func TestRelayPending_DeadLettersAfterMaxAttempts(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	failing := uuid.New()
+	poisoned := deps.add(t, failing, "account-created", events.AccountCreated{UserID: failing, Email: "a@example.com"})
+	// The stored envelope cannot be decoded, so every attempt fails
+	poisoned.Payload = "{not json"
+	deps.outbox.find(poisoned.ID).Payload = poisoned.Payload
+	later := deps.add(t, failing, "account-blocked",
+		events.AccountBlocked{UserID: failing, Email: "a@example.com", Reason: "locked"})
+	relay := deps.newRelay()
+
+	// Act
+	for attempt := 0; attempt < 3; attempt++ {
+		_, err := relay.RelayPending(context.Background())
+		assert.NoError(t, err)
+		deps.now = deps.now.Add(time.Minute)
+	}
+
+	// Assert
+	stored := deps.outbox.get(poisoned.ID)
+	assert.Equal(t, 3, stored.Attempts)
+	assert.NotNil(t, stored.DeadLetteredAt)
+	assert.Nil(t, stored.DeliveredAt)
+	raw := deps.sender.RawSent()
+	if assert.Len(t, raw, 1) {
+		assert.Equal(t, "outbox-dead-letter", raw[0].Topic)
+		assert.Equal(t, "{not json", string(raw[0].Value))
+		assert.Equal(t, "account-created", raw[0].Headers[HeaderDeadLetterTopic])
+		assert.Equal(t, "3", raw[0].Headers[HeaderDeadLetterAttempts])
+	}
+	// The later message of the aggregate is no longer held back
+	assert.NotNil(t, deps.outbox.get(later.ID).DeliveredAt)
+	pending, _ := deps.outbox.FindPending(context.Background(), deps.now, 10)
+	assert.Empty(t, pending)
+}
+
+func TestRelayPending_SkipsWhileAnotherRelayHoldsTheLock(t *testing.T) {
+	// Arrange
+	deps := newRelayTestDeps()
+	deps.add(t, uuid.New(), "account-created", events.AccountCreated{UserID: uuid.New(), Email: "a@example.com"})
+	deps.outbox.locked = true
+
+	// Act
+	delivered, err := deps.newRelay().RelayPending(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Empty(t, deps.sender.Sent())
+}
+
+func TestBackoff_DoublesUpToMaximum(t *testing.T) {
+	// Arrange
+	r := newRelayTestDeps().newRelay().(*relay)
+
+	// Act & Assert
+	assert.Equal(t, time.Second, r.backoff(1))
+	assert.Equal(t, 2*time.Second, r.backoff(2))
+	assert.Equal(t, 4*time.Second, r.backoff(3))
+	assert.Equal(t, 4*time.Second, r.backoff(50))
+}
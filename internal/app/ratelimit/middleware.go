@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/services/iservice"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware enforces every rule for the route and reports the tightest one through the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. Limiter errors let the
+// request through, so a Redis outage does not take authentication down with it.
+func Middleware(limiter iservice.RateLimiter, logger iservice.Logger, rules ...Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tightest *iservice.RateLimitResult
+		for _, rule := range rules {
+			subject := rule.Key(c)
+			if subject == "" {
+				continue
+			}
+
+			result, err := limiter.Allow(c.Request.Context(), c.FullPath()+":"+rule.Name+":"+subject, rule.Limit)
+			if err != nil {
+				iservice.LoggerWith(c.Request.Context(), logger).Error("Rate limiter unavailable for rule %s on %s: %v", rule.Name, c.FullPath(), err)
+				continue
+			}
+
+			if !result.Allowed {
+				setHeaders(c, result)
+				c.Header("Retry-After", seconds(result.RetryAfter))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, dto.ErrorResponse{
+					Message:   "Too many requests, please try again later",
+					ErrorCode: http.StatusTooManyRequests,
+				})
+				return
+			}
+			if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = result
+			}
+		}
+
+		if tightest != nil {
+			setHeaders(c, tightest)
+		}
+		c.Next()
+	}
+}
+
+func setHeaders(c *gin.Context, result *iservice.RateLimitResult) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", seconds(result.ResetAfter))
+}
+
+// seconds rounds up so clients never retry before the window has moved.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
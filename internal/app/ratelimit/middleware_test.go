@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"automation-hub-idp/internal/app/services"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRouter(t *testing.T, rules ...Rule) *gin.Engine {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return newTestRouterWith(services.NewRedisRateLimiter(client), service_mock.NewPermissiveMockLogger(), rules...)
+}
+
+func newTestRouterWith(limiter iservice.RateLimiter, logger iservice.Logger, rules ...Rule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/login", Middleware(limiter, logger, rules...), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	return router
+}
+
+type unavailableLimiter struct{}
+
+func (unavailableLimiter) Allow(context.Context, string, iservice.RateLimit) (*iservice.RateLimitResult, error) {
+	return nil, errors.New("connection refused")
+}
+
+func login(router *gin.Engine, ip, email string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_PerIPLimit(t *testing.T) {
+	router := newTestRouter(t, PerIP(iservice.RateLimit{Requests: 2, Window: time.Minute}))
+
+	first := login(router, "10.0.0.1", "a@example.com")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", first.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, login(router, "10.0.0.1", "b@example.com").Code)
+
+	limited := login(router, "10.0.0.1", "c@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "0", limited.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, limited.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, login(router, "10.0.0.2", "c@example.com").Code)
+}
+
+func TestMiddleware_PerAccountLimitAcrossIPs(t *testing.T) {
+	router := newTestRouter(t,
+		PerIP(iservice.RateLimit{Requests: 100, Window: time.Minute}),
+		PerAccount("email", iservice.RateLimit{Requests: 2, Window: time.Minute}),
+	)
+
+	assert.Equal(t, http.StatusOK, login(router, "10.0.0.1", "victim@example.com").Code)
+	assert.Equal(t, http.StatusOK, login(router, "10.0.0.2", "Victim@Example.com").Code)
+
+	limited := login(router, "10.0.0.3", "victim@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "2", limited.Header().Get("RateLimit-Limit"))
+
+	assert.Equal(t, http.StatusOK, login(router, "10.0.0.3", "other@example.com").Code)
+}
+
+func TestMiddleware_BodyIsPreservedForHandler(t *testing.T) {
+	router := newTestRouter(t, PerAccount("email", iservice.RateLimit{Requests: 5, Window: time.Minute}))
+
+	w := login(router, "10.0.0.1", "user@example.com")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"email":"user@example.com","password":"secret"}`, w.Body.String())
+}
+
+func TestMiddleware_UnavailableLimiterLetsRequestThroughAndLogs(t *testing.T) {
+	logger := new(service_mock.MockLogger)
+	logger.On("Error", "Rate limiter unavailable for rule %s on %s: %v", mock.Anything).Once()
+	router := newTestRouterWith(unavailableLimiter{}, logger, PerIP(iservice.RateLimit{Requests: 1, Window: time.Minute}))
+
+	w := login(router, "10.0.0.1", "user@example.com")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	logger.AssertExpectations(t)
+}
+
+func TestMiddleware_OversizedBodyIsNotReadBeyondTheLimit(t *testing.T) {
+	router := newTestRouter(t, PerAccount("email", iservice.RateLimit{Requests: 5, Window: time.Minute}))
+	body := `{"email":"user@example.com","padding":"` + strings.Repeat("x", maxBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxBodyBytes bounds the body read to find the account, before the request is authenticated or limited.
+// The bodies of the rate limited routes are a few fields, a larger one is cut off for the handler too.
+const maxBodyBytes = 64 << 10
+
+// KeyFunc extracts the subject a rule counts requests for. An empty key skips the rule.
+type KeyFunc func(c *gin.Context) string
+
+type Rule struct {
+	Name  string
+	Limit iservice.RateLimit
+	Key   KeyFunc
+}
+
+// PerIP counts requests per client IP address.
+func PerIP(limit iservice.RateLimit) Rule {
+	return Rule{
+		Name:  "ip",
+		Limit: limit,
+		Key: func(c *gin.Context) string {
+			return c.ClientIP()
+		},
+	}
+}
+
+// PerAccount counts requests per account identifier taken from the request body field,
+// which catches slow attacks against one account spread over many IP addresses.
+func PerAccount(field string, limit iservice.RateLimit) Rule {
+	return Rule{
+		Name:  "account",
+		Limit: limit,
+		Key: func(c *gin.Context) string {
+			account := strings.ToLower(strings.TrimSpace(bodyField(c, field)))
+			if account == "" {
+				return ""
+			}
+			// Keep raw emails out of Redis
+			sum := sha256.Sum256([]byte(account))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+}
+
+// bodyField reads a field from a JSON or form body without consuming it for the handler.
+func bodyField(c *gin.Context, field string) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+	if c.ContentType() != gin.MIMEJSON {
+		return c.PostForm(field)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	value, _ := payload[field].(string)
+	return value
+}
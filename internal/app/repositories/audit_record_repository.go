@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// auditChainLockID is the Postgres advisory lock that serializes appends to the audit chain.
+const auditChainLockID = 0x61756469
+
+type GormAuditRecordRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormAuditRecordRepository(db *gorm.DB, logger Logger) irepository.AuditRecordRepository {
+	return &GormAuditRecordRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormAuditRecordRepository) Append(ctx context.Context, record *models.AuditRecord) (*models.AuditRecord, error) {
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Held until the transaction ends, so the head read below is still the head on insert
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockID).Error; err != nil {
+			return err
+		}
+		var head models.AuditRecord
+		err := tx.Order("sequence DESC").Limit(1).Find(&head).Error
+		if err != nil {
+			return err
+		}
+		record.Sequence = head.Sequence + 1
+		record.PrevHash = head.Hash
+		record.Hash = record.ComputeHash()
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to append audit record: %s", err)
+		return nil, errors.New("failed to append audit record")
+	}
+	return record, nil
+}
+
+func (r *GormAuditRecordRepository) Find(ctx context.Context, filter irepository.AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error) {
+	var records []*models.AuditRecord
+	err := applyAuditFilter(r.DB.WithContext(ctx), filter).Order("sequence DESC").
+		Limit(p.Limit).Offset(p.Offset).Find(&records).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch audit records: %s", err)
+		return nil, errors.New("failed to fetch audit records")
+	}
+	return records, nil
+}
+
+func (r *GormAuditRecordRepository) FindAfter(ctx context.Context, filter irepository.AuditFilter, afterSequence int64, limit int) ([]*models.AuditRecord, error) {
+	var records []*models.AuditRecord
+	err := applyAuditFilter(r.DB.WithContext(ctx), filter).Where("sequence > ?", afterSequence).
+		Order("sequence").Limit(limit).Find(&records).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch audit records: %s", err)
+		return nil, errors.New("failed to fetch audit records")
+	}
+	return records, nil
+}
+
+func applyAuditFilter(db *gorm.DB, filter irepository.AuditFilter) *gorm.DB {
+	query := db.Model(&models.AuditRecord{})
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		query = query.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type GormImpersonationSessionRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormImpersonationSessionRepository(db *gorm.DB, logger Logger) irepository.ImpersonationSessionRepository {
+	return &GormImpersonationSessionRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormImpersonationSessionRepository) Create(ctx context.Context, session *models.ImpersonationSession) (*models.ImpersonationSession, error) {
+	err := r.DB.WithContext(ctx).Create(session).Error
+	if err != nil {
+		r.logger.Error("Failed to create impersonation session: %s", err)
+		return nil, errors.New("failed to create impersonation session")
+	}
+	return session, nil
+}
+
+func (r *GormImpersonationSessionRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.ImpersonationSession, error) {
+	var session models.ImpersonationSession
+	err := r.DB.WithContext(ctx).First(&session, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch impersonation session by ID: %s", err)
+		return nil, errors.New("impersonation session not found")
+	}
+	return &session, nil
+}
+
+func (r *GormImpersonationSessionRepository) FindByTargetID(ctx context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error) {
+	var sessions []*models.ImpersonationSession
+	err := r.DB.WithContext(ctx).Where("target_id = ?", targetID).Order("started_at DESC").
+		Limit(p.Limit).Offset(p.Offset).Find(&sessions).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch impersonation sessions: %s", err)
+		return nil, errors.New("failed to fetch impersonation sessions")
+	}
+	return sessions, nil
+}
+
+// End closes the session. It reports false when the session had already ended.
+func (r *GormImpersonationSessionRepository) End(ctx context.Context, id uuid.UUID, endedAt time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&models.ImpersonationSession{}).Where("id = ? AND ended_at IS NULL", id).
+		UpdateColumn("ended_at", endedAt)
+	if result.Error != nil {
+		r.logger.Error("Failed to end impersonation session: %s", result.Error)
+		return false, errors.New("failed to end impersonation session")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *GormImpersonationSessionRepository) EndExpired(ctx context.Context, now time.Time) ([]*models.ImpersonationSession, error) {
+	var sessions []*models.ImpersonationSession
+	err := r.DB.WithContext(ctx).Raw(`UPDATE impersonation_sessions SET ended_at = expires_at
+	WHERE ended_at IS NULL AND expires_at <= ? RETURNING *`, now).Scan(&sessions).Error
+	if err != nil {
+		r.logger.Error("Failed to end expired impersonation sessions: %s", err)
+		return nil, errors.New("failed to end impersonation session")
+	}
+	return sessions, nil
+}
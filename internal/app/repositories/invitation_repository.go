@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type GormInvitationRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormInvitationRepository(db *gorm.DB, logger Logger) irepository.InvitationRepository {
+	return &GormInvitationRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormInvitationRepository) Create(ctx context.Context, invitation *models.Invitation) (*models.Invitation, error) {
+	err := r.DB.WithContext(ctx).Create(invitation).Error
+	if err != nil {
+		r.logger.Error("Failed to create invitation: %s", err)
+		return nil, errors.New("failed to create invitation")
+	}
+	return invitation, nil
+}
+
+func (r *GormInvitationRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.DB.WithContext(ctx).First(&invitation, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch invitation by ID: %s", err)
+		return nil, errors.New("invitation not found")
+	}
+	return &invitation, nil
+}
+
+// MarkAccepted consumes the invitation. It reports false when it had already been accepted,
+// so two concurrent acceptances cannot both succeed.
+func (r *GormInvitationRepository) MarkAccepted(ctx context.Context, id uuid.UUID, acceptedAt time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Invitation{}).Where("id = ? AND accepted_at IS NULL", id).
+		UpdateColumn("accepted_at", acceptedAt)
+	if result.Error != nil {
+		r.logger.Error("Failed to mark invitation as accepted: %s", result.Error)
+		return false, errors.New("failed to update invitation")
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GormIPRuleRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormIPRuleRepository(db *gorm.DB, logger Logger) irepository.IPRuleRepository {
+	return &GormIPRuleRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormIPRuleRepository) Create(ctx context.Context, rule *models.IPRule) (*models.IPRule, error) {
+	err := r.DB.WithContext(ctx).Create(rule).Error
+	if err != nil {
+		r.logger.Error("Failed to create IP rule: %s", err)
+		return nil, errors.New("failed to create IP rule")
+	}
+	return rule, nil
+}
+
+func (r *GormIPRuleRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.IPRule, error) {
+	var rule models.IPRule
+	err := r.DB.WithContext(ctx).First(&rule, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch IP rule by ID: %s", err)
+		return nil, errors.New("IP rule not found")
+	}
+	return &rule, nil
+}
+
+func (r *GormIPRuleRepository) FindAll(ctx context.Context) ([]*models.IPRule, error) {
+	var rules []*models.IPRule
+	err := r.DB.WithContext(ctx).Order("created_at").Find(&rules).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch IP rules: %s", err)
+		return nil, errors.New("failed to fetch IP rules")
+	}
+	return rules, nil
+}
+
+func (r *GormIPRuleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.DB.WithContext(ctx).Delete(&models.IPRule{}, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to delete IP rule: %s", err)
+		return errors.New("failed to delete IP rule")
+	}
+	return nil
+}
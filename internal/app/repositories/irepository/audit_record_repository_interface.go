@@ -0,0 +1,30 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	EventType string
+	Outcome   string
+	ActorID   *uuid.UUID
+	TargetID  *uuid.UUID
+	IP        string
+	From      *time.Time
+	To        *time.Time
+}
+
+type AuditRecordRepository interface {
+	// Append links the record to the end of the chain and stores it. Appends are serialized, so
+	// concurrent writers cannot fork the chain.
+	Append(ctx context.Context, record *models.AuditRecord) (*models.AuditRecord, error)
+	// Find returns the matching records, newest first.
+	Find(ctx context.Context, filter AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error)
+	// FindAfter returns up to limit matching records with a sequence above afterSequence, in chain order.
+	FindAfter(ctx context.Context, filter AuditFilter, afterSequence int64, limit int) ([]*models.AuditRecord, error)
+}
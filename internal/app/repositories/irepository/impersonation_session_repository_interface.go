@@ -0,0 +1,18 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type ImpersonationSessionRepository interface {
+	Create(ctx context.Context, session *models.ImpersonationSession) (*models.ImpersonationSession, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.ImpersonationSession, error)
+	FindByTargetID(ctx context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error)
+	End(ctx context.Context, id uuid.UUID, endedAt time.Time) (bool, error)
+	// EndExpired ends the sessions that expired by now at their expiry and returns them.
+	EndExpired(ctx context.Context, now time.Time) ([]*models.ImpersonationSession, error)
+}
@@ -0,0 +1,14 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) (*models.Invitation, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Invitation, error)
+	MarkAccepted(ctx context.Context, id uuid.UUID, acceptedAt time.Time) (bool, error)
+}
@@ -0,0 +1,14 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+)
+
+type IPRuleRepository interface {
+	Create(ctx context.Context, rule *models.IPRule) (*models.IPRule, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.IPRule, error)
+	FindAll(ctx context.Context) ([]*models.IPRule, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
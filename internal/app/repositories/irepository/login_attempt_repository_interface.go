@@ -0,0 +1,18 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type LoginAttemptRepository interface {
+	Create(ctx context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error)
+	FindByUserID(ctx context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error)
+	HasSuccessfulLogin(ctx context.Context, userID uuid.UUID) (bool, error)
+	HasSuccessfulLoginFromDevice(ctx context.Context, userID uuid.UUID, deviceFingerprint string) (bool, error)
+	FindLastSuccessful(ctx context.Context, userID uuid.UUID) (*models.LoginAttempt, error)
+	CountFailuresFromIP(ctx context.Context, ip string, since time.Time) (int64, error)
+}
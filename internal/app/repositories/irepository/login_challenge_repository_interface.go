@@ -0,0 +1,15 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type LoginChallengeRepository interface {
+	Create(ctx context.Context, challenge *models.LoginChallenge) (*models.LoginChallenge, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.LoginChallenge, error)
+	IncrementFailedAttempts(ctx context.Context, id uuid.UUID) error
+	MarkCompleted(ctx context.Context, id uuid.UUID, completedAt time.Time) (bool, error)
+}
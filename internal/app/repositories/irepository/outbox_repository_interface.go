@@ -0,0 +1,21 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type OutboxRepository interface {
+	Add(ctx context.Context, message *models.OutboxMessage) error
+	// FindPending returns the undelivered messages that were not dead-lettered and are due at now, in sequence order. It leaves
+	// out every message of an aggregate behind one still waiting for a retry, so an aggregate held back never fills the batch.
+	FindPending(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID, deliveredAt time.Time) error
+	MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error
+	// MarkDeadLettered takes a message that failed too often out of the pending ones.
+	MarkDeadLettered(ctx context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error
+	// RunExclusive runs fn unless another relay is already running. It reports whether fn ran.
+	RunExclusive(ctx context.Context, fn func(repo OutboxRepository) error) (bool, error)
+}
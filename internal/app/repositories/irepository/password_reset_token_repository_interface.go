@@ -0,0 +1,16 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type PasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) (*models.PasswordResetToken, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.PasswordResetToken, error)
+	IncrementFailedAttempts(ctx context.Context, id uuid.UUID) error
+	MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error)
+	InvalidateAllForUser(ctx context.Context, userID uuid.UUID, usedAt time.Time) error
+}
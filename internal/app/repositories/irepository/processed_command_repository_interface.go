@@ -0,0 +1,13 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+)
+
+type ProcessedCommandRepository interface {
+	// FindByID returns nil when the command was not processed yet.
+	FindByID(ctx context.Context, id string) (*models.ProcessedCommand, error)
+	// Create reports false when the command had already been recorded.
+	Create(ctx context.Context, command *models.ProcessedCommand) (bool, error)
+}
@@ -0,0 +1,18 @@
+package irepository
+
+// Store is every repository of the service, all backed by the same database, and the unit of work running
+// transactions on it.
+type Store struct {
+	Users                 UserRepository
+	PasswordResetTokens   PasswordResetTokenRepository
+	LoginChallenges       LoginChallengeRepository
+	LoginAttempts         LoginAttemptRepository
+	IPRules               IPRuleRepository
+	Invitations           InvitationRepository
+	ImpersonationSessions ImpersonationSessionRepository
+	AuditRecords          AuditRecordRepository
+	Outbox                OutboxRepository
+	Webhooks              WebhookRepository
+	ProcessedCommands     ProcessedCommandRepository
+	UnitOfWork            UnitOfWork
+}
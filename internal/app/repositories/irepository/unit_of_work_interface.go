@@ -0,0 +1,14 @@
+package irepository
+
+import "context"
+
+// Repositories are bound to the transaction of a unit of work.
+type Repositories struct {
+	Users  UserRepository
+	Outbox OutboxRepository
+}
+
+// UnitOfWork runs fn in a transaction. It commits when fn returns nil and rolls back otherwise.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(repos Repositories) error) error
+}
@@ -3,15 +3,26 @@ package irepository
 import (
 	"automation-hub-idp/internal/app/models"
 	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
 	"github.com/google/uuid"
+	"time"
 )
 
+// ErrUserNotFound is returned by FindByID when no active user has the ID. Other errors mean the lookup failed.
+var ErrUserNotFound = errors.New("user not found")
+
 type UserRepository interface {
-	FindByID(id uuid.UUID) (*models.User, error)
-	FindByEmail(email string) (*models.User, error)
-	Create(user *models.User) (*models.User, error)
-	Update(user *models.User) (*models.User, error)
-	Delete(id uuid.UUID) error
-	FindAll(p utils.Pagination) ([]*models.User, error)
-	FindByResetToken(token string) (*models.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) (*models.User, error)
+	Update(ctx context.Context, user *models.User) (*models.User, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindAll(ctx context.Context, p utils.Pagination) ([]*models.User, error)
+	FindByEmailChangeToken(ctx context.Context, tokenHash string) (*models.User, error)
+	FindByEmailRevertToken(ctx context.Context, tokenHash string) (*models.User, error)
+	IncrementFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) (int, error)
+	ResetFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) error
+	BlockUntil(ctx context.Context, id uuid.UUID, blockedUntil time.Time) (bool, error)
+	UnblockIfExpired(ctx context.Context, id uuid.UUID, now time.Time) error
 }
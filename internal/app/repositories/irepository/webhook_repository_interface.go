@@ -0,0 +1,27 @@
+package irepository
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error)
+	FindSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error)
+	FindSubscriptionsByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*models.WebhookSubscription, error)
+	// DeleteSubscription deletes the subscription together with its delivery log.
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	// CreateDelivery reports false when the event was already queued for the subscription. Redeliveries are
+	// always created.
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error)
+	FindDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error)
+	// FindDeliveries returns the delivery log of a subscription, newest first.
+	FindDeliveries(ctx context.Context, subscriptionID uuid.UUID, p utils.Pagination) ([]*models.WebhookDelivery, error)
+	// ClaimDue returns up to limit pending deliveries due at now and postpones them until leaseUntil, so
+	// another dispatcher does not send them at the same time.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type GormLoginAttemptRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormLoginAttemptRepository(db *gorm.DB, logger Logger) irepository.LoginAttemptRepository {
+	return &GormLoginAttemptRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormLoginAttemptRepository) Create(ctx context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error) {
+	err := r.DB.WithContext(ctx).Create(attempt).Error
+	if err != nil {
+		r.logger.Error("Failed to create login attempt: %s", err)
+		return nil, errors.New("failed to create login attempt")
+	}
+	return attempt, nil
+}
+
+func (r *GormLoginAttemptRepository) FindByUserID(ctx context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error) {
+	var attempts []*models.LoginAttempt
+	err := r.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").
+		Limit(p.Limit).Offset(p.Offset).Find(&attempts).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch login attempts: %s", err)
+		return nil, errors.New("failed to fetch login attempts")
+	}
+	return attempts, nil
+}
+
+func (r *GormLoginAttemptRepository) HasSuccessfulLogin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("user_id = ? AND outcome = ?", userID, models.LoginOutcomeSuccess).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		r.logger.Error("Failed to check login history: %s", err)
+		return false, errors.New("failed to check login history")
+	}
+	return count > 0, nil
+}
+
+func (r *GormLoginAttemptRepository) HasSuccessfulLoginFromDevice(ctx context.Context, userID uuid.UUID, deviceFingerprint string) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("user_id = ? AND outcome = ? AND device_fingerprint = ?", userID, models.LoginOutcomeSuccess, deviceFingerprint).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		r.logger.Error("Failed to check login history: %s", err)
+		return false, errors.New("failed to check login history")
+	}
+	return count > 0, nil
+}
+
+// FindLastSuccessful returns the most recent successful login of the user, or nil if there is none.
+func (r *GormLoginAttemptRepository) FindLastSuccessful(ctx context.Context, userID uuid.UUID) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.DB.WithContext(ctx).Where("user_id = ? AND outcome = ?", userID, models.LoginOutcomeSuccess).
+		Order("created_at DESC").First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch last successful login: %s", err)
+		return nil, errors.New("failed to fetch last successful login")
+	}
+	return &attempt, nil
+}
+
+func (r *GormLoginAttemptRepository) CountFailuresFromIP(ctx context.Context, ip string, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("ip = ? AND outcome IN ? AND created_at >= ?", ip, models.FailedLoginOutcomes, since).
+		Count(&count).Error
+	if err != nil {
+		r.logger.Error("Failed to count failed login attempts: %s", err)
+		return 0, errors.New("failed to count failed login attempts")
+	}
+	return count, nil
+}
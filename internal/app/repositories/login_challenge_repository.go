@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type GormLoginChallengeRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormLoginChallengeRepository(db *gorm.DB, logger Logger) irepository.LoginChallengeRepository {
+	return &GormLoginChallengeRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormLoginChallengeRepository) Create(ctx context.Context, challenge *models.LoginChallenge) (*models.LoginChallenge, error) {
+	err := r.DB.WithContext(ctx).Create(challenge).Error
+	if err != nil {
+		r.logger.Error("Failed to create login challenge: %s", err)
+		return nil, errors.New("failed to create login challenge")
+	}
+	return challenge, nil
+}
+
+func (r *GormLoginChallengeRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	err := r.DB.WithContext(ctx).First(&challenge, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch login challenge by ID: %s", err)
+		return nil, errors.New("login challenge not found")
+	}
+	return &challenge, nil
+}
+
+func (r *GormLoginChallengeRepository) IncrementFailedAttempts(ctx context.Context, id uuid.UUID) error {
+	err := r.DB.WithContext(ctx).Model(&models.LoginChallenge{}).Where("id = ?", id).
+		UpdateColumn("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+	if err != nil {
+		r.logger.Error("Failed to increment login challenge attempts: %s", err)
+		return errors.New("failed to update login challenge")
+	}
+	return nil
+}
+
+// MarkCompleted consumes the challenge. It reports false when the challenge had already been completed,
+// so one code cannot be exchanged for tokens twice.
+func (r *GormLoginChallengeRepository) MarkCompleted(ctx context.Context, id uuid.UUID, completedAt time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&models.LoginChallenge{}).Where("id = ? AND completed_at IS NULL", id).
+		UpdateColumn("completed_at", completedAt)
+	if result.Error != nil {
+		r.logger.Error("Failed to mark login challenge as completed: %s", result.Error)
+		return false, errors.New("failed to update login challenge")
+	}
+	return result.RowsAffected == 1, nil
+}
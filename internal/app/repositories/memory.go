@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/utils"
+	"errors"
+	"time"
+)
+
+// The Memory* repositories keep their rows in process memory, for running the service without Postgres. They
+// follow the semantics of their Gorm counterparts, unique indexes and the defaults filled in by the database
+// included, and hand out copies, so a caller never changes a stored row without going through the repository.
+
+var (
+	errRecordNotFound = errors.New("record not found")
+	// errDuplicateKey is the memory counterpart of a unique violation
+	errDuplicateKey = errors.New("duplicate key value violates unique constraint")
+)
+
+// page applies the pagination like Postgres does: a negative limit means no limit.
+func page[T any](rows []T, p utils.Pagination) []T {
+	if p.Offset > 0 {
+		if p.Offset >= len(rows) {
+			return []T{}
+		}
+		rows = rows[p.Offset:]
+	}
+	if p.Limit >= 0 && p.Limit < len(rows) {
+		rows = rows[:p.Limit]
+	}
+	return rows
+}
+
+// timePtr copies the time, so a stored row does not share it with the caller.
+func timePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := *t
+	return &value
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+)
+
+// MemoryAuditRecordRepository keeps the chain in sequence order.
+type MemoryAuditRecordRepository struct {
+	mu      sync.RWMutex
+	records []*models.AuditRecord
+	logger  Logger
+}
+
+func NewMemoryAuditRecordRepository(logger Logger) *MemoryAuditRecordRepository {
+	return &MemoryAuditRecordRepository{logger: logger}
+}
+
+func cloneAuditRecord(record *models.AuditRecord) *models.AuditRecord {
+	clone := *record
+	if record.ActorID != nil {
+		actorID := *record.ActorID
+		clone.ActorID = &actorID
+	}
+	if record.TargetID != nil {
+		targetID := *record.TargetID
+		clone.TargetID = &targetID
+	}
+	return &clone
+}
+
+func (r *MemoryAuditRecordRepository) Append(_ context.Context, record *models.AuditRecord) (*models.AuditRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The ID is part of the hash, so it is set before hashing
+	if record.ID == uuid.Nil {
+		record.ID = uuid.New()
+	}
+	var head models.AuditRecord
+	if len(r.records) > 0 {
+		head = *r.records[len(r.records)-1]
+	}
+	record.Sequence = head.Sequence + 1
+	record.PrevHash = head.Hash
+	record.Hash = record.ComputeHash()
+	r.records = append(r.records, cloneAuditRecord(record))
+	return record, nil
+}
+
+func (r *MemoryAuditRecordRepository) Find(_ context.Context, filter irepository.AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	records := make([]*models.AuditRecord, 0)
+	for index := len(r.records) - 1; index >= 0; index-- {
+		if matchesAuditFilter(r.records[index], filter) {
+			records = append(records, cloneAuditRecord(r.records[index]))
+		}
+	}
+	return page(records, p), nil
+}
+
+func (r *MemoryAuditRecordRepository) FindAfter(_ context.Context, filter irepository.AuditFilter, afterSequence int64, limit int) ([]*models.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	records := make([]*models.AuditRecord, 0)
+	for _, record := range r.records {
+		if limit >= 0 && len(records) == limit {
+			break
+		}
+		if record.Sequence > afterSequence && matchesAuditFilter(record, filter) {
+			records = append(records, cloneAuditRecord(record))
+		}
+	}
+	return records, nil
+}
+
+func matchesAuditFilter(record *models.AuditRecord, filter irepository.AuditFilter) bool {
+	switch {
+	case filter.EventType != "" && record.EventType != filter.EventType:
+		return false
+	case filter.Outcome != "" && record.Outcome != filter.Outcome:
+		return false
+	case filter.ActorID != nil && (record.ActorID == nil || *record.ActorID != *filter.ActorID):
+		return false
+	case filter.TargetID != nil && (record.TargetID == nil || *record.TargetID != *filter.TargetID):
+		return false
+	case filter.IP != "" && record.IP != filter.IP:
+		return false
+	case filter.From != nil && record.CreatedAt.Before(*filter.From):
+		return false
+	case filter.To != nil && !record.CreatedAt.Before(*filter.To):
+		return false
+	}
+	return true
+}
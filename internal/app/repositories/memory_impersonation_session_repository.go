@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryImpersonationSessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]*models.ImpersonationSession
+	logger   Logger
+}
+
+func NewMemoryImpersonationSessionRepository(logger Logger) *MemoryImpersonationSessionRepository {
+	return &MemoryImpersonationSessionRepository{
+		sessions: make(map[uuid.UUID]*models.ImpersonationSession),
+		logger:   logger,
+	}
+}
+
+func cloneImpersonationSession(session *models.ImpersonationSession) *models.ImpersonationSession {
+	clone := *session
+	clone.EndedAt = timePtr(session.EndedAt)
+	return &clone
+}
+
+func (r *MemoryImpersonationSessionRepository) Create(_ context.Context, session *models.ImpersonationSession) (*models.ImpersonationSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.sessions[session.ID]; exists {
+		r.logger.Error("Failed to create impersonation session: %s", errDuplicateKey)
+		return nil, errors.New("failed to create impersonation session")
+	}
+	r.sessions[session.ID] = cloneImpersonationSession(session)
+	return session, nil
+}
+
+func (r *MemoryImpersonationSessionRepository) FindByID(_ context.Context, id uuid.UUID) (*models.ImpersonationSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		r.logger.Error("Failed to fetch impersonation session by ID: %s", errRecordNotFound)
+		return nil, errors.New("impersonation session not found")
+	}
+	return cloneImpersonationSession(session), nil
+}
+
+func (r *MemoryImpersonationSessionRepository) FindByTargetID(_ context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]*models.ImpersonationSession, 0)
+	for _, session := range r.sessions {
+		if session.TargetID == targetID {
+			sessions = append(sessions, cloneImpersonationSession(session))
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartedAt.After(sessions[j].StartedAt) })
+	return page(sessions, p), nil
+}
+
+// End closes the session. It reports false when the session had already ended.
+func (r *MemoryImpersonationSessionRepository) End(_ context.Context, id uuid.UUID, endedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.EndedAt != nil {
+		return false, nil
+	}
+	session.EndedAt = timePtr(&endedAt)
+	return true, nil
+}
+
+func (r *MemoryImpersonationSessionRepository) EndExpired(_ context.Context, now time.Time) ([]*models.ImpersonationSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ended := make([]*models.ImpersonationSession, 0)
+	for _, session := range r.sessions {
+		if session.EndedAt == nil && !session.ExpiresAt.After(now) {
+			session.EndedAt = timePtr(&session.ExpiresAt)
+			ended = append(ended, cloneImpersonationSession(session))
+		}
+	}
+	return ended, nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type MemoryInvitationRepository struct {
+	mu          sync.RWMutex
+	invitations map[uuid.UUID]*models.Invitation
+	logger      Logger
+}
+
+func NewMemoryInvitationRepository(logger Logger) *MemoryInvitationRepository {
+	return &MemoryInvitationRepository{
+		invitations: make(map[uuid.UUID]*models.Invitation),
+		logger:      logger,
+	}
+}
+
+func cloneInvitation(invitation *models.Invitation) *models.Invitation {
+	clone := *invitation
+	clone.AcceptedAt = timePtr(invitation.AcceptedAt)
+	if invitation.OrganizationID != nil {
+		organizationID := *invitation.OrganizationID
+		clone.OrganizationID = &organizationID
+	}
+	return &clone
+}
+
+func (r *MemoryInvitationRepository) Create(_ context.Context, invitation *models.Invitation) (*models.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.invitations[invitation.ID]; exists {
+		r.logger.Error("Failed to create invitation: %s", errDuplicateKey)
+		return nil, errors.New("failed to create invitation")
+	}
+	if invitation.CreatedAt.IsZero() {
+		invitation.CreatedAt = time.Now()
+	}
+	r.invitations[invitation.ID] = cloneInvitation(invitation)
+	return invitation, nil
+}
+
+func (r *MemoryInvitationRepository) FindByID(_ context.Context, id uuid.UUID) (*models.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invitation, ok := r.invitations[id]
+	if !ok {
+		r.logger.Error("Failed to fetch invitation by ID: %s", errRecordNotFound)
+		return nil, errors.New("invitation not found")
+	}
+	return cloneInvitation(invitation), nil
+}
+
+// MarkAccepted consumes the invitation. It reports false when it had already been accepted.
+func (r *MemoryInvitationRepository) MarkAccepted(_ context.Context, id uuid.UUID, acceptedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitation, ok := r.invitations[id]
+	if !ok || invitation.AcceptedAt != nil {
+		return false, nil
+	}
+	invitation.AcceptedAt = timePtr(&acceptedAt)
+	return true, nil
+}
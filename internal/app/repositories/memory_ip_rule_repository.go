@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// MemoryIPRuleRepository keeps the rules in creation order.
+type MemoryIPRuleRepository struct {
+	mu     sync.RWMutex
+	rules  []*models.IPRule
+	logger Logger
+}
+
+func NewMemoryIPRuleRepository(logger Logger) *MemoryIPRuleRepository {
+	return &MemoryIPRuleRepository{logger: logger}
+}
+
+func cloneIPRule(rule *models.IPRule) *models.IPRule {
+	clone := *rule
+	if rule.OrganizationID != nil {
+		organizationID := *rule.OrganizationID
+		clone.OrganizationID = &organizationID
+	}
+	return &clone
+}
+
+func (r *MemoryIPRuleRepository) Create(_ context.Context, rule *models.IPRule) (*models.IPRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rule.ID == uuid.Nil {
+		rule.ID = uuid.New()
+	}
+	for _, stored := range r.rules {
+		if stored.ID == rule.ID {
+			r.logger.Error("Failed to create IP rule: %s", errDuplicateKey)
+			return nil, errors.New("failed to create IP rule")
+		}
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	r.rules = append(r.rules, cloneIPRule(rule))
+	return rule, nil
+}
+
+func (r *MemoryIPRuleRepository) FindByID(_ context.Context, id uuid.UUID) (*models.IPRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rule := range r.rules {
+		if rule.ID == id {
+			return cloneIPRule(rule), nil
+		}
+	}
+	r.logger.Error("Failed to fetch IP rule by ID: %s", errRecordNotFound)
+	return nil, errors.New("IP rule not found")
+}
+
+func (r *MemoryIPRuleRepository) FindAll(_ context.Context) ([]*models.IPRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rules := make([]*models.IPRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, cloneIPRule(rule))
+	}
+	return rules, nil
+}
+
+func (r *MemoryIPRuleRepository) Delete(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for index, rule := range r.rules {
+		if rule.ID == id {
+			r.rules = append(r.rules[:index], r.rules[index+1:]...)
+			break
+		}
+	}
+	return nil
+}
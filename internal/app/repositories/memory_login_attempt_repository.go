@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptRepository keeps the attempts in the order they were made.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.RWMutex
+	attempts []*models.LoginAttempt
+	logger   Logger
+}
+
+func NewMemoryLoginAttemptRepository(logger Logger) *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{logger: logger}
+}
+
+func cloneLoginAttempt(attempt *models.LoginAttempt) *models.LoginAttempt {
+	clone := *attempt
+	if attempt.UserID != nil {
+		userID := *attempt.UserID
+		clone.UserID = &userID
+	}
+	if attempt.Latitude != nil {
+		latitude := *attempt.Latitude
+		clone.Latitude = &latitude
+	}
+	if attempt.Longitude != nil {
+		longitude := *attempt.Longitude
+		clone.Longitude = &longitude
+	}
+	return &clone
+}
+
+func (r *MemoryLoginAttemptRepository) Create(_ context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt.ID == uuid.Nil {
+		attempt.ID = uuid.New()
+	}
+	for _, stored := range r.attempts {
+		if stored.ID == attempt.ID {
+			r.logger.Error("Failed to create login attempt: %s", errDuplicateKey)
+			return nil, errors.New("failed to create login attempt")
+		}
+	}
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now()
+	}
+	r.attempts = append(r.attempts, cloneLoginAttempt(attempt))
+	return attempt, nil
+}
+
+// newestFirst returns copies of the matching attempts, the most recent first. The caller holds the lock.
+func (r *MemoryLoginAttemptRepository) newestFirst(match func(attempt *models.LoginAttempt) bool) []*models.LoginAttempt {
+	attempts := make([]*models.LoginAttempt, 0)
+	for index := len(r.attempts) - 1; index >= 0; index-- {
+		if match(r.attempts[index]) {
+			attempts = append(attempts, cloneLoginAttempt(r.attempts[index]))
+		}
+	}
+	return attempts
+}
+
+func isSuccessfulLoginOf(userID uuid.UUID, attempt *models.LoginAttempt) bool {
+	return attempt.UserID != nil && *attempt.UserID == userID && attempt.Outcome == models.LoginOutcomeSuccess
+}
+
+func (r *MemoryLoginAttemptRepository) FindByUserID(_ context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	attempts := r.newestFirst(func(attempt *models.LoginAttempt) bool {
+		return attempt.UserID != nil && *attempt.UserID == userID
+	})
+	return page(attempts, p), nil
+}
+
+func (r *MemoryLoginAttemptRepository) HasSuccessfulLogin(ctx context.Context, userID uuid.UUID) (bool, error) {
+	attempt, err := r.FindLastSuccessful(ctx, userID)
+	return attempt != nil, err
+}
+
+func (r *MemoryLoginAttemptRepository) HasSuccessfulLoginFromDevice(_ context.Context, userID uuid.UUID, deviceFingerprint string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	attempts := r.newestFirst(func(attempt *models.LoginAttempt) bool {
+		return isSuccessfulLoginOf(userID, attempt) && attempt.DeviceFingerprint == deviceFingerprint
+	})
+	return len(attempts) > 0, nil
+}
+
+// FindLastSuccessful returns the most recent successful login of the user, or nil if there is none.
+func (r *MemoryLoginAttemptRepository) FindLastSuccessful(_ context.Context, userID uuid.UUID) (*models.LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	attempts := r.newestFirst(func(attempt *models.LoginAttempt) bool { return isSuccessfulLoginOf(userID, attempt) })
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+	return attempts[0], nil
+}
+
+func (r *MemoryLoginAttemptRepository) CountFailuresFromIP(_ context.Context, ip string, since time.Time) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var count int64
+	for _, attempt := range r.attempts {
+		if attempt.IP != ip || attempt.CreatedAt.Before(since) {
+			continue
+		}
+		for _, outcome := range models.FailedLoginOutcomes {
+			if attempt.Outcome == outcome {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
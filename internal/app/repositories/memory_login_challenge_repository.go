@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type MemoryLoginChallengeRepository struct {
+	mu         sync.RWMutex
+	challenges map[uuid.UUID]*models.LoginChallenge
+	logger     Logger
+}
+
+func NewMemoryLoginChallengeRepository(logger Logger) *MemoryLoginChallengeRepository {
+	return &MemoryLoginChallengeRepository{
+		challenges: make(map[uuid.UUID]*models.LoginChallenge),
+		logger:     logger,
+	}
+}
+
+func cloneLoginChallenge(challenge *models.LoginChallenge) *models.LoginChallenge {
+	clone := *challenge
+	clone.CompletedAt = timePtr(challenge.CompletedAt)
+	return &clone
+}
+
+func (r *MemoryLoginChallengeRepository) Create(_ context.Context, challenge *models.LoginChallenge) (*models.LoginChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.challenges[challenge.ID]; exists {
+		r.logger.Error("Failed to create login challenge: %s", fmt.Errorf("%w \"login_challenges_pkey\"", errDuplicateKey))
+		return nil, errors.New("failed to create login challenge")
+	}
+	if challenge.CreatedAt.IsZero() {
+		challenge.CreatedAt = time.Now()
+	}
+	r.challenges[challenge.ID] = cloneLoginChallenge(challenge)
+	return challenge, nil
+}
+
+func (r *MemoryLoginChallengeRepository) FindByID(_ context.Context, id uuid.UUID) (*models.LoginChallenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	challenge, ok := r.challenges[id]
+	if !ok {
+		r.logger.Error("Failed to fetch login challenge by ID: %s", errRecordNotFound)
+		return nil, errors.New("login challenge not found")
+	}
+	return cloneLoginChallenge(challenge), nil
+}
+
+func (r *MemoryLoginChallengeRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if challenge, ok := r.challenges[id]; ok {
+		challenge.FailedAttempts++
+	}
+	return nil
+}
+
+// MarkCompleted consumes the challenge. It reports false when the challenge had already been completed.
+func (r *MemoryLoginChallengeRepository) MarkCompleted(_ context.Context, id uuid.UUID, completedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge, ok := r.challenges[id]
+	if !ok || challenge.CompletedAt != nil {
+		return false, nil
+	}
+	challenge.CompletedAt = timePtr(&completedAt)
+	return true, nil
+}
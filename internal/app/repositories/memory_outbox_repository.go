@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryOutboxRepository struct {
+	mu       sync.RWMutex
+	messages map[uuid.UUID]*models.OutboxMessage
+	sequence int64
+	// relay is held by the relay publishing the outbox
+	relay  sync.Mutex
+	logger Logger
+}
+
+func NewMemoryOutboxRepository(logger Logger) *MemoryOutboxRepository {
+	return &MemoryOutboxRepository{
+		messages: make(map[uuid.UUID]*models.OutboxMessage),
+		logger:   logger,
+	}
+}
+
+func cloneOutboxMessage(message *models.OutboxMessage) *models.OutboxMessage {
+	clone := *message
+	clone.DeliveredAt = timePtr(message.DeliveredAt)
+	clone.DeadLetteredAt = timePtr(message.DeadLetteredAt)
+	return &clone
+}
+
+func (r *MemoryOutboxRepository) Add(_ context.Context, message *models.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if message.ID == uuid.Nil {
+		message.ID = uuid.New()
+	}
+	if _, exists := r.messages[message.ID]; exists {
+		r.logger.Error("Failed to add outbox message: %s", errDuplicateKey)
+		return errors.New("failed to add outbox message")
+	}
+	r.sequence++
+	message.Sequence = r.sequence
+	r.messages[message.ID] = cloneOutboxMessage(message)
+	return nil
+}
+
+func (r *MemoryOutboxRepository) FindPending(_ context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pending := make([]*models.OutboxMessage, 0)
+	for _, message := range r.messages {
+		if message.DeliveredAt == nil && message.DeadLetteredAt == nil {
+			pending = append(pending, message)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Sequence < pending[j].Sequence })
+	messages := make([]*models.OutboxMessage, 0)
+	held := make(map[uuid.UUID]bool)
+	for _, message := range pending {
+		// A message waiting for a retry holds back itself and the later messages of its aggregate
+		if held[message.AggregateID] || message.NextAttemptAt.After(now) {
+			held[message.AggregateID] = true
+			continue
+		}
+		if limit >= 0 && len(messages) >= limit {
+			break
+		}
+		messages = append(messages, cloneOutboxMessage(message))
+	}
+	return messages, nil
+}
+
+func (r *MemoryOutboxRepository) MarkDelivered(_ context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if message, ok := r.messages[id]; ok {
+		message.DeliveredAt = timePtr(&deliveredAt)
+	}
+	return nil
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(_ context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if message, ok := r.messages[id]; ok {
+		message.Attempts = attempts
+		message.LastError = lastError
+		message.NextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+func (r *MemoryOutboxRepository) MarkDeadLettered(_ context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if message, ok := r.messages[id]; ok {
+		message.Attempts = attempts
+		message.LastError = lastError
+		message.DeadLetteredAt = timePtr(&deadLetteredAt)
+	}
+	return nil
+}
+
+// RunExclusive runs fn unless another relay holds the outbox. The updates made through the repository passed
+// to fn are rolled back when fn fails, as the transaction of the Gorm repository would be.
+func (r *MemoryOutboxRepository) RunExclusive(_ context.Context, fn func(repo irepository.OutboxRepository) error) (bool, error) {
+	if !r.relay.TryLock() {
+		return false, nil
+	}
+	defer r.relay.Unlock()
+	journal := &memoryJournal{}
+	if err := fn(&journaledOutboxRepository{MemoryOutboxRepository: r, journal: journal}); err != nil {
+		journal.rollback()
+		r.logger.Error("Failed to relay outbox messages: %s", err)
+		return true, errors.New("failed to relay outbox messages")
+	}
+	return true, nil
+}
+
+// snapshot returns a copy of the stored message, or nil, for a journal to roll back to.
+func (r *MemoryOutboxRepository) snapshot(id uuid.UUID) *models.OutboxMessage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if message, ok := r.messages[id]; ok {
+		return cloneOutboxMessage(message)
+	}
+	return nil
+}
+
+// restore puts back a snapshot; a nil one removes the message.
+func (r *MemoryOutboxRepository) restore(id uuid.UUID, message *models.OutboxMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if message == nil {
+		delete(r.messages, id)
+		return
+	}
+	r.messages[id] = message
+}
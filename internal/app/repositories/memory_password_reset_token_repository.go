@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type MemoryPasswordResetTokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]*models.PasswordResetToken
+	logger Logger
+}
+
+func NewMemoryPasswordResetTokenRepository(logger Logger) *MemoryPasswordResetTokenRepository {
+	return &MemoryPasswordResetTokenRepository{
+		tokens: make(map[uuid.UUID]*models.PasswordResetToken),
+		logger: logger,
+	}
+}
+
+func clonePasswordResetToken(token *models.PasswordResetToken) *models.PasswordResetToken {
+	clone := *token
+	clone.UsedAt = timePtr(token.UsedAt)
+	return &clone
+}
+
+func (r *MemoryPasswordResetTokenRepository) Create(_ context.Context, token *models.PasswordResetToken) (*models.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tokens[token.ID]; exists {
+		r.logger.Error("Failed to create password reset token: %s", fmt.Errorf("%w \"password_reset_tokens_pkey\"", errDuplicateKey))
+		return nil, errors.New("failed to create password reset token")
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	r.tokens[token.ID] = clonePasswordResetToken(token)
+	return token, nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) FindByID(_ context.Context, id uuid.UUID) (*models.PasswordResetToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	token, ok := r.tokens[id]
+	if !ok {
+		r.logger.Error("Failed to fetch password reset token by ID: %s", errRecordNotFound)
+		return nil, errors.New("password reset token not found")
+	}
+	return clonePasswordResetToken(token), nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[id]; ok {
+		token.FailedAttempts++
+	}
+	return nil
+}
+
+// MarkUsed consumes the token. It reports false when the token had already been used.
+func (r *MemoryPasswordResetTokenRepository) MarkUsed(_ context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = timePtr(&usedAt)
+	return true, nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) InvalidateAllForUser(_ context.Context, userID uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = timePtr(&usedAt)
+		}
+	}
+	return nil
+}
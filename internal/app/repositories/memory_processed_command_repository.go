@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"sync"
+)
+
+type MemoryProcessedCommandRepository struct {
+	mu       sync.RWMutex
+	commands map[string]*models.ProcessedCommand
+	logger   Logger
+}
+
+func NewMemoryProcessedCommandRepository(logger Logger) *MemoryProcessedCommandRepository {
+	return &MemoryProcessedCommandRepository{
+		commands: make(map[string]*models.ProcessedCommand),
+		logger:   logger,
+	}
+}
+
+func (r *MemoryProcessedCommandRepository) FindByID(_ context.Context, id string) (*models.ProcessedCommand, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	command, ok := r.commands[id]
+	if !ok {
+		return nil, nil
+	}
+	clone := *command
+	return &clone, nil
+}
+
+func (r *MemoryProcessedCommandRepository) Create(_ context.Context, command *models.ProcessedCommand) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.commands[command.ID]; exists {
+		return false, nil
+	}
+	clone := *command
+	r.commands[command.ID] = &clone
+	return true, nil
+}
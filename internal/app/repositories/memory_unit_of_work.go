@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// MemoryUnitOfWork runs one transaction at a time. Writes go to the repositories right away and are undone
+// when fn fails, so a transaction is atomic, but it is not isolated: other callers see its writes before it
+// commits.
+type MemoryUnitOfWork struct {
+	mu     sync.Mutex
+	users  *MemoryUserRepository
+	outbox *MemoryOutboxRepository
+}
+
+func NewMemoryUnitOfWork(users *MemoryUserRepository, outbox *MemoryOutboxRepository) *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{
+		users:  users,
+		outbox: outbox,
+	}
+}
+
+func (u *MemoryUnitOfWork) Do(_ context.Context, fn func(repos irepository.Repositories) error) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	journal := &memoryJournal{}
+	err := fn(irepository.Repositories{
+		Users:  &journaledUserRepository{MemoryUserRepository: u.users, journal: journal},
+		Outbox: &journaledOutboxRepository{MemoryOutboxRepository: u.outbox, journal: journal},
+	})
+	if err != nil {
+		journal.rollback()
+	}
+	return err
+}
+
+// memoryJournal records how to undo the writes of a transaction.
+type memoryJournal struct {
+	undo []func()
+}
+
+func (j *memoryJournal) record(undo func()) {
+	j.undo = append(j.undo, undo)
+}
+
+// rollback undoes the writes, the last one first.
+func (j *memoryJournal) rollback() {
+	for index := len(j.undo) - 1; index >= 0; index-- {
+		j.undo[index]()
+	}
+	j.undo = nil
+}
+
+// journaledUserRepository records the previous state of every user it writes.
+type journaledUserRepository struct {
+	*MemoryUserRepository
+	journal *memoryJournal
+}
+
+func (r *journaledUserRepository) save(id uuid.UUID) {
+	previous := r.snapshot(id)
+	r.journal.record(func() { r.restore(id, previous) })
+}
+
+func (r *journaledUserRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	created, err := r.MemoryUserRepository.Create(ctx, user)
+	if err == nil {
+		id := created.ID
+		r.journal.record(func() { r.restore(id, nil) })
+	}
+	return created, err
+}
+
+func (r *journaledUserRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	r.save(user.ID)
+	return r.MemoryUserRepository.Update(ctx, user)
+}
+
+func (r *journaledUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.save(id)
+	return r.MemoryUserRepository.Delete(ctx, id)
+}
+
+func (r *journaledUserRepository) IncrementFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	r.save(id)
+	return r.MemoryUserRepository.IncrementFailedAttempts(ctx, id, attemptAt)
+}
+
+func (r *journaledUserRepository) ResetFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) error {
+	r.save(id)
+	return r.MemoryUserRepository.ResetFailedAttempts(ctx, id, attemptAt)
+}
+
+func (r *journaledUserRepository) BlockUntil(ctx context.Context, id uuid.UUID, blockedUntil time.Time) (bool, error) {
+	r.save(id)
+	return r.MemoryUserRepository.BlockUntil(ctx, id, blockedUntil)
+}
+
+func (r *journaledUserRepository) UnblockIfExpired(ctx context.Context, id uuid.UUID, now time.Time) error {
+	r.save(id)
+	return r.MemoryUserRepository.UnblockIfExpired(ctx, id, now)
+}
+
+// journaledOutboxRepository records the previous state of every message it writes.
+type journaledOutboxRepository struct {
+	*MemoryOutboxRepository
+	journal *memoryJournal
+}
+
+func (r *journaledOutboxRepository) save(id uuid.UUID) {
+	previous := r.snapshot(id)
+	r.journal.record(func() { r.restore(id, previous) })
+}
+
+func (r *journaledOutboxRepository) Add(ctx context.Context, message *models.OutboxMessage) error {
+	err := r.MemoryOutboxRepository.Add(ctx, message)
+	if err == nil {
+		id := message.ID
+		r.journal.record(func() { r.restore(id, nil) })
+	}
+	return err
+}
+
+func (r *journaledOutboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	r.save(id)
+	return r.MemoryOutboxRepository.MarkDelivered(ctx, id, deliveredAt)
+}
+
+func (r *journaledOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error {
+	r.save(id)
+	return r.MemoryOutboxRepository.MarkFailed(ctx, id, attempts, lastError, nextAttemptAt)
+}
+
+func (r *journaledOutboxRepository) MarkDeadLettered(ctx context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error {
+	r.save(id)
+	return r.MemoryOutboxRepository.MarkDeadLettered(ctx, id, attempts, lastError, deadLetteredAt)
+}
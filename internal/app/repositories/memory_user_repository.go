@@ -0,0 +1,265 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[uuid.UUID]*models.User
+	logger Logger
+}
+
+func NewMemoryUserRepository(logger Logger) *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:  make(map[uuid.UUID]*models.User),
+		logger: logger,
+	}
+}
+
+func cloneUser(user *models.User) *models.User {
+	clone := *user
+	clone.LastAttempt = timePtr(user.LastAttempt)
+	clone.BlockedUntil = timePtr(user.BlockedUntil)
+	clone.EmailChangeExpires = timePtr(user.EmailChangeExpires)
+	clone.EmailRevertExpires = timePtr(user.EmailRevertExpires)
+	if user.OrganizationID != nil {
+		organizationID := *user.OrganizationID
+		clone.OrganizationID = &organizationID
+	}
+	return &clone
+}
+
+// findActive returns the stored active user matching the predicate. The caller holds the lock.
+func (r *MemoryUserRepository) findActive(match func(user *models.User) bool) *models.User {
+	for _, user := range r.users {
+		if user.IsActive && match(user) {
+			return user
+		}
+	}
+	return nil
+}
+
+// checkEmailActive enforces idx_email_active: one user per email and activity, so an email has at most one
+// active user and at most one deleted one. The caller holds the lock.
+func (r *MemoryUserRepository) checkEmailActive(id uuid.UUID, email string, isActive bool) error {
+	for _, user := range r.users {
+		if user.ID != id && user.Email == email && user.IsActive == isActive {
+			return fmt.Errorf("%w \"idx_email_active\"", errDuplicateKey)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) FindByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok || !user.IsActive {
+		r.logger.Error("Failed to fetch user by ID: %s", errRecordNotFound)
+		return nil, irepository.ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (r *MemoryUserRepository) FindByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user := r.findActive(func(user *models.User) bool { return user.Email == email })
+	if user == nil {
+		r.logger.Error("Failed to fetch user by email: %s", errRecordNotFound)
+		return nil, errors.New("user not found")
+	}
+	return cloneUser(user), nil
+}
+
+// Create stores the user with the defaults of the users table. Like the database, it fills them in on the
+// user passed: false booleans defaulting to true become true.
+func (r *MemoryUserRepository) Create(_ context.Context, user *models.User) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	user.FirstAccess = true
+	user.IsActive = true
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+
+	err := r.checkEmailActive(user.ID, user.Email, user.IsActive)
+	if _, exists := r.users[user.ID]; exists {
+		err = fmt.Errorf("%w \"users_pkey\"", errDuplicateKey)
+	}
+	if err != nil {
+		r.logger.Error("Failed to create user: %s", err)
+		return nil, errors.New("failed to create user")
+	}
+	r.users[user.ID] = cloneUser(user)
+	return user, nil
+}
+
+// Update saves every column but those of the login state, which only the atomic operations below change.
+// Like an UPDATE matching no row, updating a user that does not exist succeeds without storing it.
+func (r *MemoryUserRepository) Update(_ context.Context, user *models.User) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return user, nil
+	}
+	if err := r.checkEmailActive(user.ID, user.Email, user.IsActive); err != nil {
+		r.logger.Error("Failed to update user: %s", err)
+		return nil, errors.New("failed to update user")
+	}
+	user.UpdatedAt = time.Now()
+	updated := cloneUser(user)
+	updated.FailedAttempts = stored.FailedAttempts
+	updated.LastAttempt = stored.LastAttempt
+	updated.IsBlocked = stored.IsBlocked
+	updated.BlockedUntil = stored.BlockedUntil
+	r.users[user.ID] = updated
+	return user, nil
+}
+
+// Delete soft deletes the user. It fails when the email already belongs to a deleted user, as the index
+// allows a single one.
+func (r *MemoryUserRepository) Delete(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	if err := r.checkEmailActive(id, user.Email, false); err != nil {
+		r.logger.Error("Failed to soft delete user: %s", err)
+		return errors.New("failed to soft delete user")
+	}
+	user.IsActive = false
+	return nil
+}
+
+// FindAll returns the active users in creation order.
+func (r *MemoryUserRepository) FindAll(_ context.Context, p utils.Pagination) ([]*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		if user.IsActive {
+			users = append(users, cloneUser(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID.String() < users[j].ID.String()
+	})
+	return page(users, p), nil
+}
+
+func (r *MemoryUserRepository) FindByEmailChangeToken(_ context.Context, token string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user := r.findActive(func(user *models.User) bool { return user.EmailChangeToken == token })
+	if user == nil {
+		r.logger.Error("Failed to fetch user by email change token: %s", errRecordNotFound)
+		return nil, errors.New("user not found")
+	}
+	return cloneUser(user), nil
+}
+
+func (r *MemoryUserRepository) FindByEmailRevertToken(_ context.Context, token string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user := r.findActive(func(user *models.User) bool { return user.EmailRevertToken == token })
+	if user == nil {
+		r.logger.Error("Failed to fetch user by email revert token: %s", errRecordNotFound)
+		return nil, errors.New("user not found")
+	}
+	return cloneUser(user), nil
+}
+
+func (r *MemoryUserRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || !user.IsActive {
+		return 0, errors.New("user not found")
+	}
+	user.FailedAttempts++
+	user.LastAttempt = timePtr(&attemptAt)
+	return user.FailedAttempts, nil
+}
+
+func (r *MemoryUserRepository) ResetFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		user.FailedAttempts = 0
+		user.LastAttempt = timePtr(&attemptAt)
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) BlockUntil(_ context.Context, id uuid.UUID, blockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || (user.IsBlocked && user.BlockedUntil != nil && !user.BlockedUntil.Before(blockedUntil)) {
+		return false, nil
+	}
+	user.IsBlocked = true
+	user.BlockedUntil = timePtr(&blockedUntil)
+	return true, nil
+}
+
+func (r *MemoryUserRepository) UnblockIfExpired(_ context.Context, id uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || !user.IsBlocked || (user.BlockedUntil != nil && user.BlockedUntil.After(now)) {
+		return nil
+	}
+	user.IsBlocked = false
+	user.FailedAttempts = 0
+	user.BlockedUntil = nil
+	return nil
+}
+
+// snapshot returns a copy of the stored user, or nil, for MemoryUnitOfWork to roll back to.
+func (r *MemoryUserRepository) snapshot(id uuid.UUID) *models.User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if user, ok := r.users[id]; ok {
+		return cloneUser(user)
+	}
+	return nil
+}
+
+// restore puts back a snapshot; a nil one removes the user.
+func (r *MemoryUserRepository) restore(id uuid.UUID, user *models.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user == nil {
+		delete(r.users, id)
+		return
+	}
+	r.users[id] = user
+}
@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestMemoryUsers(t *testing.T) *MemoryUserRepository {
+	t.Helper()
+	return NewMemoryUserRepository(service_mock.NewPermissiveMockLogger())
+}
+
+func createTestUser(t *testing.T, repo irepository.UserRepository, email string) *models.User {
+	t.Helper()
+	user, err := repo.Create(context.Background(), &models.User{Email: email, Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestMemoryUserRepository_Create_FillsInDatabaseDefaults(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+
+	// Act
+	user, err := repo.Create(context.Background(), &models.User{Email: "someone@example.com", Password: "hash"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, user.ID)
+	assert.True(t, user.IsActive)
+	assert.True(t, user.FirstAccess)
+	assert.Equal(t, models.RoleUser, user.Role)
+	assert.False(t, user.CreatedAt.IsZero())
+	stored, err := repo.FindByEmail(context.Background(), "someone@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, stored.ID)
+}
+
+func TestMemoryUserRepository_Create_RejectsSecondActiveUserWithEmail(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	createTestUser(t, repo, "someone@example.com")
+
+	// Act
+	_, err := repo.Create(context.Background(), &models.User{Email: "someone@example.com", Password: "hash"})
+
+	// Assert
+	assert.EqualError(t, err, "failed to create user")
+	users, _ := repo.FindAll(context.Background(), utils.DefaultPagination())
+	assert.Len(t, users, 1)
+}
+
+func TestMemoryUserRepository_Create_ReusesEmailOfDeletedUser(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	deleted := createTestUser(t, repo, "someone@example.com")
+	assert.NoError(t, repo.Delete(context.Background(), deleted.ID))
+
+	// Act
+	user, err := repo.Create(context.Background(), &models.User{Email: "someone@example.com", Password: "hash"})
+
+	// Assert
+	assert.NoError(t, err)
+	found, err := repo.FindByEmail(context.Background(), "someone@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	_, err = repo.FindByID(context.Background(), deleted.ID)
+	assert.EqualError(t, err, "user not found")
+}
+
+func TestMemoryUserRepository_Delete_RejectsSecondDeletedUserWithEmail(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	first := createTestUser(t, repo, "someone@example.com")
+	assert.NoError(t, repo.Delete(context.Background(), first.ID))
+	second := createTestUser(t, repo, "someone@example.com")
+
+	// Act
+	err := repo.Delete(context.Background(), second.ID)
+
+	// Assert
+	assert.EqualError(t, err, "failed to soft delete user")
+	_, err = repo.FindByID(context.Background(), second.ID)
+	assert.NoError(t, err)
+}
+
+func TestMemoryUserRepository_Update_RejectsEmailOfAnotherActiveUser(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	createTestUser(t, repo, "taken@example.com")
+	user := createTestUser(t, repo, "someone@example.com")
+	user.Email = "taken@example.com"
+
+	// Act
+	_, err := repo.Update(context.Background(), user)
+
+	// Assert
+	assert.EqualError(t, err, "failed to update user")
+	stored, _ := repo.FindByID(context.Background(), user.ID)
+	assert.Equal(t, "someone@example.com", stored.Email)
+}
+
+func TestMemoryUserRepository_Update_KeepsLoginState(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	user := createTestUser(t, repo, "someone@example.com")
+	now := time.Now()
+	_, _ = repo.IncrementFailedAttempts(context.Background(), user.ID, now)
+	blocked, _ := repo.BlockUntil(context.Background(), user.ID, now.Add(time.Hour))
+	assert.True(t, blocked)
+	user.IsLocked = true
+
+	// Act
+	_, err := repo.Update(context.Background(), user)
+
+	// Assert
+	assert.NoError(t, err)
+	stored, _ := repo.FindByID(context.Background(), user.ID)
+	assert.True(t, stored.IsLocked)
+	assert.Equal(t, 1, stored.FailedAttempts)
+	assert.True(t, stored.IsBlocked)
+}
+
+func TestMemoryUserRepository_FindByID_ReturnsCopy(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	user := createTestUser(t, repo, "someone@example.com")
+
+	// Act
+	found, _ := repo.FindByID(context.Background(), user.ID)
+	found.Email = "changed@example.com"
+	user.Email = "changed@example.com"
+
+	// Assert
+	stored, _ := repo.FindByID(context.Background(), user.ID)
+	assert.Equal(t, "someone@example.com", stored.Email)
+}
+
+func TestMemoryUserRepository_BlockUntil_KeepsLongerBlock(t *testing.T) {
+	// Arrange
+	repo := newTestMemoryUsers(t)
+	user := createTestUser(t, repo, "someone@example.com")
+	now := time.Now()
+	first, _ := repo.BlockUntil(context.Background(), user.ID, now.Add(time.Hour))
+
+	// Act
+	shorter, err := repo.BlockUntil(context.Background(), user.ID, now.Add(time.Minute))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, first)
+	assert.False(t, shorter)
+	assert.NoError(t, repo.UnblockIfExpired(context.Background(), user.ID, now.Add(30*time.Minute)))
+	stored, _ := repo.FindByID(context.Background(), user.ID)
+	assert.True(t, stored.IsBlocked)
+	assert.NoError(t, repo.UnblockIfExpired(context.Background(), user.ID, now.Add(time.Hour)))
+	stored, _ = repo.FindByID(context.Background(), user.ID)
+	assert.False(t, stored.IsBlocked)
+	assert.Nil(t, stored.BlockedUntil)
+}
+
+func TestMemoryUnitOfWork_Do_RollsBackWritesOfFailedTransaction(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore(service_mock.NewPermissiveMockLogger())
+	existing := createTestUser(t, store.Users, "existing@example.com")
+	failure := errors.New("publishing failed")
+
+	// Act
+	err := store.UnitOfWork.Do(context.Background(), func(repos irepository.Repositories) error {
+		createTestUser(t, repos.Users, "created@example.com")
+		existing.IsLocked = true
+		if _, err := repos.Users.Update(context.Background(), existing); err != nil {
+			return err
+		}
+		if err := repos.Outbox.Add(context.Background(), &models.OutboxMessage{Topic: "account-created"}); err != nil {
+			return err
+		}
+		return failure
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, failure)
+	_, err = store.Users.FindByEmail(context.Background(), "created@example.com")
+	assert.Error(t, err)
+	stored, _ := store.Users.FindByID(context.Background(), existing.ID)
+	assert.False(t, stored.IsLocked)
+	pending, _ := store.Outbox.FindPending(context.Background(), time.Now(), 10)
+	assert.Empty(t, pending)
+}
+
+func TestMemoryUnitOfWork_Do_CommitsWritesOfTransaction(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore(service_mock.NewPermissiveMockLogger())
+
+	// Act
+	err := store.UnitOfWork.Do(context.Background(), func(repos irepository.Repositories) error {
+		createTestUser(t, repos.Users, "created@example.com")
+		return repos.Outbox.Add(context.Background(), &models.OutboxMessage{Topic: "account-created"})
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	_, err = store.Users.FindByEmail(context.Background(), "created@example.com")
+	assert.NoError(t, err)
+	pending, _ := store.Outbox.FindPending(context.Background(), time.Now(), 10)
+	assert.Len(t, pending, 1)
+}
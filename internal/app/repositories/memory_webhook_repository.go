@@ -0,0 +1,183 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryWebhookRepository struct {
+	mu            sync.RWMutex
+	subscriptions map[uuid.UUID]*models.WebhookSubscription
+	deliveries    map[uuid.UUID]*models.WebhookDelivery
+	logger        Logger
+}
+
+func NewMemoryWebhookRepository(logger Logger) *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		subscriptions: make(map[uuid.UUID]*models.WebhookSubscription),
+		deliveries:    make(map[uuid.UUID]*models.WebhookDelivery),
+		logger:        logger,
+	}
+}
+
+func cloneWebhookDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	clone := *delivery
+	clone.DeliveredAt = timePtr(delivery.DeliveredAt)
+	if delivery.RedeliveryOf != nil {
+		redeliveryOf := *delivery.RedeliveryOf
+		clone.RedeliveryOf = &redeliveryOf
+	}
+	return &clone
+}
+
+func (r *MemoryWebhookRepository) CreateSubscription(_ context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if subscription.ID == uuid.Nil {
+		subscription.ID = uuid.New()
+	}
+	if _, exists := r.subscriptions[subscription.ID]; exists {
+		r.logger.Error("Failed to create webhook subscription: %s", errDuplicateKey)
+		return nil, errors.New("failed to create webhook subscription")
+	}
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now()
+	}
+	clone := *subscription
+	r.subscriptions[subscription.ID] = &clone
+	return subscription, nil
+}
+
+func (r *MemoryWebhookRepository) FindSubscription(_ context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		r.logger.Error("Failed to fetch webhook subscription by ID: %s", errRecordNotFound)
+		return nil, errors.New("webhook subscription not found")
+	}
+	clone := *subscription
+	return &clone, nil
+}
+
+func (r *MemoryWebhookRepository) FindSubscriptionsByOrganization(_ context.Context, organizationID uuid.UUID) ([]*models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscriptions := make([]*models.WebhookSubscription, 0)
+	for _, subscription := range r.subscriptions {
+		if subscription.OrganizationID == organizationID {
+			clone := *subscription
+			subscriptions = append(subscriptions, &clone)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes the subscription together with its delivery log.
+func (r *MemoryWebhookRepository) DeleteSubscription(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.SubscriptionID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	delete(r.subscriptions, id)
+	return nil
+}
+
+// CreateDelivery reports false when the event was already queued for the subscription, as the partial unique
+// index of the table does. Redeliveries are always created.
+func (r *MemoryWebhookRepository) CreateDelivery(_ context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	if _, exists := r.deliveries[delivery.ID]; exists {
+		return false, nil
+	}
+	if delivery.RedeliveryOf == nil {
+		for _, stored := range r.deliveries {
+			if stored.RedeliveryOf == nil && stored.SubscriptionID == delivery.SubscriptionID &&
+				stored.EventID == delivery.EventID {
+				return false, nil
+			}
+		}
+	}
+	r.deliveries[delivery.ID] = cloneWebhookDelivery(delivery)
+	return true, nil
+}
+
+func (r *MemoryWebhookRepository) FindDelivery(_ context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		r.logger.Error("Failed to fetch webhook delivery by ID: %s", errRecordNotFound)
+		return nil, errors.New("webhook delivery not found")
+	}
+	return cloneWebhookDelivery(delivery), nil
+}
+
+// FindDeliveries returns the delivery log of a subscription, newest first.
+func (r *MemoryWebhookRepository) FindDeliveries(_ context.Context, subscriptionID uuid.UUID, p utils.Pagination) ([]*models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, cloneWebhookDelivery(delivery))
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	return page(deliveries, p), nil
+}
+
+// ClaimDue returns up to limit pending deliveries due at now, the longest due first, and postpones them until
+// leaseUntil.
+func (r *MemoryWebhookRepository) ClaimDue(_ context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := make([]*models.WebhookDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if limit >= 0 && limit < len(due) {
+		due = due[:limit]
+	}
+	claimed := make([]*models.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = leaseUntil
+		claimed = append(claimed, cloneWebhookDelivery(delivery))
+	}
+	return claimed, nil
+}
+
+func (r *MemoryWebhookRepository) UpdateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastStatusCode = delivery.LastStatusCode
+	stored.LastError = delivery.LastError
+	stored.DeliveredAt = timePtr(delivery.DeliveredAt)
+	return nil
+}
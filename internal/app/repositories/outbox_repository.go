@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// outboxRelayLockID is the Postgres advisory lock held by the relay that is publishing the outbox.
+const outboxRelayLockID = 0x6f757462
+
+type GormOutboxRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormOutboxRepository(db *gorm.DB, logger Logger) irepository.OutboxRepository {
+	return &GormOutboxRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormOutboxRepository) Add(ctx context.Context, message *models.OutboxMessage) error {
+	err := r.DB.WithContext(ctx).Create(message).Error
+	if err != nil {
+		r.logger.Error("Failed to add outbox message: %s", err)
+		return errors.New("failed to add outbox message")
+	}
+	return nil
+}
+
+func (r *GormOutboxRepository) FindPending(ctx context.Context, now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	var messages []*models.OutboxMessage
+	err := r.DB.WithContext(ctx).Where("delivered_at IS NULL AND dead_lettered_at IS NULL").
+		// A message waiting for a retry holds back itself and the later messages of its aggregate
+		Where(`NOT EXISTS (SELECT 1 FROM outbox_messages waiting WHERE waiting.aggregate_id = outbox_messages.aggregate_id
+			AND waiting.delivered_at IS NULL AND waiting.dead_lettered_at IS NULL
+			AND waiting.sequence <= outbox_messages.sequence AND waiting.next_attempt_at > ?)`, now).
+		Order("sequence").Limit(limit).Find(&messages).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch pending outbox messages: %s", err)
+		return nil, errors.New("failed to fetch outbox messages")
+	}
+	return messages, nil
+}
+
+func (r *GormOutboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).Update("delivered_at", deliveredAt).Error
+	if err != nil {
+		r.logger.Error("Failed to mark outbox message %s delivered: %s", id, err)
+		return errors.New("failed to update outbox message")
+	}
+	return nil
+}
+
+func (r *GormOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+		}).Error
+	if err != nil {
+		r.logger.Error("Failed to mark outbox message %s failed: %s", id, err)
+		return errors.New("failed to update outbox message")
+	}
+	return nil
+}
+
+func (r *GormOutboxRepository) MarkDeadLettered(ctx context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":         attempts,
+			"last_error":       lastError,
+			"dead_lettered_at": deadLetteredAt,
+		}).Error
+	if err != nil {
+		r.logger.Error("Failed to mark outbox message %s dead-lettered: %s", id, err)
+		return errors.New("failed to update outbox message")
+	}
+	return nil
+}
+
+// RunExclusive holds the relay lock for the duration of a transaction. Updates made through the repository
+// passed to fn commit together when fn returns, so a crash before that publishes the messages again.
+func (r *GormOutboxRepository) RunExclusive(ctx context.Context, fn func(repo irepository.OutboxRepository) error) (bool, error) {
+	acquired := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxRelayLockID).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		return fn(NewGormOutboxRepository(tx, r.logger))
+	})
+	if err != nil {
+		r.logger.Error("Failed to relay outbox messages: %s", err)
+		return acquired, errors.New("failed to relay outbox messages")
+	}
+	return acquired, nil
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGormOutboxRepository_FindPending_LeavesOutAggregatesWaitingForRetry(t *testing.T) {
+	// Arrange
+	const limit = 3
+	db := newTestPostgres(t)
+	repo := NewGormOutboxRepository(db, service_mock.NewPermissiveMockLogger())
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	stuck, other := uuid.New(), uuid.New()
+	add := func(aggregateID uuid.UUID, nextAttemptAt time.Time) *models.OutboxMessage {
+		message := &models.OutboxMessage{ID: uuid.New(), AggregateType: models.AggregateUser, AggregateID: aggregateID,
+			Topic: "account-blocked", Payload: "{}", NextAttemptAt: nextAttemptAt, CreatedAt: now}
+		if err := repo.Add(context.Background(), message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	}
+	// The first message of the stuck aggregate waits for a retry, the later ones fill more than a batch
+	add(stuck, now.Add(time.Minute))
+	for i := 0; i < limit; i++ {
+		add(stuck, now)
+	}
+	due := add(other, now)
+	t.Cleanup(func() { db.Where("aggregate_id IN ?", []uuid.UUID{stuck, other}).Delete(&models.OutboxMessage{}) })
+
+	// Act
+	pending, err := repo.FindPending(context.Background(), now, limit)
+
+	// Assert
+	assert.NoError(t, err)
+	var found bool
+	for _, message := range pending {
+		assert.NotEqual(t, stuck, message.AggregateID)
+		found = found || message.ID == due.ID
+	}
+	assert.True(t, found, "the due message of the other aggregate was not returned")
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type GormPasswordResetTokenRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormPasswordResetTokenRepository(db *gorm.DB, logger Logger) irepository.PasswordResetTokenRepository {
+	return &GormPasswordResetTokenRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormPasswordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) (*models.PasswordResetToken, error) {
+	err := r.DB.WithContext(ctx).Create(token).Error
+	if err != nil {
+		r.logger.Error("Failed to create password reset token: %s", err)
+		return nil, errors.New("failed to create password reset token")
+	}
+	return token, nil
+}
+
+func (r *GormPasswordResetTokenRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.DB.WithContext(ctx).First(&token, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch password reset token by ID: %s", err)
+		return nil, errors.New("password reset token not found")
+	}
+	return &token, nil
+}
+
+func (r *GormPasswordResetTokenRepository) IncrementFailedAttempts(ctx context.Context, id uuid.UUID) error {
+	err := r.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).Where("id = ?", id).
+		UpdateColumn("failed_attempts", gorm.Expr("failed_attempts + 1")).Error
+	if err != nil {
+		r.logger.Error("Failed to increment password reset token attempts: %s", err)
+		return errors.New("failed to update password reset token")
+	}
+	return nil
+}
+
+// MarkUsed consumes the token. It reports false when the token had already been used,
+// so two concurrent confirmations cannot both succeed.
+func (r *GormPasswordResetTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", id).
+		UpdateColumn("used_at", usedAt)
+	if result.Error != nil {
+		r.logger.Error("Failed to mark password reset token as used: %s", result.Error)
+		return false, errors.New("failed to update password reset token")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *GormPasswordResetTokenRepository) InvalidateAllForUser(ctx context.Context, userID uuid.UUID, usedAt time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", userID).
+		UpdateColumn("used_at", usedAt).Error
+	if err != nil {
+		r.logger.Error("Failed to invalidate password reset tokens: %s", err)
+		return errors.New("failed to invalidate password reset tokens")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GormProcessedCommandRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormProcessedCommandRepository(db *gorm.DB, logger Logger) irepository.ProcessedCommandRepository {
+	return &GormProcessedCommandRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormProcessedCommandRepository) FindByID(ctx context.Context, id string) (*models.ProcessedCommand, error) {
+	var command models.ProcessedCommand
+	err := r.DB.WithContext(ctx).First(&command, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.Error("Failed to fetch processed command: %s", err)
+		return nil, errors.New("failed to fetch processed command")
+	}
+	return &command, nil
+}
+
+func (r *GormProcessedCommandRepository) Create(ctx context.Context, command *models.ProcessedCommand) (bool, error) {
+	result := r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(command)
+	if result.Error != nil {
+		r.logger.Error("Failed to record processed command: %s", result.Error)
+		return false, errors.New("failed to record processed command")
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,37 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRecordRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRecordRepository) Append(_ context.Context, record *models.AuditRecord) (*models.AuditRecord, error) {
+	args := m.Called(record)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditRecord), args.Error(1)
+}
+
+func (m *MockAuditRecordRepository) Find(_ context.Context, filter irepository.AuditFilter, p utils.Pagination) ([]*models.AuditRecord, error) {
+	args := m.Called(filter, p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AuditRecord), args.Error(1)
+}
+
+func (m *MockAuditRecordRepository) FindAfter(_ context.Context, filter irepository.AuditFilter, afterSequence int64, limit int) ([]*models.AuditRecord, error) {
+	args := m.Called(filter, afterSequence, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AuditRecord), args.Error(1)
+}
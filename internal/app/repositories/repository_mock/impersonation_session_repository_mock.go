@@ -0,0 +1,51 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockImpersonationSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockImpersonationSessionRepository) Create(_ context.Context, session *models.ImpersonationSession) (*models.ImpersonationSession, error) {
+	args := m.Called(session)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImpersonationSession), args.Error(1)
+}
+
+func (m *MockImpersonationSessionRepository) FindByID(_ context.Context, id uuid.UUID) (*models.ImpersonationSession, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImpersonationSession), args.Error(1)
+}
+
+func (m *MockImpersonationSessionRepository) FindByTargetID(_ context.Context, targetID uuid.UUID, p utils.Pagination) ([]*models.ImpersonationSession, error) {
+	args := m.Called(targetID, p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ImpersonationSession), args.Error(1)
+}
+
+func (m *MockImpersonationSessionRepository) End(_ context.Context, id uuid.UUID, endedAt time.Time) (bool, error) {
+	args := m.Called(id, endedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockImpersonationSessionRepository) EndExpired(_ context.Context, now time.Time) ([]*models.ImpersonationSession, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ImpersonationSession), args.Error(1)
+}
@@ -0,0 +1,34 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockInvitationRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvitationRepository) Create(_ context.Context, invitation *models.Invitation) (*models.Invitation, error) {
+	args := m.Called(invitation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) FindByID(_ context.Context, id uuid.UUID) (*models.Invitation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Invitation), args.Error(1)
+}
+
+func (m *MockInvitationRepository) MarkAccepted(_ context.Context, id uuid.UUID, acceptedAt time.Time) (bool, error) {
+	args := m.Called(id, acceptedAt)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,41 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIPRuleRepository struct {
+	mock.Mock
+}
+
+func (m *MockIPRuleRepository) Create(_ context.Context, rule *models.IPRule) (*models.IPRule, error) {
+	args := m.Called(rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.IPRule), args.Error(1)
+}
+
+func (m *MockIPRuleRepository) FindByID(_ context.Context, id uuid.UUID) (*models.IPRule, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.IPRule), args.Error(1)
+}
+
+func (m *MockIPRuleRepository) FindAll(_ context.Context) ([]*models.IPRule, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.IPRule), args.Error(1)
+}
+
+func (m *MockIPRuleRepository) Delete(_ context.Context, id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
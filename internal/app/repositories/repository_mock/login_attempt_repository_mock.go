@@ -0,0 +1,53 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) Create(_ context.Context, attempt *models.LoginAttempt) (*models.LoginAttempt, error) {
+	args := m.Called(attempt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) FindByUserID(_ context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error) {
+	args := m.Called(userID, p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) HasSuccessfulLogin(_ context.Context, userID uuid.UUID) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) HasSuccessfulLoginFromDevice(_ context.Context, userID uuid.UUID, deviceFingerprint string) (bool, error) {
+	args := m.Called(userID, deviceFingerprint)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) FindLastSuccessful(_ context.Context, userID uuid.UUID) (*models.LoginAttempt, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) CountFailuresFromIP(_ context.Context, ip string, since time.Time) (int64, error) {
+	args := m.Called(ip, since)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,39 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockLoginChallengeRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginChallengeRepository) Create(_ context.Context, challenge *models.LoginChallenge) (*models.LoginChallenge, error) {
+	args := m.Called(challenge)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginChallenge), args.Error(1)
+}
+
+func (m *MockLoginChallengeRepository) FindByID(_ context.Context, id uuid.UUID) (*models.LoginChallenge, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginChallenge), args.Error(1)
+}
+
+func (m *MockLoginChallengeRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockLoginChallengeRepository) MarkCompleted(_ context.Context, id uuid.UUID, completedAt time.Time) (bool, error) {
+	args := m.Called(id, completedAt)
+	return args.Bool(0), args.Error(1)
+}
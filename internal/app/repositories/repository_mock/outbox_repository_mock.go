@@ -0,0 +1,47 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) Add(_ context.Context, message *models.OutboxMessage) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) FindPending(_ context.Context, _ time.Time, limit int) ([]*models.OutboxMessage, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkDelivered(_ context.Context, id uuid.UUID, deliveredAt time.Time) error {
+	args := m.Called(id, deliveredAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkFailed(_ context.Context, id uuid.UUID, attempts int, lastError string, nextAttemptAt time.Time) error {
+	args := m.Called(id, attempts, lastError, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkDeadLettered(_ context.Context, id uuid.UUID, attempts int, lastError string, deadLetteredAt time.Time) error {
+	args := m.Called(id, attempts, lastError, deadLetteredAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) RunExclusive(_ context.Context, fn func(repo irepository.OutboxRepository) error) (bool, error) {
+	args := m.Called(fn)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,44 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockPasswordResetTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetTokenRepository) Create(_ context.Context, token *models.PasswordResetToken) (*models.PasswordResetToken, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) FindByID(_ context.Context, id uuid.UUID) (*models.PasswordResetToken, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetTokenRepository) MarkUsed(_ context.Context, id uuid.UUID, usedAt time.Time) (bool, error) {
+	args := m.Called(id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPasswordResetTokenRepository) InvalidateAllForUser(_ context.Context, userID uuid.UUID, usedAt time.Time) error {
+	args := m.Called(userID, usedAt)
+	return args.Error(0)
+}
@@ -0,0 +1,24 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockProcessedCommandRepository struct {
+	mock.Mock
+}
+
+func (m *MockProcessedCommandRepository) FindByID(_ context.Context, id string) (*models.ProcessedCommand, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ProcessedCommand), args.Error(1)
+}
+
+func (m *MockProcessedCommandRepository) Create(_ context.Context, command *models.ProcessedCommand) (bool, error) {
+	args := m.Called(command)
+	return args.Bool(0), args.Error(1)
+}
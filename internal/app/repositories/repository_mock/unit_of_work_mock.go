@@ -0,0 +1,73 @@
+package repository_mock
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"github.com/google/uuid"
+)
+
+// FakeUnitOfWork stages the writes to the users and applies them only when the work succeeds, so a failing
+// unit of work leaves the users untouched. Reads go to the repositories and do not see the staged writes. The
+// conditional updates, like BlockUntil, report what the stored row allowed and go through right away, as do
+// the outbox messages, so a failing add fails the work the way it fails the transaction.
+type FakeUnitOfWork struct {
+	Users  irepository.UserRepository
+	Outbox irepository.OutboxRepository
+}
+
+func (u *FakeUnitOfWork) Do(_ context.Context, fn func(repos irepository.Repositories) error) error {
+	staged := &stagedWrites{}
+	err := fn(irepository.Repositories{
+		Users:  &stagedUserRepository{UserRepository: u.Users, staged: staged},
+		Outbox: u.Outbox,
+	})
+	if err != nil {
+		return err
+	}
+	return staged.commit()
+}
+
+// stagedWrites holds the writes of a unit of work in the order they were made.
+type stagedWrites struct {
+	writes []func() error
+}
+
+func (s *stagedWrites) stage(write func() error) {
+	s.writes = append(s.writes, write)
+}
+
+func (s *stagedWrites) commit() error {
+	for _, write := range s.writes {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type stagedUserRepository struct {
+	irepository.UserRepository
+	staged *stagedWrites
+}
+
+func (r *stagedUserRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	r.staged.stage(func() error {
+		_, err := r.UserRepository.Create(ctx, user)
+		return err
+	})
+	return user, nil
+}
+
+func (r *stagedUserRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	r.staged.stage(func() error {
+		_, err := r.UserRepository.Update(ctx, user)
+		return err
+	})
+	return user, nil
+}
+
+func (r *stagedUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.staged.stage(func() error { return r.UserRepository.Delete(ctx, id) })
+	return nil
+}
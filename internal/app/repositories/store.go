@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"gorm.io/gorm"
+)
+
+// NewGormStore returns the repositories of the database.
+func NewGormStore(db *gorm.DB, logger Logger) irepository.Store {
+	return irepository.Store{
+		Users:                 NewGormUserRepository(db, logger),
+		PasswordResetTokens:   NewGormPasswordResetTokenRepository(db, logger),
+		LoginChallenges:       NewGormLoginChallengeRepository(db, logger),
+		LoginAttempts:         NewGormLoginAttemptRepository(db, logger),
+		IPRules:               NewGormIPRuleRepository(db, logger),
+		Invitations:           NewGormInvitationRepository(db, logger),
+		ImpersonationSessions: NewGormImpersonationSessionRepository(db, logger),
+		AuditRecords:          NewGormAuditRecordRepository(db, logger),
+		Outbox:                NewGormOutboxRepository(db, logger),
+		Webhooks:              NewGormWebhookRepository(db, logger),
+		ProcessedCommands:     NewGormProcessedCommandRepository(db, logger),
+		UnitOfWork:            NewGormUnitOfWork(db, logger),
+	}
+}
+
+// NewMemoryStore returns repositories keeping their rows in memory, empty on every start.
+func NewMemoryStore(logger Logger) irepository.Store {
+	users := NewMemoryUserRepository(logger)
+	outbox := NewMemoryOutboxRepository(logger)
+	return irepository.Store{
+		Users:                 users,
+		PasswordResetTokens:   NewMemoryPasswordResetTokenRepository(logger),
+		LoginChallenges:       NewMemoryLoginChallengeRepository(logger),
+		LoginAttempts:         NewMemoryLoginAttemptRepository(logger),
+		IPRules:               NewMemoryIPRuleRepository(logger),
+		Invitations:           NewMemoryInvitationRepository(logger),
+		ImpersonationSessions: NewMemoryImpersonationSessionRepository(logger),
+		AuditRecords:          NewMemoryAuditRecordRepository(logger),
+		Outbox:                outbox,
+		Webhooks:              NewMemoryWebhookRepository(logger),
+		ProcessedCommands:     NewMemoryProcessedCommandRepository(logger),
+		UnitOfWork:            NewMemoryUnitOfWork(users, outbox),
+	}
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"context"
+	"gorm.io/gorm"
+)
+
+type GormUnitOfWork struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormUnitOfWork(db *gorm.DB, logger Logger) irepository.UnitOfWork {
+	return &GormUnitOfWork{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (u *GormUnitOfWork) Do(ctx context.Context, fn func(repos irepository.Repositories) error) error {
+	return u.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(irepository.Repositories{
+			Users:  NewGormUserRepository(tx, u.logger),
+			Outbox: NewGormOutboxRepository(tx, u.logger),
+		})
+	})
+}
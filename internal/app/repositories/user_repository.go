@@ -4,9 +4,12 @@ import (
 	"automation-hub-idp/internal/app/models"
 	"automation-hub-idp/internal/app/repositories/irepository"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type Logger interface {
@@ -28,19 +31,22 @@ func NewGormUserRepository(db *gorm.DB, logger Logger) irepository.UserRepositor
 	}
 }
 
-func (r *GormUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+func (r *GormUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	err := r.DB.First(&user, "id = ? AND is_active = ?", id, true).Error
+	err := r.DB.WithContext(ctx).First(&user, "id = ? AND is_active = ?", id, true).Error
 	if err != nil {
 		r.logger.Error("Failed to fetch user by ID: %s", err)
-		return nil, errors.New("user not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, irepository.ErrUserNotFound
+		}
+		return nil, errors.New("failed to fetch user")
 	}
 	return &user, nil
 }
 
-func (r *GormUserRepository) FindByEmail(email string) (*models.User, error) {
+func (r *GormUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.DB.First(&user, "email = ? AND is_active = ?", email, true).Error
+	err := r.DB.WithContext(ctx).First(&user, "email = ? AND is_active = ?", email, true).Error
 	if err != nil {
 		r.logger.Error("Failed to fetch user by email: %s", err)
 		return nil, errors.New("user not found")
@@ -48,8 +54,8 @@ func (r *GormUserRepository) FindByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *GormUserRepository) Create(user *models.User) (*models.User, error) {
-	err := r.DB.Create(user).Error
+func (r *GormUserRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	err := r.DB.WithContext(ctx).Create(user).Error
 	if err != nil {
 		r.logger.Error("Failed to create user: %s", err)
 		return nil, errors.New("failed to create user")
@@ -57,8 +63,12 @@ func (r *GormUserRepository) Create(user *models.User) (*models.User, error) {
 	return user, nil
 }
 
-func (r *GormUserRepository) Update(user *models.User) (*models.User, error) {
-	err := r.DB.Save(user).Error
+// loginStateColumns are only written through the atomic login operations below, so a full-row
+// update never overwrites a counter or block set concurrently by another login attempt.
+var loginStateColumns = []string{"failed_attempts", "last_attempt", "is_blocked", "blocked_until"}
+
+func (r *GormUserRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	err := r.DB.WithContext(ctx).Model(user).Select("*").Omit(loginStateColumns...).Updates(user).Error
 	if err != nil {
 		r.logger.Error("Failed to update user: %s", err)
 		return nil, errors.New("failed to update user")
@@ -66,9 +76,9 @@ func (r *GormUserRepository) Update(user *models.User) (*models.User, error) {
 	return user, nil
 }
 
-func (r *GormUserRepository) Delete(id uuid.UUID) error {
+func (r *GormUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	user := models.User{ID: id}
-	err := r.DB.Model(&user).Update("is_active", false).Error
+	err := r.DB.WithContext(ctx).Model(&user).Update("is_active", false).Error
 	if err != nil {
 		r.logger.Error("Failed to soft delete user: %s", err)
 		return errors.New("failed to soft delete user")
@@ -76,9 +86,9 @@ func (r *GormUserRepository) Delete(id uuid.UUID) error {
 	return nil
 }
 
-func (r *GormUserRepository) FindAll(p utils.Pagination) ([]*models.User, error) {
+func (r *GormUserRepository) FindAll(ctx context.Context, p utils.Pagination) ([]*models.User, error) {
 	var users []*models.User
-	err := r.DB.Where("is_active = ?", true).Limit(p.Limit).Offset(p.Offset).Find(&users).Error
+	err := r.DB.WithContext(ctx).Where("is_active = ?", true).Limit(p.Limit).Offset(p.Offset).Find(&users).Error
 	if err != nil {
 		r.logger.Error("Failed to fetch all users: %s", err)
 		return nil, errors.New("failed to fetch users")
@@ -86,12 +96,87 @@ func (r *GormUserRepository) FindAll(p utils.Pagination) ([]*models.User, error)
 	return users, nil
 }
 
-func (r *GormUserRepository) FindByResetToken(token string) (*models.User, error) {
+func (r *GormUserRepository) FindByEmailChangeToken(ctx context.Context, token string) (*models.User, error) {
+	var user models.User
+	err := r.DB.WithContext(ctx).First(&user, "email_change_token = ? AND is_active = ?", token, true).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch user by email change token: %s", err)
+		return nil, errors.New("user not found")
+	}
+	return &user, nil
+}
+
+func (r *GormUserRepository) FindByEmailRevertToken(ctx context.Context, token string) (*models.User, error) {
 	var user models.User
-	err := r.DB.First(&user, "reset_password_token = ? AND is_active = ?", token, true).Error
+	err := r.DB.WithContext(ctx).First(&user, "email_revert_token = ? AND is_active = ?", token, true).Error
 	if err != nil {
-		r.logger.Error("Failed to fetch user by reset token: %s", err)
+		r.logger.Error("Failed to fetch user by email revert token: %s", err)
 		return nil, errors.New("user not found")
 	}
 	return &user, nil
 }
+
+// IncrementFailedAttempts atomically counts a login attempt and returns the new count.
+func (r *GormUserRepository) IncrementFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	var user models.User
+	result := r.DB.WithContext(ctx).Model(&user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "failed_attempts"}}}).
+		Where("id = ? AND is_active = ?", id, true).
+		Updates(map[string]interface{}{
+			"failed_attempts": gorm.Expr("failed_attempts + 1"),
+			"last_attempt":    attemptAt,
+		})
+	if result.Error != nil {
+		r.logger.Error("Failed to increment failed attempts: %s", result.Error)
+		return 0, errors.New("failed to update user")
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("user not found")
+	}
+	return user.FailedAttempts, nil
+}
+
+func (r *GormUserRepository) ResetFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"last_attempt":    attemptAt,
+		}).Error
+	if err != nil {
+		r.logger.Error("Failed to reset failed attempts: %s", err)
+		return errors.New("failed to update user")
+	}
+	return nil
+}
+
+// BlockUntil blocks the user unless a block lasting at least as long is already in place.
+// It reports whether this call set the block.
+func (r *GormUserRepository) BlockUntil(ctx context.Context, id uuid.UUID, blockedUntil time.Time) (bool, error) {
+	result := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND (is_blocked = ? OR blocked_until IS NULL OR blocked_until < ?)", id, false, blockedUntil).
+		Updates(map[string]interface{}{
+			"is_blocked":    true,
+			"blocked_until": blockedUntil,
+		})
+	if result.Error != nil {
+		r.logger.Error("Failed to block user: %s", result.Error)
+		return false, errors.New("failed to update user")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UnblockIfExpired lifts a block whose time has passed. A block extended concurrently is left alone.
+func (r *GormUserRepository) UnblockIfExpired(ctx context.Context, id uuid.UUID, now time.Time) error {
+	err := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND is_blocked = ? AND (blocked_until IS NULL OR blocked_until <= ?)", id, true, now).
+		Updates(map[string]interface{}{
+			"is_blocked":      false,
+			"failed_attempts": 0,
+			"blocked_until":   nil,
+		}).Error
+	if err != nil {
+		r.logger.Error("Failed to unblock user: %s", err)
+		return errors.New("failed to update user")
+	}
+	return nil
+}
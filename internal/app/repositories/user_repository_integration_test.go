@@ -0,0 +1,106 @@
+//go:build integration
+
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/infra"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestPostgres connects to the Postgres of the DB_* variables and migrates it, skipping the test when
+// none is configured.
+func newTestPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	host := os.Getenv("DB_HOST")
+	if host == "" {
+		t.Skip("DB_HOST is not set, no Postgres to run against")
+	}
+	port, err := strconv.Atoi(os.Getenv("DB_PORT"))
+	if err != nil {
+		t.Fatalf("DB_PORT: %v", err)
+	}
+	db, err := infra.NewPostgresDatabase(os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := infra.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestGormUserRepository_ConcurrentFailedLoginsCountEveryAttemptAndBlockOnce(t *testing.T) {
+	// Arrange
+	const attempts = 20
+	const maxAttempts = 5
+	db := newTestPostgres(t)
+	repo := NewGormUserRepository(db, service_mock.NewPermissiveMockLogger())
+	user, err := repo.Create(context.Background(), &models.User{Email: fmt.Sprintf("%s@example.com", uuid.New()), Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Unscoped().Delete(&models.User{}, "id = ?", user.ID) })
+	blockedUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+	// Act
+	var mu sync.Mutex
+	var counts []int
+	var blocks int
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := repo.IncrementFailedAttempts(context.Background(), user.ID, time.Now())
+			assert.NoError(t, err)
+			blocked := false
+			if count >= maxAttempts {
+				blocked, err = repo.BlockUntil(context.Background(), user.ID, blockedUntil)
+				assert.NoError(t, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			counts = append(counts, count)
+			if blocked {
+				blocks++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	// Every attempt saw its own count, none was lost to a concurrent one
+	sort.Ints(counts)
+	expected := make([]int, attempts)
+	for i := range expected {
+		expected[i] = i + 1
+	}
+	assert.Equal(t, expected, counts)
+	assert.Equal(t, 1, blocks)
+	stored, err := repo.FindByID(context.Background(), user.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, attempts, stored.FailedAttempts)
+	assert.True(t, stored.IsBlocked)
+	if assert.NotNil(t, stored.BlockedUntil) {
+		assert.True(t, blockedUntil.Equal(*stored.BlockedUntil))
+	}
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/services/service_mock"
+	"automation-hub-idp/internal/app/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newDryRunPostgres returns a traced Postgres database that builds the statements without connecting.
+func newDryRunPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=idp"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracing.InstrumentDB(db, "idp"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// recordSpans makes the test's in-memory exporter the global one for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func TestGormUserRepository_StatementsJoinTraceOfRequest(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	repo := NewGormUserRepository(newDryRunPostgres(t), service_mock.NewPermissiveMockLogger())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	router.GET("/users/:email", func(c *gin.Context) {
+		_, _ = repo.FindByEmail(c.Request.Context(), c.Param("email"))
+		c.Status(http.StatusOK)
+	})
+	request := httptest.NewRequest(http.MethodGet, "/users/jane@example.com", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// Act
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	// Assert
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	statement, server := spans[0], spans[1]
+	assert.Equal(t, "db.query", statement.Name)
+	assert.Equal(t, "GET /users/:email", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, server.SpanContext.TraceID(), statement.SpanContext.TraceID())
+	assert.Equal(t, server.SpanContext.SpanID(), statement.Parent.SpanID())
+}
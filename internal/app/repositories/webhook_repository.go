@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type GormWebhookRepository struct {
+	DB     *gorm.DB
+	logger Logger
+}
+
+func NewGormWebhookRepository(db *gorm.DB, logger Logger) irepository.WebhookRepository {
+	return &GormWebhookRepository{
+		DB:     db,
+		logger: logger,
+	}
+}
+
+func (r *GormWebhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	err := r.DB.WithContext(ctx).Create(subscription).Error
+	if err != nil {
+		r.logger.Error("Failed to create webhook subscription: %s", err)
+		return nil, errors.New("failed to create webhook subscription")
+	}
+	return subscription, nil
+}
+
+func (r *GormWebhookRepository) FindSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := r.DB.WithContext(ctx).First(&subscription, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook subscription by ID: %s", err)
+		return nil, errors.New("webhook subscription not found")
+	}
+	return &subscription, nil
+}
+
+func (r *GormWebhookRepository) FindSubscriptionsByOrganization(ctx context.Context, organizationID uuid.UUID) ([]*models.WebhookSubscription, error) {
+	var subscriptions []*models.WebhookSubscription
+	err := r.DB.WithContext(ctx).Where("organization_id = ?", organizationID).Order("created_at").Find(&subscriptions).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook subscriptions of organization %s: %s", organizationID, err)
+		return nil, errors.New("failed to fetch webhook subscriptions")
+	}
+	return subscriptions, nil
+}
+
+func (r *GormWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.WebhookSubscription{}, "id = ?", id).Error
+	})
+	if err != nil {
+		r.logger.Error("Failed to delete webhook subscription %s: %s", id, err)
+		return errors.New("failed to delete webhook subscription")
+	}
+	return nil
+}
+
+func (r *GormWebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	// The unique index on subscription and event skips events the relay published more than once
+	result := r.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		r.logger.Error("Failed to create webhook delivery: %s", result.Error)
+		return false, errors.New("failed to create webhook delivery")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *GormWebhookRepository) FindDelivery(ctx context.Context, id uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.DB.WithContext(ctx).First(&delivery, "id = ?", id).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook delivery by ID: %s", err)
+		return nil, errors.New("webhook delivery not found")
+	}
+	return &delivery, nil
+}
+
+func (r *GormWebhookRepository) FindDeliveries(ctx context.Context, subscriptionID uuid.UUID, p utils.Pagination) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := r.DB.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("created_at DESC").
+		Limit(p.Limit).Offset(p.Offset).Find(&deliveries).Error
+	if err != nil {
+		r.logger.Error("Failed to fetch webhook deliveries of subscription %s: %s", subscriptionID, err)
+		return nil, errors.New("failed to fetch webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+func (r *GormWebhookRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := r.DB.WithContext(ctx).Raw(`UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN (
+	SELECT id FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ?
+	ORDER BY next_attempt_at LIMIT ? FOR UPDATE SKIP LOCKED) RETURNING *`,
+		leaseUntil, models.WebhookDeliveryPending, now, limit).Scan(&deliveries).Error
+	if err != nil {
+		r.logger.Error("Failed to claim due webhook deliveries: %s", err)
+		return nil, errors.New("failed to fetch webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+func (r *GormWebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	err := r.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).
+		Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error
+	if err != nil {
+		r.logger.Error("Failed to update webhook delivery %s: %s", delivery.ID, err)
+		return errors.New("failed to update webhook delivery")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package risk
+
+import (
+	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+)
+
+type assessor struct {
+	signals         []Signal
+	stepUpThreshold int
+	denyThreshold   int
+	logger          iservice.Logger
+}
+
+func NewAssessor(stepUpThreshold, denyThreshold int, logger iservice.Logger, signals ...Signal) Assessor {
+	return &assessor{
+		signals:         signals,
+		stepUpThreshold: stepUpThreshold,
+		denyThreshold:   denyThreshold,
+		logger:          logger,
+	}
+}
+
+// NewConfiguredAssessor builds the assessor from the risk configuration. When risk scoring is disabled every
+// login is allowed, when it is not enforced every login is scored and allowed.
+func NewConfiguredAssessor(settings *config.Config, loginHistory loginhistory.Service, geoLocator iservice.GeoLocator,
+	logger iservice.Logger) (Assessor, error) {
+	cfg := settings.Risk
+	if !cfg.Enabled {
+		return NewAllowAllAssessor(), nil
+	}
+	signals := []Signal{
+		NewDeviceSignal(loginHistory, cfg.NewDeviceScore),
+		NewImpossibleTravelSignal(loginHistory, geoLocator, cfg.MaxTravelSpeedKmh, cfg.ImpossibleTravelScore),
+		NewFailureRateSignal(loginHistory, cfg.FailureRateWindow, cfg.FailureRateThreshold, cfg.FailureRateScore),
+	}
+	if cfg.AnonymizerListPath != "" {
+		anonymizerSignal, err := LoadAnonymizerSignal(cfg.AnonymizerListPath, cfg.AnonymizerScore)
+		if err != nil {
+			return nil, err
+		}
+		signals = append(signals, anonymizerSignal)
+	}
+	assessor := NewAssessor(cfg.StepUpThreshold, cfg.DenyThreshold, logger, signals...)
+	if !cfg.Enforce {
+		return NewObservingAssessor(assessor, logger), nil
+	}
+	return assessor, nil
+}
+
+func (a *assessor) Assess(ctx context.Context, req Request) (*Assessment, error) {
+	assessment := &Assessment{}
+	for _, signal := range a.signals {
+		score, err := signal.Score(ctx, req)
+		if err != nil {
+			// A broken signal must not lock everyone out, so it only gets logged
+			a.logger.Error("Error evaluating risk signal %s: %v", signal.Name(), err)
+			continue
+		}
+		if score > 0 {
+			assessment.Score += score
+			assessment.Reasons = append(assessment.Reasons, signal.Name())
+		}
+	}
+
+	switch {
+	case assessment.Score >= a.denyThreshold:
+		assessment.Decision = DecisionDeny
+	case assessment.Score >= a.stepUpThreshold:
+		assessment.Decision = DecisionStepUp
+	default:
+		assessment.Decision = DecisionAllow
+	}
+	return assessment, nil
+}
+
+type allowAllAssessor struct{}
+
+// NewAllowAllAssessor returns an assessor that allows every login.
+func NewAllowAllAssessor() Assessor {
+	return allowAllAssessor{}
+}
+
+func (allowAllAssessor) Assess(context.Context, Request) (*Assessment, error) {
+	return &Assessment{Decision: DecisionAllow}, nil
+}
+
+type observingAssessor struct {
+	assessor Assessor
+	logger   iservice.Logger
+}
+
+// NewObservingAssessor returns an assessor that scores logins with assessor but allows every one of them,
+// logging the decision it would have taken. It lets the thresholds be tuned on real traffic.
+func NewObservingAssessor(assessor Assessor, logger iservice.Logger) Assessor {
+	return &observingAssessor{
+		assessor: assessor,
+		logger:   logger,
+	}
+}
+
+func (a *observingAssessor) Assess(ctx context.Context, req Request) (*Assessment, error) {
+	assessment, err := a.assessor.Assess(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if assessment.Decision != DecisionAllow {
+		a.logger.Info("Risk assessment not enforced, allowing login of user %s that would %s, risk score %d %v",
+			req.UserID, assessment.Decision, assessment.Score, assessment.Reasons)
+		assessment.Decision = DecisionAllow
+	}
+	return assessment, nil
+}
@@ -0,0 +1,38 @@
+package risk
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DecisionAllow  = "allow"
+	DecisionStepUp = "step_up"
+	DecisionDeny   = "deny"
+)
+
+// Request describes a login whose credentials have been verified and which is about to receive tokens.
+type Request struct {
+	UserID uuid.UUID
+	Client dto.ClientInfo
+	Time   time.Time
+}
+
+// Assessment is the outcome of scoring a login. Reasons lists the names of the signals that contributed.
+type Assessment struct {
+	Score    int
+	Decision string
+	Reasons  []string
+}
+
+// Signal scores one aspect of a login. It returns 0 when the signal does not apply.
+type Signal interface {
+	Name() string
+	Score(ctx context.Context, req Request) (int, error)
+}
+
+type Assessor interface {
+	Assess(ctx context.Context, req Request) (*Assessment, error)
+}
@@ -0,0 +1,124 @@
+package risk
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fixedSignal struct {
+	name  string
+	score int
+	err   error
+}
+
+func (s fixedSignal) Name() string {
+	return s.name
+}
+
+func (s fixedSignal) Score(context.Context, Request) (int, error) {
+	return s.score, s.err
+}
+
+type stubGeoLocator struct {
+	location *iservice.GeoLocation
+}
+
+func (s *stubGeoLocator) Lookup(string) (*iservice.GeoLocation, error) {
+	return s.location, nil
+}
+
+func TestAssess_DecisionFollowsThresholds(t *testing.T) {
+	tests := []struct {
+		name     string
+		signals  []Signal
+		decision string
+	}{
+		{"no signals", nil, DecisionAllow},
+		{"below step-up", []Signal{fixedSignal{"a", 20, nil}}, DecisionAllow},
+		{"step-up", []Signal{fixedSignal{"a", 20, nil}, fixedSignal{"b", 30, nil}}, DecisionStepUp},
+		{"deny", []Signal{fixedSignal{"a", 60, nil}, fixedSignal{"b", 30, nil}}, DecisionDeny},
+		{"failing signal is skipped", []Signal{fixedSignal{"a", 100, errors.New("unavailable")}}, DecisionAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			assessor := NewAssessor(50, 90, service_mock.NewPermissiveMockLogger(), tt.signals...)
+
+			// Act
+			assessment, err := assessor.Assess(context.Background(), Request{UserID: uuid.New()})
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.decision, assessment.Decision)
+		})
+	}
+}
+
+func TestObservingAssessor_AllowsButKeepsTheScore(t *testing.T) {
+	// Arrange
+	logger := service_mock.NewPermissiveMockLogger()
+	assessor := NewObservingAssessor(NewAssessor(50, 90, logger, fixedSignal{"a", 95, nil}), logger)
+
+	// Act
+	assessment, err := assessor.Assess(context.Background(), Request{UserID: uuid.New()})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, DecisionAllow, assessment.Decision)
+	assert.Equal(t, 95, assessment.Score)
+	assert.Equal(t, []string{"a"}, assessment.Reasons)
+}
+
+func TestImpossibleTravelSignal(t *testing.T) {
+	// Arrange
+	userID := uuid.New()
+	now := time.Now()
+	amsterdamLat, amsterdamLon := 52.37, 4.89
+	previous := &models.LoginAttempt{Latitude: &amsterdamLat, Longitude: &amsterdamLon, CreatedAt: now.Add(-time.Hour)}
+	loginHistory := new(service_mock.MockLoginHistoryService)
+	loginHistory.On("GetLastSuccessfulLogin", userID).Return(previous, nil)
+
+	sydney := &stubGeoLocator{location: &iservice.GeoLocation{Latitude: -33.87, Longitude: 151.21}}
+	brussels := &stubGeoLocator{location: &iservice.GeoLocation{Latitude: 50.85, Longitude: 4.35}}
+	req := Request{UserID: userID, Client: dto.ClientInfo{IP: "203.0.113.7"}, Time: now}
+
+	// Act
+	farScore, farErr := NewImpossibleTravelSignal(loginHistory, sydney, 1000, 60).Score(context.Background(), req)
+	nearScore, nearErr := NewImpossibleTravelSignal(loginHistory, brussels, 1000, 60).Score(context.Background(), req)
+
+	// Assert
+	assert.NoError(t, farErr)
+	assert.NoError(t, nearErr)
+	assert.Equal(t, 60, farScore)
+	assert.Equal(t, 0, nearScore)
+}
+
+func TestLoadAnonymizerSignal(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "anonymizers.txt")
+	content := "# tor exit nodes\n198.51.100.0/24\n\n2001:db8::1\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	// Act
+	signal, err := LoadAnonymizerSignal(path, 40)
+
+	// Assert
+	assert.NoError(t, err)
+	inRange, _ := signal.Score(context.Background(), Request{Client: dto.ClientInfo{IP: "198.51.100.23"}})
+	exact, _ := signal.Score(context.Background(), Request{Client: dto.ClientInfo{IP: "2001:db8::1"}})
+	outside, _ := signal.Score(context.Background(), Request{Client: dto.ClientInfo{IP: "203.0.113.7"}})
+	assert.Equal(t, 40, inRange)
+	assert.Equal(t, 40, exact)
+	assert.Equal(t, 0, outside)
+}
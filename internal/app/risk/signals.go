@@ -0,0 +1,176 @@
+package risk
+
+import (
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const earthRadiusKm = 6371.0
+
+type newDeviceSignal struct {
+	loginHistory loginhistory.Service
+	score        int
+}
+
+// NewDeviceSignal scores logins from a device the user has not logged in from before.
+func NewDeviceSignal(loginHistory loginhistory.Service, score int) Signal {
+	return &newDeviceSignal{loginHistory: loginHistory, score: score}
+}
+
+func (s *newDeviceSignal) Name() string {
+	return "new_device"
+}
+
+func (s *newDeviceSignal) Score(ctx context.Context, req Request) (int, error) {
+	newDevice, err := s.loginHistory.IsNewDevice(ctx, req.UserID, req.Client)
+	if err != nil || !newDevice {
+		return 0, err
+	}
+	return s.score, nil
+}
+
+type impossibleTravelSignal struct {
+	loginHistory loginhistory.Service
+	geoLocator   iservice.GeoLocator
+	maxSpeedKmh  float64
+	score        int
+}
+
+// NewImpossibleTravelSignal scores logins that are too far from the previous successful login to have
+// been reached in the time between them.
+func NewImpossibleTravelSignal(loginHistory loginhistory.Service, geoLocator iservice.GeoLocator, maxSpeedKmh float64, score int) Signal {
+	return &impossibleTravelSignal{
+		loginHistory: loginHistory,
+		geoLocator:   geoLocator,
+		maxSpeedKmh:  maxSpeedKmh,
+		score:        score,
+	}
+}
+
+func (s *impossibleTravelSignal) Name() string {
+	return "impossible_travel"
+}
+
+func (s *impossibleTravelSignal) Score(ctx context.Context, req Request) (int, error) {
+	previous, err := s.loginHistory.GetLastSuccessfulLogin(ctx, req.UserID)
+	if err != nil || previous == nil || previous.Latitude == nil || previous.Longitude == nil {
+		return 0, err
+	}
+	current, err := s.geoLocator.Lookup(req.Client.IP)
+	if err != nil || current == nil {
+		return 0, err
+	}
+
+	distance := haversineKm(*previous.Latitude, *previous.Longitude, current.Latitude, current.Longitude)
+	elapsed := req.Time.Sub(previous.CreatedAt)
+	// Anything closer than this is within the accuracy of an IP lookup
+	if distance < 100 {
+		return 0, nil
+	}
+	if elapsed <= 0 || distance/elapsed.Hours() > s.maxSpeedKmh {
+		return s.score, nil
+	}
+	return 0, nil
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+type failureRateSignal struct {
+	loginHistory loginhistory.Service
+	window       time.Duration
+	threshold    int
+	score        int
+}
+
+// NewFailureRateSignal scores logins from an IP with at least threshold failed attempts within the window.
+func NewFailureRateSignal(loginHistory loginhistory.Service, window time.Duration, threshold, score int) Signal {
+	return &failureRateSignal{
+		loginHistory: loginHistory,
+		window:       window,
+		threshold:    threshold,
+		score:        score,
+	}
+}
+
+func (s *failureRateSignal) Name() string {
+	return "ip_failure_rate"
+}
+
+func (s *failureRateSignal) Score(ctx context.Context, req Request) (int, error) {
+	failures, err := s.loginHistory.CountRecentFailuresFromIP(ctx, req.Client.IP, req.Time.Add(-s.window))
+	if err != nil || failures < int64(s.threshold) {
+		return 0, err
+	}
+	return s.score, nil
+}
+
+type anonymizerSignal struct {
+	networks []*net.IPNet
+	score    int
+}
+
+// NewAnonymizerSignal scores logins from IPs inside known Tor exit or VPN ranges.
+func NewAnonymizerSignal(networks []*net.IPNet, score int) Signal {
+	return &anonymizerSignal{networks: networks, score: score}
+}
+
+// LoadAnonymizerSignal reads the ranges from a file with one IP or CIDR per line. Blank lines and
+// lines starting with # are ignored.
+func LoadAnonymizerSignal(path string, score int) (Signal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var networks []*net.IPNet
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		network, err := utils.ParseNetwork(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		networks = append(networks, network)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewAnonymizerSignal(networks, score), nil
+}
+
+func (s *anonymizerSignal) Name() string {
+	return "anonymizer_network"
+}
+
+func (s *anonymizerSignal) Score(ctx context.Context, req Request) (int, error) {
+	ip := net.ParseIP(req.Client.IP)
+	if ip == nil {
+		return 0, nil
+	}
+	for _, network := range s.networks {
+		if network.Contains(ip) {
+			return s.score, nil
+		}
+	}
+	return 0, nil
+}
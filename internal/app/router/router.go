@@ -1,26 +1,51 @@
 package router
 
 import (
+	"automation-hub-idp/internal/app/audit"
+	"automation-hub-idp/internal/app/authentication"
 	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/health"
+	"automation-hub-idp/internal/app/invitations"
+	"automation-hub-idp/internal/app/iprules"
+	"automation-hub-idp/internal/app/logging"
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
+	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/webhooks"
 	"github.com/gin-gonic/gin"
+	"log/slog"
 )
 
-func Initialize() error {
-	// initialize Router
-	router := gin.Default()
-
-	// initialize routes
-	err := initializeRoutes(router)
-	if err != nil {
-		return err
-	}
+// Services are what the routes serve. The application builds each of them once.
+type Services struct {
+	Auth         authentication.IService
+	Users        users.UserService
+	LoginHistory loginhistory.Service
+	Invitations  invitations.Service
+	IPRules      iprules.Service
+	Webhooks     webhooks.Service
+	Audit        audit.Service
+	Health       health.Service
+	RateLimiter  iservice.RateLimiter
+	// Logger reports the failures the middlewares let requests through despite
+	Logger iservice.Logger
+}
 
-	// run server
-	port := ":" + config.ServerConfig.Port
-	err = router.Run(port)
+// New returns the HTTP API.
+func New(cfg *config.Config, logger *slog.Logger, services Services) (*gin.Engine, error) {
+	// initialize Router, requests are logged by the logging middleware instead of gin's logger. The request
+	// span is started first, so the request log carries its trace ID.
+	router := gin.New()
+	router.Use(gin.Recovery(), tracing.Middleware(), logging.Middleware(logger), metrics.Middleware())
+	// Only believe X-Forwarded-For from our own proxies, otherwise any client can pick its IP
+	err := router.SetTrustedProxies(cfg.Server.TrustedProxies)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	// initialize routes
+	initializeRoutes(router, cfg, services)
+	return router, nil
 }
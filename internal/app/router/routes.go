@@ -2,57 +2,127 @@ package router
 
 import (
 	"automation-hub-idp/docs"
+	"automation-hub-idp/internal/app/audit"
 	"automation-hub-idp/internal/app/authentication"
 	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/health"
+	"automation-hub-idp/internal/app/invitations"
+	"automation-hub-idp/internal/app/iprules"
+	"automation-hub-idp/internal/app/loginhistory"
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/ratelimit"
+	"automation-hub-idp/internal/app/services/iservice"
 	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/webhooks"
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func initializeRoutes(router *gin.Engine) error {
-	relativePathV1 := config.ServerConfig.BaseURL + "/v1"
+func initializeRoutes(router *gin.Engine, cfg *config.Config, services Services) {
+	relativePathV1 := cfg.Server.BaseURL + "/v1"
 	docs.SwaggerInfo.BasePath = relativePathV1
 	v1 := router.Group(relativePathV1)
+	v1.Use(iprules.Middleware(services.IPRules, models.IPRuleScopeGlobal))
 	{
 		// initialize auth routes
-		err := initializeAuthRoutes(v1)
-		if err != nil {
-			return err
-		}
+		initializeAuthRoutes(v1, cfg, services)
 	}
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
-	return nil
+	// the metrics tell a lot about the accounts, only the scrapers that hold the token read them
+	if cfg.Server.MetricsToken != "" {
+		router.GET("/metrics", metrics.RequireToken(cfg.Server.MetricsToken), metrics.Handler())
+	}
+
+	// probes sit outside the API, so IP rules and rate limits never fail them
+	healthHandler := health.NewHandler(services.Health)
+	router.GET("/healthz", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
 }
 
-func initializeAuthRoutes(apiVersion *gin.RouterGroup) error {
-	authService, err := authentication.GetDefaultAuthService()
-	if err != nil {
-		return err
-	}
-	authHandler := authentication.NewHandler(authService)
+func initializeAuthRoutes(apiVersion *gin.RouterGroup, cfg *config.Config, services Services) {
+	authHandler := authentication.NewHandler(services.Auth, cfg.Authentication.UniformAuthResponses)
 	authMiddleware := authentication.AuthMiddleware(authHandler)
+	// Credentials stay with their owner, an impersonating admin cannot change them
+	denyImpersonation := authentication.DenyImpersonation(authHandler)
+	userService := services.Users
+	userHandler := users.NewHandler(userService, services.Auth)
+	loginHistoryHandler := loginhistory.NewHandler(services.LoginHistory)
+	invitationHandler := invitations.NewHandler(services.Invitations)
 
-	userService, err := users.GetDefaultUserService()
-	if err != nil {
-		return err
-	}
-	userHandler := users.NewHandler(userService)
+	limits := cfg.RateLimit
+	rateLimit := rateLimitMiddleware(limits.Enabled, services.RateLimiter, services.Logger)
+	defaultLimit := rateLimit(ratelimit.PerIP(limits.DefaultPerIP))
 
 	auth := apiVersion.Group("/auth")
 	{
-		auth.POST("/register", authHandler.Register)
-		auth.POST("/login", authHandler.Login)
-		auth.GET("/logout", authMiddleware, authHandler.Logout)
-		auth.POST("/request-password-reset", authHandler.RequestPasswordReset)
-		auth.POST("/confirm-password-reset/:reset-token", authHandler.ConfirmPasswordReset)
-		auth.GET("/is-user-authenticated", authHandler.IsUserAuthenticated)
+		auth.POST("/register", rateLimit(ratelimit.PerIP(limits.RegisterPerIP)), authHandler.Register)
+		auth.POST("/login", rateLimit(ratelimit.PerIP(limits.LoginPerIP),
+			ratelimit.PerAccount("email", limits.LoginPerAccount)), authHandler.Login)
+		auth.POST("/login/verify", rateLimit(ratelimit.PerIP(limits.LoginPerIP)), authHandler.VerifyLoginChallenge)
+		auth.GET("/logout", defaultLimit, authMiddleware, authHandler.Logout)
+		auth.POST("/request-password-reset", rateLimit(ratelimit.PerIP(limits.PasswordResetPerIP),
+			ratelimit.PerAccount("email", limits.PasswordResetPerAccount)), denyImpersonation, authHandler.RequestPasswordReset)
+		auth.POST("/confirm-password-reset/:reset-token", rateLimit(ratelimit.PerIP(limits.PasswordResetPerIP)),
+			denyImpersonation, authHandler.ConfirmPasswordReset)
+		auth.GET("/is-user-authenticated", defaultLimit, authHandler.IsUserAuthenticated)
+		auth.POST("/confirm-email-change", defaultLimit, denyImpersonation, authHandler.ConfirmEmailChange)
+		auth.POST("/revert-email-change", defaultLimit, denyImpersonation, authHandler.RevertEmailChange)
+		auth.POST("/stop-impersonation", defaultLimit, authMiddleware, authHandler.StopImpersonation)
+		auth.POST("/accept-invitation", rateLimit(ratelimit.PerIP(limits.RegisterPerIP)), invitationHandler.AcceptInvitation)
 	}
 
 	user := apiVersion.Group("/user")
+	user.Use(defaultLimit)
 	{
 		user.GET("/", authMiddleware, userHandler.GetCurrentUser)
-		user.PATCH("/", authMiddleware, userHandler.Update)
+		user.PATCH("/", authMiddleware, denyImpersonation, userHandler.Update)
+		user.GET("/login-history", authMiddleware, loginHistoryHandler.GetLoginHistory)
+		user.GET("/impersonations", authMiddleware, authHandler.GetImpersonationSessions)
+	}
+
+	invitation := apiVersion.Group("/invitations")
+	invitation.Use(defaultLimit, authMiddleware, users.RequireRole(userService, models.RoleAdmin, models.RoleOrgOwner))
+	{
+		invitation.POST("", invitationHandler.CreateInvitation)
+	}
+
+	webhookHandler := webhooks.NewHandler(services.Webhooks)
+	webhook := apiVersion.Group("/organizations/:organizationId/webhooks")
+	webhook.Use(defaultLimit, authMiddleware, users.RequireRole(userService, models.RoleAdmin, models.RoleOrgOwner))
+	{
+		webhook.POST("", webhookHandler.CreateWebhook)
+		webhook.GET("", webhookHandler.ListWebhooks)
+		webhook.DELETE("/:id", webhookHandler.DeleteWebhook)
+		webhook.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+		webhook.POST("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+	}
+
+	auditHandler := audit.NewHandler(services.Audit)
+
+	ipRuleHandler := iprules.NewHandler(services.IPRules)
+	admin := apiVersion.Group("/admin")
+	admin.Use(iprules.Middleware(services.IPRules, models.IPRuleScopeAdmin), defaultLimit, authMiddleware,
+		users.RequireRole(userService, models.RoleAdmin))
+	{
+		admin.GET("/ip-rules", ipRuleHandler.ListRules)
+		admin.POST("/ip-rules", ipRuleHandler.CreateRule)
+		admin.DELETE("/ip-rules/:id", ipRuleHandler.DeleteRule)
+		admin.POST("/impersonations", authHandler.StartImpersonation)
+		admin.PUT("/users/:id/role", userHandler.ChangeRole)
+		admin.GET("/audit-events", auditHandler.QueryEvents)
+		admin.GET("/audit-events/export", auditHandler.ExportEvents)
+		admin.GET("/audit-events/verify", auditHandler.VerifyChain)
+	}
+}
+
+// rateLimitMiddleware builds per-route limiters, or no-ops when rate limiting is disabled.
+func rateLimitMiddleware(enabled bool, limiter iservice.RateLimiter, logger iservice.Logger) func(rules ...ratelimit.Rule) gin.HandlerFunc {
+	return func(rules ...ratelimit.Rule) gin.HandlerFunc {
+		if !enabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		return ratelimit.Middleware(limiter, logger, rules...)
 	}
-	return nil
 }
@@ -1,38 +1,32 @@
 package services
 
 import (
-	"automation-hub-idp/internal/app/config"
 	"context"
 	"github.com/go-redis/redis/v8"
+	"strconv"
 	"time"
 )
 import "automation-hub-idp/internal/app/services/iservice"
 
+const sessionsRevokedKeyPrefix = "sessions_revoked:"
+
 type tokenBlockListServiceImpl struct {
 	client *redis.Client
-	ctx    context.Context
 }
 
-func NewRedisTokenBlockListService() iservice.TokenBlockListService {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: config.RedisConfig.RedisAddr,
-	})
-
-	ctx := context.TODO()
-
+func NewTokenBlockListService(client *redis.Client) iservice.TokenBlockListService {
 	return &tokenBlockListServiceImpl{
-		client: rdb,
-		ctx:    ctx,
+		client: client,
 	}
 }
 
-func (r *tokenBlockListServiceImpl) AddToBlockList(jwtUUID string, expirationTime time.Duration) error {
-	err := r.client.Set(r.ctx, jwtUUID, 1, expirationTime).Err()
+func (r *tokenBlockListServiceImpl) AddToBlockList(ctx context.Context, jwtUUID string, expirationTime time.Duration) error {
+	err := r.client.Set(ctx, jwtUUID, 1, expirationTime).Err()
 	return err
 }
 
-func (r *tokenBlockListServiceImpl) IsInBlockList(jwtUUID string) (bool, error) {
-	_, err := r.client.Get(r.ctx, jwtUUID).Result()
+func (r *tokenBlockListServiceImpl) IsInBlockList(ctx context.Context, jwtUUID string) (bool, error) {
+	_, err := r.client.Get(ctx, jwtUUID).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return false, nil
@@ -41,3 +35,26 @@ func (r *tokenBlockListServiceImpl) IsInBlockList(jwtUUID string) (bool, error)
 	}
 	return true, err
 }
+
+// RevokeUserSessions marks every token issued to the user up to revokedAt as revoked.
+// The marker only needs to outlive the longest-lived token, so expirationTime should be
+// the refresh token duration.
+func (r *tokenBlockListServiceImpl) RevokeUserSessions(ctx context.Context, userID string, revokedAt time.Time, expirationTime time.Duration) error {
+	return r.client.Set(ctx, sessionsRevokedKeyPrefix+userID, revokedAt.Unix(), expirationTime).Err()
+}
+
+func (r *tokenBlockListServiceImpl) GetSessionsRevokedAt(ctx context.Context, userID string) (*time.Time, error) {
+	value, err := r.client.Get(ctx, sessionsRevokedKeyPrefix+userID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	revokedAt := time.Unix(unix, 0)
+	return &revokedAt, nil
+}
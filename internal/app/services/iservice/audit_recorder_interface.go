@@ -0,0 +1,12 @@
+package iservice
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"context"
+)
+
+// AuditRecorder adds events to the audit log. A failure to record is logged and never fails the
+// audited operation.
+type AuditRecorder interface {
+	Record(ctx context.Context, event dto.AuditEvent)
+}
@@ -0,0 +1,13 @@
+package iservice
+
+type GeoLocation struct {
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+type GeoLocator interface {
+	// Lookup returns nil without an error when the address is not in the database.
+	Lookup(ip string) (*GeoLocation, error)
+}
@@ -1,8 +1,25 @@
 package iservice
 
+import "context"
+
 type Logger interface {
 	Info(message string, args ...interface{})
 	Error(message string, args ...interface{})
 	Warn(message string, args ...interface{})
 	Debug(message string, args ...interface{})
 }
+
+// ContextLogger is a Logger that can tag its records with the request and user IDs and the trace of a context.
+type ContextLogger interface {
+	Logger
+	With(ctx context.Context) Logger
+}
+
+// LoggerWith returns a logger whose records carry the request and user IDs and the trace of ctx, or logger
+// itself when it cannot tag its records.
+func LoggerWith(ctx context.Context, logger Logger) Logger {
+	if contextLogger, ok := logger.(ContextLogger); ok {
+		return contextLogger.With(ctx)
+	}
+	return logger
+}
@@ -0,0 +1,23 @@
+package iservice
+
+import "context"
+
+// ConsumedMessage is a record read from a topic, with the position needed to commit it.
+type ConsumedMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// MessageConsumer reads topics as a member of a consumer group. Offsets are only committed explicitly, so a
+// message that was not committed is read again after a restart or a rebalance.
+type MessageConsumer interface {
+	// Fetch blocks until a message arrives or the context is done.
+	Fetch(ctx context.Context) (*ConsumedMessage, error)
+	// Commit marks the message, and those before it in its partition, as processed.
+	Commit(message *ConsumedMessage) error
+	Close() error
+}
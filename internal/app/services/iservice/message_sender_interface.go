@@ -1,5 +1,19 @@
 package iservice
 
+import (
+	"automation-hub-idp/internal/app/events"
+	"context"
+)
+
 type MessageSender interface {
-	Send(topic string, message interface{}) error
+	// Send publishes the event in a new CloudEvents envelope, keyed by its subject.
+	Send(ctx context.Context, topic string, event events.Event) error
+	// SendEnvelope publishes an envelope built earlier, e.g. one stored in the outbox. Envelopes with the same
+	// key go to the same partition, which keeps them in order.
+	SendEnvelope(ctx context.Context, topic string, key string, envelope *events.Envelope) error
+}
+
+// RawMessageSender publishes bytes as they are, for messages that are not events of ours, like dead letters.
+type RawMessageSender interface {
+	SendRaw(ctx context.Context, topic string, key []byte, value []byte, headers map[string]string) error
 }
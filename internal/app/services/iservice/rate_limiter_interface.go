@@ -0,0 +1,23 @@
+package iservice
+
+import (
+	"context"
+	"time"
+)
+
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error)
+}
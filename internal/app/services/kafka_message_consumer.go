@@ -0,0 +1,73 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/infra"
+	"context"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"time"
+)
+
+// pollTimeout bounds how long Fetch waits for Kafka before it checks the context again.
+const pollTimeout = 500 * time.Millisecond
+
+type KafkaMessageConsumer struct {
+	Consumer *kafka.Consumer
+}
+
+func NewKafkaMessageConsumer(brokers []string, clientID string, groupID string, topics ...string) (*KafkaMessageConsumer, error) {
+	consumer, err := infra.NewKafkaConsumer(brokers, clientID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := consumer.SubscribeTopics(topics, nil); err != nil {
+		_ = consumer.Close()
+		return nil, err
+	}
+	return &KafkaMessageConsumer{
+		Consumer: consumer,
+	}, nil
+}
+
+func (k *KafkaMessageConsumer) Fetch(ctx context.Context) (*iservice.ConsumedMessage, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, err := k.Consumer.ReadMessage(pollTimeout)
+		if err != nil {
+			if kafkaErr, ok := err.(kafka.Error); ok && kafkaErr.Code() == kafka.ErrTimedOut {
+				continue
+			}
+			return nil, err
+		}
+
+		headers := make(map[string]string, len(msg.Headers))
+		for _, header := range msg.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		return &iservice.ConsumedMessage{
+			Topic:     *msg.TopicPartition.Topic,
+			Partition: msg.TopicPartition.Partition,
+			Offset:    int64(msg.TopicPartition.Offset),
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Headers:   headers,
+		}, nil
+	}
+}
+
+func (k *KafkaMessageConsumer) Commit(message *iservice.ConsumedMessage) error {
+	topic := message.Topic
+	// The committed offset is the next message to read
+	_, err := k.Consumer.CommitOffsets([]kafka.TopicPartition{{
+		Topic:     &topic,
+		Partition: message.Partition,
+		Offset:    kafka.Offset(message.Offset + 1),
+	}})
+	return err
+}
+
+func (k *KafkaMessageConsumer) Close() error {
+	return k.Consumer.Close()
+}
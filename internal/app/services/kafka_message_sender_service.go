@@ -1,29 +1,45 @@
 package services
 
 import (
-	"automation-hub-idp/internal/infra"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/tracing"
+	"context"
 	"encoding/json"
+	"github.com/google/uuid"
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"time"
 )
 
 type KafkaMessageSender struct {
 	Producer *kafka.Producer
+	// origin is where the envelopes built by Send come from
+	origin events.Origin
 }
 
-func NewKafkaMessageSender() (*KafkaMessageSender, error) {
-	prod, err := infra.GetDefaultKafkaProducer()
+func NewKafkaMessageSender(producer *kafka.Producer, origin events.Origin) *KafkaMessageSender {
+	return &KafkaMessageSender{
+		Producer: producer,
+		origin:   origin,
+	}
+}
+
+func (k *KafkaMessageSender) Send(ctx context.Context, topic string, event events.Event) error {
+	envelope, err := events.NewEnvelope(k.origin, event, uuid.New(), time.Now())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &KafkaMessageSender{
-		Producer: prod,
-	}, nil
+	return k.SendEnvelope(ctx, topic, envelope.Subject, envelope)
 }
 
-func (k *KafkaMessageSender) Send(topic string, message interface{}) error {
+func (k *KafkaMessageSender) SendEnvelope(ctx context.Context, topic string, key string, envelope *events.Envelope) error {
+	// Nothing that breaks its contract leaves the service, whenever the envelope was built
+	if err := envelope.Validate(); err != nil {
+		return err
+	}
 
 	// Marshal the message into JSON
-	msgBytes, err := json.Marshal(message)
+	msgBytes, err := json.Marshal(envelope)
 	if err != nil {
 		return err
 	}
@@ -33,11 +49,48 @@ func (k *KafkaMessageSender) Send(topic string, message interface{}) error {
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Value:          msgBytes,
 	}
+	if key != "" {
+		msg.Key = []byte(key)
+	}
+	for name, value := range envelope.Headers() {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+	return k.produce(ctx, msg)
+}
+
+func (k *KafkaMessageSender) SendRaw(ctx context.Context, topic string, key []byte, value []byte, headers map[string]string) error {
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          value,
+	}
+	for name, value := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+	return k.produce(ctx, msg)
+}
+
+func (k *KafkaMessageSender) produce(ctx context.Context, msg *kafka.Message) (err error) {
+	topic := *msg.TopicPartition.Topic
+	defer metrics.ObserveDuration(metrics.KafkaSendDuration.WithLabelValues(topic), time.Now())
+
+	// Carry the trace context to the consumers
+	headers := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	span := tracing.StartProducerSpan(ctx, topic, headers)
+	defer func() { tracing.End(span, err) }()
+	msg.Headers = msg.Headers[:0]
+	for name, value := range headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
 
 	// Produce the message to the Kafka topic
 	deliveryChan := make(chan kafka.Event)
 	err = k.Producer.Produce(msg, deliveryChan)
 	if err != nil {
+		metrics.KafkaSendErrors.WithLabelValues(topic).Inc()
 		return err
 	}
 
@@ -46,6 +99,7 @@ func (k *KafkaMessageSender) Send(topic string, message interface{}) error {
 	m := e.(*kafka.Message)
 
 	if m.TopicPartition.Error != nil {
+		metrics.KafkaSendErrors.WithLabelValues(topic).Inc()
 		return m.TopicPartition.Error
 	}
 
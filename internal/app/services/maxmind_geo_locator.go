@@ -0,0 +1,58 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"github.com/oschwald/geoip2-golang"
+	"net"
+)
+
+type MaxMindGeoLocator struct {
+	reader *geoip2.Reader
+}
+
+// NewMaxMindGeoLocator opens a local MaxMind-format (GeoLite2/GeoIP2 City) database file.
+func NewMaxMindGeoLocator(path string) (*MaxMindGeoLocator, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindGeoLocator{reader: reader}, nil
+}
+
+// NewGeoLocator returns a MaxMind locator, or one that never finds anything when no database is configured.
+func NewGeoLocator(path string) (iservice.GeoLocator, error) {
+	if path == "" {
+		return noopGeoLocator{}, nil
+	}
+	return NewMaxMindGeoLocator(path)
+}
+
+func (m *MaxMindGeoLocator) Lookup(ip string) (*iservice.GeoLocation, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, nil
+	}
+	record, err := m.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+	if record.Country.IsoCode == "" && record.Location.Latitude == 0 && record.Location.Longitude == 0 {
+		return nil, nil
+	}
+	return &iservice.GeoLocation{
+		Country:   record.Country.IsoCode,
+		City:      record.City.Names["en"],
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+	}, nil
+}
+
+func (m *MaxMindGeoLocator) Close() error {
+	return m.reader.Close()
+}
+
+type noopGeoLocator struct{}
+
+func (noopGeoLocator) Lookup(string) (*iservice.GeoLocation, error) {
+	return nil, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often writes also remove the expired entries, so entries nobody reads again do
+// not pile up.
+const memorySweepInterval = time.Minute
+
+type memoryBlockListEntry struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryTokenBlockListService keeps the block list in process memory. Entries expire like the Redis keys of
+// the Redis implementation; a zero or negative expiration never expires.
+type MemoryTokenBlockListService struct {
+	mu        sync.Mutex
+	tokens    map[string]memoryBlockListEntry
+	revoked   map[string]memoryBlockListEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryTokenBlockListService() *MemoryTokenBlockListService {
+	return &MemoryTokenBlockListService{
+		tokens:  make(map[string]memoryBlockListEntry),
+		revoked: make(map[string]memoryBlockListEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryTokenBlockListService) AddToBlockList(_ context.Context, jwtUUID string, expirationTime time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(s.tokens, jwtUUID, 1, expirationTime)
+	return nil
+}
+
+func (s *MemoryTokenBlockListService) IsInBlockList(_ context.Context, jwtUUID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, found := s.get(s.tokens, jwtUUID)
+	return found, nil
+}
+
+// RevokeUserSessions marks every token issued to the user up to revokedAt as revoked. Like the Redis
+// implementation it keeps the time in whole seconds.
+func (s *MemoryTokenBlockListService) RevokeUserSessions(_ context.Context, userID string, revokedAt time.Time, expirationTime time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(s.revoked, userID, revokedAt.Unix(), expirationTime)
+	return nil
+}
+
+func (s *MemoryTokenBlockListService) GetSessionsRevokedAt(_ context.Context, userID string) (*time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unix, found := s.get(s.revoked, userID)
+	if !found {
+		return nil, nil
+	}
+	revokedAt := time.Unix(unix, 0)
+	return &revokedAt, nil
+}
+
+// set stores the entry and sweeps the expired ones when the last sweep is old enough. The caller holds the
+// lock.
+func (s *MemoryTokenBlockListService) set(entries map[string]memoryBlockListEntry, key string, value int64, expiration time.Duration) {
+	now := s.now()
+	entry := memoryBlockListEntry{value: value}
+	if expiration > 0 {
+		entry.expiresAt = now.Add(expiration)
+	}
+	entries[key] = entry
+
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for _, sweep := range []map[string]memoryBlockListEntry{s.tokens, s.revoked} {
+		for key, entry := range sweep {
+			if entry.expired(now) {
+				delete(sweep, key)
+			}
+		}
+	}
+}
+
+// get returns the value of an entry that has not expired. The caller holds the lock.
+func (s *MemoryTokenBlockListService) get(entries map[string]memoryBlockListEntry, key string) (int64, bool) {
+	entry, found := entries[key]
+	if !found {
+		return 0, false
+	}
+	if entry.expired(s.now()) {
+		delete(entries, key)
+		return 0, false
+	}
+	return entry.value, true
+}
+
+func (e memoryBlockListEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestMemoryBlockList() (*MemoryTokenBlockListService, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	blockList := NewMemoryTokenBlockListService()
+	blockList.now = func() time.Time { return now }
+	return blockList, &now
+}
+
+func TestMemoryTokenBlockListService_ExpiresTokensAfterTTL(t *testing.T) {
+	// Arrange
+	blockList, now := newTestMemoryBlockList()
+	assert.NoError(t, blockList.AddToBlockList(context.Background(), "token-id", time.Minute))
+
+	// Act
+	blockedBefore, _ := blockList.IsInBlockList(context.Background(), "token-id")
+	*now = now.Add(time.Minute)
+	blockedAfter, err := blockList.IsInBlockList(context.Background(), "token-id")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, blockedBefore)
+	assert.False(t, blockedAfter)
+}
+
+func TestMemoryTokenBlockListService_KeepsTokensWithoutTTL(t *testing.T) {
+	// Arrange
+	blockList, now := newTestMemoryBlockList()
+	assert.NoError(t, blockList.AddToBlockList(context.Background(), "token-id", 0))
+
+	// Act
+	*now = now.Add(365 * 24 * time.Hour)
+	blocked, err := blockList.IsInBlockList(context.Background(), "token-id")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+}
+
+func TestMemoryTokenBlockListService_RevokesSessionsUntilTTL(t *testing.T) {
+	// Arrange
+	blockList, now := newTestMemoryBlockList()
+	revokedAt := now.Add(1500 * time.Millisecond)
+	assert.NoError(t, blockList.RevokeUserSessions(context.Background(), "user-id", revokedAt, time.Hour))
+
+	// Act
+	stored, err := blockList.GetSessionsRevokedAt(context.Background(), "user-id")
+	*now = now.Add(time.Hour)
+	expired, _ := blockList.GetSessionsRevokedAt(context.Background(), "user-id")
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.True(t, stored.Equal(revokedAt.Truncate(time.Second)))
+	}
+	assert.Nil(t, expired)
+}
+
+func TestMemoryTokenBlockListService_SweepsExpiredEntriesOnWrite(t *testing.T) {
+	// Arrange
+	blockList, now := newTestMemoryBlockList()
+	assert.NoError(t, blockList.AddToBlockList(context.Background(), "expired", time.Second))
+	*now = now.Add(memorySweepInterval)
+
+	// Act
+	assert.NoError(t, blockList.AddToBlockList(context.Background(), "fresh", time.Hour))
+
+	// Assert
+	assert.NotContains(t, blockList.tokens, "expired")
+	assert.Contains(t, blockList.tokens, "fresh")
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"sync"
+)
+
+// MemoryMessageConsumer reads the messages added with Add in order, like a topic with a single partition.
+// Nothing else publishes to it, as there is no broker.
+type MemoryMessageConsumer struct {
+	mu       sync.Mutex
+	topic    string
+	messages chan *iservice.ConsumedMessage
+	offset   int64
+}
+
+func NewMemoryMessageConsumer(topic string) *MemoryMessageConsumer {
+	return &MemoryMessageConsumer{
+		topic:    topic,
+		messages: make(chan *iservice.ConsumedMessage, 1000),
+	}
+}
+
+// Add appends a message to the topic. It blocks while the topic holds as many unread messages as it can.
+func (c *MemoryMessageConsumer) Add(key []byte, value []byte, headers map[string]string) *iservice.ConsumedMessage {
+	c.mu.Lock()
+	message := &iservice.ConsumedMessage{Topic: c.topic, Offset: c.offset, Key: key, Value: value, Headers: headers}
+	c.offset++
+	c.mu.Unlock()
+	c.messages <- message
+	return message
+}
+
+func (c *MemoryMessageConsumer) Fetch(ctx context.Context) (*iservice.ConsumedMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case message := <-c.messages:
+		return message, nil
+	}
+}
+
+// Commit has nothing to do, the messages read are gone from the topic.
+func (c *MemoryMessageConsumer) Commit(*iservice.ConsumedMessage) error {
+	return nil
+}
+
+func (c *MemoryMessageConsumer) Close() error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// memoryMessageSenderCapacity bounds the captured messages; the oldest are dropped first.
+const memoryMessageSenderCapacity = 10000
+
+// CapturedMessage is a message the MemoryMessageSender would have produced to Kafka. Envelope is nil for raw
+// messages.
+type CapturedMessage struct {
+	Topic    string
+	Key      string
+	Value    []byte
+	Headers  map[string]string
+	Envelope *events.Envelope
+	SentAt   time.Time
+}
+
+// MemoryMessageSender captures messages instead of producing them to Kafka, so they can be inspected. Like the
+// Kafka sender, it rejects events that break their contract.
+type MemoryMessageSender struct {
+	mu       sync.Mutex
+	messages []CapturedMessage
+	logger   iservice.Logger
+	origin   events.Origin
+}
+
+func NewMemoryMessageSender(logger iservice.Logger, origin events.Origin) *MemoryMessageSender {
+	return &MemoryMessageSender{logger: logger, origin: origin}
+}
+
+func (s *MemoryMessageSender) Send(ctx context.Context, topic string, event events.Event) error {
+	envelope, err := events.NewEnvelope(s.origin, event, uuid.New(), time.Now())
+	if err != nil {
+		return err
+	}
+	return s.SendEnvelope(ctx, topic, envelope.Subject, envelope)
+}
+
+func (s *MemoryMessageSender) SendEnvelope(ctx context.Context, topic string, key string, envelope *events.Envelope) error {
+	if err := envelope.Validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	s.capture(ctx, CapturedMessage{Topic: topic, Key: key, Value: value, Headers: envelope.Headers(), Envelope: envelope})
+	return nil
+}
+
+func (s *MemoryMessageSender) SendRaw(ctx context.Context, topic string, key []byte, value []byte, headers map[string]string) error {
+	copied := make(map[string]string, len(headers))
+	for name, header := range headers {
+		copied[name] = header
+	}
+	s.capture(ctx, CapturedMessage{Topic: topic, Key: string(key), Value: append([]byte(nil), value...), Headers: copied})
+	return nil
+}
+
+func (s *MemoryMessageSender) capture(ctx context.Context, message CapturedMessage) {
+	message.SentAt = time.Now()
+	s.mu.Lock()
+	if len(s.messages) == memoryMessageSenderCapacity {
+		s.messages = append(s.messages[:0], s.messages[1:]...)
+	}
+	s.messages = append(s.messages, message)
+	s.mu.Unlock()
+	s.logger.Info("Captured message for topic %s with key %s", message.Topic, message.Key)
+}
+
+// Messages returns the captured messages, in the order they were sent.
+func (s *MemoryMessageSender) Messages() []CapturedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CapturedMessage(nil), s.messages...)
+}
+
+// MessagesOn returns the captured messages of the topic, in the order they were sent.
+func (s *MemoryMessageSender) MessagesOn(topic string) []CapturedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := make([]CapturedMessage, 0)
+	for _, message := range s.messages {
+		if message.Topic == topic {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Reset forgets the captured messages.
+func (s *MemoryMessageSender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryMessageSender_CapturesMessagesByTopic(t *testing.T) {
+	// Arrange
+	sender := NewMemoryMessageSender(service_mock.NewPermissiveMockLogger(), service_mock.FakeOrigin)
+	userID := uuid.New()
+	envelope, err := events.NewEnvelope(service_mock.FakeOrigin, events.AccountCreated{UserID: userID, Email: "someone@example.com"},
+		uuid.New(), time.Now())
+	assert.NoError(t, err)
+
+	// Act
+	assert.NoError(t, sender.SendEnvelope(context.Background(), "account-created", envelope.Subject, envelope))
+	assert.NoError(t, sender.SendRaw(context.Background(), "dead-letters", []byte("key"), []byte("value"), map[string]string{"reason": "x"}))
+
+	// Assert
+	assert.Len(t, sender.Messages(), 2)
+	created := sender.MessagesOn("account-created")
+	if assert.Len(t, created, 1) {
+		assert.Equal(t, userID.String(), created[0].Key)
+		assert.Equal(t, envelope, created[0].Envelope)
+		assert.Contains(t, string(created[0].Value), `"email":"someone@example.com"`)
+	}
+	raw := sender.MessagesOn("dead-letters")
+	if assert.Len(t, raw, 1) {
+		assert.Nil(t, raw[0].Envelope)
+		assert.Equal(t, []byte("value"), raw[0].Value)
+		assert.Equal(t, "x", raw[0].Headers["reason"])
+	}
+	sender.Reset()
+	assert.Empty(t, sender.Messages())
+}
+
+func TestMemoryMessageSender_RejectsInvalidEnvelope(t *testing.T) {
+	// Arrange
+	sender := NewMemoryMessageSender(service_mock.NewPermissiveMockLogger(), service_mock.FakeOrigin)
+
+	// Act
+	err := sender.SendEnvelope(context.Background(), "account-created", "key", &events.Envelope{})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Empty(t, sender.Messages())
+}
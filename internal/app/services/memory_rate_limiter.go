@@ -0,0 +1,84 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimiter is the sliding window of the Redis rate limiter, counting the requests of this process
+// only.
+type MemoryRateLimiter struct {
+	mu sync.Mutex
+	// requests holds the times of the accepted requests of each key, oldest first
+	requests  map[string][]time.Time
+	windows   map[string]time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		requests: make(map[string][]time.Time),
+		windows:  make(map[string]time.Duration),
+		now:      time.Now,
+	}
+}
+
+func (r *MemoryRateLimiter) Allow(_ context.Context, key string, limit iservice.RateLimit) (*iservice.RateLimitResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.sweep(now)
+
+	requests := trimWindow(r.requests[key], now, limit.Window)
+	allowed := len(requests) < limit.Requests
+	if allowed {
+		requests = append(requests, now)
+	}
+	r.requests[key] = requests
+	r.windows[key] = limit.Window
+
+	resetAt := now.Add(limit.Window)
+	if len(requests) > 0 {
+		resetAt = requests[0].Add(limit.Window)
+	}
+	result := &iservice.RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit.Requests,
+		Remaining:  limit.Requests - len(requests),
+		ResetAfter: resetAt.Sub(now),
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if !allowed {
+		result.RetryAfter = result.ResetAfter
+	}
+	return result, nil
+}
+
+// sweep forgets the keys whose window is empty, like the expiring Redis keys. The caller holds the lock.
+func (r *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < memorySweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, requests := range r.requests {
+		if len(trimWindow(requests, now, r.windows[key])) == 0 {
+			delete(r.requests, key)
+			delete(r.windows, key)
+		}
+	}
+}
+
+// trimWindow drops the requests that left the window ending at now.
+func trimWindow(requests []time.Time, now time.Time, window time.Duration) []time.Time {
+	start := now.Add(-window)
+	kept := 0
+	for kept < len(requests) && !requests[kept].After(start) {
+		kept++
+	}
+	return requests[kept:]
+}
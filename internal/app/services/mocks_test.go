@@ -5,6 +5,7 @@ import (
 	"automation-hub-idp/internal/app/utils"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MockLogger struct {
@@ -98,10 +99,38 @@ func (m *MockUserRepository) FindAll(p utils.Pagination) ([]*models.User, error)
 	return args.Get(0).([]*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindByResetToken(token string) (*models.User, error) {
+func (m *MockUserRepository) FindByEmailChangeToken(token string) (*models.User, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
+
+func (m *MockUserRepository) FindByEmailRevertToken(token string) (*models.User, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) IncrementFailedAttempts(id uuid.UUID, attemptAt time.Time) (int, error) {
+	args := m.Called(id, attemptAt)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) ResetFailedAttempts(id uuid.UUID, attemptAt time.Time) error {
+	args := m.Called(id, attemptAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) BlockUntil(id uuid.UUID, blockedUntil time.Time) (bool, error) {
+	args := m.Called(id, blockedUntil)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) UnblockIfExpired(id uuid.UUID, now time.Time) error {
+	args := m.Called(id, now)
+	return args.Error(0)
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"time"
+)
+
+const rateLimitKeyPrefix = "rate_limit:"
+
+// slidingWindowScript keeps one sorted set entry per accepted request, scored by its time in
+// milliseconds. Entries older than the window are trimmed before counting, so the check and
+// the insert happen atomically across every instance sharing the Redis server.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local resetAt = now + window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	resetAt = tonumber(oldest[2]) + window
+end
+return {allowed, count, resetAt}
+`)
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(client *redis.Client) iservice.RateLimiter {
+	return &redisRateLimiter{
+		client: client,
+	}
+}
+
+func (r *redisRateLimiter) Allow(ctx context.Context, key string, limit iservice.RateLimit) (*iservice.RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	window := limit.Window.Milliseconds()
+	values, err := slidingWindowScript.Run(ctx, r.client, []string{rateLimitKeyPrefix + key},
+		now, window, limit.Requests, uuid.New().String()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, count, resetAt := values[0] == 1, int(values[1]), values[2]
+	result := &iservice.RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit.Requests,
+		Remaining:  limit.Requests - count,
+		ResetAfter: time.Duration(resetAt-now) * time.Millisecond,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if !allowed {
+		result.RetryAfter = result.ResetAfter
+	}
+	return result, nil
+}
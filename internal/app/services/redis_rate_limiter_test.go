@@ -0,0 +1,69 @@
+package services
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestRateLimiter(t *testing.T) (iservice.RateLimiter, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisRateLimiter(client), server
+}
+
+func TestRedisRateLimiter_AllowsUpToLimit(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t)
+	limit := iservice.RateLimit{Requests: 3, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(context.Background(), "login:ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(context.Background(), "login:ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, result.RetryAfter, time.Minute)
+}
+
+func TestRedisRateLimiter_KeysAreIndependent(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t)
+	limit := iservice.RateLimit{Requests: 1, Window: time.Minute}
+
+	first, err := limiter.Allow(context.Background(), "login:ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	second, err := limiter.Allow(context.Background(), "login:ip:10.0.0.2", limit)
+	assert.NoError(t, err)
+
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+}
+
+func TestRedisRateLimiter_WindowSlides(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t)
+	limit := iservice.RateLimit{Requests: 1, Window: 50 * time.Millisecond}
+
+	result, err := limiter.Allow(context.Background(), "register:ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = limiter.Allow(context.Background(), "register:ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	time.Sleep(60 * time.Millisecond)
+
+	result, err = limiter.Allow(context.Background(), "register:ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
@@ -0,0 +1,36 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRecorder struct {
+	mock.Mock
+}
+
+// NewPermissiveMockAuditRecorder returns an audit recorder mock that accepts every event.
+func NewPermissiveMockAuditRecorder() *MockAuditRecorder {
+	recorder := new(MockAuditRecorder)
+	recorder.On("Record", mock.Anything).Maybe()
+	return recorder
+}
+
+func (m *MockAuditRecorder) Record(_ context.Context, event dto.AuditEvent) {
+	m.Called(event)
+}
+
+// Recorded returns the events of the given type recorded so far.
+func (m *MockAuditRecorder) Recorded(eventType string) []dto.AuditEvent {
+	var events []dto.AuditEvent
+	for _, call := range m.Calls {
+		if call.Method != "Record" {
+			continue
+		}
+		if event := call.Arguments.Get(0).(dto.AuditEvent); event.Type == eventType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
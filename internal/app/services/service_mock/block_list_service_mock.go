@@ -1,6 +1,7 @@
 package service_mock
 
 import (
+	"context"
 	"github.com/stretchr/testify/mock"
 	"time"
 )
@@ -9,12 +10,25 @@ type MockBlockListService struct {
 	mock.Mock
 }
 
-func (m *MockBlockListService) AddToBlockList(jwtUUID string, expirationTime time.Duration) error {
+func (m *MockBlockListService) AddToBlockList(_ context.Context, jwtUUID string, expirationTime time.Duration) error {
 	args := m.Called(jwtUUID, expirationTime)
 	return args.Error(0)
 }
 
-func (m *MockBlockListService) IsInBlockList(jwtUUID string) (bool, error) {
+func (m *MockBlockListService) IsInBlockList(_ context.Context, jwtUUID string) (bool, error) {
 	args := m.Called(jwtUUID)
 	return args.Get(0).(bool), args.Error(1)
 }
+
+func (m *MockBlockListService) RevokeUserSessions(_ context.Context, userID string, revokedAt time.Time, expirationTime time.Duration) error {
+	args := m.Called(userID, revokedAt, expirationTime)
+	return args.Error(0)
+}
+
+func (m *MockBlockListService) GetSessionsRevokedAt(_ context.Context, userID string) (*time.Time, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
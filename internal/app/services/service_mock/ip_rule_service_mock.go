@@ -0,0 +1,46 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIPRuleService struct {
+	mock.Mock
+}
+
+// NewPermissiveMockIPRuleService returns an IP rule mock that allows every address.
+func NewPermissiveMockIPRuleService() *MockIPRuleService {
+	ipRules := new(MockIPRuleService)
+	ipRules.On("IsAllowed", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+	return ipRules
+}
+
+func (m *MockIPRuleService) CreateRule(_ context.Context, rule models.IPRule, actorID uuid.UUID, client dto.ClientInfo) (*models.IPRule, error) {
+	args := m.Called(rule, actorID, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.IPRule), args.Error(1)
+}
+
+func (m *MockIPRuleService) DeleteRule(_ context.Context, id uuid.UUID, actorID uuid.UUID, client dto.ClientInfo) error {
+	args := m.Called(id, actorID, client)
+	return args.Error(0)
+}
+
+func (m *MockIPRuleService) ListRules(_ context.Context) ([]*models.IPRule, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.IPRule), args.Error(1)
+}
+
+func (m *MockIPRuleService) IsAllowed(_ context.Context, ip string, scope string, organizationID *uuid.UUID) (bool, error) {
+	args := m.Called(ip, scope, organizationID)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,32 @@
+package service_mock
+
+import "github.com/stretchr/testify/mock"
+
+type MockLogger struct {
+	mock.Mock
+}
+
+// NewPermissiveMockLogger returns a logger mock that accepts any call.
+func NewPermissiveMockLogger() *MockLogger {
+	logger := new(MockLogger)
+	for _, method := range []string{"Info", "Error", "Warn", "Debug"} {
+		logger.On(method, mock.Anything, mock.Anything).Maybe()
+	}
+	return logger
+}
+
+func (m *MockLogger) Info(message string, args ...interface{}) {
+	m.Called(message, args)
+}
+
+func (m *MockLogger) Error(message string, args ...interface{}) {
+	m.Called(message, args)
+}
+
+func (m *MockLogger) Warn(message string, args ...interface{}) {
+	m.Called(message, args)
+}
+
+func (m *MockLogger) Debug(message string, args ...interface{}) {
+	m.Called(message, args)
+}
@@ -0,0 +1,56 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockLoginHistoryService struct {
+	mock.Mock
+}
+
+// NewPermissiveMockLoginHistoryService returns a login history mock that records any attempt as a known device.
+func NewPermissiveMockLoginHistoryService() *MockLoginHistoryService {
+	loginHistory := new(MockLoginHistoryService)
+	loginHistory.On("RecordAttempt", mock.Anything, mock.Anything, mock.Anything).Return(&models.LoginAttempt{}, nil).Maybe()
+	return loginHistory
+}
+
+func (m *MockLoginHistoryService) RecordAttempt(_ context.Context, userID *uuid.UUID, outcome string, client dto.ClientInfo) (*models.LoginAttempt, error) {
+	args := m.Called(userID, outcome, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginHistoryService) IsNewDevice(_ context.Context, userID uuid.UUID, client dto.ClientInfo) (bool, error) {
+	args := m.Called(userID, client)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoginHistoryService) GetHistory(_ context.Context, userID uuid.UUID, p utils.Pagination) ([]*models.LoginAttempt, error) {
+	args := m.Called(userID, p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginHistoryService) GetLastSuccessfulLogin(_ context.Context, userID uuid.UUID) (*models.LoginAttempt, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LoginAttempt), args.Error(1)
+}
+
+func (m *MockLoginHistoryService) CountRecentFailuresFromIP(_ context.Context, ip string, since time.Time) (int64, error) {
+	args := m.Called(ip, since)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,60 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"sync"
+)
+
+// FakeMessageConsumer hands out the messages added with Add in order, like a topic with one partition.
+type FakeMessageConsumer struct {
+	mu        sync.Mutex
+	messages  chan *iservice.ConsumedMessage
+	offset    int64
+	committed []iservice.ConsumedMessage
+}
+
+func NewFakeMessageConsumer() *FakeMessageConsumer {
+	return &FakeMessageConsumer{messages: make(chan *iservice.ConsumedMessage, 100)}
+}
+
+// Add appends a message to the topic and returns it with its offset.
+func (c *FakeMessageConsumer) Add(topic string, key []byte, value []byte, headers map[string]string) *iservice.ConsumedMessage {
+	copied := make(map[string]string, len(headers))
+	for name, header := range headers {
+		copied[name] = header
+	}
+	c.mu.Lock()
+	message := &iservice.ConsumedMessage{Topic: topic, Offset: c.offset, Key: key, Value: value, Headers: copied}
+	c.offset++
+	c.mu.Unlock()
+	c.messages <- message
+	return message
+}
+
+func (c *FakeMessageConsumer) Fetch(ctx context.Context) (*iservice.ConsumedMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case message := <-c.messages:
+		return message, nil
+	}
+}
+
+func (c *FakeMessageConsumer) Commit(message *iservice.ConsumedMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.committed = append(c.committed, *message)
+	return nil
+}
+
+func (c *FakeMessageConsumer) Close() error {
+	return nil
+}
+
+// Committed returns the committed messages, in order.
+func (c *FakeMessageConsumer) Committed() []iservice.ConsumedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]iservice.ConsumedMessage(nil), c.committed...)
+}
@@ -0,0 +1,102 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type SentMessage struct {
+	Topic    string
+	Key      string
+	Envelope *events.Envelope
+}
+
+type RawMessage struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// FakeMessageSender keeps sent messages in memory instead of talking to Kafka. Like the Kafka sender it
+// rejects events that break their contract. Sends fail while an error is set with Fail, or with FailKey
+// for messages of one key.
+type FakeMessageSender struct {
+	mu        sync.Mutex
+	sent      []SentMessage
+	raw       []RawMessage
+	err       error
+	keyErrors map[string]error
+}
+
+// FakeOrigin is the origin of the events the fakes and the tests build.
+var FakeOrigin = events.Origin{Source: "fake", SchemaBaseURI: "https://schemas.automation-hub.example/idp/"}
+
+func NewFakeMessageSender() *FakeMessageSender {
+	return &FakeMessageSender{keyErrors: make(map[string]error)}
+}
+
+func (s *FakeMessageSender) Send(ctx context.Context, topic string, event events.Event) error {
+	envelope, err := events.NewEnvelope(FakeOrigin, event, uuid.New(), time.Now())
+	if err != nil {
+		return err
+	}
+	return s.SendEnvelope(ctx, topic, envelope.Subject, envelope)
+}
+
+func (s *FakeMessageSender) SendEnvelope(_ context.Context, topic string, key string, envelope *events.Envelope) error {
+	if err := envelope.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.keyErrors[key]; err != nil {
+		return err
+	}
+	s.sent = append(s.sent, SentMessage{Topic: topic, Key: key, Envelope: envelope})
+	return nil
+}
+
+func (s *FakeMessageSender) SendRaw(_ context.Context, topic string, key []byte, value []byte, headers map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.raw = append(s.raw, RawMessage{Topic: topic, Key: key, Value: value, Headers: headers})
+	return nil
+}
+
+// Fail makes every following send return err. A nil error lets sends succeed again.
+func (s *FakeMessageSender) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// FailKey makes the following sends with the key return err. A nil error lets them succeed again.
+func (s *FakeMessageSender) FailKey(key string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keyErrors[key] = err
+}
+
+// Sent returns the messages sent successfully, in order.
+func (s *FakeMessageSender) Sent() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// RawSent returns the raw messages sent successfully, in order.
+func (s *FakeMessageSender) RawSent() []RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RawMessage(nil), s.raw...)
+}
@@ -0,0 +1,21 @@
+package service_mock
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMessageSender struct {
+	mock.Mock
+}
+
+func (m *MockMessageSender) Send(_ context.Context, topic string, event events.Event) error {
+	args := m.Called(topic, event)
+	return args.Error(0)
+}
+
+func (m *MockMessageSender) SendEnvelope(_ context.Context, topic string, key string, envelope *events.Envelope) error {
+	args := m.Called(topic, key, envelope)
+	return args.Error(0)
+}
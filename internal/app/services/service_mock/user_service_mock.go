@@ -1,72 +1,113 @@
 package service_mock
 
 import (
+	"automation-hub-idp/internal/app/dto"
 	"automation-hub-idp/internal/app/models"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) CreateUser(user models.User) (*models.User, error) {
+func (m *MockUserService) CreateUser(_ context.Context, user models.User) (*models.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (m *MockUserService) GetUserByID(_ context.Context, id uuid.UUID) (*models.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetUserByEmail(email string) (*models.User, error) {
+func (m *MockUserService) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
 	args := m.Called(email)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetUserByResetToken(token string) (*models.User, error) {
+func (m *MockUserService) GetUserByEmailChangeToken(_ context.Context, token string) (*models.User, error) {
 	args := m.Called(token)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(user models.User) (*models.User, error) {
+func (m *MockUserService) GetUserByEmailRevertToken(_ context.Context, token string) (*models.User, error) {
+	args := m.Called(token)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) UpdateUser(_ context.Context, user models.User) (*models.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) DeleteUser(id uuid.UUID) error {
+func (m *MockUserService) LockUser(_ context.Context, user models.User, reason string) (*models.User, error) {
+	args := m.Called(user, reason)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) DeleteUser(_ context.Context, id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockUserService) GetAllUsers(p *utils.Pagination) ([]*models.User, error) {
+func (m *MockUserService) GetAllUsers(_ context.Context, p *utils.Pagination) ([]*models.User, error) {
 	args := m.Called(p)
 	return args.Get(0).([]*models.User), args.Error(1)
 }
 
-func (m *MockUserService) ResetPassword(email string, opts ...utils.PasswordResetOptions) error {
+func (m *MockUserService) ResetPassword(_ context.Context, email string, opts ...utils.PasswordResetOptions) error {
 	args := m.Called(email, opts)
 	return args.Error(0)
 }
 
-func (m *MockUserService) UpdatePassword(id uuid.UUID, newPassword string) error {
+func (m *MockUserService) UpdatePassword(_ context.Context, id uuid.UUID, newPassword string) error {
 	args := m.Called(id, newPassword)
 	return args.Error(0)
 }
 
-func (m *MockUserService) VerifyPasswordResetToken(token string) error {
+func (m *MockUserService) ChangeRole(_ context.Context, actorID, id uuid.UUID, role string, client dto.ClientInfo) (*models.User, error) {
+	args := m.Called(actorID, id, role, client)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) VerifyPasswordResetToken(_ context.Context, token string) error {
 	args := m.Called(token)
 	return args.Error(0)
 }
 
-func (m *MockUserService) BlockUser(id uuid.UUID) error {
+func (m *MockUserService) BlockUser(_ context.Context, id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockUserService) UnblockUser(id uuid.UUID) error {
+func (m *MockUserService) UnblockUser(_ context.Context, id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *MockUserService) IncrementFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	args := m.Called(id, attemptAt)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserService) ResetFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) error {
+	args := m.Called(id, attemptAt)
+	return args.Error(0)
+}
+
+func (m *MockUserService) BlockUntil(_ context.Context, id uuid.UUID, blockedUntil time.Time, reason string) (bool, error) {
+	args := m.Called(id, blockedUntil, reason)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserService) UnblockIfExpired(_ context.Context, id uuid.UUID, now time.Time) error {
+	args := m.Called(id, now)
+	return args.Error(0)
+}
@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+const spanKey = "tracing:span"
+
+// Middleware continues the trace of the caller, or starts one, with a server span per request. Spans are
+// named after the route template, so IDs and tokens in paths stay out of the traces. Handlers find the span
+// in the context of the request.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		ctx, span := tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(c.Request.Method), semconv.HTTPRoute(route)))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// InstrumentDB adds a client span for every statement of the database. Statements run with a context, via
+// WithContext, become children of its span. The SQL is recorded with placeholders, never with its values.
+func InstrumentDB(db *gorm.DB, dbName string) error {
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			_, span := tracer().Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBName(dbName), semconv.DBOperation(operation)))
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		span.SetAttributes(semconv.DBSQLTable(tx.Statement.Table), semconv.DBStatement(tx.Statement.SQL.String()))
+		recordError(span, tx.Error, tx.Error == gorm.ErrRecordNotFound)
+		span.End()
+	}
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", after),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", after),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", after),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", after),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedisHook adds a client span for every command and pipeline of a Redis client. Only the command names are
+// recorded, the keys are token IDs and user IDs. A nil reply is not an error.
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = tracer().Start(ctx, "redis."+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation(cmd.Name())))
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	recordError(span, cmd.Err(), cmd.Err() == redis.Nil)
+	span.End()
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = tracer().Start(ctx, "redis.pipeline", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation("pipeline")))
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	span := trace.SpanFromContext(ctx)
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			recordError(span, cmd.Err(), false)
+			break
+		}
+	}
+	span.End()
+	return nil
+}
+
+// StartProducerSpan starts the span of publishing to the topic and writes its trace context into headers,
+// so consumers continue the trace. Without a span in ctx the span continues the trace already in headers,
+// which keeps forwarded messages, like dead letters, in the trace of the original. Links tie the span to the
+// spans of the work a batch carries. The caller ends the span once the broker acknowledged the message.
+func StartProducerSpan(ctx context.Context, topic string, headers map[string]string, links ...trace.Link) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+	}
+	ctx, span := tracer().Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingSystemKafka, semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationPublish), trace.WithLinks(links...))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	return span
+}
+
+// TraceParent returns the W3C traceparent of the span in ctx, "" without one. Stored with work that is done
+// later, like an outbox message, it lets that work continue the trace.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ContinueTrace returns ctx with the span of the traceparent as the remote parent of the spans started
+// with it. An empty or invalid traceparent leaves ctx as it is.
+func ContinueTrace(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
+
+// StartConsumerSpan starts the span of processing a message, continuing the trace found in its headers.
+func StartConsumerSpan(ctx context.Context, topic string, headers map[string]string) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+	return tracer().Start(ctx, topic+" process", trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconv.MessagingSystemKafka, semconv.MessagingDestinationName(topic),
+			semconv.MessagingOperationDeliver))
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	recordError(span, err, false)
+	span.End()
+}
+
+// recordError marks the span as failed, unless err is nil or an expected miss.
+func recordError(span trace.Span, err error, miss bool) {
+	if err == nil || miss {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
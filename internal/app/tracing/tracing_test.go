@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const callerTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordSpans makes the test's in-memory exporter the global one for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware_ContinuesTraceOfCaller(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	var handlerSpan trace.SpanContext
+	router.GET("/reset/:token", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+	request := httptest.NewRequest(http.MethodGet, "/reset/secret-token", nil)
+	request.Header.Set("traceparent", callerTraceParent)
+
+	// Act
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	// Assert
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "GET /reset/:token", span.Name)
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+		assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID())
+		assert.Equal(t, int64(500), attributeValue(span, "http.response.status_code").AsInt64())
+		assert.Equal(t, codes.Error, span.Status.Code)
+	}
+}
+
+func TestRedisHook_TracesCommands(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	client.AddHook(RedisHook{})
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "login")
+
+	// Act
+	assert.NoError(t, client.Set(ctx, "jti", 1, 0).Err())
+	assert.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, "counter")
+		return nil
+	})
+	assert.NoError(t, err)
+	parent.End()
+
+	// Assert
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 4) {
+		for i, name := range []string{"redis.set", "redis.get", "redis.pipeline"} {
+			assert.Equal(t, name, spans[i].Name)
+			assert.Equal(t, parent.SpanContext().SpanID(), spans[i].Parent.SpanID())
+			assert.Equal(t, codes.Unset, spans[i].Status.Code)
+		}
+	}
+}
+
+func TestProducerAndConsumerSpans_ShareTrace(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	headers := map[string]string{"ce_type": "com.automationhub.idp.command.force_logout"}
+
+	// Act
+	producer := StartProducerSpan(context.Background(), "idp-commands", headers)
+	End(producer, nil)
+	_, consumer := StartConsumerSpan(context.Background(), "idp-commands", headers)
+	consumer.End()
+
+	// Assert
+	assert.Contains(t, headers, "traceparent")
+	assert.Equal(t, "com.automationhub.idp.command.force_logout", headers["ce_type"])
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, trace.SpanKindProducer, spans[0].SpanKind)
+		assert.Equal(t, trace.SpanKindConsumer, spans[1].SpanKind)
+		assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+		assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	}
+}
+
+func TestStartProducerSpan_ContinuesTraceOfForwardedMessage(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	headers := map[string]string{"traceparent": callerTraceParent}
+
+	// Act
+	span := StartProducerSpan(context.Background(), "idp-commands-dead-letter", headers)
+	End(span, assert.AnError)
+
+	// Assert
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.NotEqual(t, callerTraceParent, headers["traceparent"])
+	}
+}
+
+func TestContinueTrace_ContinuesTraceOfTraceParent(t *testing.T) {
+	// Arrange
+	exporter := recordSpans(t)
+	ctx, request := tracer().Start(context.Background(), "POST /auth/register")
+	traceParent := TraceParent(ctx)
+	request.End()
+
+	// Act
+	span := StartProducerSpan(ContinueTrace(context.Background(), traceParent), "account-created", map[string]string{})
+	End(span, nil)
+
+	// Assert
+	assert.Empty(t, TraceParent(context.Background()))
+	spans := exporter.GetSpans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+		assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	}
+}
@@ -3,8 +3,10 @@ package users
 import (
 	"automation-hub-idp/internal/app/models"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MockLogger struct {
@@ -31,7 +33,7 @@ type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+func (m *MockUserRepository) FindByID(_ context.Context, id uuid.UUID) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -39,7 +41,7 @@ func (m *MockUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindByEmail(email string) (*models.User, error) {
+func (m *MockUserRepository) FindByEmail(_ context.Context, email string) (*models.User, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -47,12 +49,12 @@ func (m *MockUserRepository) FindByEmail(email string) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Create(user *models.User) (*models.User, error) {
+func (m *MockUserRepository) Create(_ context.Context, user *models.User) (*models.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(user *models.User) (*models.User, error) {
+func (m *MockUserRepository) Update(_ context.Context, user *models.User) (*models.User, error) {
 	args := m.Called(user)
 	if fn, ok := args.Get(0).(func(mock.Arguments) (*models.User, error)); ok {
 		return fn(args)
@@ -60,17 +62,17 @@ func (m *MockUserRepository) Update(user *models.User) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Delete(id uuid.UUID) error {
+func (m *MockUserRepository) Delete(_ context.Context, id uuid.UUID) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) FindAll(p utils.Pagination) ([]*models.User, error) {
+func (m *MockUserRepository) FindAll(_ context.Context, p utils.Pagination) ([]*models.User, error) {
 	args := m.Called(p)
 	return args.Get(0).([]*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindByResetToken(token string) (*models.User, error) {
+func (m *MockUserRepository) FindByEmailChangeToken(_ context.Context, token string) (*models.User, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -78,6 +80,34 @@ func (m *MockUserRepository) FindByResetToken(token string) (*models.User, error
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) FindByEmailRevertToken(_ context.Context, token string) (*models.User, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) IncrementFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	args := m.Called(id, attemptAt)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) ResetFailedAttempts(_ context.Context, id uuid.UUID, attemptAt time.Time) error {
+	args := m.Called(id, attemptAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) BlockUntil(_ context.Context, id uuid.UUID, blockedUntil time.Time) (bool, error) {
+	args := m.Called(id, blockedUntil)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) UnblockIfExpired(_ context.Context, id uuid.UUID, now time.Time) error {
+	args := m.Called(id, now)
+	return args.Error(0)
+}
+
 type MockPasswordHasher struct {
 	mock.Mock
 }
@@ -2,18 +2,30 @@ package users
 
 import (
 	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
 )
 
+// AccountService runs the account changes with side effects beyond the user record. Email changes are
+// never applied directly, and password changes invalidate outstanding reset tokens and are audited.
+type AccountService interface {
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail string) (*models.User, error)
+	ChangePassword(ctx context.Context, accessToken string, newPassword string, client dto.ClientInfo) error
+}
+
 type Handler struct {
-	userService UserService
+	userService    UserService
+	accountService AccountService
 }
 
-func NewHandler(userService UserService) *Handler {
+func NewHandler(userService UserService, accountService AccountService) *Handler {
 	return &Handler{
-		userService: userService,
+		userService:    userService,
+		accountService: accountService,
 	}
 }
 
@@ -50,7 +62,8 @@ func (h *Handler) Update(c *gin.Context) {
 
 	// check if userRequest.password is not empty
 	if user.Password != "" {
-		err := h.userService.UpdatePassword(userID, user.Password)
+		accessToken, _ := c.Cookie("access_token")
+		err := h.accountService.ChangePassword(c.Request.Context(), accessToken, user.Password, utils.ClientInfo(c))
 		if err != nil {
 			errorResponse.Message = "Error updating user"
 			errorResponse.ErrorCode = http.StatusInternalServerError
@@ -59,7 +72,7 @@ func (h *Handler) Update(c *gin.Context) {
 		}
 	}
 
-	userToUpdate, err := h.userService.GetUserByID(userID)
+	userToUpdate, err := h.userService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		errorResponse.Message = "Error updating user"
 		errorResponse.ErrorCode = http.StatusInternalServerError
@@ -67,16 +80,21 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
-	userToUpdate.Email = user.Email
-
-	updatedUser, err := h.userService.UpdateUser(*userToUpdate)
-	if err != nil {
-		c.Status(http.StatusInternalServerError)
-		return
+	// A new email only becomes active once confirmed from the new address
+	if user.Email != "" && user.Email != userToUpdate.Email {
+		userToUpdate, err = h.accountService.RequestEmailChange(c.Request.Context(), userID, user.Email)
+		if err != nil {
+			errorResponse.Message = err.Error()
+			errorResponse.ErrorCode = http.StatusBadRequest
+			c.JSON(http.StatusBadRequest, errorResponse)
+			return
+		}
 	}
+
 	userResponse := dto.UserResponse{
-		ID:    updatedUser.ID,
-		Email: updatedUser.Email,
+		ID:           userToUpdate.ID,
+		Email:        userToUpdate.Email,
+		PendingEmail: userToUpdate.PendingEmail,
 	}
 	c.JSON(http.StatusOK, userResponse)
 }
@@ -101,7 +119,7 @@ func (h *Handler) GetCurrentUser(c *gin.Context) {
 	}
 	userID := temp.(uuid.UUID)
 
-	user, err := h.userService.GetUserByID(userID)
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		errorResponse.Message = "User not found"
 		errorResponse.ErrorCode = http.StatusNotFound
@@ -114,3 +132,45 @@ func (h *Handler) GetCurrentUser(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, userResponse)
 }
+
+// ChangeRole
+// @Summary ChangeRole
+// @Description Sets the role of a user. Admins cannot change their own role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param body body dto.RoleChangeRequest true "New role"
+// @Success 200 {object} dto.UserRoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /admin/users/{id}/role [put]
+func (h *Handler) ChangeRole(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		errorResponse.Message = "Invalid user ID"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	var request dto.RoleChangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	user, err := h.userService.ChangeRole(c.Request.Context(), c.MustGet("userID").(uuid.UUID), id, request.Role, utils.ClientInfo(c))
+	if err != nil {
+		errorResponse.Message = err.Error()
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+	c.JSON(http.StatusOK, dto.UserRoleResponse{
+		ID:    user.ID,
+		Email: user.Email,
+		Role:  user.Role,
+	})
+}
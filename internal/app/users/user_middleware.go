@@ -0,0 +1,32 @@
+package users
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// RequireRole only lets users with one of the roles through. It must run after the auth middleware,
+// which puts the userID in the context.
+func RequireRole(userService UserService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		temp, ok := c.Get("userID")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+			return
+		}
+		user, err := userService.GetUserByID(c.Request.Context(), temp.(uuid.UUID))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+			return
+		}
+		for _, role := range roles {
+			if user.Role == role {
+				c.Set("userRole", user.Role)
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	}
+}
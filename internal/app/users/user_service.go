@@ -1,88 +1,143 @@
 package users
 
 import (
-	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
 	"automation-hub-idp/internal/app/models"
-	"automation-hub-idp/internal/app/repositories"
 	"automation-hub-idp/internal/app/repositories/irepository"
-	"automation-hub-idp/internal/app/services"
 	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/tracing"
 	"automation-hub-idp/internal/app/utils"
-	"automation-hub-idp/internal/infra"
+	"context"
 	"errors"
 	"github.com/google/uuid"
+	"time"
 )
 
 type userServiceImpl struct {
 	userRepo irepository.UserRepository
+	uow      irepository.UnitOfWork
+	audit    iservice.AuditRecorder
 	logger   iservice.Logger
 	hasher   utils.PasswordHasher
+	clock    utils.Clock
+	ids      utils.IDGenerator
+	// origin, accountCreatedTopic and accountBlockedTopic address the domain events added to the outbox
+	origin              events.Origin
+	accountCreatedTopic string
+	accountBlockedTopic string
 }
 
-func NewUserService(repo irepository.UserRepository, logger iservice.Logger, hasher utils.PasswordHasher) UserService {
+func NewUserService(repo irepository.UserRepository, uow irepository.UnitOfWork, audit iservice.AuditRecorder,
+	logger iservice.Logger, hasher utils.PasswordHasher, clock utils.Clock, ids utils.IDGenerator, origin events.Origin,
+	accountCreatedTopic, accountBlockedTopic string) UserService {
 	return &userServiceImpl{
-		userRepo: repo,
-		logger:   logger,
-		hasher:   hasher,
+		userRepo:            repo,
+		uow:                 uow,
+		audit:               audit,
+		logger:              logger,
+		hasher:              hasher,
+		clock:               clock,
+		ids:                 ids,
+		origin:              origin,
+		accountCreatedTopic: accountCreatedTopic,
+		accountBlockedTopic: accountBlockedTopic,
 	}
 }
 
-func GetDefaultUserService() (UserService, error) {
-	logger, err := services.NewKafkaLogger(config.KafkaConfig.BrokersAddr, config.KafkaConfig.LoggerTopic)
+// addEvent stores a domain event of the user in the outbox. It is published once the transaction commits.
+func (s *userServiceImpl) addEvent(ctx context.Context, repos irepository.Repositories, userID uuid.UUID, topic string, event events.Event) error {
+	envelope, err := events.NewEnvelope(s.origin, event, s.ids.NewID(), s.clock.Now())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	database, err := infra.GetDefaultDB()
+	message, err := models.NewOutboxMessage(models.AggregateUser, userID, topic, envelope)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	userRepository := repositories.NewGormUserRepository(database, logger)
-	hasher := config.AuthenticationConfig.PasswordHasher
-	return NewUserService(userRepository, logger, hasher), nil
+	message.TraceParent = tracing.TraceParent(ctx)
+	return repos.Outbox.Add(ctx, message)
 }
 
-func (s *userServiceImpl) CreateUser(user models.User) (*models.User, error) {
-	if existingUser, _ := s.userRepo.FindByEmail(user.Email); existingUser != nil {
-		s.logger.Error("User already exists with email: %s", user.Email)
-		return nil, errors.New("user already exists")
-	}
+func (s *userServiceImpl) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	var createdUser *models.User
+	err := s.uow.Do(ctx, func(repos irepository.Repositories) error {
+		if existingUser, _ := repos.Users.FindByEmail(ctx, user.Email); existingUser != nil {
+			s.logger.Error("User already exists with email: %s", user.Email)
+			return errors.New("user already exists")
+		}
 
-	return s.userRepo.Create(&user)
+		var err error
+		createdUser, err = repos.Users.Create(ctx, &user)
+		if err != nil {
+			return err
+		}
+		event := events.AccountCreated{UserID: createdUser.ID, Email: createdUser.Email}
+		return s.addEvent(ctx, repos, createdUser.ID, s.accountCreatedTopic, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return createdUser, nil
 }
 
-func (s *userServiceImpl) GetUserByID(id uuid.UUID) (*models.User, error) {
-	return s.userRepo.FindByID(id)
+func (s *userServiceImpl) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.userRepo.FindByID(ctx, id)
 }
 
-func (s *userServiceImpl) GetAllUsers(p *utils.Pagination) ([]*models.User, error) {
+func (s *userServiceImpl) GetAllUsers(ctx context.Context, p *utils.Pagination) ([]*models.User, error) {
 	if p == nil {
 		defaultPagination := utils.DefaultPagination()
 		p = &defaultPagination
 	}
 
-	return s.userRepo.FindAll(*p)
+	return s.userRepo.FindAll(ctx, *p)
 }
 
-func (s *userServiceImpl) UpdateUser(user models.User) (*models.User, error) {
-	currentUser, err := s.userRepo.FindByID(user.ID)
+func (s *userServiceImpl) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	return s.updateUser(ctx, s.userRepo, user)
+}
+
+func (s *userServiceImpl) updateUser(ctx context.Context, repo irepository.UserRepository, user models.User) (*models.User, error) {
+	currentUser, err := repo.FindByID(ctx, user.ID)
 	if err != nil {
 		s.logger.Error("Error fetching user with ID: %s, %v", user.ID, err)
 		return nil, errors.New("error fetching user by ID")
 	}
 
 	if currentUser.Email != user.Email {
-		existingUser, err := s.userRepo.FindByEmail(user.Email)
+		existingUser, err := repo.FindByEmail(ctx, user.Email)
 		if err == nil && existingUser.ID != user.ID {
 			s.logger.Error("Email already exists: %s", user.Email)
 			return nil, errors.New("email already exists")
 		}
 	}
 	user.Password = currentUser.Password
-	return s.userRepo.Update(&user)
+	return repo.Update(ctx, &user)
+}
+
+// LockUser saves the user with the account locked and announces the lock together with the reason.
+func (s *userServiceImpl) LockUser(ctx context.Context, user models.User, reason string) (*models.User, error) {
+	user.IsLocked = true
+	var lockedUser *models.User
+	err := s.uow.Do(ctx, func(repos irepository.Repositories) error {
+		var err error
+		lockedUser, err = s.updateUser(ctx, repos.Users, user)
+		if err != nil {
+			return err
+		}
+		event := events.AccountBlocked{UserID: lockedUser.ID, Email: lockedUser.Email, Reason: reason}
+		return s.addEvent(ctx, repos, lockedUser.ID, s.accountBlockedTopic, event)
+	})
+	if err != nil {
+		s.logger.Error("Error locking user with ID: %s, %v", user.ID, err)
+		return nil, errors.New("error updating user")
+	}
+	return lockedUser, nil
 }
 
-func (s *userServiceImpl) DeleteUser(id uuid.UUID) error {
-	err := s.userRepo.Delete(id)
+func (s *userServiceImpl) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	err := s.userRepo.Delete(ctx, id)
 	if err != nil {
 		s.logger.Error("Error deleting user with ID: %s, %v", id, err)
 		return errors.New("error deleting user")
@@ -90,8 +145,8 @@ func (s *userServiceImpl) DeleteUser(id uuid.UUID) error {
 	return nil
 }
 
-func (s *userServiceImpl) UpdatePassword(id uuid.UUID, newPassword string) error {
-	user, err := s.userRepo.FindByID(id)
+func (s *userServiceImpl) UpdatePassword(ctx context.Context, id uuid.UUID, newPassword string) error {
+	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
 		s.logger.Error("Error fetching user with ID: %s, %v", id, err)
 		return errors.New("error fetching user")
@@ -105,7 +160,7 @@ func (s *userServiceImpl) UpdatePassword(id uuid.UUID, newPassword string) error
 
 	user.FirstAccess = false
 
-	_, err = s.userRepo.Update(user)
+	_, err = s.userRepo.Update(ctx, user)
 	if err != nil {
 		s.logger.Error("Error updating user with ID: %s, %v", id, err)
 		return errors.New("error updating user")
@@ -113,8 +168,47 @@ func (s *userServiceImpl) UpdatePassword(id uuid.UUID, newPassword string) error
 	return nil
 }
 
-func (s *userServiceImpl) GetUserByEmail(email string) (*models.User, error) {
-	user, err := s.userRepo.FindByEmail(email)
+// ChangeRole sets the role of a user on behalf of an admin. Admins cannot change their own role, so the
+// last admin cannot lock everyone out of the admin routes by accident.
+func (s *userServiceImpl) ChangeRole(ctx context.Context, actorID, id uuid.UUID, role string, client dto.ClientInfo) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, errors.New("invalid role")
+	}
+	if actorID == id {
+		return nil, errors.New("cannot change your own role")
+	}
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Error fetching user with ID: %s, %v", id, err)
+		return nil, errors.New("user not found")
+	}
+	previousRole := user.Role
+	if previousRole == role {
+		return user, nil
+	}
+
+	user.Role = role
+	updatedUser, err := s.userRepo.Update(ctx, user)
+	if err != nil {
+		s.logger.Error("Error updating role of user with ID: %s, %v", id, err)
+		return nil, errors.New("error updating user")
+	}
+
+	s.logger.Info("Role of user %s changed from %s to %s by %s", id, previousRole, role, actorID)
+	s.audit.Record(ctx, dto.AuditEvent{
+		Type:       models.AuditEventRoleChanged,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &actorID,
+		TargetType: models.AuditTargetUser,
+		TargetID:   &id,
+		IP:         client.IP,
+		Details:    map[string]string{"previous_role": previousRole, "role": role},
+	})
+	return updatedUser, nil
+}
+
+func (s *userServiceImpl) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
 		s.logger.Error("Failed to fetch user with email: %s, %v", email, err)
 		return nil, errors.New("failed to fetch user")
@@ -128,17 +222,82 @@ func (s *userServiceImpl) GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
-func (s *userServiceImpl) GetUserByResetToken(token string) (*models.User, error) {
-	user, err := s.userRepo.FindByResetToken(token)
+func (s *userServiceImpl) GetUserByEmailChangeToken(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.userRepo.FindByEmailChangeToken(ctx, token)
 	if err != nil {
-		s.logger.Error("Failed to fetch user with reset token: %s, %v", token, err)
+		s.logger.Error("Failed to fetch user with email change token: %v", err)
 		return nil, errors.New("failed to fetch user")
 	}
 
 	if user == nil {
-		s.logger.Error("User not found with reset token: %s", token)
+		s.logger.Error("User not found with email change token")
 		return nil, errors.New("user not found")
 	}
 
 	return user, nil
 }
+
+func (s *userServiceImpl) GetUserByEmailRevertToken(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.userRepo.FindByEmailRevertToken(ctx, token)
+	if err != nil {
+		s.logger.Error("Failed to fetch user with email revert token: %v", err)
+		return nil, errors.New("failed to fetch user")
+	}
+
+	if user == nil {
+		s.logger.Error("User not found with email revert token")
+		return nil, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+func (s *userServiceImpl) IncrementFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) (int, error) {
+	failedAttempts, err := s.userRepo.IncrementFailedAttempts(ctx, id, attemptAt)
+	if err != nil {
+		s.logger.Error("Error incrementing failed attempts for user with ID: %s, %v", id, err)
+		return 0, errors.New("error updating user")
+	}
+	return failedAttempts, nil
+}
+
+func (s *userServiceImpl) ResetFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) error {
+	err := s.userRepo.ResetFailedAttempts(ctx, id, attemptAt)
+	if err != nil {
+		s.logger.Error("Error resetting failed attempts for user with ID: %s, %v", id, err)
+		return errors.New("error updating user")
+	}
+	return nil
+}
+
+// BlockUntil announces the block only when this call set it, so concurrent attempts send one event.
+func (s *userServiceImpl) BlockUntil(ctx context.Context, id uuid.UUID, blockedUntil time.Time, reason string) (bool, error) {
+	blocked := false
+	err := s.uow.Do(ctx, func(repos irepository.Repositories) error {
+		var err error
+		blocked, err = repos.Users.BlockUntil(ctx, id, blockedUntil)
+		if err != nil || !blocked {
+			return err
+		}
+		user, err := repos.Users.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		event := events.AccountBlocked{UserID: id, Email: user.Email, Reason: reason, BlockedUntil: &blockedUntil}
+		return s.addEvent(ctx, repos, id, s.accountBlockedTopic, event)
+	})
+	if err != nil {
+		s.logger.Error("Error blocking user with ID: %s, %v", id, err)
+		return false, errors.New("error updating user")
+	}
+	return blocked, nil
+}
+
+func (s *userServiceImpl) UnblockIfExpired(ctx context.Context, id uuid.UUID, now time.Time) error {
+	err := s.userRepo.UnblockIfExpired(ctx, id, now)
+	if err != nil {
+		s.logger.Error("Error unblocking user with ID: %s, %v", id, err)
+		return errors.New("error updating user")
+	}
+	return nil
+}
@@ -1,18 +1,28 @@
 package users
 
 import (
+	"automation-hub-idp/internal/app/dto"
 	"automation-hub-idp/internal/app/models"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 type UserService interface {
-	CreateUser(user models.User) (*models.User, error)
-	GetUserByID(id uuid.UUID) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
-	GetUserByResetToken(token string) (*models.User, error)
-	UpdateUser(user models.User) (*models.User, error)
-	DeleteUser(id uuid.UUID) error
-	GetAllUsers(p *utils.Pagination) ([]*models.User, error)
-	UpdatePassword(id uuid.UUID, newPassword string) error
+	CreateUser(ctx context.Context, user models.User) (*models.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*models.User, error)
+	GetUserByEmailRevertToken(ctx context.Context, tokenHash string) (*models.User, error)
+	UpdateUser(ctx context.Context, user models.User) (*models.User, error)
+	LockUser(ctx context.Context, user models.User, reason string) (*models.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	GetAllUsers(ctx context.Context, p *utils.Pagination) ([]*models.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, newPassword string) error
+	ChangeRole(ctx context.Context, actorID, id uuid.UUID, role string, client dto.ClientInfo) (*models.User, error)
+	IncrementFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) (int, error)
+	ResetFailedAttempts(ctx context.Context, id uuid.UUID, attemptAt time.Time) error
+	BlockUntil(ctx context.Context, id uuid.UUID, blockedUntil time.Time, reason string) (bool, error)
+	UnblockIfExpired(ctx context.Context, id uuid.UUID, now time.Time) error
 }
@@ -1,18 +1,40 @@
 package users
 
 import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
 	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories"
+	"automation-hub-idp/internal/app/repositories/repository_mock"
+	"automation-hub-idp/internal/app/services/service_mock"
 	"automation-hub-idp/internal/app/utils"
+	"automation-hub-idp/internal/app/utils/utils_mock"
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
+const (
+	testAccountCreatedTopic = "account-created"
+	testAccountBlockedTopic = "account-blocked"
+)
+
+// outboxData returns the event payload inside the envelope of an outbox message.
+func outboxData(message *models.OutboxMessage) string {
+	var envelope events.Envelope
+	_ = json.Unmarshal([]byte(message.Payload), &envelope)
+	return string(envelope.Data)
+}
+
 func TestCreateUser_Success(t *testing.T) {
 	// Arrange
 	mockRepo := new(MockUserRepository)
+	outbox := new(repository_mock.MockOutboxRepository)
 	mockLogger := new(MockLogger)
 	hasher := new(MockPasswordHasher)
 	email := "test@example.com"
@@ -23,17 +45,129 @@ func TestCreateUser_Success(t *testing.T) {
 
 	expectedUser := user
 	mockRepo.On("Create", &expectedUser).Return(&expectedUser, nil)
+	outbox.On("Add", mock.MatchedBy(func(m *models.OutboxMessage) bool {
+		return m.Topic == testAccountCreatedTopic &&
+			outboxData(m) == `{"userId":"00000000-0000-0000-0000-000000000000","email":"test@example.com"}`
+	})).Return(nil)
 
-	service := NewUserService(mockRepo, mockLogger, hasher)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo, Outbox: outbox},
+		service_mock.NewPermissiveMockAuditRecorder(), mockLogger, hasher, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.CreateUser(user)
+	result, err := service.CreateUser(context.Background(), user)
 
 	// Assert
 	assert.Nil(t, err)
 	assert.Equal(t, user.Password, result.Password)
 	assert.Equal(t, user.Email, result.Email)
 	mockRepo.AssertExpectations(t)
+	outbox.AssertExpectations(t)
+}
+
+func TestCreateUser_FailsWhenEventCannotBeStored(t *testing.T) {
+	// Arrange
+	userRepo := repositories.NewMemoryUserRepository(service_mock.NewPermissiveMockLogger())
+	outbox := new(repository_mock.MockOutboxRepository)
+	user := models.User{Email: "test@example.com", Password: "test123"}
+	outbox.On("Add", mock.Anything).Return(errors.New("failed to add outbox message"))
+
+	service := NewUserService(userRepo, &repository_mock.FakeUnitOfWork{Users: userRepo, Outbox: outbox},
+		service_mock.NewPermissiveMockAuditRecorder(), service_mock.NewPermissiveMockLogger(), nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+	// Act
+	result, err := service.CreateUser(context.Background(), user)
+
+	// Assert
+	// The error makes the unit of work roll back the created user
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	stored, err := userRepo.FindAll(context.Background(), utils.DefaultPagination())
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestCreateUser_EventTakesIDAndTimeOfTheService(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockUserRepository)
+	outbox := new(repository_mock.MockOutboxRepository)
+	user := models.User{Email: "test@example.com", Password: "test123"}
+	mockRepo.On("FindByEmail", user.Email).Return(nil, errors.New("user not found"))
+	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(&user, nil)
+	var stored *models.OutboxMessage
+	outbox.On("Add", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*models.OutboxMessage)
+	}).Return(nil)
+	clock := utils_mock.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo, Outbox: outbox},
+		service_mock.NewPermissiveMockAuditRecorder(), service_mock.NewPermissiveMockLogger(), nil, clock,
+		utils_mock.NewSequentialIDGenerator(), service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+	// Act
+	_, err := service.CreateUser(context.Background(), user)
+
+	// Assert
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, utils_mock.SequentialID(1), stored.ID)
+		assert.True(t, clock.Now().Equal(stored.CreatedAt))
+	}
+}
+
+func TestBlockUntil_AnnouncesOnlyNewBlocks(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockUserRepository)
+	outbox := new(repository_mock.MockOutboxRepository)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com"}
+	blockedUntil := time.Now().Add(time.Hour)
+	mockRepo.On("BlockUntil", user.ID, blockedUntil).Return(true, nil).Once()
+	mockRepo.On("BlockUntil", user.ID, blockedUntil).Return(false, nil).Once()
+	mockRepo.On("FindByID", user.ID).Return(user, nil)
+	outbox.On("Add", mock.MatchedBy(func(m *models.OutboxMessage) bool {
+		return m.Topic == testAccountBlockedTopic && m.AggregateID == user.ID
+	})).Return(nil)
+
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo, Outbox: outbox},
+		service_mock.NewPermissiveMockAuditRecorder(), service_mock.NewPermissiveMockLogger(), nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+	// Act
+	first, firstErr := service.BlockUntil(context.Background(), user.ID, blockedUntil, "too many failed login attempts")
+	second, secondErr := service.BlockUntil(context.Background(), user.ID, blockedUntil, "too many failed login attempts")
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	assert.True(t, first)
+	assert.False(t, second)
+	outbox.AssertNumberOfCalls(t, "Add", 1)
+}
+
+func TestLockUser_StoresLockAndEvent(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockUserRepository)
+	outbox := new(repository_mock.MockOutboxRepository)
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Password: "hashed"}
+	mockRepo.On("FindByID", user.ID).Return(user, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(u *models.User) bool { return u.IsLocked })).Return(user, nil)
+	outbox.On("Add", mock.MatchedBy(func(m *models.OutboxMessage) bool {
+		return m.Topic == testAccountBlockedTopic &&
+			outboxData(m) == `{"userId":"`+user.ID.String()+`","email":"test@example.com","reason":"email change reverted"}`
+	})).Return(nil)
+
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo, Outbox: outbox},
+		service_mock.NewPermissiveMockAuditRecorder(), service_mock.NewPermissiveMockLogger(), nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+	// Act
+	_, err := service.LockUser(context.Background(), models.User{ID: user.ID, Email: user.Email}, "email change reverted")
+
+	// Assert
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	outbox.AssertExpectations(t)
 }
 
 func TestGetUserByID(t *testing.T) {
@@ -46,10 +180,12 @@ func TestGetUserByID(t *testing.T) {
 
 	mockRepo.On("FindByID", id).Return(&user, nil)
 
-	service := NewUserService(mockRepo, mockLogger, hasher)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), mockLogger, hasher, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.GetUserByID(id)
+	result, err := service.GetUserByID(context.Background(), id)
 
 	// Assert
 	assert.Nil(t, err)
@@ -68,10 +204,12 @@ func TestGetAllUsers_WithDefaultPagination(t *testing.T) {
 	defaultPagination := utils.DefaultPagination()
 	mockRepo.On("FindAll", defaultPagination).Return(users, nil)
 
-	service := NewUserService(mockRepo, nil, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), nil, nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.GetAllUsers(nil)
+	result, err := service.GetAllUsers(context.Background(), nil)
 
 	// Assert
 	assert.Nil(t, err)
@@ -92,10 +230,12 @@ func TestUpdateUser_Success(t *testing.T) {
 	updatedUser.Password = "hashedPassword"
 	mockRepo.On("Update", &updatedUser).Return(&updatedUser, nil)
 
-	service := NewUserService(mockRepo, nil, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), nil, nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.UpdateUser(newUser)
+	result, err := service.UpdateUser(context.Background(), newUser)
 
 	// Assert
 	assert.Nil(t, err)
@@ -132,10 +272,12 @@ func TestUpdateUser_PasswordNotChanged(t *testing.T) {
 		return true
 	})).Return()
 
-	service := NewUserService(mockRepo, mockLogger, hasher)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), mockLogger, hasher, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.UpdateUser(newUser)
+	result, err := service.UpdateUser(context.Background(), newUser)
 
 	// Assert
 	assert.Nil(t, err)
@@ -154,10 +296,12 @@ func TestDeleteUser_Success(t *testing.T) {
 
 	mockRepo.On("Delete", id).Return(nil)
 
-	service := NewUserService(mockRepo, mockLogger, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), mockLogger, nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	err := service.DeleteUser(id)
+	err := service.DeleteUser(context.Background(), id)
 
 	// Assert
 	assert.Nil(t, err)
@@ -175,10 +319,12 @@ func TestGetUserByEmail_Success(t *testing.T) {
 
 	mockRepo.On("FindByEmail", email).Return(user, nil)
 
-	service := NewUserService(mockRepo, nil, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), nil, nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.GetUserByEmail(email)
+	result, err := service.GetUserByEmail(context.Background(), email)
 
 	// Assert
 	assert.Nil(t, err)
@@ -194,10 +340,12 @@ func TestGetUserByEmail_RepoError(t *testing.T) {
 
 	mockRepo.On("FindByEmail", email).Return(nil, errors.New("database error"))
 	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
-	service := NewUserService(mockRepo, mockLogger, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		service_mock.NewPermissiveMockAuditRecorder(), mockLogger, nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
 
 	// Act
-	result, err := service.GetUserByEmail(email)
+	result, err := service.GetUserByEmail(context.Background(), email)
 
 	// Assert
 	assert.Nil(t, result)
@@ -205,3 +353,64 @@ func TestGetUserByEmail_RepoError(t *testing.T) {
 	assert.Equal(t, "failed to fetch user", err.Error())
 	mockRepo.AssertExpectations(t)
 }
+
+func TestChangeRole_RecordsAuditEvent(t *testing.T) {
+	// Arrange
+	mockRepo := new(MockUserRepository)
+	auditRecorder := service_mock.NewPermissiveMockAuditRecorder()
+	actorID := uuid.New()
+	user := &models.User{ID: uuid.New(), Email: "test@example.com", Role: models.RoleUser}
+	mockRepo.On("FindByID", user.ID).Return(user, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(u *models.User) bool { return u.Role == models.RoleOrgOwner })).Return(user, nil)
+	service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+		auditRecorder, service_mock.NewPermissiveMockLogger(), nil, utils.SystemClock(), utils.RandomIDGenerator(),
+		service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+	// Act
+	result, err := service.ChangeRole(context.Background(), actorID, user.ID, models.RoleOrgOwner, dto.ClientInfo{IP: "192.0.2.1"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleOrgOwner, result.Role)
+	events := auditRecorder.Recorded(models.AuditEventRoleChanged)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, &actorID, events[0].ActorID)
+		assert.Equal(t, &user.ID, events[0].TargetID)
+		assert.Equal(t, "192.0.2.1", events[0].IP)
+		assert.Equal(t, models.RoleUser, events[0].Details["previous_role"])
+		assert.Equal(t, models.RoleOrgOwner, events[0].Details["role"])
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangeRole_Rejected(t *testing.T) {
+	actorID := uuid.New()
+	tests := []struct {
+		name   string
+		userID uuid.UUID
+		role   string
+	}{
+		{"own role", actorID, models.RoleUser},
+		{"unknown role", uuid.New(), "superuser"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			mockRepo := new(MockUserRepository)
+			auditRecorder := service_mock.NewPermissiveMockAuditRecorder()
+			service := NewUserService(mockRepo, &repository_mock.FakeUnitOfWork{Users: mockRepo},
+				auditRecorder, service_mock.NewPermissiveMockLogger(), nil, utils.SystemClock(), utils.RandomIDGenerator(),
+				service_mock.FakeOrigin, testAccountCreatedTopic, testAccountBlockedTopic)
+
+			// Act
+			result, err := service.ChangeRole(context.Background(), actorID, tt.userID, tt.role, dto.ClientInfo{})
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, result)
+			mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+			assert.Empty(t, auditRecorder.Recorded(models.AuditEventRoleChanged))
+		})
+	}
+}
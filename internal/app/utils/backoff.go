@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// ExponentialBackoff doubles the base delay with every failed attempt after the first, up to the maximum.
+func ExponentialBackoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
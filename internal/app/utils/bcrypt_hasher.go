@@ -1,6 +1,10 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"automation-hub-idp/internal/app/metrics"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
 
 type BcryptHasher struct {
 	cost int
@@ -15,6 +19,7 @@ func DefaultBcryptHasher() PasswordHasher {
 }
 
 func (b *BcryptHasher) Hash(password string) (string, error) {
+	defer metrics.ObserveDuration(metrics.PasswordHashDuration.WithLabelValues("hash"), time.Now())
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
 	if err != nil {
 		return "", err
@@ -23,5 +28,6 @@ func (b *BcryptHasher) Hash(password string) (string, error) {
 }
 
 func (b *BcryptHasher) Compare(hashedPassword, password string) error {
+	defer metrics.ObserveDuration(metrics.PasswordHashDuration.WithLabelValues("compare"), time.Now())
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfo describes the client of a request, for the login history and the audit log.
+func ClientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		IP:             c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	}
+}
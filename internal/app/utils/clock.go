@@ -0,0 +1,20 @@
+package utils
+
+import "time"
+
+// Clock tells the current time. Services read the time through it rather than through time.Now, so tests
+// can move time forward instead of waiting for it.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// SystemClock returns the clock of the machine.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
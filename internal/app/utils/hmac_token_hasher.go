@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+type HmacTokenHasher struct {
+	key []byte
+}
+
+func NewHmacTokenHasher(key string) TokenHasher {
+	return &HmacTokenHasher{key: []byte(key)}
+}
+
+func (h *HmacTokenHasher) Hash(token string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *HmacTokenHasher) Compare(hashedToken, token string) bool {
+	return hmac.Equal([]byte(hashedToken), []byte(h.Hash(token)))
+}
+
+// GenerateRandomToken returns a URL-safe token carrying size bytes of entropy.
+func GenerateRandomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// GenerateRandomCode returns a numeric code of the given number of digits, for codes a user has to type.
+func GenerateRandomCode(digits int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
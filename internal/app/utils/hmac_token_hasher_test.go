@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHmacTokenHasher_Hash(t *testing.T) {
+	hasher := NewHmacTokenHasher("key")
+
+	hashedToken := hasher.Hash("my-token")
+
+	assert.NotEmpty(t, hashedToken)
+	assert.NotContains(t, hashedToken, "my-token")
+	assert.Equal(t, hashedToken, hasher.Hash("my-token"))
+	assert.NotEqual(t, hashedToken, NewHmacTokenHasher("other-key").Hash("my-token"))
+}
+
+func TestHmacTokenHasher_Compare(t *testing.T) {
+	hasher := NewHmacTokenHasher("key")
+	hashedToken := hasher.Hash("my-token")
+
+	assert.True(t, hasher.Compare(hashedToken, "my-token"))
+	assert.False(t, hasher.Compare(hashedToken, "wrong-token"))
+}
+
+func TestGenerateRandomToken(t *testing.T) {
+	first, err := GenerateRandomToken(32)
+	assert.NoError(t, err)
+	second, err := GenerateRandomToken(32)
+	assert.NoError(t, err)
+
+	assert.Len(t, first, 43)
+	assert.NotEqual(t, first, second)
+}
+
+func TestGenerateRandomCode(t *testing.T) {
+	code, err := GenerateRandomCode(6)
+
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9]{6}$", code)
+}
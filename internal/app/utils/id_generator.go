@@ -0,0 +1,20 @@
+package utils
+
+import "github.com/google/uuid"
+
+// IDGenerator makes the IDs of new records and tokens. Services take it instead of calling uuid.New, so
+// tests can predict the IDs they hand out.
+type IDGenerator interface {
+	NewID() uuid.UUID
+}
+
+type randomIDGenerator struct{}
+
+// RandomIDGenerator returns a generator of random (version 4) UUIDs.
+func RandomIDGenerator() IDGenerator {
+	return randomIDGenerator{}
+}
+
+func (randomIDGenerator) NewID() uuid.UUID {
+	return uuid.New()
+}
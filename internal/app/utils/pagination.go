@@ -5,10 +5,16 @@ type Pagination struct {
 	Offset int `default:"0"`
 }
 
+// MaxLimit is the largest page a client can ask for, so a single request cannot read a whole table.
+const MaxLimit = 100
+
 func NewPagination(limit, offset int) Pagination {
 	if limit <= 0 {
 		limit = 10
 	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
 	if offset < 0 {
 		offset = 0
 	}
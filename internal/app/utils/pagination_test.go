@@ -16,6 +16,9 @@ func TestNewPagination(t *testing.T) {
 		{0, 10, 10, 10},
 		{10, -5, 10, 0},
 		{-5, -5, 10, 0},
+		{100, 0, 100, 0},
+		{101, 0, 100, 0},
+		{1000000, 20, 100, 20},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,6 @@
+package utils
+
+type TokenHasher interface {
+	Hash(token string) string
+	Compare(hashedToken, token string) bool
+}
@@ -0,0 +1,32 @@
+package utils_mock
+
+import (
+	"encoding/binary"
+	"github.com/google/uuid"
+	"sync"
+)
+
+// SequentialIDGenerator hands out SequentialID(1), SequentialID(2) and so on, so tests know the IDs in advance.
+type SequentialIDGenerator struct {
+	mu   sync.Mutex
+	next uint64
+}
+
+func NewSequentialIDGenerator() *SequentialIDGenerator {
+	return &SequentialIDGenerator{next: 1}
+}
+
+func (g *SequentialIDGenerator) NewID() uuid.UUID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	id := SequentialID(g.next)
+	g.next++
+	return id
+}
+
+// SequentialID is the n-th ID of a SequentialIDGenerator.
+func SequentialID(n uint64) uuid.UUID {
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], n)
+	return id
+}
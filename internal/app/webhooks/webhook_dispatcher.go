@@ -0,0 +1,199 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/utils"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// The headers of a delivery. Receivers verify the signature over the timestamp and the body and reject old
+// timestamps, which stops replays. The event ID stays the same when a delivery is retried or redelivered.
+const (
+	HeaderEventID    = "Webhook-Id"
+	HeaderDeliveryID = "Webhook-Delivery-Id"
+	HeaderTimestamp  = "Webhook-Timestamp"
+	HeaderSignature  = "Webhook-Signature"
+
+	signatureVersion = "v1"
+	// maxResponseBytes of a receiver's response are read, the rest is dropped with the connection
+	maxResponseBytes = 64 << 10
+)
+
+// Sign returns the signature header of a delivery: "v1=" followed by the hex encoded HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription's secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatcher sends due deliveries, at least once and in no particular order. A failed delivery is retried
+// with exponential backoff until it failed maxAttempts times.
+type dispatcher struct {
+	repo         irepository.WebhookRepository
+	client       *http.Client
+	logger       iservice.Logger
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retryBackoff time.Duration
+	maxBackoff   time.Duration
+	now          func() time.Time
+}
+
+func NewDispatcher(repo irepository.WebhookRepository, client *http.Client, logger iservice.Logger,
+	pollInterval time.Duration, batchSize, maxAttempts int, retryBackoff, maxBackoff time.Duration) Dispatcher {
+	return &dispatcher{
+		repo:         repo,
+		client:       client,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		retryBackoff: retryBackoff,
+		maxBackoff:   maxBackoff,
+		now:          time.Now,
+	}
+}
+
+// NewHTTPClient returns the client deliveries are sent with. Redirects are not followed and, unless
+// allowPrivateNetworks is set, connections to loopback, private and link-local addresses are refused. The
+// check runs on the resolved address, so a public name resolving to an internal address is refused too.
+func NewHTTPClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (d *dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		// A batch that started goes out completely, shutdown only stops the next one
+		if _, err := d.DeliverDue(context.WithoutCancel(ctx)); err != nil {
+			d.logger.Error("Failed to deliver webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	now := d.now()
+	// The claimed deliveries are left alone by other dispatchers until this batch had time to go out
+	leaseUntil := now.Add(d.client.Timeout*time.Duration(d.batchSize) + d.pollInterval)
+	deliveries, err := d.repo.ClaimDue(ctx, now, leaseUntil, d.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	subscriptions := make(map[uuid.UUID]*models.WebhookSubscription)
+	for _, delivery := range deliveries {
+		subscription, found := subscriptions[delivery.SubscriptionID]
+		if !found {
+			subscription, err = d.repo.FindSubscription(ctx, delivery.SubscriptionID)
+			if err != nil {
+				// Retried once the lease ran out
+				d.logger.Error("Failed to fetch webhook subscription %s of delivery %s: %v",
+					delivery.SubscriptionID, delivery.ID, err)
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		statusCode, err := d.send(ctx, subscription, delivery)
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		if err == nil {
+			deliveredAt := d.now()
+			delivery.Status = models.WebhookDeliverySucceeded
+			delivery.DeliveredAt = &deliveredAt
+			delivery.LastError = ""
+			delivered++
+		} else {
+			delivery.LastError = err.Error()
+			if delivery.Attempts >= d.maxAttempts {
+				delivery.Status = models.WebhookDeliveryFailed
+				d.logger.Error("Webhook delivery %s to %s failed %d times, giving up: %v",
+					delivery.ID, subscription.URL, delivery.Attempts, err)
+			} else {
+				delivery.NextAttemptAt = d.now().Add(utils.ExponentialBackoff(d.retryBackoff, d.maxBackoff, delivery.Attempts))
+				d.logger.Warn("Webhook delivery %s to %s failed (attempt %d), retrying at %s: %v",
+					delivery.ID, subscription.URL, delivery.Attempts, delivery.NextAttemptAt.String(), err)
+			}
+		}
+		if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// send posts the delivery and returns the status code of the response, or 0 when there was none. Any
+// status but 2xx is a failure.
+func (d *dispatcher) send(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := d.now().Unix()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", events.ContentType)
+	request.Header.Set(HeaderEventID, delivery.EventID)
+	request.Header.Set(HeaderDeliveryID, delivery.ID.String())
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseBytes))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
@@ -0,0 +1,10 @@
+package webhooks
+
+import "context"
+
+type Dispatcher interface {
+	// Run delivers due webhooks every poll interval until the context is cancelled.
+	Run(ctx context.Context)
+	// DeliverDue sends one batch of due deliveries and returns how many succeeded.
+	DeliverDue(ctx context.Context) (int, error)
+}
@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/services/service_mock"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a partner endpoint answering with status and recording what it received.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+type dispatcherTestDeps struct {
+	repo     *memoryWebhooks
+	receiver *receiver
+	server   *httptest.Server
+	now      time.Time
+}
+
+func newDispatcherTestDeps(t *testing.T) *dispatcherTestDeps {
+	deps := &dispatcherTestDeps{
+		repo:     newMemoryWebhooks(),
+		receiver: &receiver{status: http.StatusNoContent},
+		now:      time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+	deps.server = httptest.NewServer(deps.receiver)
+	t.Cleanup(deps.server.Close)
+	return deps
+}
+
+// newDispatcher sends to the test server on loopback, which the production client refuses.
+func (d *dispatcherTestDeps) newDispatcher() *dispatcher {
+	disp := NewDispatcher(d.repo, NewHTTPClient(time.Second, true), service_mock.NewPermissiveMockLogger(),
+		time.Second, 10, 3, time.Minute, 4*time.Minute).(*dispatcher)
+	disp.now = func() time.Time { return d.now }
+	return disp
+}
+
+func (d *dispatcherTestDeps) queue(t *testing.T, url, secret string) *models.WebhookDelivery {
+	subscription, _ := d.repo.CreateSubscription(context.Background(), &models.WebhookSubscription{ID: uuid.New(), OrganizationID: uuid.New(),
+		URL: url, Secret: secret, EventTypes: events.AccountCreated{}.Contract().Type()})
+	envelope := envelopeOf(t, events.AccountCreated{UserID: uuid.New(), Email: "jane@example.com"})
+	payload, err := webhookPayload(envelope)
+	assert.NoError(t, err)
+	delivery := &models.WebhookDelivery{ID: uuid.New(), SubscriptionID: subscription.ID, EventID: envelope.ID,
+		EventType: envelope.Type, Payload: payload, Status: models.WebhookDeliveryPending, NextAttemptAt: d.now, CreatedAt: d.now}
+	_, err = d.repo.CreateDelivery(context.Background(), delivery)
+	assert.NoError(t, err)
+	return delivery
+}
+
+func (d *dispatcherTestDeps) get(t *testing.T, id uuid.UUID) *models.WebhookDelivery {
+	delivery, err := d.repo.FindDelivery(context.Background(), id)
+	assert.NoError(t, err)
+	return delivery
+}
+
+func TestSign(t *testing.T) {
+	// Act
+	signature := Sign("whsec_test", 1709285400, []byte(`{"hello":"world"}`))
+
+	// Assert
+	assert.Equal(t, "v1=93f23f3dc0216d60afd6929300650b3eaec5d22245cef6d130521265747ddc3f", signature)
+}
+
+func TestDeliverDue_SendsSignedEnvelope(t *testing.T) {
+	// Arrange
+	deps := newDispatcherTestDeps(t)
+	delivery := deps.queue(t, deps.server.URL, "a-secret-of-sufficient-length")
+
+	// Act
+	delivered, err := deps.newDispatcher().DeliverDue(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	if assert.Equal(t, 1, deps.receiver.received()) {
+		request, body := deps.receiver.requests[0], deps.receiver.bodies[0]
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, events.ContentType, request.Header.Get("Content-Type"))
+		assert.Equal(t, delivery.EventID, request.Header.Get(HeaderEventID))
+		assert.Equal(t, delivery.ID.String(), request.Header.Get(HeaderDeliveryID))
+		assert.Equal(t, strconv.FormatInt(deps.now.Unix(), 10), request.Header.Get(HeaderTimestamp))
+		assert.Equal(t, Sign("a-secret-of-sufficient-length", deps.now.Unix(), body), request.Header.Get(HeaderSignature))
+		assert.JSONEq(t, delivery.Payload, string(body))
+	}
+	stored := deps.get(t, delivery.ID)
+	assert.Equal(t, models.WebhookDeliverySucceeded, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, http.StatusNoContent, stored.LastStatusCode)
+	assert.NotNil(t, stored.DeliveredAt)
+
+	delivered, err = deps.newDispatcher().DeliverDue(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Equal(t, 1, deps.receiver.received())
+}
+
+func TestDeliverDue_RetriesWithBackoffUntilMaxAttempts(t *testing.T) {
+	// Arrange
+	deps := newDispatcherTestDeps(t)
+	deps.receiver.setStatus(http.StatusServiceUnavailable)
+	delivery := deps.queue(t, deps.server.URL, "a-secret-of-sufficient-length")
+	dispatcher := deps.newDispatcher()
+
+	// Act & Assert
+	delivered, err := dispatcher.DeliverDue(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, delivered)
+	stored := deps.get(t, delivery.ID)
+	assert.Equal(t, models.WebhookDeliveryPending, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, stored.LastStatusCode)
+	assert.Equal(t, "unexpected status 503", stored.LastError)
+	assert.Equal(t, deps.now.Add(time.Minute), stored.NextAttemptAt)
+
+	// Not due yet
+	_, _ = dispatcher.DeliverDue(context.Background())
+	assert.Equal(t, 1, deps.receiver.received())
+
+	deps.now = deps.now.Add(time.Minute)
+	_, _ = dispatcher.DeliverDue(context.Background())
+	stored = deps.get(t, delivery.ID)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Equal(t, deps.now.Add(2*time.Minute), stored.NextAttemptAt)
+
+	deps.now = deps.now.Add(2 * time.Minute)
+	_, _ = dispatcher.DeliverDue(context.Background())
+	stored = deps.get(t, delivery.ID)
+	assert.Equal(t, 3, stored.Attempts)
+	assert.Equal(t, models.WebhookDeliveryFailed, stored.Status)
+
+	deps.now = deps.now.Add(time.Hour)
+	_, _ = dispatcher.DeliverDue(context.Background())
+	assert.Equal(t, 3, deps.receiver.received(), "a failed delivery was retried")
+}
+
+func TestDeliverDue_RecoversOnceReceiverIsBack(t *testing.T) {
+	// Arrange
+	deps := newDispatcherTestDeps(t)
+	deps.receiver.setStatus(http.StatusInternalServerError)
+	delivery := deps.queue(t, deps.server.URL, "a-secret-of-sufficient-length")
+	dispatcher := deps.newDispatcher()
+	_, _ = dispatcher.DeliverDue(context.Background())
+
+	// Act
+	deps.receiver.setStatus(http.StatusOK)
+	deps.now = deps.now.Add(time.Minute)
+	delivered, err := dispatcher.DeliverDue(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	stored := deps.get(t, delivery.ID)
+	assert.Equal(t, models.WebhookDeliverySucceeded, stored.Status)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Empty(t, stored.LastError)
+}
+
+func TestNewHTTPClient_RefusesPrivateAddresses(t *testing.T) {
+	// Arrange
+	deps := newDispatcherTestDeps(t)
+	client := NewHTTPClient(time.Second, false)
+
+	// Act
+	response, err := client.Post(deps.server.URL, "application/json", nil)
+
+	// Assert
+	if response != nil {
+		_ = response.Body.Close()
+	}
+	assert.ErrorContains(t, err, "is not public")
+	assert.Zero(t, deps.receiver.received())
+}
@@ -0,0 +1,249 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+type Handler struct {
+	webhookService Service
+}
+
+func NewHandler(webhookService Service) *Handler {
+	return &Handler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateWebhook
+// @Summary CreateWebhook
+// @Description Subscribes a URL to events about the organization's users. Deliveries are signed with the secret, which is only returned here.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param organizationId path string true "Organization ID"
+// @Param body body dto.WebhookRequest true "Webhook"
+// @Success 201 {object} dto.WebhookResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /organizations/{organizationId}/webhooks [post]
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var errorResponse dto.ErrorResponse
+	organizationID, ok := parseID(c, "organizationId")
+	if !ok {
+		return
+	}
+	var request dto.WebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		errorResponse.Message = "Invalid request body"
+		errorResponse.ErrorCode = http.StatusBadRequest
+		c.JSON(http.StatusBadRequest, errorResponse)
+		return
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(c.Request.Context(), c.MustGet("userID").(uuid.UUID), organizationID, request,
+		utils.ClientInfo(c))
+	if err != nil {
+		respondError(c, err, http.StatusBadRequest)
+		return
+	}
+	response := toResponse(subscription)
+	response.Secret = subscription.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListWebhooks
+// @Summary ListWebhooks
+// @Description Lists the webhooks of an organization
+// @Tags Webhooks
+// @Produce json
+// @Param organizationId path string true "Organization ID"
+// @Success 200 {array} dto.WebhookResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Router /organizations/{organizationId}/webhooks [get]
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	organizationID, ok := parseID(c, "organizationId")
+	if !ok {
+		return
+	}
+	subscriptions, err := h.webhookService.ListSubscriptions(c.Request.Context(), c.MustGet("userID").(uuid.UUID), organizationID)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]dto.WebhookResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		response = append(response, toResponse(subscription))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook
+// @Summary DeleteWebhook
+// @Description Deletes a webhook together with its delivery log
+// @Tags Webhooks
+// @Produce json
+// @Param organizationId path string true "Organization ID"
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} dto.SuccessResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /organizations/{organizationId}/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	organizationID, ok := parseID(c, "organizationId")
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	err := h.webhookService.DeleteSubscription(c.Request.Context(), c.MustGet("userID").(uuid.UUID), organizationID, id, utils.ClientInfo(c))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Message:    "Webhook deleted",
+		StatusCode: http.StatusOK,
+	})
+}
+
+// ListDeliveries
+// @Summary ListDeliveries
+// @Description Lists the delivery log of a webhook, newest first
+// @Tags Webhooks
+// @Produce json
+// @Param organizationId path string true "Organization ID"
+// @Param id path string true "Webhook ID"
+// @Param limit query int false "Page size, at most 100"
+// @Param offset query int false "Page offset"
+// @Success 200 {array} dto.WebhookDeliveryResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /organizations/{organizationId}/webhooks/{id}/deliveries [get]
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	organizationID, ok := parseID(c, "organizationId")
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	deliveries, err := h.webhookService.ListDeliveries(c.Request.Context(), c.MustGet("userID").(uuid.UUID), organizationID, id,
+		utils.NewPagination(limit, offset))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]dto.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response = append(response, toDeliveryResponse(delivery))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Redeliver
+// @Summary Redeliver
+// @Description Sends the event of a delivery to the webhook again, as a new delivery
+// @Tags Webhooks
+// @Produce json
+// @Param organizationId path string true "Organization ID"
+// @Param id path string true "Webhook ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} dto.WebhookDeliveryResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /organizations/{organizationId}/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (h *Handler) Redeliver(c *gin.Context) {
+	organizationID, ok := parseID(c, "organizationId")
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseID(c, "deliveryId")
+	if !ok {
+		return
+	}
+	delivery, err := h.webhookService.Redeliver(c.Request.Context(), c.MustGet("userID").(uuid.UUID), organizationID, id, deliveryID,
+		utils.ClientInfo(c))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusAccepted, toDeliveryResponse(delivery))
+}
+
+func parseID(c *gin.Context, param string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Message:   "Invalid " + param,
+			ErrorCode: http.StatusBadRequest,
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondError maps the errors of the service to their status, other errors get the fallback status.
+func respondError(c *gin.Context, err error, fallback int) {
+	status := fallback
+	switch {
+	case errors.Is(err, ErrNotAllowedToManageWebhooks):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrWebhookNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, dto.ErrorResponse{
+		Message:   err.Error(),
+		ErrorCode: status,
+	})
+}
+
+func toResponse(subscription *models.WebhookSubscription) dto.WebhookResponse {
+	return dto.WebhookResponse{
+		ID:             subscription.ID,
+		OrganizationID: subscription.OrganizationID,
+		URL:            subscription.URL,
+		EventTypes:     subscription.EventTypeList(),
+		CreatedBy:      subscription.CreatedBy,
+		CreatedAt:      subscription.CreatedAt,
+	}
+}
+
+func toDeliveryResponse(delivery *models.WebhookDelivery) dto.WebhookDeliveryResponse {
+	response := dto.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		RedeliveryOf:   delivery.RedeliveryOf,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		response.NextAttemptAt = &nextAttemptAt
+	}
+	return response
+}
@@ -0,0 +1,42 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/services/iservice"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+// sender queues the webhook deliveries of every event the next sender published, so webhooks receive the
+// same events as Kafka. Failing to queue them fails the send: the outbox relay then publishes the event
+// again, and its deliveries are still queued only once.
+type sender struct {
+	next     iservice.MessageSender
+	webhooks Service
+	origin   events.Origin
+}
+
+func NewMessageSender(next iservice.MessageSender, webhooks Service, origin events.Origin) iservice.MessageSender {
+	return &sender{
+		next:     next,
+		webhooks: webhooks,
+		origin:   origin,
+	}
+}
+
+func (s *sender) Send(ctx context.Context, topic string, event events.Event) error {
+	envelope, err := events.NewEnvelope(s.origin, event, uuid.New(), time.Now())
+	if err != nil {
+		return err
+	}
+	return s.SendEnvelope(ctx, topic, envelope.Subject, envelope)
+}
+
+func (s *sender) SendEnvelope(ctx context.Context, topic string, key string, envelope *events.Envelope) error {
+	if err := s.next.SendEnvelope(ctx, topic, key, envelope); err != nil {
+		return err
+	}
+	_, err := s.webhooks.Enqueue(ctx, envelope)
+	return err
+}
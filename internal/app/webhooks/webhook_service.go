@@ -0,0 +1,355 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
+	"automation-hub-idp/internal/app/services/iservice"
+	"automation-hub-idp/internal/app/users"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	generatedSecretBytes = 32
+	minSecretLength      = 16
+)
+
+var (
+	// ErrNotAllowedToManageWebhooks is returned when the actor may not manage the organization's webhooks.
+	ErrNotAllowedToManageWebhooks = errors.New("not allowed to manage the webhooks of this organization")
+	// ErrWebhookNotFound is returned for subscriptions and deliveries that do not exist in the organization.
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// EventTypes are the events offered to webhooks: those about a user that partners act upon.
+var EventTypes = []string{
+	events.AccountCreated{}.Contract().Type(),
+	events.AccountBlocked{}.Contract().Type(),
+	events.PasswordResetRequested{}.Contract().Type(),
+	events.SessionRevoked{}.Contract().Type(),
+}
+
+// redactedFields are left out of webhook payloads. The reset token lets whoever holds it take over the
+// account, it is only meant for the user's mailbox. Every type listed here has a redacted schema in events.
+var redactedFields = map[string][]string{
+	events.PasswordResetRequested{}.Contract().Type(): {"resetToken"},
+}
+
+type service struct {
+	repo                 irepository.WebhookRepository
+	userService          users.UserService
+	audit                iservice.AuditRecorder
+	logger               iservice.Logger
+	allowPrivateNetworks bool
+	now                  func() time.Time
+}
+
+func NewService(repo irepository.WebhookRepository, userService users.UserService, audit iservice.AuditRecorder,
+	logger iservice.Logger, allowPrivateNetworks bool) Service {
+	return &service{
+		repo:                 repo,
+		userService:          userService,
+		audit:                audit,
+		logger:               logger,
+		allowPrivateNetworks: allowPrivateNetworks,
+		now:                  time.Now,
+	}
+}
+
+func (s *service) CreateSubscription(ctx context.Context, actorID, organizationID uuid.UUID, request dto.WebhookRequest,
+	client dto.ClientInfo) (*models.WebhookSubscription, error) {
+	if err := s.authorize(ctx, actorID, organizationID, models.AuditEventWebhookCreated, client); err != nil {
+		return nil, err
+	}
+	if err := s.validateURL(request.URL); err != nil {
+		return nil, err
+	}
+	eventTypes, err := validateEventTypes(request.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		secret, err = utils.GenerateRandomToken(generatedSecretBytes)
+		if err != nil {
+			s.logger.Error("Error generating webhook secret: %v", err)
+			return nil, errors.New("failed to create webhook")
+		}
+	} else if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d characters", minSecretLength)
+	}
+
+	subscription, err := s.repo.CreateSubscription(ctx, &models.WebhookSubscription{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		URL:            request.URL,
+		Secret:         secret,
+		EventTypes:     strings.Join(eventTypes, ","),
+		CreatedBy:      actorID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Webhook %s for organization %s created by %s: %s", subscription.ID, organizationID, actorID, subscription.URL)
+	s.audit.Record(ctx, webhookEvent(models.AuditEventWebhookCreated, subscription, actorID, client, nil))
+	return subscription, nil
+}
+
+func (s *service) ListSubscriptions(ctx context.Context, actorID, organizationID uuid.UUID) ([]*models.WebhookSubscription, error) {
+	if err := s.authorize(ctx, actorID, organizationID, "", dto.ClientInfo{}); err != nil {
+		return nil, err
+	}
+	return s.repo.FindSubscriptionsByOrganization(ctx, organizationID)
+}
+
+func (s *service) DeleteSubscription(ctx context.Context, actorID, organizationID, id uuid.UUID, client dto.ClientInfo) error {
+	if err := s.authorize(ctx, actorID, organizationID, models.AuditEventWebhookDeleted, client); err != nil {
+		return err
+	}
+	subscription, err := s.findSubscription(ctx, organizationID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteSubscription(ctx, subscription.ID); err != nil {
+		return err
+	}
+	s.logger.Info("Webhook %s of organization %s deleted by %s", subscription.ID, organizationID, actorID)
+	s.audit.Record(ctx, webhookEvent(models.AuditEventWebhookDeleted, subscription, actorID, client, nil))
+	return nil
+}
+
+func (s *service) ListDeliveries(ctx context.Context, actorID, organizationID, subscriptionID uuid.UUID,
+	p utils.Pagination) ([]*models.WebhookDelivery, error) {
+	if err := s.authorize(ctx, actorID, organizationID, "", dto.ClientInfo{}); err != nil {
+		return nil, err
+	}
+	subscription, err := s.findSubscription(ctx, organizationID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindDeliveries(ctx, subscription.ID, p)
+}
+
+func (s *service) Redeliver(ctx context.Context, actorID, organizationID, subscriptionID, deliveryID uuid.UUID,
+	client dto.ClientInfo) (*models.WebhookDelivery, error) {
+	if err := s.authorize(ctx, actorID, organizationID, models.AuditEventWebhookRedelivered, client); err != nil {
+		return nil, err
+	}
+	subscription, err := s.findSubscription(ctx, organizationID, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	original, err := s.repo.FindDelivery(ctx, deliveryID)
+	if err != nil || original.SubscriptionID != subscription.ID {
+		return nil, ErrWebhookNotFound
+	}
+
+	now := s.now()
+	delivery := &models.WebhookDelivery{
+		ID:             uuid.New(),
+		SubscriptionID: subscription.ID,
+		EventID:        original.EventID,
+		RedeliveryOf:   &original.ID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	if _, err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	s.logger.Info("Webhook delivery %s of event %s redelivered as %s by %s", original.ID, original.EventID, delivery.ID, actorID)
+	s.audit.Record(ctx, webhookEvent(models.AuditEventWebhookRedelivered, subscription, actorID, client,
+		map[string]string{"delivery_id": original.ID.String(), "event_id": original.EventID}))
+	return delivery, nil
+}
+
+func (s *service) Enqueue(ctx context.Context, envelope *events.Envelope) (int, error) {
+	if !isWebhookEvent(envelope.Type) {
+		return 0, nil
+	}
+	userID, err := uuid.Parse(envelope.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("event %s has no user as subject", envelope.ID)
+	}
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("Error fetching user %s of event %s: %v", userID, envelope.ID, err)
+		return 0, errors.New("failed to queue webhooks")
+	}
+	if user.OrganizationID == nil {
+		return 0, nil
+	}
+	subscriptions, err := s.repo.FindSubscriptionsByOrganization(ctx, *user.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+
+	var payload string
+	queued := 0
+	now := s.now()
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(envelope.Type) {
+			continue
+		}
+		if payload == "" {
+			if payload, err = webhookPayload(envelope); err != nil {
+				return queued, err
+			}
+		}
+		created, err := s.repo.CreateDelivery(ctx, &models.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: subscription.ID,
+			EventID:        envelope.ID,
+			EventType:      envelope.Type,
+			Payload:        payload,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			return queued, err
+		}
+		if created {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// authorize lets admins manage the webhooks of any organization and organization owners those of their own.
+// Denied changes are audited under auditType, reads pass no type.
+func (s *service) authorize(ctx context.Context, actorID, organizationID uuid.UUID, auditType string, client dto.ClientInfo) error {
+	actor, err := s.userService.GetUserByID(ctx, actorID)
+	if err != nil {
+		s.logger.Error("Error fetching user %s: %v", actorID, err)
+		return errors.New("failed to check permissions")
+	}
+	switch actor.Role {
+	case models.RoleAdmin:
+		return nil
+	case models.RoleOrgOwner:
+		if actor.OrganizationID != nil && *actor.OrganizationID == organizationID {
+			return nil
+		}
+	}
+
+	s.logger.Warn("User %s may not manage the webhooks of organization %s", actor.Email, organizationID)
+	if auditType != "" {
+		s.audit.Record(ctx, dto.AuditEvent{
+			Type:    auditType,
+			Outcome: models.AuditOutcomeDenied,
+			ActorID: &actorID,
+			IP:      client.IP,
+			Details: map[string]string{"organization_id": organizationID.String()},
+		})
+	}
+	return ErrNotAllowedToManageWebhooks
+}
+
+// findSubscription hides the subscriptions of other organizations.
+func (s *service) findSubscription(ctx context.Context, organizationID, id uuid.UUID) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.FindSubscription(ctx, id)
+	if err != nil || subscription.OrganizationID != organizationID {
+		return nil, ErrWebhookNotFound
+	}
+	return subscription, nil
+}
+
+// validateURL only accepts HTTPS, deliveries carry user data. Plain HTTP is allowed together with private
+// networks, for local development.
+func (s *service) validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return errors.New("invalid webhook URL")
+	}
+	if parsed.User != nil {
+		return errors.New("webhook URL must not contain credentials")
+	}
+	if parsed.Scheme != "https" && !(parsed.Scheme == "http" && s.allowPrivateNetworks) {
+		return errors.New("webhook URL must use https")
+	}
+	return nil
+}
+
+// validateEventTypes defaults to every event type offered to webhooks.
+func validateEventTypes(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return EventTypes, nil
+	}
+	seen := make(map[string]bool)
+	var valid []string
+	for _, eventType := range eventTypes {
+		if !isWebhookEvent(eventType) {
+			return nil, fmt.Errorf("event type %q is not available for webhooks", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			valid = append(valid, eventType)
+		}
+	}
+	return valid, nil
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, offered := range EventTypes {
+		if offered == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookPayload encodes the envelope as request body, without the redacted fields of its type and
+// pointing at the redacted schema it then follows.
+func webhookPayload(envelope *events.Envelope) (string, error) {
+	if fields := redactedFields[envelope.Type]; len(fields) > 0 {
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(envelope.Data, &data); err != nil {
+			return "", err
+		}
+		for _, field := range fields {
+			delete(data, field)
+		}
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		if envelope, err = envelope.Redacted(encoded); err != nil {
+			return "", err
+		}
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func webhookEvent(eventType string, subscription *models.WebhookSubscription, actorID uuid.UUID,
+	client dto.ClientInfo, details map[string]string) dto.AuditEvent {
+	if details == nil {
+		details = make(map[string]string)
+	}
+	details["organization_id"] = subscription.OrganizationID.String()
+	details["url"] = subscription.URL
+	return dto.AuditEvent{
+		Type:       eventType,
+		Outcome:    models.AuditOutcomeSuccess,
+		ActorID:    &actorID,
+		TargetType: models.AuditTargetWebhook,
+		TargetID:   &subscription.ID,
+		IP:         client.IP,
+		Details:    details,
+	}
+}
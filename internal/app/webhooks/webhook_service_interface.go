@@ -0,0 +1,25 @@
+package webhooks
+
+import (
+	"automation-hub-idp/internal/app/dto"
+	"automation-hub-idp/internal/app/events"
+	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/utils"
+	"context"
+	"github.com/google/uuid"
+)
+
+// Service manages the webhook subscriptions of organizations. Admins manage those of every organization,
+// organization owners those of their own.
+type Service interface {
+	CreateSubscription(ctx context.Context, actorID, organizationID uuid.UUID, request dto.WebhookRequest,
+		client dto.ClientInfo) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context, actorID, organizationID uuid.UUID) ([]*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, actorID, organizationID, id uuid.UUID, client dto.ClientInfo) error
+	ListDeliveries(ctx context.Context, actorID, organizationID, subscriptionID uuid.UUID, p utils.Pagination) ([]*models.WebhookDelivery, error)
+	// Redeliver queues the event of a delivery again, whatever the outcome of the delivery was.
+	Redeliver(ctx context.Context, actorID, organizationID, subscriptionID, deliveryID uuid.UUID, client dto.ClientInfo) (*models.WebhookDelivery, error)
+	// Enqueue queues a delivery of the event to every subscription of the subject's organization that
+	// subscribed to its type, and returns how many were queued.
+	Enqueue(ctx context.Context, envelope *events.Envelope) (int, error)
+}
@@ -2,6 +2,8 @@ package infra
 
 import (
 	"automation-hub-idp/internal/app/config"
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/tracing"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -19,9 +21,11 @@ func NewPostgresDatabase(user, password, dbName, dbHost string, dbPort int) (*go
 	return db, nil
 }
 
-func GetDefaultDB() (*gorm.DB, error) {
-	db, err := NewPostgresDatabase(config.PostgresConfig.User, config.PostgresConfig.Password,
-		config.PostgresConfig.DbName, config.PostgresConfig.DbHost, config.PostgresConfig.DbPort)
+// OpenDatabase connects to the database of the configuration, migrates and seeds it, and instruments its
+// connection pool. The services share the pool, so it is opened once per process.
+func OpenDatabase(cfg *config.Config) (*gorm.DB, error) {
+	db, err := NewPostgresDatabase(cfg.Postgres.User, cfg.Postgres.Password, cfg.Postgres.DbName, cfg.Postgres.DbHost,
+		cfg.Postgres.DbPort)
 	if err != nil {
 		return nil, err
 	}
@@ -34,5 +38,13 @@ func GetDefaultDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
+	if err := metrics.InstrumentDB(db, cfg.Postgres.DbName); err != nil {
+		return nil, err
+	}
+
+	if err := tracing.InstrumentDB(db, cfg.Postgres.DbName); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
@@ -1,9 +1,11 @@
 package infra
 
 import (
-	"automation-hub-idp/internal/app/config"
+	"context"
 	"fmt"
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+	"strings"
+	"time"
 )
 
 func NewKafkaProducer(brokers []string, client string) (*kafka.Producer, error) {
@@ -25,7 +27,46 @@ func NewKafkaProducer(brokers []string, client string) (*kafka.Producer, error)
 	return producer, nil
 }
 
-func GetDefaultKafkaProducer() (*kafka.Producer, error) {
+// defaultFlushTimeout bounds the flush of a producer closed without time left, a flush of 0 would drop the
+// queued messages.
+const defaultFlushTimeout = 10 * time.Second
 
-	return NewKafkaProducer(config.KafkaConfig.BrokersAddr, config.KafkaConfig.ClientID)
+// CloseKafkaProducer waits until the producer delivered its queued messages, or ctx is done, and closes it.
+func CloseKafkaProducer(ctx context.Context, producer *kafka.Producer) error {
+	defer producer.Close()
+	if undelivered := producer.Flush(int(flushTimeout(ctx).Milliseconds())); undelivered > 0 {
+		return fmt.Errorf("%d Kafka messages were not delivered before the producer closed", undelivered)
+	}
+	return nil
+}
+
+// flushTimeout is the time left before the deadline of ctx, or defaultFlushTimeout when ctx has no deadline or
+// it passed already.
+func flushTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return defaultFlushTimeout
+	}
+	if left := time.Until(deadline); left > 0 {
+		return left
+	}
+	return defaultFlushTimeout
+}
+
+// NewKafkaConsumer joins the consumer group. Offsets are committed by the caller once a message was processed.
+func NewKafkaConsumer(brokers []string, client string, groupID string) (*kafka.Consumer, error) {
+	consumerConfig := &kafka.ConfigMap{
+		"bootstrap.servers":  strings.Join(brokers, ","),
+		"client.id":          client,
+		"group.id":           groupID,
+		"enable.auto.commit": false,
+		"auto.offset.reset":  "earliest",
+	}
+
+	consumer, err := kafka.NewConsumer(consumerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer: %v", err)
+	}
+
+	return consumer, nil
 }
@@ -0,0 +1,26 @@
+package infra
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFlushTimeout_WaitsDefaultWithoutTimeLeft(t *testing.T) {
+	// Arrange
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	bounded, cancelBounded := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelBounded()
+
+	// Act
+	withoutDeadline := flushTimeout(context.Background())
+	afterDeadline := flushTimeout(expired)
+	beforeDeadline := flushTimeout(bounded)
+
+	// Assert
+	assert.Equal(t, defaultFlushTimeout, withoutDeadline)
+	assert.Equal(t, defaultFlushTimeout, afterDeadline)
+	assert.InDelta(t, time.Minute, beforeDeadline, float64(time.Second))
+}
@@ -2,41 +2,124 @@ package infra
 
 import (
 	"automation-hub-idp/internal/app/models"
+	"automation-hub-idp/internal/app/repositories/irepository"
 	"automation-hub-idp/internal/app/utils"
+	"context"
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
+// SchemaVersion is the version of the schema RunMigrations creates. Bump it with every change to the
+// migrations, so readiness fails until the new migrations ran.
+const SchemaVersion = 4
+
 func RunMigrations(db *gorm.DB) error {
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.PasswordResetToken{}, &models.LoginChallenge{}, &models.LoginAttempt{},
+		&models.IPRule{}, &models.Invitation{}, &models.ImpersonationSession{}, &models.AuditRecord{}, &models.OutboxMessage{},
+		&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.ProcessedCommand{}, &models.SchemaMigration{}); err != nil {
+		return err
+	}
+	if err := protectAuditRecords(db); err != nil {
+		return err
+	}
+	if err := dropPlaintextResetTokens(db); err != nil {
 		return err
 	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SchemaMigration{Version: SchemaVersion, AppliedAt: time.Now()}).Error
+}
+
+// AppliedSchemaVersion returns the latest schema version migrated, 0 when none was recorded.
+func AppliedSchemaVersion(ctx context.Context, db *gorm.DB) (int, error) {
+	var version int
+	err := db.WithContext(ctx).Model(&models.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// protectAuditRecords makes the audit table append-only for the application's database user. The hash chain
+// still detects changes made by anyone who can disable the trigger.
+func protectAuditRecords(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`CREATE OR REPLACE FUNCTION reject_audit_record_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit records are append-only';
+END;
+$$ LANGUAGE plpgsql`).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DROP TRIGGER IF EXISTS audit_records_append_only ON audit_records").Error; err != nil {
+			return err
+		}
+		return tx.Exec(`CREATE TRIGGER audit_records_append_only BEFORE UPDATE OR DELETE ON audit_records
+FOR EACH ROW EXECUTE FUNCTION reject_audit_record_change()`).Error
+	})
+}
+
+// dropPlaintextResetTokens removes the legacy reset token columns, which held tokens in plaintext.
+func dropPlaintextResetTokens(db *gorm.DB) error {
+	for _, column := range []string{"reset_password_token", "reset_token_expires"} {
+		if db.Migrator().HasColumn(&models.User{}, column) {
+			if err := db.Migrator().DropColumn(&models.User{}, column); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// seedAdminEmail and seedAdminPassword are the credentials of the administrator created on first start.
+const (
+	seedAdminEmail    = "admin@admin.nl"
+	seedAdminPassword = "1234"
+)
+
+func newSeedAdmin() (*models.User, error) {
+	hashedPassword, err := utils.DefaultBcryptHasher().Hash(seedAdminPassword)
+	if err != nil {
+		return nil, err
+	}
+	return &models.User{
+		Email:       seedAdminEmail,
+		Password:    hashedPassword,
+		FirstAccess: false,
+		Role:        models.RoleAdmin,
+	}, nil
+}
+
 func SeedDatabase(db *gorm.DB) error {
-	hasher := utils.DefaultBcryptHasher()
-	defaultPassword := "1234"
-	defaultEmail := "admin@admin.nl"
 	var user models.User
-	err := db.Where("Email = ?", defaultEmail).First(&user).Error
+	err := db.Where("Email = ?", seedAdminEmail).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			hashedPassword, err := hasher.Hash(defaultPassword)
+			adminUser, err := newSeedAdmin()
 			if err != nil {
 				return err
 			}
-			adminUser := models.User{
-				Email:       defaultEmail,
-				Password:    hashedPassword,
-				FirstAccess: false,
-			}
-			if err := db.Create(&adminUser).Error; err != nil {
+			if err := db.Create(adminUser).Error; err != nil {
 				return err
 			}
 		} else {
 			return err
 		}
+	} else if user.Role != models.RoleAdmin {
+		// Accounts seeded before roles existed
+		return db.Model(&user).Update("role", models.RoleAdmin).Error
 	}
 	return nil
 }
+
+// SeedUsers creates the administrator in a store that is not backed by the database, like the memory one.
+func SeedUsers(ctx context.Context, users irepository.UserRepository) error {
+	if _, err := users.FindByEmail(ctx, seedAdminEmail); err == nil {
+		return nil
+	}
+	adminUser, err := newSeedAdmin()
+	if err != nil {
+		return err
+	}
+	_, err = users.Create(ctx, adminUser)
+	return err
+}
@@ -0,0 +1,18 @@
+package infra
+
+import (
+	"automation-hub-idp/internal/app/metrics"
+	"automation-hub-idp/internal/app/tracing"
+	"github.com/go-redis/redis/v8"
+)
+
+// NewRedisClient returns an instrumented client. It is safe for concurrent use, so one is shared by every
+// Redis-backed service.
+func NewRedisClient(addr string) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+	})
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(tracing.RedisHook{})
+	return client
+}